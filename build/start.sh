@@ -39,6 +39,7 @@ sed -i "s~{{MONITOR_ARCHIVE_MAX_HTTP_OPEN}}~$MONITOR_ARCHIVE_MAX_HTTP_OPEN~g" ar
 sed -i "s~{{MONITOR_AGENT_MANAGER_REMOTE_MODE}}~$MONITOR_AGENT_MANAGER_REMOTE_MODE~g" agent_manager/conf.json
 sed -i "s~{{ENCRYPT_SEED}}~$ENCRYPT_SEED~g" monitor/conf/default.json
 sed -i "s~{{MONITOR_MENU_API_ENABLE}}~$MONITOR_MENU_API_ENABLE~g" monitor/conf/default.json
+sed -i "s~{{MONITOR_ALARM_WEBHOOK_SECRET}}~$MONITOR_ALARM_WEBHOOK_SECRET~g" monitor/conf/default.json
 
 
 if [ $GATEWAY_URL ]
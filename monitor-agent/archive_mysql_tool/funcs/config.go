@@ -46,6 +46,27 @@ type TransConfig struct {
 	JobTimeout          int   `json:"job_timeout"`
 }
 
+// RollupConfig 汇总配置,分位数只支持 0.5/0.9/0.95/0.99,metric_quantiles 按指标覆盖默认分位数,
+// p95 是 monitor-server 查询的列,未配置时也会计算
+type RollupConfig struct {
+	Quantiles       []float64            `json:"quantiles"`
+	MetricQuantiles map[string][]float64 `json:"metric_quantiles"`
+	Retention       RetentionConfig      `json:"retention"`
+}
+
+// RetentionConfig 各层级保留天数,0表示永久保留
+type RetentionConfig struct {
+	FiveMinDay int64 `json:"five_min_day"`
+	HourDay    int64 `json:"hour_day"`
+	DayDay     int64 `json:"day_day"`
+}
+
+// StorageConfig 归档存储类型 mysql(默认) | file,file 类型数据写在 file_dir 下
+type StorageConfig struct {
+	Type    string `json:"type"`
+	FileDir string `json:"file_dir"`
+}
+
 type HttpConfig struct {
 	Enable bool `json:"enable"`
 	Port   int  `json:"port"`
@@ -57,6 +78,8 @@ type GlobalConfig struct {
 	Prometheus PrometheusConfig `json:"prometheus"`
 	Monitor    MonitorConfig    `json:"monitor"`
 	Trans      TransConfig      `json:"trans"`
+	Rollup     RollupConfig     `json:"rollup"`
+	Storage    StorageConfig    `json:"storage"`
 	Http       HttpConfig       `json:"http"`
 }
 
@@ -99,6 +122,7 @@ func InitConfig(cfg string) error {
 	}
 	c.Mysql.Password, _ = cipher.DecryptRsa(c.Mysql.Password, string(rsaPemByte))
 	c.Monitor.Mysql.Password, _ = cipher.DecryptRsa(c.Monitor.Mysql.Password, string(rsaPemByte))
+	initRollupConfig(&c.Rollup)
 	lock.Lock()
 	config = &c
 	log.Println("read config file:", cfg, "successfully")
@@ -125,3 +149,40 @@ func initLocalTimeZone() {
 		}
 	}
 }
+
+func initRollupConfig(c *RollupConfig) {
+	if len(c.Quantiles) == 0 {
+		c.Quantiles = supportQuantiles
+	}
+	c.Quantiles = ensureP95Quantile(filterSupportQuantiles(c.Quantiles))
+	for k, v := range c.MetricQuantiles {
+		c.MetricQuantiles[k] = ensureP95Quantile(filterSupportQuantiles(v))
+	}
+}
+
+func ensureP95Quantile(input []float64) []float64 {
+	for _, v := range input {
+		if v == 0.95 {
+			return input
+		}
+	}
+	return append(input, 0.95)
+}
+
+func filterSupportQuantiles(input []float64) (output []float64) {
+	for _, v := range input {
+		legalFlag := false
+		for _, vv := range supportQuantiles {
+			if v == vv {
+				legalFlag = true
+				break
+			}
+		}
+		if legalFlag {
+			output = append(output, v)
+		} else {
+			log.Printf("rollup quantile %v not support,ignore \n", v)
+		}
+	}
+	return
+}
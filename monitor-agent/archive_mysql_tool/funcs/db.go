@@ -9,13 +9,14 @@ import (
 	"math/rand"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 var (
 	mysqlEngine         *xorm.Engine
 	monitorMysqlEngine  *xorm.Engine
-	databaseSelect      string
+	archiveDatabaseMap  sync.Map
 	hostIp              string
 	maxUnitNum          int
 	concurrentInsertNum int
@@ -23,54 +24,59 @@ var (
 	jobTimeout          int
 )
 
-func InitDbEngine(databaseName string) (err error) {
-	if databaseName == "" {
-		databaseName = "mysql"
-	}
-	connectStr := fmt.Sprintf("%s:%s@%s(%s:%s)/%s?collation=utf8mb4_unicode_ci&allowNativePasswords=true",
-		Config().Mysql.User, Config().Mysql.Password, "tcp", Config().Mysql.Server, Config().Mysql.Port, databaseName)
+// InitDbEngine 归档库按年分库,只建一个不指定库的连接池,所有表都用 库名.表名 访问,不再按年切换连接
+func InitDbEngine() (err error) {
+	connectStr := fmt.Sprintf("%s:%s@%s(%s:%s)/?collation=utf8mb4_unicode_ci&allowNativePasswords=true",
+		Config().Mysql.User, Config().Mysql.Password, "tcp", Config().Mysql.Server, Config().Mysql.Port)
 	mysqlEngine, err = xorm.NewEngine("mysql", connectStr)
 	if err != nil {
 		log.Printf("init mysql fail with connect: %s error: %v \n", connectStr, err)
-	} else {
-		mysqlEngine.SetMaxIdleConns(Config().Mysql.MaxIdle)
-		mysqlEngine.SetMaxOpenConns(Config().Mysql.MaxOpen)
-		mysqlEngine.SetConnMaxLifetime(time.Duration(Config().Mysql.Timeout) * time.Second)
-		mysqlEngine.Charset("utf8")
-		// 使用驼峰式映射
-		mysqlEngine.SetMapper(core.SnakeMapper{})
-		if !strings.HasPrefix(databaseName, Config().Mysql.DatabasePrefix) {
-			err = ChangeDatabase("")
-		} else {
-			databaseSelect = databaseName
-			log.Printf("init mysql %s success \n", databaseSelect)
-			err = initJobRecordTable()
-		}
+		return err
+	}
+	mysqlEngine.SetMaxIdleConns(Config().Mysql.MaxIdle)
+	mysqlEngine.SetMaxOpenConns(Config().Mysql.MaxOpen)
+	mysqlEngine.SetConnMaxLifetime(time.Duration(Config().Mysql.Timeout) * time.Second)
+	mysqlEngine.Charset("utf8")
+	// 使用驼峰式映射
+	mysqlEngine.SetMapper(core.SnakeMapper{})
+	if _, err = ensureArchiveDatabase(""); err == nil {
+		log.Println("init mysql success ")
 	}
 	return err
 }
 
+// ResetDbEngine 任务超时后回收空闲连接,卡住的连接在超时后由连接池自行淘汰
 func ResetDbEngine() {
-	err := mysqlEngine.Close()
-	if err != nil {
-		log.Printf("close mysql engine fail,%s \n", err.Error())
-	}
+	mysqlEngine.SetMaxIdleConns(0)
 	time.Sleep(30 * time.Second)
-	databaseName := Config().Mysql.DatabasePrefix + time.Now().Format("2006")
-	connectStr := fmt.Sprintf("%s:%s@%s(%s:%s)/%s?collation=utf8mb4_unicode_ci&allowNativePasswords=true",
-		Config().Mysql.User, Config().Mysql.Password, "tcp", Config().Mysql.Server, Config().Mysql.Port, databaseName)
-	mysqlEngine, err = xorm.NewEngine("mysql", connectStr)
+	mysqlEngine.SetMaxIdleConns(Config().Mysql.MaxIdle)
+	log.Println("Reset db engine done! ")
+}
+
+// ensureArchiveDatabase 返回 year 对应的库名,首次使用时建库和 job_record 表
+func ensureArchiveDatabase(year string) (databaseName string, err error) {
+	if year == "" {
+		year = time.Now().Format("2006")
+	}
+	databaseName = Config().Mysql.DatabasePrefix + year
+	if _, b := archiveDatabaseMap.Load(databaseName); b {
+		return
+	}
+	_, err = mysqlEngine.Exec(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", databaseName))
 	if err != nil {
-		log.Printf("init mysql fail with connect: %s error: %v \n", connectStr, err)
-	} else {
-		mysqlEngine.SetMaxIdleConns(Config().Mysql.MaxIdle)
-		mysqlEngine.SetMaxOpenConns(Config().Mysql.MaxOpen)
-		mysqlEngine.SetConnMaxLifetime(time.Duration(Config().Mysql.Timeout) * time.Second)
-		mysqlEngine.Charset("utf8")
-		// 使用驼峰式映射
-		mysqlEngine.SetMapper(core.SnakeMapper{})
+		log.Printf("create database error -> %v \n", err)
+		return
 	}
-	log.Println("Reset db engine done! ")
+	if err = initJobRecordTable(databaseName); err != nil {
+		return
+	}
+	archiveDatabaseMap.Store(databaseName, true)
+	log.Printf("init mysql database %s success \n", databaseName)
+	return
+}
+
+func getFullTableName(databaseName, tableName string) string {
+	return fmt.Sprintf("`%s`.`%s`", databaseName, tableName)
 }
 
 func InitMonitorDbEngine() (err error) {
@@ -91,21 +97,22 @@ func InitMonitorDbEngine() (err error) {
 	return err
 }
 
+// insertMysql tableName 为 库名.表名
 func insertMysql(rows []*ArchiveTable, tableName string) error {
 	startTime := time.Now()
 	log.Printf("start insert mysql table:%s,row num:%d,concurrentInsertNum:%d \n", tableName, len(rows), concurrentInsertNum)
 	var sqlList []string
 	var rowCountList []int
 	tmpCount := 0
-	sqlString := fmt.Sprintf("INSERT INTO %s(endpoint,metric,tags,unix_time,`avg`,`min`,`max`,`p95`,`sum`,`create_time`) VALUES ", tableName)
+	sqlString := fmt.Sprintf("INSERT INTO %s(endpoint,metric,tags,unix_time,`avg`,`min`,`max`,`p95`,`sum`,`p50`,`p90`,`p99`,`count`,`last`,`create_time`) VALUES ", tableName)
 	for i, v := range rows {
 		tmpCount += 1
-		sqlString += fmt.Sprintf("('%s','%s','%s',%d,%.3f,%.3f,%.3f,%.3f,%.3f,'%s')", strings.ReplaceAll(v.Endpoint, "'", ""), strings.ReplaceAll(v.Metric, "'", ""), strings.ReplaceAll(v.Tags, "'", ""), v.UnixTime, v.Avg, v.Min, v.Max, v.P95, v.Sum, transUnixTime(v.UnixTime))
+		sqlString += fmt.Sprintf("('%s','%s','%s',%d,%.3f,%.3f,%.3f,%.3f,%.3f,%.3f,%.3f,%.3f,%d,%.3f,'%s')", strings.ReplaceAll(v.Endpoint, "'", ""), strings.ReplaceAll(v.Metric, "'", ""), strings.ReplaceAll(v.Tags, "'", ""), v.UnixTime, v.Avg, v.Min, v.Max, v.P95, v.Sum, v.P50, v.P90, v.P99, v.Count, v.Last, transUnixTime(v.UnixTime))
 		if (i+1)%concurrentInsertNum == 0 || i == len(rows)-1 {
 			rowCountList = append(rowCountList, tmpCount)
 			tmpCount = 0
 			sqlList = append(sqlList, sqlString)
-			sqlString = fmt.Sprintf("INSERT INTO %s(endpoint,metric,tags,unix_time,`avg`,`min`,`max`,`p95`,`sum`,`create_time`) VALUES ", tableName)
+			sqlString = fmt.Sprintf("INSERT INTO %s(endpoint,metric,tags,unix_time,`avg`,`min`,`max`,`p95`,`sum`,`p50`,`p90`,`p99`,`count`,`last`,`create_time`) VALUES ", tableName)
 		} else {
 			sqlString += ","
 		}
@@ -143,118 +150,97 @@ func insertMysql(rows []*ArchiveTable, tableName string) error {
 	}
 }
 
-func createTable(start int64, isFiveArchive bool) (err error, tableName string) {
-	tableName = fmt.Sprintf("archive_%s", time.Unix(start, 0).Format("2006_01_02"))
-	if isFiveArchive {
-		tableName = tableName + "_5min"
-	}
-	err = ChangeDatabase(time.Unix(start, 0).Format("2006"))
+func getMinuteTierTableName(start int64) string {
+	return fmt.Sprintf("archive_%s", time.Unix(start, 0).Format("2006_01_02"))
+}
+
+// createArchiveTable 在对应年份的库中建表,旧表缺少新增的统计列时自动补齐
+func createArchiveTable(tableName, year string) (err error) {
+	databaseName, err := ensureArchiveDatabase(year)
 	if err != nil {
-		return err, tableName
-	}
-	if checkTableExists(tableName) {
-		return nil, tableName
+		return err
 	}
-	tableDate := time.Unix(start, 0).Format("2006_01_02")
-	if isFiveArchive {
-		tableDate = tableDate + "_5m"
+	if checkTableExists(databaseName, tableName) {
+		return ensureArchiveColumns(databaseName, tableName)
 	}
-	createSql := fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (`id` int(11) unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY,`endpoint` VARCHAR(255) NOT NULL,`metric` VARCHAR(255) NOT NULL,`tags` VARCHAR(500) NOT NULL DEFAULT '',`unix_time` INT(11) NOT NULL,`avg` DOUBLE NOT NULL DEFAULT 0,`min` DOUBLE NOT NULL DEFAULT 0,`max` DOUBLE NOT NULL DEFAULT 0,`p95` DOUBLE NOT NULL DEFAULT 0,`sum` DOUBLE NOT NULL DEFAULT 0,`create_time` VARCHAR(64) DEFAULT NULL,INDEX idx_%s_endpoint (`endpoint`),INDEX idx_%s_metric (`metric`)) ENGINE=INNODB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8", tableName, tableDate, tableDate)
+	createSql := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (`id` int(11) unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY,`endpoint` VARCHAR(255) NOT NULL,`metric` VARCHAR(255) NOT NULL,`tags` VARCHAR(500) NOT NULL DEFAULT '',`unix_time` INT(11) NOT NULL,`avg` DOUBLE NOT NULL DEFAULT 0,`min` DOUBLE NOT NULL DEFAULT 0,`max` DOUBLE NOT NULL DEFAULT 0,`p95` DOUBLE NOT NULL DEFAULT 0,`sum` DOUBLE NOT NULL DEFAULT 0,`p50` DOUBLE NOT NULL DEFAULT 0,`p90` DOUBLE NOT NULL DEFAULT 0,`p99` DOUBLE NOT NULL DEFAULT 0,`count` INT(11) NOT NULL DEFAULT 0,`last` DOUBLE NOT NULL DEFAULT 0,`create_time` VARCHAR(64) DEFAULT NULL,INDEX idx_%s_endpoint (`endpoint`),INDEX idx_%s_metric (`metric`),INDEX idx_%s_time (`unix_time`)) ENGINE=INNODB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8", getFullTableName(databaseName, tableName), tableName, tableName, tableName)
 	_, err = mysqlEngine.Exec(createSql)
 	if err != nil {
 		log.Printf("create table %s error: %v \n", tableName, err)
 	}
-	return err, tableName
+	return err
 }
 
-func checkTableExists(tableName string) bool {
-	var tables []*PrometheusArchiveTables
-	err := mysqlEngine.SQL(fmt.Sprintf("SELECT `TABLE_NAME` FROM information_schema.`TABLES` WHERE TABLE_SCHEMA='%s'", databaseSelect)).Find(&tables)
+func ensureArchiveColumns(databaseName, tableName string) error {
+	queryRows, err := mysqlEngine.QueryString(fmt.Sprintf("SELECT COLUMN_NAME FROM information_schema.`COLUMNS` WHERE TABLE_SCHEMA='%s' AND TABLE_NAME='%s' AND COLUMN_NAME='last'", databaseName, tableName))
 	if err != nil {
-		log.Printf("show tables error: %v \n", err)
-		return false
+		return fmt.Errorf("query table:%s columns error: %v ", tableName, err)
 	}
-	for _, v := range tables {
-		if v.TableName == tableName {
-			return true
-		}
+	if len(queryRows) > 0 {
+		return nil
 	}
-	return false
+	_, err = mysqlEngine.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN `p50` DOUBLE NOT NULL DEFAULT 0,ADD COLUMN `p90` DOUBLE NOT NULL DEFAULT 0,ADD COLUMN `p99` DOUBLE NOT NULL DEFAULT 0,ADD COLUMN `count` INT(11) NOT NULL DEFAULT 0,ADD COLUMN `last` DOUBLE NOT NULL DEFAULT 0", getFullTableName(databaseName, tableName)))
+	if err != nil {
+		return fmt.Errorf("alter table:%s add rollup columns error: %v ", tableName, err)
+	}
+	log.Printf("alter table:%s add rollup columns done \n", tableName)
+	return nil
 }
 
-func ChangeDatabase(year string) error {
-	if year == "" {
-		year = time.Now().Format("2006")
-	}
-	databaseName := Config().Mysql.DatabasePrefix + year
-	if databaseName == databaseSelect {
-		return nil
-	}
-	_, err := mysqlEngine.Exec(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", databaseName))
+// clearArchiveRows 重建某层级数据前先删除时间范围内的旧数据,保证重复执行结果一致
+func clearArchiveRows(tableName string, start, end int64) error {
+	_, err := mysqlEngine.Exec(fmt.Sprintf("DELETE FROM %s WHERE unix_time>=%d AND unix_time<%d", tableName, start, end))
 	if err != nil {
-		log.Printf("create database error -> %v \n", err)
-		return err
+		err = fmt.Errorf("clear table:%s rows between %d and %d error: %v ", tableName, start, end, err)
 	}
-	err = InitDbEngine(databaseName)
 	return err
 }
 
+func queryArchiveRows(tableName, filterSql string) (result []*ArchiveTable, err error) {
+	err = mysqlEngine.SQL(fmt.Sprintf("SELECT endpoint,metric,tags,unix_time,`avg`,`min`,`max`,`p95`,`sum`,`p50`,`p90`,`p99`,`count`,`last` FROM %s WHERE %s ORDER BY endpoint,metric,tags,unix_time", tableName, filterSql)).Find(&result)
+	return
+}
+
+func checkTableExists(databaseName, tableName string) bool {
+	var tables []*PrometheusArchiveTables
+	err := mysqlEngine.SQL(fmt.Sprintf("SELECT `TABLE_NAME` FROM information_schema.`TABLES` WHERE TABLE_SCHEMA='%s' AND TABLE_NAME='%s'", databaseName, tableName)).Find(&tables)
+	if err != nil {
+		log.Printf("show tables error: %v \n", err)
+		return false
+	}
+	return len(tables) > 0
+}
+
 func getArchiveTableCountData(tableName string) (err error, result []*ArchiveCountQueryObj) {
 	err = mysqlEngine.SQL(fmt.Sprintf("SELECT endpoint,metric,COUNT(1) AS num FROM %s GROUP BY endpoint,metric", tableName)).Find(&result)
 	return err, result
 }
 
-func archiveOneToFive(oldTable, newTable, endpoint, metric string) error {
-	var oldTableData []*ArchiveTable
-	var newTableData []*ArchiveTable
-	err := mysqlEngine.SQL(fmt.Sprintf("SELECT tags,unix_time,`avg`,`min`,`max`,`p95`,`sum` FROM %s WHERE endpoint='%s' AND metric='%s' ORDER BY tags,unix_time", oldTable, endpoint, metric)).Find(&oldTableData)
+func archiveOneToFive(oldTable, newTable, endpoint, metric string, dayStart int64) error {
+	oldTableData, err := archiveStorage.QueryRows(oldTable, ArchiveRowFilter{Endpoint: endpoint, Metric: metric})
 	if err != nil {
 		return err
 	}
 	if len(oldTableData) == 0 {
 		return fmt.Errorf("table:%s endpoint:%s metric:%s empty data", oldTable, endpoint, metric)
 	}
-	var tmpRowObj ArchiveFiveRowObj
-	tmpCountIndex := 0
-	for i, v := range oldTableData {
-		if v.Tags != tmpRowObj.Tags {
-			if tmpRowObj.UnixTime > 0 {
-				newArchiveRowData := tmpRowObj.CalcArchiveTable()
-				newTableData = append(newTableData, &newArchiveRowData)
-			}
-			tmpCountIndex = 0
-		}
-		if tmpCountIndex == 0 {
-			tmpRowObj = ArchiveFiveRowObj{Endpoint: endpoint, Metric: metric, Tags: v.Tags, UnixTime: v.UnixTime, Avg: []float64{}, Min: []float64{}, Max: []float64{}, P95: []float64{}, Sum: []float64{}}
-		}
-		tmpCountIndex += 1
-		tmpRowObj.Avg = append(tmpRowObj.Avg, v.Avg)
-		tmpRowObj.Min = append(tmpRowObj.Min, v.Min)
-		tmpRowObj.Max = append(tmpRowObj.Max, v.Max)
-		tmpRowObj.P95 = append(tmpRowObj.P95, v.P95)
-		tmpRowObj.Sum = append(tmpRowObj.Sum, v.Sum)
-		if tmpCountIndex == 5 || i == len(oldTableData)-1 {
-			newArchiveRowData := tmpRowObj.CalcArchiveTable()
-			newTableData = append(newTableData, &newArchiveRowData)
-			tmpCountIndex = 0
-		}
-	}
-	err = insertMysql(newTableData, newTable)
+	err = archiveStorage.InsertRows(newTable, rollupRows(oldTableData, dayStart, 300))
 	return err
 }
 
-func renameFiveToOne(oldTable, newTable string) error {
+// renameFiveToOne oldTable,newTable 为同一个库中的表名
+func renameFiveToOne(databaseName, oldTable, newTable string) error {
 	var err error
-	_, err = mysqlEngine.Exec(fmt.Sprintf("drop table %s", oldTable))
+	_, err = mysqlEngine.Exec(fmt.Sprintf("drop table %s", getFullTableName(databaseName, oldTable)))
 	if err != nil {
 		return err
 	}
-	_, err = mysqlEngine.Exec(fmt.Sprintf("ALTER TABLE %s RENAME `%s`", newTable, oldTable))
+	_, err = mysqlEngine.Exec(fmt.Sprintf("ALTER TABLE %s RENAME %s", getFullTableName(databaseName, newTable), getFullTableName(databaseName, oldTable)))
 	return err
 }
 
-func initJobRecordTable() error {
-	_, err := mysqlEngine.Exec("CREATE TABLE IF NOT EXISTS `job_record` (`id` INT(11) UNSIGNED NOT NULL AUTO_INCREMENT,`host_ip` VARCHAR(255) NOT NULL,`update_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,  PRIMARY KEY (`id`)) ENGINE=INNODB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8")
+func initJobRecordTable(databaseName string) error {
+	_, err := mysqlEngine.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (`id` INT(11) UNSIGNED NOT NULL AUTO_INCREMENT,`host_ip` VARCHAR(255) NOT NULL,`update_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,  PRIMARY KEY (`id`)) ENGINE=INNODB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8", getFullTableName(databaseName, "job_record")))
 	if err != nil {
 		err = fmt.Errorf("init job_record table error: %v", err)
 	}
@@ -262,17 +248,27 @@ func initJobRecordTable() error {
 }
 
 func checkJobState() bool {
+	if _, b := archiveStorage.(*mysqlArchiveStorage); !b {
+		// 文件存储为单实例部署,无需通过 job_record 抢占任务
+		return true
+	}
 	ipInt, _ := strconv.Atoi(strings.Replace(hostIp, ".", "", -1))
 	rand.Seed(time.Now().UnixNano() + int64(ipInt))
 	waitSecond := rand.Intn(100)
 	log.Printf("host:%s run wait job %d second...\n", hostIp, waitSecond)
 	time.Sleep(time.Duration(waitSecond) * time.Second)
+	databaseName, err := ensureArchiveDatabase("")
+	if err != nil {
+		log.Printf("check job state error: %v \n", err)
+		return false
+	}
+	jobRecordTable := getFullTableName(databaseName, "job_record")
 	var jobTables []*JobRecordTable
-	mysqlEngine.SQL(fmt.Sprintf("SELECT * FROM job_record WHERE update_at>'%s'", time.Unix(time.Now().Unix()-120, 0).Format("2006-01-02 15:04:05"))).Find(&jobTables)
+	mysqlEngine.SQL(fmt.Sprintf("SELECT * FROM %s WHERE update_at>'%s'", jobRecordTable, time.Unix(time.Now().Unix()-120, 0).Format("2006-01-02 15:04:05"))).Find(&jobTables)
 	if len(jobTables) > 0 {
 		return false
 	}
-	_, err := mysqlEngine.Exec(fmt.Sprintf("INSERT INTO job_record(host_ip,update_at) VALUE ('%s','%s')", hostIp, time.Now().Format("2006-01-02 15:04:05")))
+	_, err = mysqlEngine.Exec(fmt.Sprintf("INSERT INTO %s(host_ip,update_at) VALUE ('%s','%s')", jobRecordTable, hostIp, time.Now().Format("2006-01-02 15:04:05")))
 	if err != nil {
 		log.Printf("update job_record table with host:%s error: %v \n", hostIp, err)
 	}
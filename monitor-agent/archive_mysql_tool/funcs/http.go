@@ -19,15 +19,42 @@ func InitHttpHandles() {
 	http.ListenAndServe(listenPort, nil)
 }
 
+// handleCustomJob tier 为空时按 date 执行1分钟归档,否则按 start~end 重建指定层级(1m|5m|1h|1d)
 func handleCustomJob(w http.ResponseWriter, r *http.Request) {
-	dateString := r.FormValue("date")
-	_, err := time.Parse("2006-01-02 15:04:05 MST", fmt.Sprintf("%s 00:00:00 "+DefaultLocalTimeZone, dateString))
+	tier := r.FormValue("tier")
+	if tier == "" || (tier == "1m" && r.FormValue("start") == "") {
+		dateString := r.FormValue("date")
+		t, err := time.Parse("2006-01-02 15:04:05 MST", fmt.Sprintf("%s 00:00:00 "+DefaultLocalTimeZone, dateString))
+		if err == nil {
+			err = checkPrometheusDataCover(t.Unix(), t.Unix()+86400)
+		}
+		if err != nil {
+			returnJson(r, w, err, nil)
+		} else {
+			CreateJob(dateString)
+			returnJson(r, w, err, "start 1min job success")
+		}
+		return
+	}
+	startDate, endDate := r.FormValue("start"), r.FormValue("end")
+	if startDate == "" {
+		startDate = r.FormValue("date")
+	}
+	if endDate == "" {
+		endDate = startDate
+	}
+	start, err := time.Parse("2006-01-02 15:04:05 MST", fmt.Sprintf("%s 00:00:00 "+DefaultLocalTimeZone, startDate))
 	if err != nil {
 		returnJson(r, w, err, nil)
-	} else {
-		CreateJob(dateString)
-		returnJson(r, w, err, "start 1min job success")
+		return
+	}
+	end, err := time.Parse("2006-01-02 15:04:05 MST", fmt.Sprintf("%s 00:00:00 "+DefaultLocalTimeZone, endDate))
+	if err != nil {
+		returnJson(r, w, err, nil)
+		return
 	}
+	err = RebuildArchiveTier(tier, start.Unix(), end.Unix())
+	returnJson(r, w, err, fmt.Sprintf("start rebuild %s job success", tier))
 }
 
 func handleFiveMinJob(w http.ResponseWriter, r *http.Request) {
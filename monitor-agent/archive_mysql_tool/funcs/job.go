@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"sort"
 	"sync"
 	"time"
 )
@@ -40,6 +39,7 @@ func StartCronJob() {
 				CreateJob("")
 				time.Sleep(10 * time.Minute)
 				ArchiveFromMysql(0)
+				cleanExpireArchiveTables()
 			}
 		}()
 		<-c
@@ -68,19 +68,29 @@ func CreateJob(dateString string) {
 		end = t.Unix() + 86400
 	}
 	log.Printf("start cron job %s \n", dateString)
-	err, tableName := createTable(start, false)
-	if err != nil {
+	// 1分钟数据只能从 Prometheus 重新拉取,超出保留期时先清理会把已归档的数据删掉
+	if err = checkPrometheusDataCover(start, end); err != nil {
+		log.Printf("refuse to create job %s,%v \n", dateString, err)
+		return
+	}
+	tableName := getMinuteTierTableName(start)
+	if err = archiveStorage.CreatePartition(tableName); err != nil {
 		log.Printf("try to create table:%s error:%v \n", tableName, err)
 		return
 	}
+	if err = archiveStorage.ClearRows(tableName, start, end); err != nil {
+		log.Printf("try to clear table:%s before job error:%v \n", tableName, err)
+		return
+	}
 	unitCount := 0
 	actionParamObjLength := maxUnitNum * Config().Prometheus.MaxHttpOpen
 	var actionParamList []*ArchiveActionList
 	var tmpActionParamObjList []*ArchiveActionParamObj
+	jobWg := &sync.WaitGroup{}
 	for _, v := range MonitorObjList {
 		for _, vv := range v.Metrics {
 			unitCount++
-			tmpActionParamObjList = append(tmpActionParamObjList, &ArchiveActionParamObj{Endpoint: v.Endpoint, Metric: vv.Metric, PromQl: vv.PromQl, TableName: tableName, Start: start, End: end})
+			tmpActionParamObjList = append(tmpActionParamObjList, &ArchiveActionParamObj{Endpoint: v.Endpoint, Metric: vv.Metric, PromQl: vv.PromQl, TableName: tableName, Start: start, End: end, jobWg: jobWg})
 			if unitCount == actionParamObjLength {
 				tmpArchiveActionList := ArchiveActionList{}
 				for _, vvv := range tmpActionParamObjList {
@@ -99,12 +109,31 @@ func CreateJob(dateString string) {
 		}
 		actionParamList = append(actionParamList, &tmpArchiveActionList)
 	}
-	go checkJobStatus()
+	jobWg.Add(len(actionParamList))
+	go checkJobStatus(tableName, jobWg)
 	for _, v := range actionParamList {
 		jobChannelList <- *v
 	}
 }
 
+// checkPrometheusDataCover 检查 Prometheus 在 start 时刻是否还有数据,允许一个查询步长的误差
+func checkPrometheusDataCover(start, end int64) error {
+	param := PrometheusQueryParam{Start: start, End: end, PromQl: "count(up)"}
+	if err := getPrometheusData(&param); err != nil {
+		return fmt.Errorf("query prometheus data cover fail,%v ", err)
+	}
+	var firstTime int64
+	for _, v := range param.Data {
+		if len(v.Values) > 0 && (firstTime == 0 || int64(v.Values[0][0]) < firstTime) {
+			firstTime = int64(v.Values[0][0])
+		}
+	}
+	if firstTime == 0 || firstTime > start+int64(queryStep)+60 {
+		return fmt.Errorf("prometheus data not cover %s,out of prometheus retention ", transUnixTime(start))
+	}
+	return nil
+}
+
 func consumeJob() {
 	for {
 		param := <-jobChannelList
@@ -138,26 +167,26 @@ func consumeJob() {
 			}(job, &wg)
 		}
 		wg.Wait()
+		if param[0].jobWg != nil {
+			param[0].jobWg.Done()
+		}
 		endTime := time.Now()
 		useTime := float64(endTime.Sub(startTime).Nanoseconds()) / 1e6
 		log.Printf("done with consume job,use time: %.3f ms", useTime)
 		if int(endTime.Sub(startTime).Seconds()) >= jobTimeout {
 			log.Println("job timeout,try to reset db connection ")
-			ResetDbEngine()
+			archiveStorage.Reset()
 		}
 		gTransport.CloseIdleConnections()
 	}
 }
 
-func checkJobStatus() {
-	time.Sleep(2 * time.Second)
-	for {
-		log.Printf("job channel list length --> %d \n", len(jobChannelList))
-		if len(jobChannelList) == 0 {
-			log.Printf("archive job done \n")
-			break
-		}
-		time.Sleep(30 * time.Second)
+// checkJobStatus 等待当天的任务全部执行完后合并分区数据
+func checkJobStatus(tableName string, jobWg *sync.WaitGroup) {
+	jobWg.Wait()
+	log.Printf("archive job %s done \n", tableName)
+	if err := archiveStorage.CompactPartition(tableName); err != nil {
+		log.Printf("archive job,compact table:%s error:%v \n", tableName, err)
 	}
 }
 
@@ -200,8 +229,9 @@ func archiveAction(param ArchiveActionList) {
 					tmpFloatList = append(tmpFloatList, vvv[1])
 				} else {
 					if len(tmpFloatList) > 0 {
-						avg, min, max, p95, sum := calcData(tmpFloatList)
-						rowData = append(rowData, &ArchiveTable{Endpoint: v.Endpoint, Metric: v.Metric, Tags: tmpTagString, UnixTime: tmpStartTime - 60, Avg: avg, Min: min, Max: max, P95: p95, Sum: sum})
+						tmpRow := calcData(tmpFloatList, getMetricQuantiles(v.Metric))
+						tmpRow.Endpoint, tmpRow.Metric, tmpRow.Tags, tmpRow.UnixTime = v.Endpoint, v.Metric, tmpTagString, tmpStartTime-60
+						rowData = append(rowData, tmpRow)
 					}
 					pointStepTime := pointTime - 60
 					for tmpStartTime < pointStepTime {
@@ -212,8 +242,9 @@ func archiveAction(param ArchiveActionList) {
 				}
 			}
 			if len(tmpFloatList) > 0 && tmpStartTime <= v.End {
-				avg, min, max, p95, sum := calcData(tmpFloatList)
-				rowData = append(rowData, &ArchiveTable{Endpoint: v.Endpoint, Metric: v.Metric, Tags: tmpTagString, UnixTime: tmpStartTime - 60, Avg: avg, Min: min, Max: max, P95: p95, Sum: sum})
+				tmpRow := calcData(tmpFloatList, getMetricQuantiles(v.Metric))
+				tmpRow.Endpoint, tmpRow.Metric, tmpRow.Tags, tmpRow.UnixTime = v.Endpoint, v.Metric, tmpTagString, tmpStartTime-60
+				rowData = append(rowData, tmpRow)
 			}
 		}
 	}
@@ -221,27 +252,12 @@ func archiveAction(param ArchiveActionList) {
 		log.Printf("acrhive action: endpoint->%s unit_num->%d row data is empty \n", param[0].Endpoint, len(param))
 		return
 	}
-	err = insertMysql(rowData, param[0].TableName)
+	err = archiveStorage.InsertRows(param[0].TableName, rowData)
 	if err != nil {
 		log.Printf("acrhive action: endpoint->%s unit_num->%d row_num->%d insert to mysql error-> %v \n", param[0].Endpoint, len(param), len(rowData), err)
 	}
 }
 
-func calcData(data []float64) (avg, min, max, p95, sum float64) {
-	if len(data) == 1 {
-		return data[0], data[0], data[0], data[0], data[0]
-	}
-	sort.Float64s(data)
-	min = data[0]
-	max = data[len(data)-1]
-	p95 = data[len(data)-2]
-	for _, v := range data {
-		sum += v
-	}
-	avg = sum / float64(len(data))
-	return avg, min, max, p95, sum
-}
-
 func ArchiveFromMysql(tableUnixTime int64) {
 	if tableUnixTime <= 0 {
 		var startDays int64 = 90
@@ -251,28 +267,43 @@ func ArchiveFromMysql(tableUnixTime int64) {
 		t, _ := time.Parse("2006-01-02 15:04:05 MST", fmt.Sprintf("%s 00:00:00 "+DefaultLocalTimeZone, time.Now().Format("2006-01-02")))
 		tableUnixTime = t.Unix() - (startDays * 86400)
 	}
-	oldTableName := fmt.Sprintf("archive_%s", time.Unix(tableUnixTime, 0).Format("2006_01_02"))
-	if !checkTableExists(oldTableName) {
+	oldTableName := getMinuteTierTableName(tableUnixTime)
+	if !archiveStorage.PartitionExists(oldTableName) {
 		return
 	}
-	err, newTableName := createTable(tableUnixTime, true)
-	if err != nil {
+	if err := archiveStorage.CreatePartition(oldTableName); err != nil {
+		log.Printf("archive 5 min job,%v \n", err)
+		return
+	}
+	// 清理上次未完成的中间表,保证重复执行结果一致
+	newTableName := oldTableName + "_5min"
+	if err := archiveStorage.DropPartition(newTableName); err != nil {
+		log.Printf("archive 5 min job,drop table:%s error:%v \n", newTableName, err)
+		return
+	}
+	if err := archiveStorage.CreatePartition(newTableName); err != nil {
 		log.Printf("archive 5 min job,create table:%s error:%v \n", newTableName, err)
 		return
 	}
-	err, countNowTable := getArchiveTableCountData(oldTableName)
+	countNowTable, err := archiveStorage.ListSeries(oldTableName)
 	if err != nil {
 		log.Printf("archive 5 min job,get count data from table:%s error:%v \n", oldTableName, err)
 		return
 	}
 	for _, v := range countNowTable {
-		tmpErr := archiveOneToFive(oldTableName, newTableName, v.Endpoint, v.Metric)
+		tmpErr := archiveOneToFive(oldTableName, newTableName, v.Endpoint, v.Metric, tableUnixTime)
 		if tmpErr != nil {
 			log.Printf("archive 5 min job,archive 1 min to 5 min job error: %v \n", tmpErr)
 		}
 	}
-	err = renameFiveToOne(oldTableName, newTableName)
+	if err = archiveStorage.CompactPartition(newTableName); err != nil {
+		log.Printf("archive 5 min job,compact table:%s error:%v \n", newTableName, err)
+		return
+	}
+	err = archiveStorage.ReplacePartition(oldTableName, newTableName)
 	if err != nil {
 		log.Printf("archive 5 min job,rename %s to %s error: %v \n", oldTableName, newTableName, err)
+		return
 	}
+	ArchiveHourTier(tableUnixTime)
 }
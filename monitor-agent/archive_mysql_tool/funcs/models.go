@@ -1,6 +1,9 @@
 package funcs
 
-import "fmt"
+import (
+	"fmt"
+	"sync"
+)
 
 type PrometheusResponse struct {
 	Status string         `json:"status"`
@@ -41,6 +44,11 @@ type ArchiveTable struct {
 	Max        float64 `json:"max"`
 	P95        float64 `json:"p_95"`
 	Sum        float64 `json:"sum"`
+	P50        float64 `json:"p_50"`
+	P90        float64 `json:"p_90"`
+	P99        float64 `json:"p_99"`
+	Count      int64   `json:"count"`
+	Last       float64 `json:"last"`
 	CreateTime string  `json:"create_time"`
 }
 
@@ -89,6 +97,7 @@ type ArchiveActionParamObj struct {
 	TableName string `json:"table_name"`
 	Start     int64  `json:"start"`
 	End       int64  `json:"end"`
+	jobWg     *sync.WaitGroup
 }
 
 type ArchiveActionList []*ArchiveActionParamObj
@@ -129,27 +138,6 @@ type HttpRespJson struct {
 	Data interface{} `json:"data"`
 }
 
-type ArchiveFiveRowObj struct {
-	Endpoint string    `json:"endpoint"`
-	Metric   string    `json:"metric"`
-	Tags     string    `json:"tags"`
-	UnixTime int64     `json:"unix_time"`
-	Avg      []float64 `json:"avg"`
-	Min      []float64 `json:"min"`
-	Max      []float64 `json:"max"`
-	P95      []float64 `json:"p_95"`
-	Sum      []float64 `json:"sum"`
-}
-
-func (a ArchiveFiveRowObj) CalcArchiveTable() ArchiveTable {
-	tmpAvg, _, _, _, _ := calcData(a.Avg)
-	_, tmpMin, _, _, _ := calcData(a.Min)
-	_, _, tmpMax, _, _ := calcData(a.Max)
-	_, _, _, tmpP95, _ := calcData(a.P95)
-	_, _, _, _, tmpSum := calcData(a.Sum)
-	return ArchiveTable{Endpoint: a.Endpoint, Metric: a.Metric, Tags: a.Tags, UnixTime: a.UnixTime, Avg: tmpAvg, Min: tmpMin, Max: tmpMax, P95: tmpP95, Sum: tmpSum}
-}
-
 type JobRecordTable struct {
 	Id     int    `json:"id"`
 	HostIp string `json:"host_ip"`
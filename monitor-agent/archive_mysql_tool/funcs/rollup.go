@@ -0,0 +1,273 @@
+package funcs
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+)
+
+// 归档表固定的分位数列
+var supportQuantiles = []float64{0.5, 0.9, 0.95, 0.99}
+
+// calcData 计算一组原始采样点的统计值,分位数按线性插值计算
+func calcData(data []float64, quantiles []float64) *ArchiveTable {
+	result := &ArchiveTable{Count: int64(len(data)), Last: data[len(data)-1]}
+	sortData := make([]float64, len(data))
+	copy(sortData, data)
+	sort.Float64s(sortData)
+	result.Min = sortData[0]
+	result.Max = sortData[len(sortData)-1]
+	for _, v := range sortData {
+		result.Sum += v
+	}
+	result.Avg = result.Sum / float64(len(sortData))
+	for _, q := range quantiles {
+		switch q {
+		case 0.5:
+			result.P50 = calcQuantile(sortData, q)
+		case 0.9:
+			result.P90 = calcQuantile(sortData, q)
+		case 0.95:
+			result.P95 = calcQuantile(sortData, q)
+		case 0.99:
+			result.P99 = calcQuantile(sortData, q)
+		}
+	}
+	return result
+}
+
+func calcQuantile(sortData []float64, q float64) float64 {
+	if len(sortData) == 1 {
+		return sortData[0]
+	}
+	pos := q * float64(len(sortData)-1)
+	lower := int(math.Floor(pos))
+	if lower >= len(sortData)-1 {
+		return sortData[len(sortData)-1]
+	}
+	return sortData[lower] + (sortData[lower+1]-sortData[lower])*(pos-float64(lower))
+}
+
+func getMetricQuantiles(metric string) []float64 {
+	if quantiles, b := Config().Rollup.MetricQuantiles[metric]; b {
+		return quantiles
+	}
+	return Config().Rollup.Quantiles
+}
+
+// rollupRows 把按 endpoint,metric,tags,unix_time 排序的数据以 start 为起点按 step 汇总
+func rollupRows(rows []*ArchiveTable, start, step int64) (result []*ArchiveTable) {
+	var bucketRows []*ArchiveTable
+	var bucketKey string
+	var bucketTime int64
+	for _, row := range rows {
+		tmpBucketTime := start + int64(math.Floor(float64(row.UnixTime-start)/float64(step)))*step
+		tmpKey := fmt.Sprintf("%s^%s^%s", row.Endpoint, row.Metric, row.Tags)
+		if tmpKey != bucketKey || tmpBucketTime != bucketTime {
+			if len(bucketRows) > 0 {
+				result = append(result, mergeArchiveRows(bucketRows, bucketTime))
+			}
+			bucketRows = []*ArchiveTable{}
+			bucketKey = tmpKey
+			bucketTime = tmpBucketTime
+		}
+		bucketRows = append(bucketRows, row)
+	}
+	if len(bucketRows) > 0 {
+		result = append(result, mergeArchiveRows(bucketRows, bucketTime))
+	}
+	return
+}
+
+// mergeArchiveRows 合并同一时间桶内的数据,分位数无法从下层分位数还原,按样本数加权近似
+func mergeArchiveRows(rows []*ArchiveTable, unixTime int64) *ArchiveTable {
+	lastRow := rows[len(rows)-1]
+	result := &ArchiveTable{Endpoint: lastRow.Endpoint, Metric: lastRow.Metric, Tags: lastRow.Tags, UnixTime: unixTime, Min: rows[0].Min, Max: rows[0].Max, Last: lastRow.Last}
+	if lastRow.Count == 0 {
+		// 旧数据没有 last 列
+		result.Last = lastRow.Avg
+	}
+	var weightSum, avgSum, p50Sum, p90Sum, p95Sum, p99Sum float64
+	for _, row := range rows {
+		weight := float64(row.Count)
+		if weight <= 0 {
+			weight = 1
+		}
+		weightSum += weight
+		avgSum += row.Avg * weight
+		p50Sum += row.P50 * weight
+		p90Sum += row.P90 * weight
+		p95Sum += row.P95 * weight
+		p99Sum += row.P99 * weight
+		result.Count += row.Count
+		result.Sum += row.Sum
+		if row.Min < result.Min {
+			result.Min = row.Min
+		}
+		if row.Max > result.Max {
+			result.Max = row.Max
+		}
+	}
+	result.Avg = avgSum / weightSum
+	result.P50 = p50Sum / weightSum
+	result.P90 = p90Sum / weightSum
+	result.P95 = p95Sum / weightSum
+	result.P99 = p99Sum / weightSum
+	return result
+}
+
+func getHourTierTableName(unixTime int64) string {
+	return fmt.Sprintf("archive_1h_%s", time.Unix(unixTime, 0).Format("2006_01"))
+}
+
+func getDayTierTableName(unixTime int64) string {
+	return fmt.Sprintf("archive_1d_%s", time.Unix(unixTime, 0).Format("2006"))
+}
+
+// ArchiveHourTier 由五分钟层级的日表汇总出当天的小时数据(月表),完成后继续汇总天数据
+func ArchiveHourTier(dayStart int64) {
+	sourceTableName := getMinuteTierTableName(dayStart)
+	if !archiveStorage.PartitionExists(sourceTableName) {
+		log.Printf("archive hour job,source table:%s not exist \n", sourceTableName)
+		return
+	}
+	if err := archiveStorage.CreatePartition(sourceTableName); err != nil {
+		log.Printf("archive hour job,%v \n", err)
+		return
+	}
+	hourTableName := getHourTierTableName(dayStart)
+	if err := archiveStorage.CreatePartition(hourTableName); err != nil {
+		log.Printf("archive hour job,create table:%s error:%v \n", hourTableName, err)
+		return
+	}
+	if err := archiveStorage.ClearRows(hourTableName, dayStart, dayStart+86400); err != nil {
+		log.Printf("archive hour job,%v \n", err)
+		return
+	}
+	countNowTable, err := archiveStorage.ListSeries(sourceTableName)
+	if err != nil {
+		log.Printf("archive hour job,get count data from table:%s error:%v \n", sourceTableName, err)
+		return
+	}
+	for _, v := range countNowTable {
+		sourceRows, queryErr := archiveStorage.QueryRows(sourceTableName, ArchiveRowFilter{Endpoint: v.Endpoint, Metric: v.Metric})
+		if queryErr != nil {
+			log.Printf("archive hour job,query table:%s endpoint:%s metric:%s error:%v \n", sourceTableName, v.Endpoint, v.Metric, queryErr)
+			continue
+		}
+		if len(sourceRows) == 0 {
+			continue
+		}
+		if insertErr := archiveStorage.InsertRows(hourTableName, rollupRows(sourceRows, dayStart, 3600)); insertErr != nil {
+			log.Printf("archive hour job,insert table:%s error:%v \n", hourTableName, insertErr)
+		}
+	}
+	if err = archiveStorage.CompactPartition(hourTableName); err != nil {
+		log.Printf("archive hour job,compact table:%s error:%v \n", hourTableName, err)
+	}
+	log.Printf("archive hour job,%s done \n", sourceTableName)
+	ArchiveDayTier(dayStart)
+}
+
+// ArchiveDayTier 由小时数据汇总出当天的天数据(年表)
+func ArchiveDayTier(dayStart int64) {
+	hourTableName := getHourTierTableName(dayStart)
+	if !archiveStorage.PartitionExists(hourTableName) {
+		log.Printf("archive day job,source table:%s not exist \n", hourTableName)
+		return
+	}
+	dayTableName := getDayTierTableName(dayStart)
+	if err := archiveStorage.CreatePartition(dayTableName); err != nil {
+		log.Printf("archive day job,create table:%s error:%v \n", dayTableName, err)
+		return
+	}
+	if err := archiveStorage.ClearRows(dayTableName, dayStart, dayStart+86400); err != nil {
+		log.Printf("archive day job,%v \n", err)
+		return
+	}
+	sourceRows, err := archiveStorage.QueryRows(hourTableName, ArchiveRowFilter{Start: dayStart, End: dayStart + 86400})
+	if err != nil {
+		log.Printf("archive day job,query table:%s error:%v \n", hourTableName, err)
+		return
+	}
+	if len(sourceRows) == 0 {
+		return
+	}
+	if err = archiveStorage.InsertRows(dayTableName, rollupRows(sourceRows, dayStart, 86400)); err != nil {
+		log.Printf("archive day job,insert table:%s error:%v \n", dayTableName, err)
+		return
+	}
+	if err = archiveStorage.CompactPartition(dayTableName); err != nil {
+		log.Printf("archive day job,compact table:%s error:%v \n", dayTableName, err)
+	}
+	log.Printf("archive day job,%s done \n", time.Unix(dayStart, 0).Format("2006-01-02"))
+}
+
+// RebuildArchiveTier 按天重建指定层级 start~end(包含)的数据,各层级重建前都会先清理旧数据,可重复执行,
+// 1m 层级的数据来自 Prometheus,只能在其保留期内重建
+func RebuildArchiveTier(tier string, start, end int64) error {
+	if tier != "1m" && tier != "5m" && tier != "1h" && tier != "1d" {
+		return fmt.Errorf("tier:%s illegal,must in 1m|5m|1h|1d ", tier)
+	}
+	if end < start {
+		return fmt.Errorf("end date can not before start date ")
+	}
+	if tier == "1m" {
+		// 重建1分钟层级会先清空当天数据,起始日已超出 Prometheus 保留期时直接拒绝
+		if err := checkPrometheusDataCover(start, start+86400); err != nil {
+			return err
+		}
+	}
+	go func() {
+		for dayStart := start; dayStart <= end; dayStart += 86400 {
+			log.Printf("start rebuild archive tier:%s date:%s \n", tier, time.Unix(dayStart, 0).Format("2006-01-02"))
+			switch tier {
+			case "1m":
+				CreateJob(time.Unix(dayStart, 0).Format("2006-01-02"))
+			case "5m":
+				ArchiveFromMysql(dayStart)
+			case "1h":
+				ArchiveHourTier(dayStart)
+			case "1d":
+				ArchiveDayTier(dayStart)
+			}
+		}
+	}()
+	return nil
+}
+
+// cleanExpireArchiveTables 按各层级保留天数删除整张过期的表
+func cleanExpireArchiveTables() {
+	retention := Config().Rollup.Retention
+	t, _ := time.Parse("2006-01-02 15:04:05 MST", fmt.Sprintf("%s 00:00:00 "+DefaultLocalTimeZone, time.Now().Format("2006-01-02")))
+	if retention.FiveMinDay > 0 {
+		boundary := t.Unix() - retention.FiveMinDay*86400
+		for i := int64(1); i <= 31; i++ {
+			tmpTime := time.Unix(boundary-i*86400, 0)
+			dropArchiveTable(getMinuteTierTableName(tmpTime.Unix()))
+		}
+	}
+	if retention.HourDay > 0 {
+		boundary := time.Unix(t.Unix()-retention.HourDay*86400, 0)
+		monthStart := time.Date(boundary.Year(), boundary.Month(), 1, 0, 0, 0, 0, boundary.Location())
+		for i := 1; i <= 12; i++ {
+			tmpTime := monthStart.AddDate(0, -i, 0)
+			dropArchiveTable(getHourTierTableName(tmpTime.Unix()))
+		}
+	}
+	if retention.DayDay > 0 {
+		boundary := time.Unix(t.Unix()-retention.DayDay*86400, 0)
+		for i := 1; i <= 5; i++ {
+			tmpTime := time.Date(boundary.Year()-i, 1, 1, 0, 0, 0, 0, boundary.Location())
+			dropArchiveTable(getDayTierTableName(tmpTime.Unix()))
+		}
+	}
+}
+
+func dropArchiveTable(tableName string) {
+	if err := archiveStorage.DropPartition(tableName); err != nil {
+		log.Printf("drop expire table error: %v \n", err)
+	}
+}
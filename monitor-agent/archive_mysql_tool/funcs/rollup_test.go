@@ -0,0 +1,103 @@
+package funcs
+
+import (
+	"math"
+	"testing"
+)
+
+func floatEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestCalcQuantile(t *testing.T) {
+	sortData := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	testCases := []struct {
+		q      float64
+		expect float64
+	}{
+		{0.5, 5.5},
+		{0.9, 9.1},
+		{0.95, 9.55},
+		{0.99, 9.91},
+		{1, 10},
+	}
+	for _, c := range testCases {
+		if result := calcQuantile(sortData, c.q); !floatEqual(result, c.expect) {
+			t.Errorf("calcQuantile q:%v expect:%v,get:%v", c.q, c.expect, result)
+		}
+	}
+	if result := calcQuantile([]float64{3}, 0.95); result != 3 {
+		t.Errorf("calcQuantile single value expect:3,get:%v", result)
+	}
+}
+
+func TestCalcData(t *testing.T) {
+	row := calcData([]float64{5, 1, 4, 2, 3}, []float64{0.5, 0.95})
+	if row.Count != 5 || row.Last != 3 || row.Min != 1 || row.Max != 5 || row.Sum != 15 || row.Avg != 3 {
+		t.Errorf("calcData basic value illegal:%+v", row)
+	}
+	if !floatEqual(row.P50, 3) || !floatEqual(row.P95, 4.8) {
+		t.Errorf("calcData quantile illegal,p50:%v p95:%v", row.P50, row.P95)
+	}
+	if row.P90 != 0 || row.P99 != 0 {
+		t.Errorf("calcData should skip unconfigured quantiles,p90:%v p99:%v", row.P90, row.P99)
+	}
+}
+
+func TestInitRollupConfig(t *testing.T) {
+	c := RollupConfig{Quantiles: []float64{0.5, 0.8}, MetricQuantiles: map[string][]float64{"cpu": {0.99}}}
+	initRollupConfig(&c)
+	if len(c.Quantiles) != 2 || c.Quantiles[0] != 0.5 || c.Quantiles[1] != 0.95 {
+		t.Errorf("default quantiles expect [0.5 0.95],get:%v", c.Quantiles)
+	}
+	if cpuQuantiles := c.MetricQuantiles["cpu"]; len(cpuQuantiles) != 2 || cpuQuantiles[1] != 0.95 {
+		t.Errorf("metric quantiles expect [0.99 0.95],get:%v", cpuQuantiles)
+	}
+	emptyConfig := RollupConfig{}
+	initRollupConfig(&emptyConfig)
+	if len(emptyConfig.Quantiles) != len(supportQuantiles) {
+		t.Errorf("empty quantiles expect %v,get:%v", supportQuantiles, emptyConfig.Quantiles)
+	}
+}
+
+func TestRollupRows(t *testing.T) {
+	var rows []*ArchiveTable
+	for i := int64(0); i < 10; i++ {
+		rows = append(rows, &ArchiveTable{Endpoint: "host", Metric: "cpu", UnixTime: i * 60, Avg: float64(i), Min: float64(i), Max: float64(i), P95: float64(i), Sum: float64(i) * 2, Count: 2, Last: float64(i)})
+	}
+	rows = append(rows, &ArchiveTable{Endpoint: "host", Metric: "mem", UnixTime: 0, Avg: 1, Min: 1, Max: 1, Sum: 1, Count: 1, Last: 1})
+	result := rollupRows(rows, 0, 300)
+	if len(result) != 3 {
+		t.Fatalf("rollupRows expect 3 rows,get:%d", len(result))
+	}
+	first := result[0]
+	if first.UnixTime != 0 || first.Count != 10 || first.Min != 0 || first.Max != 4 || first.Sum != 20 || first.Last != 4 {
+		t.Errorf("first bucket illegal:%+v", first)
+	}
+	if !floatEqual(first.Avg, 2) || !floatEqual(first.P95, 2) {
+		t.Errorf("first bucket weighted value illegal,avg:%v p95:%v", first.Avg, first.P95)
+	}
+	if result[1].UnixTime != 300 || result[1].Min != 5 || result[1].Max != 9 {
+		t.Errorf("second bucket illegal:%+v", result[1])
+	}
+	if result[2].Metric != "mem" || result[2].Count != 1 {
+		t.Errorf("series key should split bucket:%+v", result[2])
+	}
+}
+
+func TestMergeArchiveRowsWeight(t *testing.T) {
+	rows := []*ArchiveTable{
+		{Avg: 1, P50: 1, P90: 1, P95: 1, P99: 1, Min: 1, Max: 1, Count: 1, Last: 1},
+		{Avg: 4, P50: 4, P90: 4, P95: 4, P99: 4, Min: 4, Max: 4, Count: 3, Last: 4},
+	}
+	result := mergeArchiveRows(rows, 0)
+	if !floatEqual(result.P95, 3.25) || !floatEqual(result.P50, 3.25) || !floatEqual(result.Avg, 3.25) {
+		t.Errorf("merge quantiles should weight by count,get avg:%v p50:%v p95:%v", result.Avg, result.P50, result.P95)
+	}
+	// 旧数据没有 count/last 列,按权重1计算并以 avg 作为 last
+	oldRows := []*ArchiveTable{{Avg: 2, P95: 2, Min: 2, Max: 2}, {Avg: 4, P95: 6, Min: 4, Max: 4}}
+	oldResult := mergeArchiveRows(oldRows, 0)
+	if oldResult.Last != 4 || !floatEqual(oldResult.P95, 4) || oldResult.Count != 0 {
+		t.Errorf("merge old rows illegal:%+v", oldResult)
+	}
+}
@@ -0,0 +1,181 @@
+package funcs
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// ArchiveStorage 归档存储接口,分区对应 mysql 中的表或文件存储中的目录,
+// 命名规则 archive_2006_01_02(分钟/五分钟) archive_1h_2006_01(小时) archive_1d_2006(天)
+type ArchiveStorage interface {
+	CreatePartition(partition string) error
+	PartitionExists(partition string) bool
+	DropPartition(partition string) error
+	// ReplacePartition 用 source 分区的数据替换 target 分区,完成后 source 分区不再存在
+	ReplacePartition(target, source string) error
+	ClearRows(partition string, start, end int64) error
+	InsertRows(partition string, rows []*ArchiveTable) error
+	// QueryRows 按 endpoint,metric,tags,unix_time 排序返回
+	QueryRows(partition string, filter ArchiveRowFilter) ([]*ArchiveTable, error)
+	ListSeries(partition string) ([]*ArchiveCountQueryObj, error)
+	// CompactPartition 分区不再写入后整理存储,mysql 无需处理
+	CompactPartition(partition string) error
+	Reset()
+}
+
+// ArchiveRowFilter 为空的条件不过滤,时间范围为 [Start,End)
+type ArchiveRowFilter struct {
+	Endpoint string
+	Metric   string
+	Start    int64
+	End      int64
+}
+
+func (f ArchiveRowFilter) match(row *ArchiveTable) bool {
+	if f.Endpoint != "" && row.Endpoint != f.Endpoint {
+		return false
+	}
+	if f.Metric != "" && row.Metric != f.Metric {
+		return false
+	}
+	if f.Start > 0 && row.UnixTime < f.Start {
+		return false
+	}
+	if f.End > 0 && row.UnixTime >= f.End {
+		return false
+	}
+	return true
+}
+
+var (
+	archiveStorage             ArchiveStorage
+	partitionYearRegexp        = regexp.MustCompile(`^archive_(1h_|1d_)?(\d{4})`)
+	supportArchiveStorageTypes = []string{"mysql", "file"}
+)
+
+func InitArchiveStorage() (err error) {
+	storageType := strings.ToLower(Config().Storage.Type)
+	switch storageType {
+	case "", "mysql":
+		err = InitDbEngine()
+		if err != nil {
+			return fmt.Errorf("init mysql connect fail,%v ", err)
+		}
+		archiveStorage = &mysqlArchiveStorage{}
+	case "file":
+		archiveStorage, err = newFileArchiveStorage(Config().Storage.FileDir)
+	default:
+		err = fmt.Errorf("storage type:%s illegal,must in %v ", storageType, supportArchiveStorageTypes)
+	}
+	if err == nil {
+		log.Printf("init archive storage %s success \n", storageType)
+	}
+	return
+}
+
+func getPartitionYear(partition string) string {
+	if fetchList := partitionYearRegexp.FindStringSubmatch(partition); len(fetchList) == 3 {
+		return fetchList[2]
+	}
+	return ""
+}
+
+// mysqlArchiveStorage 分区按年份放在不同的库中,共用一个连接池,通过 库名.表名 访问
+type mysqlArchiveStorage struct{}
+
+// fullTableName 返回分区所在库的 库名.表名,库不存在时先创建
+func (s *mysqlArchiveStorage) fullTableName(partition string) (string, error) {
+	databaseName, err := ensureArchiveDatabase(getPartitionYear(partition))
+	if err != nil {
+		return "", err
+	}
+	return getFullTableName(databaseName, partition), nil
+}
+
+func (s *mysqlArchiveStorage) CreatePartition(partition string) error {
+	return createArchiveTable(partition, getPartitionYear(partition))
+}
+
+func (s *mysqlArchiveStorage) PartitionExists(partition string) bool {
+	databaseName, err := ensureArchiveDatabase(getPartitionYear(partition))
+	if err != nil {
+		return false
+	}
+	return checkTableExists(databaseName, partition)
+}
+
+func (s *mysqlArchiveStorage) DropPartition(partition string) error {
+	tableName, err := s.fullTableName(partition)
+	if err != nil {
+		return err
+	}
+	_, err = mysqlEngine.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", tableName))
+	if err != nil {
+		err = fmt.Errorf("drop table %s error: %v ", tableName, err)
+	}
+	return err
+}
+
+func (s *mysqlArchiveStorage) ReplacePartition(target, source string) error {
+	databaseName, err := ensureArchiveDatabase(getPartitionYear(target))
+	if err != nil {
+		return err
+	}
+	return renameFiveToOne(databaseName, target, source)
+}
+
+func (s *mysqlArchiveStorage) ClearRows(partition string, start, end int64) error {
+	tableName, err := s.fullTableName(partition)
+	if err != nil {
+		return err
+	}
+	return clearArchiveRows(tableName, start, end)
+}
+
+func (s *mysqlArchiveStorage) InsertRows(partition string, rows []*ArchiveTable) error {
+	tableName, err := s.fullTableName(partition)
+	if err != nil {
+		return err
+	}
+	return insertMysql(rows, tableName)
+}
+
+func (s *mysqlArchiveStorage) QueryRows(partition string, filter ArchiveRowFilter) ([]*ArchiveTable, error) {
+	tableName, err := s.fullTableName(partition)
+	if err != nil {
+		return nil, err
+	}
+	filterSql := "1=1"
+	if filter.Endpoint != "" {
+		filterSql += fmt.Sprintf(" AND endpoint='%s'", strings.ReplaceAll(filter.Endpoint, "'", ""))
+	}
+	if filter.Metric != "" {
+		filterSql += fmt.Sprintf(" AND metric='%s'", strings.ReplaceAll(filter.Metric, "'", ""))
+	}
+	if filter.Start > 0 {
+		filterSql += fmt.Sprintf(" AND unix_time>=%d", filter.Start)
+	}
+	if filter.End > 0 {
+		filterSql += fmt.Sprintf(" AND unix_time<%d", filter.End)
+	}
+	return queryArchiveRows(tableName, filterSql)
+}
+
+func (s *mysqlArchiveStorage) ListSeries(partition string) ([]*ArchiveCountQueryObj, error) {
+	tableName, err := s.fullTableName(partition)
+	if err != nil {
+		return nil, err
+	}
+	err, result := getArchiveTableCountData(tableName)
+	return result, err
+}
+
+func (s *mysqlArchiveStorage) CompactPartition(partition string) error {
+	return nil
+}
+
+func (s *mysqlArchiveStorage) Reset() {
+	ResetDbEngine()
+}
@@ -0,0 +1,180 @@
+package funcs
+
+import (
+	"fmt"
+	"github.com/WeBankPartners/open-monitor/monitor-server/common/archivefile"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// fileArchiveStorage 本地列式文件存储,按 分区(天/月/年)/指标/对象 拆分文件,文件格式见 archivefile,
+// 写入只追加段文件,分区写完后调用 CompactPartition 合并
+type fileArchiveStorage struct {
+	dir       string
+	lock      sync.RWMutex
+	fileLocks sync.Map
+}
+
+func newFileArchiveStorage(dir string) (*fileArchiveStorage, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("storage file_dir can not empty ")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("make storage dir:%s fail,%v ", dir, err)
+	}
+	return &fileArchiveStorage{dir: dir}, nil
+}
+
+func (s *fileArchiveStorage) partitionDir(partition string) string {
+	return filepath.Join(s.dir, partition)
+}
+
+func (s *fileArchiveStorage) seriesLock(series *archivefile.Series) *sync.Mutex {
+	tmpLock, _ := s.fileLocks.LoadOrStore(filepath.Join(series.Dir, series.Endpoint), &sync.Mutex{})
+	return tmpLock.(*sync.Mutex)
+}
+
+func (s *fileArchiveStorage) CreatePartition(partition string) error {
+	return os.MkdirAll(s.partitionDir(partition), 0755)
+}
+
+func (s *fileArchiveStorage) PartitionExists(partition string) bool {
+	info, err := os.Stat(s.partitionDir(partition))
+	return err == nil && info.IsDir()
+}
+
+func (s *fileArchiveStorage) DropPartition(partition string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return os.RemoveAll(s.partitionDir(partition))
+}
+
+func (s *fileArchiveStorage) ReplacePartition(target, source string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := os.RemoveAll(s.partitionDir(target)); err != nil {
+		return err
+	}
+	return os.Rename(s.partitionDir(source), s.partitionDir(target))
+}
+
+func (s *fileArchiveStorage) ClearRows(partition string, start, end int64) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	seriesList, err := archivefile.ListSeries(s.dir, partition, "")
+	if err != nil {
+		return err
+	}
+	for _, series := range seriesList {
+		tmpLock := s.seriesLock(series)
+		tmpLock.Lock()
+		err = series.AppendClear(start, end)
+		tmpLock.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fileArchiveStorage) InsertRows(partition string, rows []*ArchiveTable) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	seriesMap := make(map[string]*archivefile.Series)
+	seriesRowMap := make(map[string][]*archivefile.Row)
+	for _, row := range rows {
+		tmpKey := row.Metric + "^" + row.Endpoint
+		if _, b := seriesMap[tmpKey]; !b {
+			seriesMap[tmpKey] = archivefile.NewSeries(s.dir, partition, row.Metric, row.Endpoint)
+		}
+		seriesRowMap[tmpKey] = append(seriesRowMap[tmpKey], &archivefile.Row{Endpoint: row.Endpoint, Metric: row.Metric, Tags: row.Tags, UnixTime: row.UnixTime, Avg: row.Avg, Min: row.Min,
+			Max: row.Max, P95: row.P95, Sum: row.Sum, P50: row.P50, P90: row.P90, P99: row.P99, Count: row.Count, Last: row.Last})
+	}
+	for tmpKey, series := range seriesMap {
+		tmpLock := s.seriesLock(series)
+		tmpLock.Lock()
+		err := series.AppendRows(seriesRowMap[tmpKey])
+		tmpLock.Unlock()
+		if err != nil {
+			return fmt.Errorf("insert partition:%s metric:%s endpoint:%s fail,%v ", partition, series.Metric, series.Endpoint, err)
+		}
+	}
+	return nil
+}
+
+func (s *fileArchiveStorage) QueryRows(partition string, filter ArchiveRowFilter) (result []*ArchiveTable, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	var seriesList []*archivefile.Series
+	if filter.Metric != "" && filter.Endpoint != "" {
+		seriesList = []*archivefile.Series{archivefile.NewSeries(s.dir, partition, filter.Metric, filter.Endpoint)}
+	} else if seriesList, err = archivefile.ListSeries(s.dir, partition, filter.Metric); err != nil {
+		return
+	}
+	for _, series := range seriesList {
+		if filter.Endpoint != "" && series.Endpoint != filter.Endpoint {
+			continue
+		}
+		rows, readErr := series.Read()
+		if readErr != nil {
+			return result, readErr
+		}
+		for _, row := range rows {
+			tmpRow := &ArchiveTable{Endpoint: row.Endpoint, Metric: row.Metric, Tags: row.Tags, UnixTime: row.UnixTime, Avg: row.Avg, Min: row.Min, Max: row.Max,
+				P95: row.P95, Sum: row.Sum, P50: row.P50, P90: row.P90, P99: row.P99, Count: row.Count, Last: row.Last}
+			if filter.match(tmpRow) {
+				result = append(result, tmpRow)
+			}
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Endpoint != result[j].Endpoint {
+			return result[i].Endpoint < result[j].Endpoint
+		}
+		if result[i].Metric != result[j].Metric {
+			return result[i].Metric < result[j].Metric
+		}
+		if result[i].Tags != result[j].Tags {
+			return result[i].Tags < result[j].Tags
+		}
+		return result[i].UnixTime < result[j].UnixTime
+	})
+	return
+}
+
+func (s *fileArchiveStorage) ListSeries(partition string) (result []*ArchiveCountQueryObj, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	seriesList, err := archivefile.ListSeries(s.dir, partition, "")
+	if err != nil {
+		return
+	}
+	for _, series := range seriesList {
+		result = append(result, &ArchiveCountQueryObj{Endpoint: series.Endpoint, Metric: series.Metric})
+	}
+	return
+}
+
+func (s *fileArchiveStorage) CompactPartition(partition string) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	seriesList, err := archivefile.ListSeries(s.dir, partition, "")
+	if err != nil {
+		return err
+	}
+	for _, series := range seriesList {
+		tmpLock := s.seriesLock(series)
+		tmpLock.Lock()
+		err = series.Compact()
+		tmpLock.Unlock()
+		if err != nil {
+			return fmt.Errorf("compact partition:%s metric:%s endpoint:%s fail,%v ", partition, series.Metric, series.Endpoint, err)
+		}
+	}
+	return nil
+}
+
+func (s *fileArchiveStorage) Reset() {
+}
@@ -22,14 +22,9 @@ func main() {
 		log.Println("enable flag false,stop... ")
 		return
 	}
-	err = funcs.InitDbEngine("")
+	err = funcs.InitArchiveStorage()
 	if err != nil {
-		log.Printf("init mysql connect fail : %v \n", err)
-		return
-	}
-	err = funcs.ChangeDatabase("")
-	if err != nil {
-		log.Printf("change mysql database connect fail : %v \n", err)
+		log.Printf("init archive storage fail : %v \n", err)
 		return
 	}
 	err = funcs.InitMonitorDbEngine()
@@ -0,0 +1,243 @@
+package funcs
+
+import (
+	"context"
+	"fmt"
+	_ "github.com/denisenkom/go-mssqldb"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-xorm/core"
+	"github.com/go-xorm/xorm"
+	_ "github.com/lib/pq"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 支持的数据库类型,和服务端 db_metric_monitor.db_type 一致
+const (
+	DbTypeMysql     = "mysql"
+	DbTypePostgres  = "postgresql"
+	DbTypeSqlServer = "sqlserver"
+)
+
+// dbDriver 每种数据库的 xorm 驱动名和连接串拼法
+type dbDriver interface {
+	driverName() string
+	buildDsn(config *DbMonitorTaskObj) string
+}
+
+var (
+	dbDriverMap = map[string]dbDriver{
+		DbTypeMysql:     mysqlDriver{},
+		DbTypePostgres:  postgresDriver{},
+		DbTypeSqlServer: sqlServerDriver{},
+	}
+	engineMap  = make(map[string]*xorm.Engine)
+	engineLock = new(sync.Mutex)
+)
+
+type mysqlDriver struct{}
+
+func (mysqlDriver) driverName() string {
+	return "mysql"
+}
+
+func (mysqlDriver) buildDsn(config *DbMonitorTaskObj) string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?collation=utf8mb4_unicode_ci&allowNativePasswords=true&timeout=%ds",
+		config.User, config.Password, config.Server, config.Port, config.DbName, timeOut)
+}
+
+type postgresDriver struct{}
+
+func (postgresDriver) driverName() string {
+	return "postgres"
+}
+
+// buildDsn postgresql 的连接必须指定库,没配置时连默认的 postgres 库
+func (postgresDriver) buildDsn(config *DbMonitorTaskObj) string {
+	dbName := config.DbName
+	if dbName == "" {
+		dbName = "postgres"
+	}
+	query := url.Values{}
+	query.Set("sslmode", "disable")
+	query.Set("connect_timeout", strconv.Itoa(timeOut))
+	dsn := url.URL{Scheme: "postgres", User: url.UserPassword(config.User, config.Password), Host: net.JoinHostPort(config.Server, config.Port), Path: "/" + dbName, RawQuery: query.Encode()}
+	return dsn.String()
+}
+
+type sqlServerDriver struct{}
+
+func (sqlServerDriver) driverName() string {
+	return "mssql"
+}
+
+// buildDsn xorm 要求连接串里有库名,没配置时用 master,sql 里可以用 库.schema.表 跨库查询
+func (sqlServerDriver) buildDsn(config *DbMonitorTaskObj) string {
+	dbName := config.DbName
+	if dbName == "" {
+		dbName = "master"
+	}
+	query := url.Values{}
+	query.Set("database", dbName)
+	query.Set("dial timeout", strconv.Itoa(timeOut))
+	dsn := url.URL{Scheme: "sqlserver", User: url.UserPassword(config.User, config.Password), Host: net.JoinHostPort(config.Server, config.Port), RawQuery: query.Encode()}
+	return dsn.String()
+}
+
+func getDbDriver(dbType string) (dbDriver, error) {
+	if dbType == "" {
+		dbType = DbTypeMysql
+	}
+	driver, ok := dbDriverMap[dbType]
+	if !ok {
+		return nil, fmt.Errorf("db type %s not support ", dbType)
+	}
+	return driver, nil
+}
+
+func newDbEngine(driver dbDriver, dsn string) (*xorm.Engine, error) {
+	engine, err := xorm.NewEngine(driver.driverName(), dsn)
+	if err != nil {
+		return nil, err
+	}
+	engine.SetMaxIdleConns(maxIdle)
+	engine.SetMaxOpenConns(maxOpen)
+	engine.SetConnMaxLifetime(time.Duration(connMaxLifetime) * time.Second)
+	// 使用驼峰式映射
+	engine.SetMapper(core.SnakeMapper{})
+	return engine, nil
+}
+
+// getDbEngine 连同一个实例同一个用户的任务共用一个连接池
+func getDbEngine(config *DbMonitorTaskObj) (*xorm.Engine, error) {
+	driver, err := getDbDriver(config.DbType)
+	if err != nil {
+		return nil, err
+	}
+	dsn := driver.buildDsn(config)
+	engineLock.Lock()
+	defer engineLock.Unlock()
+	if engine, b := engineMap[dsn]; b {
+		return engine, nil
+	}
+	engine, err := newDbEngine(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%s connect to %s:%s fail,%s ", config.DbType, config.Server, config.Port, err.Error())
+	}
+	engineMap[dsn] = engine
+	return engine, nil
+}
+
+// cleanDbEngine 配置更新后关掉不再使用的连接池,调用方需要持有 taskLock 的写锁
+func cleanDbEngine(tasks []*DbMonitorTaskObj) {
+	usedDsnMap := make(map[string]bool)
+	for _, v := range tasks {
+		if driver, err := getDbDriver(v.DbType); err == nil {
+			usedDsnMap[driver.buildDsn(v)] = true
+		}
+	}
+	engineLock.Lock()
+	for k, v := range engineMap {
+		if !usedDsnMap[k] {
+			v.Close()
+			delete(engineMap, k)
+		}
+	}
+	engineLock.Unlock()
+}
+
+// dbQueryResult 按 sql 里的列顺序保存查询结果,空值为空字符串,Truncated 表示超过行数限制后的行没有读取
+type dbQueryResult struct {
+	Columns   []string
+	Rows      [][]string
+	Truncated bool
+}
+
+// columnIndex 列名不区分大小写,postgresql 会把没加引号的列名转成小写
+func (r *dbQueryResult) columnIndex(name string) int {
+	for i, v := range r.Columns {
+		if strings.EqualFold(v, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *dbQueryResult) rowMap(index int) map[string]string {
+	result := make(map[string]string)
+	for i, v := range r.Columns {
+		result[v] = r.Rows[index][i]
+	}
+	return result
+}
+
+// queryDb 查询带超时,避免一条慢 sql 拖住整轮采集;rowLimit 大于0时最多读取这么多行
+func queryDb(engine *xorm.Engine, sql string, rowLimit int) (*dbQueryResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(queryTimeout)*time.Second)
+	defer cancel()
+	result, err := scanRows(ctx, engine, sql, rowLimit)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		log.Printf("query timeout after %ds with sql:%s \n", queryTimeout, sql)
+		err = fmt.Errorf("query timeout after %ds ", queryTimeout)
+	}
+	return result, err
+}
+
+func scanRows(ctx context.Context, engine *xorm.Engine, sql string, rowLimit int) (*dbQueryResult, error) {
+	rows, err := engine.DB().QueryContext(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := dbQueryResult{}
+	if result.Columns, err = rows.Columns(); err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		if rowLimit > 0 && len(result.Rows) >= rowLimit {
+			result.Truncated = true
+			break
+		}
+		values := make([]interface{}, len(result.Columns))
+		valuePointers := make([]interface{}, len(values))
+		for i := range values {
+			valuePointers[i] = &values[i]
+		}
+		if err = rows.Scan(valuePointers...); err != nil {
+			return nil, err
+		}
+		row := make([]string, len(values))
+		for i, v := range values {
+			row[i] = formatDbValue(v)
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func formatDbValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05")
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
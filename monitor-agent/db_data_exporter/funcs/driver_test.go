@@ -0,0 +1,48 @@
+package funcs
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestBuildDsn(t *testing.T) {
+	testCases := []struct {
+		dbType   string
+		dbName   string
+		scheme   string
+		host     string
+		path     string
+		database string
+	}{
+		{DbTypePostgres, "", "postgres", "10.0.0.1:5432", "/postgres", ""},
+		{DbTypePostgres, "app", "postgres", "10.0.0.1:5432", "/app", ""},
+		{DbTypeSqlServer, "", "sqlserver", "10.0.0.1:5432", "", "master"},
+		{DbTypeSqlServer, "report", "sqlserver", "10.0.0.1:5432", "", "report"},
+	}
+	// 密码里的特殊字符需要转义,解析后要能还原
+	password := "p@ss:w/rd?#%&="
+	for _, v := range testCases {
+		driver, err := getDbDriver(v.dbType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dsn := driver.buildDsn(&DbMonitorTaskObj{DbType: v.dbType, DbName: v.dbName, Server: "10.0.0.1", Port: "5432", User: "monitor", Password: password})
+		dsnUrl, parseErr := url.Parse(dsn)
+		if parseErr != nil {
+			t.Fatalf("%s dsn:%s parse fail,%v", v.dbType, dsn, parseErr)
+		}
+		if tmpPassword, _ := dsnUrl.User.Password(); tmpPassword != password || dsnUrl.User.Username() != "monitor" {
+			t.Errorf("%s dsn user illegal:%s", v.dbType, dsnUrl.User.String())
+		}
+		if dsnUrl.Scheme != v.scheme || dsnUrl.Host != v.host || dsnUrl.Path != v.path || dsnUrl.Query().Get("database") != v.database {
+			t.Errorf("%s dsn illegal:%s", v.dbType, dsn)
+		}
+	}
+	driver, _ := getDbDriver(DbTypePostgres)
+	if dsnUrl, _ := url.Parse(driver.buildDsn(&DbMonitorTaskObj{Server: "fe80::1", Port: "5432"})); dsnUrl == nil || dsnUrl.Hostname() != "fe80::1" || dsnUrl.Port() != "5432" {
+		t.Errorf("ipv6 host dsn illegal")
+	}
+	if _, err := getDbDriver("oracle"); err == nil {
+		t.Errorf("unsupported db type should fail")
+	}
+}
@@ -3,8 +3,11 @@ package funcs
 import (
 	"bytes"
 	"fmt"
+	"strings"
 )
 
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
 func GetExportMetric(step int64) []byte {
 	var buff bytes.Buffer
 	buff.WriteString("# HELP ping check 0 -> alive, 1 -> dead, 2 -> problem. \n")
@@ -20,13 +23,22 @@ func GetExportMetric(step int64) []byte {
 		} else {
 			tmpMetricDisplay := metricString
 			valueString := transFloatValueToString(v.Value)
-			buff.WriteString(fmt.Sprintf("%s{key=\"%s\",t_endpoint=\"%s\",address=\"%s:%s\",service_group=\"%s\"} %s \n", tmpMetricDisplay, v.Name, v.Endpoint, v.Server, v.Port, v.ServiceGroup, valueString))
+			buff.WriteString(fmt.Sprintf("%s{key=\"%s\",t_endpoint=\"%s\",address=\"%s:%s\",service_group=\"%s\"%s} %s \n", tmpMetricDisplay, v.Name, v.Endpoint, v.Server, v.Port, v.ServiceGroup, buildLabelString(v), valueString))
 		}
 	}
 	resultLock.RUnlock()
 	return buff.Bytes()
 }
 
+// buildLabelString sql 结果里的标签列,值来自业务数据,需要转义
+func buildLabelString(result *DbMonitorResultObj) string {
+	var buff bytes.Buffer
+	for i, name := range result.LabelNames {
+		buff.WriteString(fmt.Sprintf(",%s=\"%s\"", name, labelValueReplacer.Replace(result.LabelValues[i])))
+	}
+	return buff.String()
+}
+
 func transFloatValueToString(input float64) string {
 	outputString := fmt.Sprintf("%.6f", input)
 	for i := 0; i < 6; i++ {
@@ -91,6 +91,8 @@ func handleAcceptConfig(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	taskList = param
+	// doTask 在读锁内使用连接池,持有写锁时关闭不会影响正在执行的查询
+	cleanDbEngine(param)
 	taskLock.Unlock()
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("success"))
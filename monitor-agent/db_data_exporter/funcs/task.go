@@ -3,43 +3,46 @@ package funcs
 import (
 	"encoding/json"
 	"fmt"
-	_ "github.com/go-sql-driver/mysql"
-	"github.com/go-xorm/core"
-	"github.com/go-xorm/xorm"
 	"log"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
 type DbMonitorTaskObj struct {
-	DbType         string       `json:"db_type"`
-	Endpoint       string       `json:"endpoint"`
-	Name           string       `json:"name"`
-	Server         string       `json:"server"`
-	Port           string       `json:"port"`
-	User           string       `json:"user"`
-	Password       string       `json:"password"`
-	Sql            string       `json:"sql"`
-	Step           int64        `json:"step"`
-	LastTime       int64        `json:"last_time"`
-	ServiceGroup   string       `json:"service_group"`
-	Session        *xorm.Engine `json:"session"`
-	KeywordGuid    string       `json:"keyword_guid"`
-	KeywordCount   int64        `json:"keyword_count"`
-	KeywordContent string       `json:"keyword_content"`
+	DbType         string   `json:"db_type"`
+	Endpoint       string   `json:"endpoint"`
+	Name           string   `json:"name"`
+	Server         string   `json:"server"`
+	Port           string   `json:"port"`
+	User           string   `json:"user"`
+	Password       string   `json:"password"`
+	DbName         string   `json:"db_name"`
+	Sql            string   `json:"sql"`
+	Step           int64    `json:"step"`
+	LastTime       int64    `json:"last_time"`
+	ServiceGroup   string   `json:"service_group"`
+	KeywordGuid    string   `json:"keyword_guid"`
+	KeywordCount   int64    `json:"keyword_count"`
+	KeywordContent string   `json:"keyword_content"`
+	LabelColumns   []string `json:"label_columns"`
+	ValueColumns   []string `json:"value_columns"`
+	MaxSeries      int      `json:"max_series"`
 }
 
 type DbMonitorResultObj struct {
-	Name         string  `json:"name"`
-	Endpoint     string  `json:"endpoint"`
-	Server       string  `json:"server"`
-	Port         string  `json:"port"`
-	Value        float64 `json:"value"`
-	ServiceGroup string  `json:"service_group"`
-	KeywordGuid  string  `json:"keyword_guid"`
-	KeywordCount int64   `json:"keyword_count"`
-	Step         int64   `json:"step"`
+	Name         string   `json:"name"`
+	Endpoint     string   `json:"endpoint"`
+	Server       string   `json:"server"`
+	Port         string   `json:"port"`
+	Value        float64  `json:"value"`
+	ServiceGroup string   `json:"service_group"`
+	KeywordGuid  string   `json:"keyword_guid"`
+	KeywordCount int64    `json:"keyword_count"`
+	Step         int64    `json:"step"`
+	LabelNames   []string `json:"label_names"`
+	LabelValues  []string `json:"label_values"`
 }
 
 type DbLastKeywordDto struct {
@@ -57,6 +60,9 @@ var (
 	maxIdle         = 2
 	maxOpen         = 5
 	timeOut         = 10
+	queryTimeout    = 10
+	connMaxLifetime = 300
+	maxSeries       = 500
 	metricString    = "db_monitor_value"
 	dbKeywordMetric = "db_keyword_value"
 )
@@ -86,11 +92,7 @@ func doTask() {
 		} else if taskObj.Step > 10 && taskObj.LastTime == 0 {
 			log.Printf("step:%d task:%s start doTask \n", taskObj.Step, taskObj.Name)
 		}
-		var resultValue float64
-		if taskObj.DbType == "mysql" {
-			resultValue = mysqlTask(taskObj)
-		}
-		newResultList = append(newResultList, &DbMonitorResultObj{Name: taskObj.Name, Endpoint: taskObj.Endpoint, Server: taskObj.Server, Port: taskObj.Port, Value: resultValue, ServiceGroup: taskObj.ServiceGroup, KeywordGuid: taskObj.KeywordGuid, KeywordCount: taskObj.KeywordCount, Step: taskObj.Step})
+		newResultList = append(newResultList, dbTask(taskObj)...)
 		taskObj.LastTime = nowTime
 	}
 	taskLock.RUnlock()
@@ -116,76 +118,155 @@ func checkStepActive(lastTime, nowTime, step int64) bool {
 	return false
 }
 
-func mysqlTask(config *DbMonitorTaskObj) float64 {
-	if config.Session == nil {
-		connectStr := fmt.Sprintf("%s:%s@%s(%s:%s)/?collation=utf8mb4_unicode_ci&allowNativePasswords=true",
-			config.User, config.Password, "tcp", config.Server, config.Port)
-		tmpSession, err := xorm.NewEngine("mysql", connectStr)
-		if err != nil {
-			log.Printf("mysql connect fail with connectStr %s,error: %s\n", connectStr, err.Error())
-			return -1
-		} else {
-			tmpSession.SetMaxIdleConns(maxIdle)
-			tmpSession.SetMaxOpenConns(maxOpen)
-			tmpSession.SetConnMaxLifetime(time.Duration(timeOut) * time.Second)
-			tmpSession.Charset("utf8")
-			// 使用驼峰式映射
-			tmpSession.SetMapper(core.SnakeMapper{})
-			config.Session = tmpSession
-		}
+func newDbResult(config *DbMonitorTaskObj, value float64) *DbMonitorResultObj {
+	return &DbMonitorResultObj{Name: config.Name, Endpoint: config.Endpoint, Server: config.Server, Port: config.Port, Value: value, ServiceGroup: config.ServiceGroup, KeywordGuid: config.KeywordGuid, KeywordCount: config.KeywordCount, Step: config.Step}
+}
+
+func dbTask(config *DbMonitorTaskObj) []*DbMonitorResultObj {
+	engine, err := getDbEngine(config)
+	if err != nil {
+		log.Printf("get db engine fail,error: %s\n", err.Error())
+		return []*DbMonitorResultObj{newDbResult(config, -1)}
+	}
+	// 关键字和单值只用第一行,多序列最多读取最大序列数的行
+	rowLimit := 1
+	if len(config.ValueColumns) > 0 {
+		rowLimit = getSeriesLimit(config)
 	}
-	queryStringMap, err := config.Session.QueryString(config.Sql)
+	queryResult, err := queryDb(engine, config.Sql, rowLimit)
 	if err != nil {
-		log.Printf("mysql query data fail with sql:%s,error: %s\n", config.Sql, err.Error())
-		return -2
+		log.Printf("%s query data fail with sql:%s,error: %s\n", config.DbType, config.Sql, err.Error())
+		return []*DbMonitorResultObj{newDbResult(config, -2)}
 	}
-	var resultValue float64
 	if config.KeywordGuid != "" {
-		if len(queryStringMap) > 0 {
+		if len(queryResult.Rows) > 0 {
 			config.KeywordCount = config.KeywordCount + 1
-			rowOneBytes, _ := json.Marshal(queryStringMap[0])
+			rowOneBytes, _ := json.Marshal(queryResult.rowMap(0))
 			config.KeywordContent = string(rowOneBytes)
 		}
+		return []*DbMonitorResultObj{newDbResult(config, 0)}
+	}
+	// 没有指定取值列时取第一行第一列
+	if len(config.ValueColumns) == 0 {
+		var resultValue float64
+		if len(queryResult.Rows) > 0 && len(queryResult.Columns) > 0 {
+			resultValue, _ = strconv.ParseFloat(queryResult.Rows[0][0], 64)
+		}
+		return []*DbMonitorResultObj{newDbResult(config, resultValue)}
+	}
+	resultList, err := buildSeriesResult(config, queryResult)
+	if err != nil {
+		log.Printf("task:%s build series fail,error: %s\n", config.Name, err.Error())
+		return []*DbMonitorResultObj{newDbResult(config, -3)}
+	}
+	return resultList
+}
+
+func getSeriesLimit(config *DbMonitorTaskObj) int {
+	if config.MaxSeries > 0 {
+		return config.MaxSeries
+	}
+	return maxSeries
+}
+
+// buildSeriesResult 每一行的标签列作为标签,每个取值列输出一条带 column 标签的数据,超过最大序列数的部分丢弃
+func buildSeriesResult(config *DbMonitorTaskObj, queryResult *dbQueryResult) (resultList []*DbMonitorResultObj, err error) {
+	labelIndexList, err := getColumnIndexList(queryResult, config.LabelColumns)
+	if err != nil {
+		return
+	}
+	valueIndexList, err := getColumnIndexList(queryResult, config.ValueColumns)
+	if err != nil {
+		return
+	}
+	seriesLimit := getSeriesLimit(config)
+	labelNames := append(append([]string{}, config.LabelColumns...), "column")
+	existSeriesMap := make(map[string]bool)
+	dropNum := 0
+	for _, row := range queryResult.Rows {
+		var labelValues []string
+		for _, index := range labelIndexList {
+			labelValues = append(labelValues, row[index])
+		}
+		for i, index := range valueIndexList {
+			value, parseErr := strconv.ParseFloat(row[index], 64)
+			if parseErr != nil {
+				continue
+			}
+			seriesLabelValues := append(append([]string{}, labelValues...), config.ValueColumns[i])
+			seriesKey := strings.Join(seriesLabelValues, "\x00")
+			if existSeriesMap[seriesKey] {
+				continue
+			}
+			if len(resultList) >= seriesLimit {
+				dropNum++
+				continue
+			}
+			existSeriesMap[seriesKey] = true
+			resultObj := newDbResult(config, value)
+			resultObj.LabelNames = labelNames
+			resultObj.LabelValues = seriesLabelValues
+			resultList = append(resultList, resultObj)
+		}
 	}
-	if len(queryStringMap) > 0 {
-		for _, v := range queryStringMap[0] {
-			resultValue, _ = strconv.ParseFloat(v, 64)
+	if dropNum > 0 || queryResult.Truncated {
+		log.Printf("task:%s series num over limit %d,drop %d series,rows truncated:%t \n", config.Name, seriesLimit, dropNum, queryResult.Truncated)
+	}
+	return
+}
+
+func getColumnIndexList(queryResult *dbQueryResult, columns []string) (indexList []int, err error) {
+	for _, v := range columns {
+		index := queryResult.columnIndex(v)
+		if index < 0 {
+			err = fmt.Errorf("column %s not found in query result ", v)
+			return
 		}
+		indexList = append(indexList, index)
 	}
-	return resultValue
+	return
 }
 
 func checkIllegal(param DbMonitorTaskObj) error {
-	connectStr := fmt.Sprintf("%s:%s@%s(%s:%s)/?collation=utf8mb4_unicode_ci&allowNativePasswords=true",
-		param.User, param.Password, "tcp", param.Server, param.Port)
-	tmpSession, err := xorm.NewEngine("mysql", connectStr)
+	driver, err := getDbDriver(param.DbType)
+	if err != nil {
+		return err
+	}
+	tmpSession, err := newDbEngine(driver, driver.buildDsn(&param))
 	if err != nil {
-		log.Printf("check illegal, mysql connect fail with connectStr %s,error: %s\n", connectStr, err.Error())
-		return fmt.Errorf("Mysql connect fail,%s ", err.Error())
+		log.Printf("check illegal, %s connect to %s:%s fail,error: %s\n", param.DbType, param.Server, param.Port, err.Error())
+		return fmt.Errorf("%s connect fail,%s ", param.DbType, err.Error())
 	} else {
-		tmpSession.SetMaxIdleConns(maxIdle)
-		tmpSession.SetMaxOpenConns(maxOpen)
-		tmpSession.SetConnMaxLifetime(time.Duration(timeOut) * time.Second)
-		tmpSession.Charset("utf8")
-		// 使用驼峰式映射
-		tmpSession.SetMapper(core.SnakeMapper{})
-
-		queryStringMap, err := tmpSession.QueryString(param.Sql)
+		defer tmpSession.Close()
+		// 单值时多读一行用来判断行数是否为1
+		rowLimit := 2
+		if len(param.ValueColumns) > 0 {
+			rowLimit = getSeriesLimit(&param)
+		}
+		queryResult, err := queryDb(tmpSession, param.Sql, rowLimit)
 		if err != nil {
-			log.Printf("check illegal, mysql query data fail with sql:%s,error: %s\n", param.Sql, err.Error())
-			return fmt.Errorf("Mysql query data fail,%s ", err.Error())
+			log.Printf("check illegal, %s query data fail with sql:%s,error: %s\n", param.DbType, param.Sql, err.Error())
+			return fmt.Errorf("%s query data fail,%s ", param.DbType, err.Error())
 		}
-		if len(queryStringMap) != 1 {
-			return fmt.Errorf("Query result row num %d ", len(queryStringMap))
+		if len(param.ValueColumns) > 0 {
+			resultList, buildErr := buildSeriesResult(&param, queryResult)
+			if buildErr != nil {
+				return buildErr
+			}
+			if len(resultList) == 0 {
+				return fmt.Errorf("Query result has no numeric value in columns %s ", strings.Join(param.ValueColumns, ","))
+			}
+			return nil
 		}
-		if len(queryStringMap[0]) != 1 {
-			return fmt.Errorf("Query result return column num %d ", len(queryStringMap[0]))
+		if len(queryResult.Rows) != 1 {
+			return fmt.Errorf("Query result row num %d ", len(queryResult.Rows))
 		}
-		for _, v := range queryStringMap[0] {
-			_, err = strconv.ParseFloat(v, 64)
-			if err != nil {
-				err = fmt.Errorf("Query result:%s format float type fail,%s ", v, err.Error())
-			}
+		if len(queryResult.Columns) != 1 {
+			return fmt.Errorf("Query result return column num %d ", len(queryResult.Columns))
+		}
+		_, err = strconv.ParseFloat(queryResult.Rows[0][0], 64)
+		if err != nil {
+			err = fmt.Errorf("Query result:%s format float type fail,%s ", queryResult.Rows[0][0], err.Error())
 		}
 		return err
 	}
@@ -0,0 +1,70 @@
+package funcs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildSeriesResult(t *testing.T) {
+	// postgresql 返回的列名是小写,配置里的列名大小写不一致也能找到
+	queryResult := &dbQueryResult{
+		Columns: []string{"schema_name", "table_name", "rows", "size"},
+		Rows: [][]string{
+			{"public", "user", "100", "2048"},
+			{"public", "order", "", "4096"},
+			{"public", "user", "200", "1024"},
+			{"report", "log", "abc", "1.5"},
+		},
+	}
+	config := &DbMonitorTaskObj{Name: "table_size", LabelColumns: []string{"Schema_Name", "TABLE_NAME"}, ValueColumns: []string{"rows", "Size"}}
+	resultList, err := buildSeriesResult(config, queryResult)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 空值和非数字跳过,重复的序列只取第一行
+	expectList := []struct {
+		labelValues string
+		value       float64
+	}{
+		{"public,user,rows", 100},
+		{"public,user,Size", 2048},
+		{"public,order,Size", 4096},
+		{"report,log,Size", 1.5},
+	}
+	if len(resultList) != len(expectList) {
+		t.Fatalf("series num expect %d,get:%d", len(expectList), len(resultList))
+	}
+	for i, v := range resultList {
+		if strings.Join(v.LabelValues, ",") != expectList[i].labelValues || v.Value != expectList[i].value {
+			t.Errorf("series %d expect %+v,get:%v=%v", i, expectList[i], v.LabelValues, v.Value)
+		}
+		if strings.Join(v.LabelNames, ",") != "Schema_Name,TABLE_NAME,column" {
+			t.Errorf("label names illegal:%v", v.LabelNames)
+		}
+	}
+}
+
+func TestBuildSeriesResultLimit(t *testing.T) {
+	queryResult := &dbQueryResult{Columns: []string{"name", "value"}, Rows: [][]string{{"a", "1"}, {"b", "2"}, {"a", "3"}, {"c", "4"}}}
+	config := &DbMonitorTaskObj{Name: "limit", LabelColumns: []string{"name"}, ValueColumns: []string{"value"}, MaxSeries: 2}
+	resultList, err := buildSeriesResult(config, queryResult)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resultList) != 2 || resultList[0].Value != 1 || resultList[1].Value != 2 {
+		t.Errorf("series over limit should be dropped:%v", resultList)
+	}
+	if getSeriesLimit(config) != 2 || getSeriesLimit(&DbMonitorTaskObj{}) != maxSeries {
+		t.Errorf("series limit illegal")
+	}
+}
+
+func TestBuildSeriesResultColumnNotFound(t *testing.T) {
+	queryResult := &dbQueryResult{Columns: []string{"name", "value"}, Rows: [][]string{{"a", "1"}}}
+	if _, err := buildSeriesResult(&DbMonitorTaskObj{LabelColumns: []string{"host"}, ValueColumns: []string{"value"}}, queryResult); err == nil {
+		t.Errorf("label column not found should fail")
+	}
+	if _, err := buildSeriesResult(&DbMonitorTaskObj{ValueColumns: []string{"count"}}, queryResult); err == nil {
+		t.Errorf("value column not found should fail")
+	}
+}
@@ -5,10 +5,10 @@ import (
 	"fmt"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
-	"github.com/hpcloud/tail"
 	"github.com/prometheus/client_golang/prometheus"
 	"io/ioutil"
 	"net/http"
+	"reflect"
 	"strings"
 	"sync"
 	"time"
@@ -26,6 +26,7 @@ const (
 
 var (
 	logKeywordCollectorJobs []*logKeywordCollector
+	logKeywordHttpLock      = new(sync.RWMutex)
 	logKeywordChanLength    = 100000
 )
 
@@ -45,6 +46,8 @@ func NewLogMonitorCollector(logger log.Logger) (Collector, error) {
 }
 
 func (c *logMonitorCollector) Update(ch chan<- prometheus.Metric) error {
+	logKeywordHttpLock.RLock()
+	defer logKeywordHttpLock.RUnlock()
 	for _, v := range logKeywordCollectorJobs {
 		for _, vv := range v.get() {
 			ch <- prometheus.MustNewConstMetric(c.logMonitor,
@@ -85,9 +88,10 @@ type logKeywordObj struct {
 type logKeywordCollector struct {
 	Path               string
 	Rule               []*logKeywordObj
-	TailSession        *tail.Tail
+	Multiline          *logMultilineConfig
+	Tailer             *logFileTailer
 	Lock               *sync.RWMutex
-	DataChan           chan string
+	DataChan           chan *logTailLine
 	ReOpenHandlerChan  chan int      `json:"-"`
 	TailTimeLock       *sync.RWMutex `json:"-"`
 	TailLastUnixTime   int64         `json:"-"`
@@ -95,7 +99,7 @@ type logKeywordCollector struct {
 	TailDataCancelChan chan int      `json:"-"`
 }
 
-func (c *logKeywordCollector) update(rule []*logKeywordObj) {
+func (c *logKeywordCollector) update(rule []*logKeywordObj, multiline *logMultilineConfig) {
 	c.Lock.Lock()
 	for _, inputRule := range rule {
 		for _, existRule := range c.Rule {
@@ -107,18 +111,25 @@ func (c *logKeywordCollector) update(rule []*logKeywordObj) {
 		}
 	}
 	c.Rule = rule
+	if !reflect.DeepEqual(c.Multiline, multiline) {
+		c.Multiline = multiline
+	}
 	c.Lock.Unlock()
 }
 
 func (c *logKeywordCollector) startHandleTailData() {
+	reader := newLogMultilineReader(c.Path)
+	defer reader.stop()
 	for {
-		var lineText string
-		select {
-		case lineText = <-c.DataChan:
-		case <-c.TailDataCancelChan:
+		c.Lock.RLock()
+		reader.setConfig(c.Multiline)
+		c.Lock.RUnlock()
+		line, ok := reader.next(c.DataChan, c.TailDataCancelChan)
+		if !ok {
 			return
 		}
 		//lineText := <-c.DataChan
+		lineText := line.Text
 		c.Lock.Lock()
 		for _, v := range c.Rule {
 			if v.RegExp != nil {
@@ -137,6 +148,8 @@ func (c *logKeywordCollector) startHandleTailData() {
 				}
 			}
 		}
+		// 计数与检查点在同一把锁内更新,保存时两者一致
+		line.commit()
 		c.Lock.Unlock()
 	}
 }
@@ -152,15 +165,14 @@ func (c *logKeywordCollector) init() {
 
 func (c *logKeywordCollector) start() {
 	level.Info(monitorLogger).Log("log_keyword -> logKeywordCollectorStart", c.Path)
-	var err error
-	c.TailSession, err = tail.TailFile(c.Path, tail.Config{Follow: true, ReOpen: true, Poll: true, Location: &tail.SeekInfo{Offset: 0, Whence: 2}})
-	if err != nil {
-		level.Error(monitorLogger).Log("error", fmt.Sprintf("start log keyword collector fail, path: %s, error: %v", c.Path, err))
-		return
-	}
 	c.TailLastUnixTime = 0
-	c.DataChan = make(chan string, logKeywordChanLength)
+	c.DataChan = make(chan *logTailLine, logKeywordChanLength)
 	go c.startHandleTailData()
+	if isLogPathPattern(c.Path) {
+		c.startMultiPath()
+		return
+	}
+	c.Tailer = newLogFileTailer(c.tailOwner(), c.Path, false)
 	//go c.startFileHandlerCheck()
 	reopenFlag := false
 	destroyFlag := false
@@ -170,11 +182,8 @@ func (c *logKeywordCollector) start() {
 			reopenFlag = true
 		case <-c.DestroyChan:
 			destroyFlag = true
-		case line := <-c.TailSession.Lines:
-			if line == nil {
-				continue
-			}
-			c.DataChan <- line.Text
+		case line := <-c.Tailer.Lines:
+			c.DataChan <- line
 		}
 		if reopenFlag || destroyFlag {
 			break
@@ -185,8 +194,7 @@ func (c *logKeywordCollector) start() {
 		//	c.TailTimeLock.Unlock()
 		//}
 	}
-	c.TailSession.Stop()
-	//c.TailSession.Cleanup()
+	c.Tailer.Stop()
 	c.TailDataCancelChan <- 1
 	level.Info(monitorLogger).Log("log_keyword -> startLogMetricMonitorNeObj__end", c.Path)
 	if destroyFlag {
@@ -196,6 +204,23 @@ func (c *logKeywordCollector) start() {
 	//go c.start()
 }
 
+// startMultiPath 通配路径由 logPathWatcher 匹配,之后新出现的文件也会采集
+func (c *logKeywordCollector) startMultiPath() {
+	watcher := newLogPathWatcher(c.tailOwner(), c.Path, c.DataChan)
+	select {
+	case <-c.ReOpenHandlerChan:
+	case <-c.DestroyChan:
+	}
+	watcher.Stop()
+	c.TailDataCancelChan <- 1
+	level.Info(monitorLogger).Log("log_keyword -> startMultiPath__end", c.Path)
+}
+
+// tailOwner 检查点按采集配置的路径区分,通配路径下的各个文件都属于同一个采集
+func (c *logKeywordCollector) tailOwner() string {
+	return "log_keyword:" + c.Path
+}
+
 func (c *logKeywordCollector) startFileHandlerCheck() {
 	t := time.NewTicker(1 * time.Minute).C
 	for {
@@ -259,8 +284,9 @@ type logKeywordHttpRuleObj struct {
 }
 
 type logKeywordHttpDto struct {
-	Path     string                   `json:"path"`
-	Keywords []*logKeywordHttpRuleObj `json:"keywords"`
+	Path      string                   `json:"path"`
+	Keywords  []*logKeywordHttpRuleObj `json:"keywords"`
+	Multiline *logMultilineConfig      `json:"multiline"`
 }
 
 type logKeywordHttpResult struct {
@@ -301,6 +327,8 @@ func logKeywordHttpAction(requestParamBuff []byte) (err error) {
 	if err != nil {
 		return
 	}
+	logKeywordHttpLock.Lock()
+	defer logKeywordHttpLock.Unlock()
 	var newCollectorList []*logKeywordCollector
 	var removePathList []string
 	for _, existCollector := range logKeywordCollectorJobs {
@@ -322,7 +350,7 @@ func logKeywordHttpAction(requestParamBuff []byte) (err error) {
 						tmpKeywordList = append(tmpKeywordList, &logKeywordObj{Keyword: inputKeyword.Keyword, TargetEndpoint: inputKeyword.TargetEndpoint})
 					}
 				}
-				existCollector.update(tmpKeywordList)
+				existCollector.update(tmpKeywordList, inputParam.Multiline)
 			}
 		}
 		if !exist {
@@ -361,7 +389,7 @@ func logKeywordHttpAction(requestParamBuff []byte) (err error) {
 			continue
 		}
 		// Add collector
-		newCollector := logKeywordCollector{Path: inputParam.Path}
+		newCollector := logKeywordCollector{Path: inputParam.Path, Multiline: inputParam.Multiline}
 		newCollector.Lock = new(sync.RWMutex)
 		var tmpKeywordList []*logKeywordObj
 		for _, inputKeyword := range inputParam.Keywords {
@@ -376,6 +404,7 @@ func logKeywordHttpAction(requestParamBuff []byte) (err error) {
 				tmpKeywordList = append(tmpKeywordList, &logKeywordObj{Keyword: inputKeyword.Keyword, Count: inputKeyword.Count, TargetEndpoint: inputKeyword.TargetEndpoint})
 			}
 		}
+		restoreLogKeywordCount(inputParam.Path, tmpKeywordList)
 		newCollector.Rule = tmpKeywordList
 		logKeywordCollectorJobs = append(logKeywordCollectorJobs, &newCollector)
 		newCollector.init()
@@ -431,12 +460,14 @@ func LogMonitorRowsHttpHandle(w http.ResponseWriter, r *http.Request) {
 		result.Message = errorMsg
 		return
 	}
+	logKeywordHttpLock.RLock()
 	for _, v := range logKeywordCollectorJobs {
 		if v.Path == param.Path {
 			result.Data = v.getRows(param.Keyword)
 			break
 		}
 	}
+	logKeywordHttpLock.RUnlock()
 	result.Status = "ok"
 	result.Message = "success"
 }
@@ -9,13 +9,12 @@ import (
 	//"github.com/dlclark/regexp2"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
-	"github.com/hpcloud/tail"
 	"github.com/prometheus/client_golang/prometheus"
 	"io/ioutil"
 	"net/http"
 	"reflect"
 	//"regexp"
-	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -34,11 +33,14 @@ var (
 	logMetricMonitorMetricLock = new(sync.RWMutex)
 	monitorLogger              log.Logger
 	logMetricChanLength        = 100000
+	logPathDateRegexp          = regexp.MustCompile(`%[YmdH]`)
+	logPathPatternReplacer     = strings.NewReplacer(`\*`, ".*", "%Y", `\d{4}`, "%m", `\d{2}`, "%d", `\d{2}`, "%H", `\d{2}`)
 )
 
 type logMetricMonitorCollector struct {
-	logMetricMonitor *prometheus.Desc
-	logger           log.Logger
+	logMetricMonitor   *prometheus.Desc
+	logMetricHistogram *prometheus.Desc
+	logger             log.Logger
 }
 
 func InitMonitorLogger(logger log.Logger) {
@@ -56,6 +58,11 @@ func LogMetricMonitorCollector(logger log.Logger) (Collector, error) {
 			"Show log_metric data from log file.",
 			[]string{"key", "tags", "path", "agg", "t_endpoint", "service_group", "code", "retcode"}, nil,
 		),
+		logMetricHistogram: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, log_metricCollectorName, "histogram"),
+			"Show log_metric histogram from log file.",
+			[]string{"key", "tags", "path", "t_endpoint", "service_group", "code", "retcode"}, nil,
+		),
 		logger: logger,
 	}, nil
 }
@@ -63,6 +70,13 @@ func LogMetricMonitorCollector(logger log.Logger) (Collector, error) {
 func (c *logMetricMonitorCollector) Update(ch chan<- prometheus.Metric) error {
 	logMetricMonitorMetricLock.RLock()
 	for _, v := range logMetricMonitorMetrics {
+		if v.Agg == "histogram" {
+			if histogram := v.ValueObj.Histogram; histogram != nil {
+				ch <- prometheus.MustNewConstHistogram(c.logMetricHistogram, histogram.count, histogram.sum, histogram.cumulativeBuckets(),
+					v.Metric, v.TagsString, v.Path, v.TEndpoint, v.ServiceGroup, v.Code, v.RetCode)
+			}
+			continue
+		}
 		if !v.Display {
 			continue
 		}
@@ -80,7 +94,7 @@ type logMetricNodeExporterResponse struct {
 }
 
 type logMetricMonitorNeObj struct {
-	TailSession        *tail.Tail             `json:"-"`
+	Tailer             *logFileTailer         `json:"-"`
 	Lock               *sync.RWMutex          `json:"-"`
 	Path               string                 `json:"path"`
 	TargetEndpoint     string                 `json:"target_endpoint"`
@@ -88,13 +102,13 @@ type logMetricMonitorNeObj struct {
 	JsonConfig         []*logMetricJsonNeObj  `json:"config"`
 	MetricConfig       []*logMetricNeObj      `json:"custom"`
 	MetricGroupConfig  []*logMetricGroupNeObj `json:"metric_group_config"`
-	DataChan           chan string            `json:"-"`
+	Multiline          *logMultilineConfig    `json:"multiline"`
+	DataChan           chan *logTailLine      `json:"-"`
 	ReOpenHandlerChan  chan int               `json:"-"`
 	TailTimeLock       *sync.RWMutex          `json:"-"`
 	TailLastUnixTime   int64                  `json:"-"`
 	DestroyChan        chan int               `json:"-"`
 	TailDataCancelChan chan int               `json:"-"`
-	MultiPathNum       int                    `json:"-"`
 }
 
 type logMetricGroupNeObj struct {
@@ -135,6 +149,7 @@ type logMetricNeObj struct {
 	StringMap    []*logMetricStringMapNeObj `json:"string_map"`
 	TagConfig    []*LogMetricConfigTag      `json:"tag_config"`
 	LogParamName string                     `json:"log_param_name"`
+	Buckets      []float64                  `json:"buckets"`
 }
 
 type LogMetricConfigTag struct {
@@ -174,21 +189,52 @@ type logMetricDisplayObj struct {
 }
 
 type logMetricValueObj struct {
-	Sum   float64
-	Count float64
-	Max   float64
-	Min   float64
+	Sum       float64
+	Count     float64
+	Max       float64
+	Min       float64
+	Sketch    *logMetricSketch    `json:"-"`
+	Histogram *logMetricHistogram `json:"-"`
+}
+
+// observe 分位数和直方图类型需要记录每个值的分布
+func (v *logMetricDisplayObj) observe(value float64, buckets []float64) {
+	if _, b := logMetricPercentileMap[v.Agg]; b {
+		if v.ValueObj.Sketch == nil {
+			v.ValueObj.Sketch = newLogMetricSketch()
+		}
+		v.ValueObj.Sketch.add(value)
+	} else if v.Agg == "histogram" {
+		if v.ValueObj.Histogram == nil {
+			v.ValueObj.Histogram = newLogMetricHistogram(buckets)
+		}
+		v.ValueObj.Histogram.add(value)
+	}
 }
 
 func (c *logMetricMonitorNeObj) startHandleTailData() {
+	cancelFlag := false
+	reader := newLogMultilineReader(c.Path)
+	defer reader.stop()
 	for {
-		var lineText string
-		select {
-		case lineText = <-c.DataChan:
-		case <-c.TailDataCancelChan:
-			level.Info(monitorLogger).Log("log_metric -> logMetricMonitorNeObj_tail_data_cancel", fmt.Sprintf("path:%s,serviceGroup:%s", c.Path, c.ServiceGroup))
-			return
+		var line *logTailLine
+		c.Lock.RLock()
+		reader.setConfig(c.Multiline)
+		c.Lock.RUnlock()
+		if cancelFlag {
+			// 文件读取已停止,把已读到的行处理完再退出,否则重新开始时这些行会重复读取
+			if line = reader.drain(c.DataChan); line == nil {
+				level.Info(monitorLogger).Log("log_metric -> logMetricMonitorNeObj_tail_data_cancel", fmt.Sprintf("path:%s,serviceGroup:%s", c.Path, c.ServiceGroup))
+				return
+			}
+		} else {
+			var ok bool
+			if line, ok = reader.next(c.DataChan, c.TailDataCancelChan); !ok {
+				cancelFlag = true
+				continue
+			}
 		}
+		lineText := line.Text
 		//lineText := <-c.DataChan
 		//level.Info(monitorLogger).Log("log_metric_get_new_line ->", lineText)
 		//lineText = strings.ReplaceAll(lineText, "\\t", "    ")
@@ -273,6 +319,7 @@ func (c *logMetricMonitorNeObj) startHandleTailData() {
 			}
 		}
 		c.Lock.RUnlock()
+		line.commit()
 	}
 }
 
@@ -316,14 +363,9 @@ func pcreMatchSubString(re *Regexp, lineText string) (matchList []string) {
 
 func (c *logMetricMonitorNeObj) start() {
 	level.Info(monitorLogger).Log("log_metric -> startLogMetricMonitorNeObj__start", fmt.Sprintf("path:%s,serviceGroup:%s", c.Path, c.ServiceGroup))
-	var err error
-	c.TailSession, err = tail.TailFile(c.Path, tail.Config{Follow: true, ReOpen: true, Location: &tail.SeekInfo{Offset: 0, Whence: 2}})
-	if err != nil {
-		level.Error(monitorLogger).Log("msg", fmt.Sprintf("start log metric collector fail, path: %s, error: %v", c.Path, err))
-		return
-	}
+	c.Tailer = newLogFileTailer(c.tailOwner(), c.Path, false)
 	c.TailLastUnixTime = 0
-	c.DataChan = make(chan string, logMetricChanLength)
+	c.DataChan = make(chan *logTailLine, logMetricChanLength)
 	go c.startHandleTailData()
 	//go c.startFileHandlerCheck()
 	reopenFlag := false
@@ -334,12 +376,9 @@ func (c *logMetricMonitorNeObj) start() {
 			reopenFlag = true
 		case <-c.DestroyChan:
 			destroyFlag = true
-		case line := <-c.TailSession.Lines:
-			if line == nil {
-				continue
-			}
+		case line := <-c.Tailer.Lines:
 			//level.Info(monitorLogger).Log("log_metric -> get_new_line", fmt.Sprintf("path:%s,serviceGroup:%s,text:%s", c.Path, c.ServiceGroup, line.Text))
-			c.DataChan <- line.Text
+			c.DataChan <- line
 		}
 		if reopenFlag || destroyFlag {
 			break
@@ -350,8 +389,7 @@ func (c *logMetricMonitorNeObj) start() {
 		//	c.TailTimeLock.Unlock()
 		//}
 	}
-	c.TailSession.Stop()
-	//c.TailSession.Cleanup()
+	c.Tailer.Stop()
 	c.TailDataCancelChan <- 1
 	level.Info(monitorLogger).Log("log_metric -> startLogMetricMonitorNeObj__end", fmt.Sprintf("path:%s,serviceGroup:%s", c.Path, c.ServiceGroup))
 	if destroyFlag {
@@ -365,56 +403,23 @@ func (c *logMetricMonitorNeObj) start() {
 	}
 }
 
-func (c *logMetricMonitorNeObj) tailLogFile(logPath string, destroyChan chan int) {
-	level.Info(monitorLogger).Log("log_metric_start -> tailMultiLog", fmt.Sprintf("path:%s,serviceGroup:%s", logPath, c.ServiceGroup))
-	logTailSession, err := tail.TailFile(logPath, tail.Config{Follow: true, ReOpen: true, Location: &tail.SeekInfo{Offset: 0, Whence: 2}})
-	if err != nil {
-		level.Error(monitorLogger).Log("msg", fmt.Sprintf("start multi log metric collector fail, path: %s, error: %v", logPath, err))
-		return
-	}
-	destroyFlag := false
-	for {
-		select {
-		case <-destroyChan:
-			destroyFlag = true
-		case line := <-logTailSession.Lines:
-			if line == nil {
-				continue
-			}
-			//level.Info(monitorLogger).Log("log_metric -> get_new_line", fmt.Sprintf("path:%s,serviceGroup:%s,text:%s", c.Path, c.ServiceGroup, line.Text))
-			c.DataChan <- line.Text
-		}
-		if destroyFlag {
-			break
-		}
-	}
-	logTailSession.Stop()
-	level.Info(monitorLogger).Log("log_metric_end -> tailMultiLog", fmt.Sprintf("path:%s,serviceGroup:%s", logPath, c.ServiceGroup))
-}
-
+// startMultiPath 通配路径由 logPathWatcher 匹配,之后新出现的文件也会采集,配置更新时不需要重新开始
 func (c *logMetricMonitorNeObj) startMultiPath() {
 	level.Info(monitorLogger).Log("log_metric -> startLogMetricMonitorNeObj__startMultiPath", fmt.Sprintf("path:%s,serviceGroup:%s", c.Path, c.ServiceGroup))
-	pathList := listMatchLogPath(c.Path)
-	if len(pathList) == 0 {
-		level.Warn(monitorLogger).Log("log_metric -> startMultiPath_cannotMatchAnyFile", fmt.Sprintf("path:%s,serviceGroup:%s", c.Path, c.ServiceGroup))
-		return
-	}
-	c.MultiPathNum = len(pathList)
 	go c.startHandleTailData()
-	var destroyChanList []chan int
-	for _, targetFilePath := range pathList {
-		tmpDestroyChan := make(chan int, 1)
-		go c.tailLogFile(targetFilePath, tmpDestroyChan)
-		destroyChanList = append(destroyChanList, tmpDestroyChan)
-	}
+	watcher := newLogPathWatcher(c.tailOwner(), c.Path, c.DataChan)
 	<-c.DestroyChan
-	for _, tmpDestroyChan := range destroyChanList {
-		tmpDestroyChan <- 1
-	}
+	// 等所有文件停止读取后再通知处理协程,剩余的行由处理协程处理完
+	watcher.Stop()
 	c.TailDataCancelChan <- 1
 	level.Info(monitorLogger).Log("log_metric -> startLogMetricMonitorNeObj__endMultiPath", fmt.Sprintf("path:%s,serviceGroup:%s", c.Path, c.ServiceGroup))
 }
 
+// tailOwner 检查点按采集配置的服务组和路径区分,同一个文件被多个配置采集时互不影响
+func (c *logMetricMonitorNeObj) tailOwner() string {
+	return "log_metric:" + c.ServiceGroup + ":" + c.Path
+}
+
 func (c *logMetricMonitorNeObj) startFileHandlerCheck() {
 	t := time.NewTicker(1 * time.Minute).C
 	for {
@@ -446,6 +451,7 @@ func (c *logMetricMonitorNeObj) new(input *logMetricMonitorNeObj) {
 	level.Info(monitorLogger).Log("newLogMetricMonitorNeObj", c.Path)
 	c.TargetEndpoint = input.TargetEndpoint
 	c.ServiceGroup = input.ServiceGroup
+	c.Multiline = input.Multiline
 	c.JsonConfig = []*logMetricJsonNeObj{}
 	c.TailTimeLock = new(sync.RWMutex)
 	c.ReOpenHandlerChan = make(chan int, 1)
@@ -476,8 +482,8 @@ func (c *logMetricMonitorNeObj) new(input *logMetricMonitorNeObj) {
 		initLogMetricGroupNeObj(metricGroupObj)
 		c.MetricGroupConfig = append(c.MetricGroupConfig, metricGroupObj)
 	}
-	if strings.Contains(c.Path, "*") {
-		c.DataChan = make(chan string, logMetricChanLength)
+	if isLogPathPattern(c.Path) {
+		c.DataChan = make(chan *logTailLine, logMetricChanLength)
 		go c.startMultiPath()
 	} else {
 		go c.start()
@@ -540,17 +546,11 @@ func (c *logMetricMonitorNeObj) update(input *logMetricMonitorNeObj) {
 	c.TargetEndpoint = input.TargetEndpoint
 	c.ServiceGroup = input.ServiceGroup
 	c.MetricGroupConfig = newMetricGroupList
+	if !reflect.DeepEqual(c.Multiline, input.Multiline) {
+		c.Multiline = input.Multiline
+	}
 	level.Info(monitorLogger).Log("updateLogMetricMonitorNeObj_MetricGroupConfig: ", fmt.Sprintf("len:%d", len(c.MetricGroupConfig)))
 	c.Lock.Unlock()
-	if strings.Contains(c.Path, "*") {
-		if c.MultiPathNum > 0 {
-			level.Info(monitorLogger).Log("start_updateLogMetricMonitorNeObj_destroy_*: ", c.Path)
-			c.DestroyChan <- 1
-			level.Info(monitorLogger).Log("end_updateLogMetricMonitorNeObj_destroy_*: ", c.Path)
-		}
-		time.Sleep(500 * time.Millisecond)
-		go c.startMultiPath()
-	}
 }
 
 func initLogMetricGroupNeObj(metricGroupObj *logMetricGroupNeObj) {
@@ -686,8 +686,12 @@ func LogMetricMonitorHandleAction(requestParamBuff []byte) error {
 	if len(deletePathMap) > 0 && len(tmpLogMetricObjJobs) > 0 {
 		for _, existJob := range tmpLogMetricObjJobs {
 			if _, ok := deletePathMap[existJob.Path]; ok {
-				if !strings.Contains(existJob.Path, "*") {
-					existJob.ReOpenHandlerChan <- 1
+				if !isLogPathPattern(existJob.Path) {
+					// 已有重开信号未处理时不再重复发送,避免阻塞配置更新
+					select {
+					case existJob.ReOpenHandlerChan <- 1:
+					default:
+					}
 				}
 			}
 		}
@@ -797,8 +801,10 @@ func calcLogMetricData() {
 								valueExistObj.ValueObj.Min = metricValueFloat
 							}
 							valueExistObj.LastActiveTime = nowTimeUnix
+							valueExistObj.observe(metricValueFloat, metricConfig.Buckets)
 						} else {
 							valueCountMap[tmpMetricKey] = &logMetricDisplayObj{Id: tmpMetricKey, Metric: metricConfig.Metric, Path: lmObj.Path, Agg: metricConfig.AggType, TEndpoint: lmObj.TargetEndpoint, ServiceGroup: lmObj.ServiceGroup, TagsString: tmpTagString, Step: metricConfig.Step, ValueObj: logMetricValueObj{Sum: metricValueFloat, Max: metricValueFloat, Min: metricValueFloat, Count: 1}, LastActiveTime: nowTimeUnix}
+							valueCountMap[tmpMetricKey].observe(metricValueFloat, metricConfig.Buckets)
 						}
 					}
 				}
@@ -843,8 +849,10 @@ func calcLogMetricData() {
 						valueExistObj.ValueObj.Min = metricValueFloat
 					}
 					valueExistObj.LastActiveTime = nowTimeUnix
+					valueExistObj.observe(metricValueFloat, metricObj.Buckets)
 				} else {
 					valueCountMap[tmpMetricKey] = &logMetricDisplayObj{Id: tmpMetricKey, Metric: metricObj.Metric, Path: lmObj.Path, Agg: metricObj.AggType, TEndpoint: lmObj.TargetEndpoint, ServiceGroup: lmObj.ServiceGroup, TagsString: tmpTagString, Step: metricObj.Step, ValueObj: logMetricValueObj{Sum: metricValueFloat, Max: metricValueFloat, Min: metricValueFloat, Count: 1}, LastActiveTime: nowTimeUnix}
+					valueCountMap[tmpMetricKey].observe(metricValueFloat, metricObj.Buckets)
 				}
 			}
 			//valueCountMap[tmpMetricKey] = &tmpMetricObj
@@ -876,7 +884,7 @@ func calcLogMetricData() {
 				continue
 			}
 			if v.Display {
-				v.ValueObj = logMetricValueObj{Sum: 0, Count: 0, Max: 0, Min: 0}
+				v.ValueObj = logMetricValueObj{Sum: 0, Count: 0, Max: 0, Min: 0, Histogram: v.ValueObj.Histogram}
 			}
 			valueCountMap[k] = v
 		}
@@ -948,8 +956,10 @@ func calcMetricGroupFunc(logPath, endpoint, serviceGroup string, dataList []map[
 						valueExistObj.ValueObj.Min = metricValueFloat
 					}
 					valueExistObj.LastActiveTime = nowTimeUnix
+					valueExistObj.observe(metricValueFloat, metricConfig.Buckets)
 				} else {
 					valueCountMap[tmpMetricKey] = &logMetricDisplayObj{Id: tmpMetricKey, Metric: metricConfig.Metric, Path: logPath, Agg: metricConfig.AggType, TEndpoint: endpoint, ServiceGroup: serviceGroup, TagsString: tmpTagString, Step: metricConfig.Step, ValueObj: logMetricValueObj{Sum: metricValueFloat, Max: metricValueFloat, Min: metricValueFloat, Count: 1}, Code: tmpCode, RetCode: tmpRetCode, LastActiveTime: nowTimeUnix}
+					valueCountMap[tmpMetricKey].observe(metricValueFloat, metricConfig.Buckets)
 				}
 			}
 		}
@@ -974,8 +984,17 @@ func buildLogMetricDisplayMetrics(valueCountMap, existMetricMap map[string]*logM
 				if v.ValueObj.Min > existObj.ValueObj.Min {
 					v.ValueObj.Min = existObj.ValueObj.Min
 				}
+				v.ValueObj.Sketch = mergeLogMetricSketch(v.ValueObj.Sketch, existObj.ValueObj.Sketch)
 				lastTimestamp = existObj.UpdateTime
 			}
+			// 直方图的桶计数一直累加
+			if existObj != v && existObj.ValueObj.Histogram != nil {
+				if v.ValueObj.Histogram == nil {
+					v.ValueObj.Histogram = existObj.ValueObj.Histogram
+				} else {
+					v.ValueObj.Histogram.merge(existObj.ValueObj.Histogram)
+				}
+			}
 		}
 		// check display or not
 		if v.Step < 20 || firstDisplay {
@@ -1002,6 +1021,12 @@ func buildLogMetricDisplayMetrics(valueCountMap, existMetricMap map[string]*logM
 				v.Value = v.ValueObj.Min
 			case "avg":
 				avgFlag = true
+			case "histogram":
+				v.Value = v.ValueObj.Count
+			default:
+				if quantile, b := logMetricPercentileMap[v.Agg]; b {
+					v.Value = v.ValueObj.Sketch.quantile(quantile)
+				}
 			}
 		} else {
 			v.UpdateTime = lastTimestamp
@@ -1187,29 +1212,31 @@ func getFileLastUpdatedTime(filePath string) (unixTime int64, err error) {
 	return
 }
 
+// listMatchLogPath 文件名支持 * 通配以及 %Y%m%d%H 日期格式,如 app-%Y%m%d.log
 func listMatchLogPath(inputPath string) (result []string) {
-	var dirPath, fileName string
-	if lastPathIndex := strings.LastIndex(inputPath, "/"); lastPathIndex >= 0 {
-		dirPath = inputPath[:lastPathIndex+1]
-		fileName = inputPath[lastPathIndex+1:]
-	}
+	dirPath, fileName := filepath.Split(inputPath)
 	if fileName == "" {
 		level.Error(monitorLogger).Log("msg", fmt.Sprintf("log path illgal : %s ", inputPath))
 		return
 	}
-	fileName = strings.ReplaceAll(fileName, ".", "\\.")
-	fileName = strings.ReplaceAll(fileName, "*", ".*")
-	cmdString := fmt.Sprintf("ls %s |grep \"^%s$\"", dirPath, fileName)
-	cmd := exec.Command("bash", "-c", cmdString)
-	b, err := cmd.Output()
+	fileRegexp, err := regexp.Compile("^" + logPathPatternReplacer.Replace(regexp.QuoteMeta(fileName)) + "$")
 	if err != nil {
-		level.Error(monitorLogger).Log("msg", fmt.Sprintf("list log path:%s fail : %v ", cmdString, err))
+		level.Error(monitorLogger).Log("msg", fmt.Sprintf("log path illgal : %s ", inputPath))
 		return
 	}
-	for _, row := range strings.Split(string(b), "\n") {
-		if row != "" {
-			result = append(result, dirPath+row)
+	fileList, err := ioutil.ReadDir(dirPath)
+	if err != nil {
+		level.Warn(monitorLogger).Log("msg", fmt.Sprintf("list log path:%s fail : %v ", dirPath, err))
+		return
+	}
+	for _, file := range fileList {
+		if !file.IsDir() && fileRegexp.MatchString(file.Name()) {
+			result = append(result, dirPath+file.Name())
 		}
 	}
 	return
 }
+
+func isLogPathPattern(inputPath string) bool {
+	return strings.Contains(inputPath, "*") || logPathDateRegexp.MatchString(inputPath)
+}
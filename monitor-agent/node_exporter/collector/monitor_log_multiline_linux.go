@@ -0,0 +1,198 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	logMultilineDefaultMaxLines     = 500
+	logMultilineDefaultFlushTimeout = 3
+	logMultilineCheckInterval       = time.Second
+)
+
+// logMultilineConfig 多行日志合并配置,StartPattern 匹配的行是一个新事件的开始,
+// ContinuePattern 匹配的行接在上一个事件后面,两者配置一个即可,都为空时按单行处理
+type logMultilineConfig struct {
+	StartPattern    string `json:"start_pattern"`
+	ContinuePattern string `json:"continue_pattern"`
+	MaxLines        int    `json:"max_lines"`
+	FlushTimeout    int64  `json:"flush_timeout"` // 秒,事件超过该时间没有新行时直接输出
+}
+
+func (c *logMultilineConfig) enable() bool {
+	return c != nil && (c.StartPattern != "" || c.ContinuePattern != "")
+}
+
+// logMultilineAssembler 把 java 异常栈、格式化的 json 这类多行日志合并成一个事件后再匹配;
+// 按文件分别合并,通配路径下多个文件的行交错也不影响;
+// 事件的检查点取最后一行,未完成的事件不提交,重新采集时从事件第一行开始读
+type logMultilineAssembler struct {
+	startRegexp    *regexp.Regexp
+	continueRegexp *regexp.Regexp
+	maxLines       int
+	flushTimeout   time.Duration
+	buffers        map[*logFileTailer]*logMultilineBuffer
+}
+
+type logMultilineBuffer struct {
+	lines    []*logTailLine
+	lastTime time.Time
+}
+
+// newLogMultilineAssembler 没有配置或正则非法时返回 nil,按单行处理
+func newLogMultilineAssembler(path string, config *logMultilineConfig) *logMultilineAssembler {
+	if !config.enable() {
+		return nil
+	}
+	a := &logMultilineAssembler{maxLines: config.MaxLines, flushTimeout: time.Duration(config.FlushTimeout) * time.Second, buffers: make(map[*logFileTailer]*logMultilineBuffer)}
+	var err error
+	if config.StartPattern != "" {
+		a.startRegexp, err = regexp.Compile(config.StartPattern)
+	} else {
+		a.continueRegexp, err = regexp.Compile(config.ContinuePattern)
+	}
+	if err != nil {
+		level.Error(monitorLogger).Log("log_multiline -> pattern_illegal", fmt.Sprintf("path:%s,error:%s", path, err.Error()))
+		return nil
+	}
+	if a.maxLines <= 0 {
+		a.maxLines = logMultilineDefaultMaxLines
+	}
+	if a.flushTimeout <= 0 {
+		a.flushTimeout = logMultilineDefaultFlushTimeout * time.Second
+	}
+	return a
+}
+
+func (a *logMultilineAssembler) isContinueLine(text string) bool {
+	if a.startRegexp != nil {
+		return !a.startRegexp.MatchString(text)
+	}
+	return a.continueRegexp.MatchString(text)
+}
+
+// push 返回已经完整的事件,文件开头或检查点之后的续行单独作为一个事件
+func (a *logMultilineAssembler) push(line *logTailLine) (events []*logTailLine) {
+	buffer := a.buffers[line.tailer]
+	if buffer != nil && (buffer.lines[0].fileId != line.fileId || !a.isContinueLine(line.Text)) {
+		events = append(events, buildLogMultilineEvent(buffer.lines))
+		buffer = nil
+	}
+	if buffer == nil {
+		buffer = &logMultilineBuffer{}
+		a.buffers[line.tailer] = buffer
+	}
+	buffer.lines = append(buffer.lines, line)
+	buffer.lastTime = time.Now()
+	if len(buffer.lines) >= a.maxLines {
+		events = append(events, buildLogMultilineEvent(buffer.lines))
+		delete(a.buffers, line.tailer)
+	}
+	return events
+}
+
+// expire 超时没有新行的事件认为已经结束
+func (a *logMultilineAssembler) expire() (events []*logTailLine) {
+	nowTime := time.Now()
+	for tailer, buffer := range a.buffers {
+		if nowTime.Sub(buffer.lastTime) >= a.flushTimeout {
+			events = append(events, buildLogMultilineEvent(buffer.lines))
+			delete(a.buffers, tailer)
+		}
+	}
+	return events
+}
+
+// flush 配置变更时把已读到的行按旧配置输出
+func (a *logMultilineAssembler) flush() (events []*logTailLine) {
+	for tailer, buffer := range a.buffers {
+		events = append(events, buildLogMultilineEvent(buffer.lines))
+		delete(a.buffers, tailer)
+	}
+	return events
+}
+
+func buildLogMultilineEvent(lines []*logTailLine) *logTailLine {
+	lastLine := lines[len(lines)-1]
+	if len(lines) == 1 {
+		return lastLine
+	}
+	textList := make([]string, len(lines))
+	for i, v := range lines {
+		textList[i] = v.Text
+	}
+	return &logTailLine{Text: strings.Join(textList, "\n"), tailer: lastLine.tailer, fileId: lastLine.fileId, offset: lastLine.offset}
+}
+
+// logMultilineReader 处理协程从 DataChan 取事件,配置变更时重新生成合并器
+type logMultilineReader struct {
+	path      string
+	assembler *logMultilineAssembler
+	config    *logMultilineConfig
+	events    []*logTailLine
+	ticker    *time.Ticker
+}
+
+func newLogMultilineReader(path string) *logMultilineReader {
+	return &logMultilineReader{path: path, ticker: time.NewTicker(logMultilineCheckInterval)}
+}
+
+func (r *logMultilineReader) stop() {
+	r.ticker.Stop()
+}
+
+// setConfig 调用方持有采集配置的锁时传入当前配置
+func (r *logMultilineReader) setConfig(config *logMultilineConfig) {
+	if config == r.config {
+		return
+	}
+	if r.assembler != nil {
+		r.events = append(r.events, r.assembler.flush()...)
+	}
+	r.config = config
+	r.assembler = newLogMultilineAssembler(r.path, config)
+}
+
+// next 返回下一个完整事件,ok 为 false 表示 cancelChan 收到了通知
+func (r *logMultilineReader) next(dataChan chan *logTailLine, cancelChan chan int) (event *logTailLine, ok bool) {
+	for len(r.events) == 0 {
+		select {
+		case line := <-dataChan:
+			if r.assembler == nil {
+				return line, true
+			}
+			r.events = r.assembler.push(line)
+		case <-r.ticker.C:
+			if r.assembler != nil {
+				r.events = r.assembler.expire()
+			}
+		case <-cancelChan:
+			return nil, false
+		}
+	}
+	event = r.events[0]
+	r.events = r.events[1:]
+	return event, true
+}
+
+// drain 文件读取停止后取出 dataChan 里剩余的完整事件,没有时返回 nil,未完成的事件不处理也不提交检查点
+func (r *logMultilineReader) drain(dataChan chan *logTailLine) (event *logTailLine) {
+	for len(r.events) == 0 {
+		if len(dataChan) == 0 {
+			return nil
+		}
+		line := <-dataChan
+		if r.assembler == nil {
+			return line
+		}
+		r.events = r.assembler.push(line)
+	}
+	event = r.events[0]
+	r.events = r.events[1:]
+	return event
+}
@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+func buildLogMultilineTestLines(tailer *logFileTailer, fileId logTailFileId, textList ...string) (lines []*logTailLine) {
+	for i, v := range textList {
+		lines = append(lines, &logTailLine{Text: v, tailer: tailer, fileId: fileId, offset: int64(i + 1)})
+	}
+	return
+}
+
+func TestLogMultilineAssemblerStartPattern(t *testing.T) {
+	assembler := newLogMultilineAssembler("/var/log/app.log", &logMultilineConfig{StartPattern: `^\d{4}-\d{2}-\d{2}`})
+	tailer := &logFileTailer{}
+	lines := buildLogMultilineTestLines(tailer, logTailFileId{Inode: 1},
+		"\tat before.checkpoint", "2024-01-01 error", "java.lang.NullPointerException", "\tat a.b.c", "2024-01-01 info")
+	var events []*logTailLine
+	for _, line := range lines {
+		events = append(events, assembler.push(line)...)
+	}
+	// 检查点之后的续行单独作为一个事件
+	if len(events) != 2 || events[0].Text != "\tat before.checkpoint" {
+		t.Fatalf("events illegal:%+v", events)
+	}
+	if events[1].Text != "2024-01-01 error\njava.lang.NullPointerException\n\tat a.b.c" || events[1].offset != 4 {
+		t.Errorf("multiline event illegal,text:%s offset:%d", events[1].Text, events[1].offset)
+	}
+	if events = assembler.flush(); len(events) != 1 || events[0] != lines[4] {
+		t.Errorf("flush should return the single unfinished line:%+v", events)
+	}
+}
+
+func TestLogMultilineAssemblerContinuePattern(t *testing.T) {
+	assembler := newLogMultilineAssembler("/var/log/app.log", &logMultilineConfig{ContinuePattern: `^\s`, MaxLines: 3})
+	tailerA, tailerB := &logFileTailer{}, &logFileTailer{}
+	var events []*logTailLine
+	// 通配路径下两个文件的行交错
+	events = append(events, assembler.push(&logTailLine{Text: "a1", tailer: tailerA})...)
+	events = append(events, assembler.push(&logTailLine{Text: "b1", tailer: tailerB})...)
+	events = append(events, assembler.push(&logTailLine{Text: " a2", tailer: tailerA})...)
+	events = append(events, assembler.push(&logTailLine{Text: " b2", tailer: tailerB})...)
+	if len(events) != 0 {
+		t.Fatalf("unfinished events should not output:%+v", events)
+	}
+	// 达到最大行数直接输出
+	if events = assembler.push(&logTailLine{Text: " a3", tailer: tailerA}); len(events) != 1 || events[0].Text != "a1\n a2\n a3" {
+		t.Fatalf("max lines event illegal:%+v", events)
+	}
+	// 文件轮转后的续行不接到旧文件的事件后面
+	events = assembler.push(&logTailLine{Text: " b3", tailer: tailerB, fileId: logTailFileId{Inode: 2}})
+	if len(events) != 1 || events[0].Text != "b1\n b2" {
+		t.Errorf("rotate file event illegal:%+v", events)
+	}
+}
+
+func TestLogMultilineAssemblerExpire(t *testing.T) {
+	assembler := newLogMultilineAssembler("/var/log/app.log", &logMultilineConfig{StartPattern: "^start"})
+	tailer := &logFileTailer{}
+	assembler.push(&logTailLine{Text: "start", tailer: tailer})
+	assembler.push(&logTailLine{Text: "more", tailer: tailer})
+	if events := assembler.expire(); len(events) != 0 {
+		t.Fatalf("event should not expire before flush timeout:%+v", events)
+	}
+	assembler.buffers[tailer].lastTime = time.Now().Add(-assembler.flushTimeout)
+	if events := assembler.expire(); len(events) != 1 || events[0].Text != "start\nmore" {
+		t.Errorf("expire event illegal:%+v", events)
+	}
+	if len(assembler.buffers) != 0 {
+		t.Errorf("buffer should be removed after expire")
+	}
+}
+
+func TestNewLogMultilineAssemblerIllegal(t *testing.T) {
+	monitorLogger = log.NewNopLogger()
+	if newLogMultilineAssembler("/var/log/app.log", nil) != nil || newLogMultilineAssembler("/var/log/app.log", &logMultilineConfig{}) != nil {
+		t.Errorf("empty config should return nil")
+	}
+	if newLogMultilineAssembler("/var/log/app.log", &logMultilineConfig{StartPattern: "(["}) != nil {
+		t.Errorf("illegal pattern should return nil")
+	}
+}
+
+func TestLogMultilineReader(t *testing.T) {
+	reader := newLogMultilineReader("/var/log/app.log")
+	defer reader.stop()
+	dataChan, cancelChan := make(chan *logTailLine, 10), make(chan int, 1)
+	tailer := &logFileTailer{}
+	reader.setConfig(&logMultilineConfig{StartPattern: "^start"})
+	for _, line := range buildLogMultilineTestLines(tailer, logTailFileId{}, "start 1", "detail", "start 2") {
+		dataChan <- line
+	}
+	if event, ok := reader.next(dataChan, cancelChan); !ok || event.Text != "start 1\ndetail" {
+		t.Fatalf("next event illegal:%+v", event)
+	}
+	// 剩余未完成的事件在 drain 时不输出
+	if event := reader.drain(dataChan); event != nil {
+		t.Errorf("unfinished event should not drain:%+v", event)
+	}
+	// 配置变化时按旧配置输出已读到的行
+	reader.setConfig(nil)
+	if event, ok := reader.next(dataChan, cancelChan); !ok || event.Text != "start 2" {
+		t.Errorf("event after config change illegal:%+v", event)
+	}
+	cancelChan <- 1
+	if _, ok := reader.next(dataChan, cancelChan); ok {
+		t.Errorf("next should return false after cancel")
+	}
+}
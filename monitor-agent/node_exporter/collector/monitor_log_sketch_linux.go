@@ -0,0 +1,190 @@
+package collector
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	logMetricSketchAccuracy = 0.01
+	logMetricSketchMinValue = 1e-9
+)
+
+var (
+	logMetricPercentileMap  = map[string]float64{"p50": 0.5, "p90": 0.9, "p95": 0.95, "p99": 0.99}
+	logMetricDefaultBuckets = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+	logMetricSketchLogGamma = math.Log((1 + logMetricSketchAccuracy) / (1 - logMetricSketchAccuracy))
+	logMetricSketchGamma    = (1 + logMetricSketchAccuracy) / (1 - logMetricSketchAccuracy)
+)
+
+// logMetricSketch DDSketch,按 log_gamma 分桶,分位数的相对误差不超过 1%,
+// 每个采集窗口一个,未展示的窗口与下一个窗口合并
+type logMetricSketch struct {
+	positive  map[int]float64
+	negative  map[int]float64
+	zeroCount float64
+	count     float64
+	min       float64
+	max       float64
+}
+
+func newLogMetricSketch() *logMetricSketch {
+	return &logMetricSketch{positive: make(map[int]float64), negative: make(map[int]float64), min: math.Inf(1), max: math.Inf(-1)}
+}
+
+func logMetricSketchKey(value float64) int {
+	return int(math.Ceil(math.Log(value) / logMetricSketchLogGamma))
+}
+
+func logMetricSketchValue(key int) float64 {
+	return 2 * math.Exp(float64(key)*logMetricSketchLogGamma) / (logMetricSketchGamma + 1)
+}
+
+func (s *logMetricSketch) add(value float64) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return
+	}
+	if value > logMetricSketchMinValue {
+		s.positive[logMetricSketchKey(value)]++
+	} else if value < -logMetricSketchMinValue {
+		s.negative[logMetricSketchKey(-value)]++
+	} else {
+		s.zeroCount++
+	}
+	s.count++
+	if value < s.min {
+		s.min = value
+	}
+	if value > s.max {
+		s.max = value
+	}
+}
+
+func (s *logMetricSketch) merge(other *logMetricSketch) {
+	if other == nil {
+		return
+	}
+	for k, v := range other.positive {
+		s.positive[k] += v
+	}
+	for k, v := range other.negative {
+		s.negative[k] += v
+	}
+	s.zeroCount += other.zeroCount
+	s.count += other.count
+	s.min = math.Min(s.min, other.min)
+	s.max = math.Max(s.max, other.max)
+}
+
+// quantile 负数按绝对值从大到小,然后是 0,再是正数从小到大,找到排名所在的桶
+func (s *logMetricSketch) quantile(q float64) float64 {
+	if s == nil || s.count == 0 {
+		return 0
+	}
+	rank := q * (s.count - 1)
+	var result, cumulative float64
+	found := false
+	var negativeKeys, positiveKeys []int
+	for k := range s.negative {
+		negativeKeys = append(negativeKeys, k)
+	}
+	for k := range s.positive {
+		positiveKeys = append(positiveKeys, k)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(negativeKeys)))
+	sort.Ints(positiveKeys)
+	for _, k := range negativeKeys {
+		cumulative += s.negative[k]
+		if cumulative > rank {
+			result, found = -logMetricSketchValue(k), true
+			break
+		}
+	}
+	if !found {
+		cumulative += s.zeroCount
+		if cumulative > rank {
+			result, found = 0, true
+		}
+	}
+	if !found {
+		for _, k := range positiveKeys {
+			cumulative += s.positive[k]
+			if cumulative > rank {
+				result, found = logMetricSketchValue(k), true
+				break
+			}
+		}
+	}
+	if !found || result > s.max {
+		result = s.max
+	}
+	if result < s.min {
+		result = s.min
+	}
+	return result
+}
+
+func mergeLogMetricSketch(current, exist *logMetricSketch) *logMetricSketch {
+	if current == nil {
+		return exist
+	}
+	current.merge(exist)
+	return current
+}
+
+// logMetricHistogram Prometheus 直方图,桶计数与 sum/count 从采集开始一直累加
+type logMetricHistogram struct {
+	bounds []float64
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newLogMetricHistogram(buckets []float64) *logMetricHistogram {
+	if len(buckets) == 0 {
+		buckets = logMetricDefaultBuckets
+	}
+	bounds := make([]float64, len(buckets))
+	copy(bounds, buckets)
+	sort.Float64s(bounds)
+	return &logMetricHistogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+func (h *logMetricHistogram) add(value float64) {
+	if math.IsNaN(value) {
+		return
+	}
+	if i := sort.SearchFloat64s(h.bounds, value); i < len(h.bounds) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += value
+}
+
+// merge 桶配置被修改后不再合并旧数据,相当于计数器重置
+func (h *logMetricHistogram) merge(other *logMetricHistogram) {
+	if other == nil || len(other.bounds) != len(h.bounds) {
+		return
+	}
+	for i, v := range other.bounds {
+		if v != h.bounds[i] {
+			return
+		}
+	}
+	for i, v := range other.counts {
+		h.counts[i] += v
+	}
+	h.count += other.count
+	h.sum += other.sum
+}
+
+// cumulativeBuckets 转成 le 累计计数
+func (h *logMetricHistogram) cumulativeBuckets() map[float64]uint64 {
+	result := make(map[float64]uint64)
+	var cumulative uint64
+	for i, v := range h.bounds {
+		cumulative += h.counts[i]
+		result[v] = cumulative
+	}
+	return result
+}
@@ -0,0 +1,95 @@
+package collector
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func checkLogMetricSketchError(t *testing.T, sketch *logMetricSketch, values []float64) {
+	sortValues := make([]float64, len(values))
+	copy(sortValues, values)
+	sort.Float64s(sortValues)
+	for _, q := range []float64{0, 0.5, 0.9, 0.95, 0.99, 1} {
+		expect := sortValues[int(q*float64(len(sortValues)-1))]
+		get := sketch.quantile(q)
+		if math.Abs(get-expect) > math.Abs(expect)*logMetricSketchAccuracy+1e-9 {
+			t.Errorf("quantile %v expect %v,get:%v", q, expect, get)
+		}
+	}
+}
+
+func TestLogMetricSketchQuantile(t *testing.T) {
+	if newLogMetricSketch().quantile(0.5) != 0 {
+		t.Errorf("empty sketch quantile should be 0")
+	}
+	random := rand.New(rand.NewSource(1))
+	sketch := newLogMetricSketch()
+	var values []float64
+	for i := 0; i < 10000; i++ {
+		value := random.ExpFloat64() * 200
+		values = append(values, value)
+		sketch.add(value)
+	}
+	checkLogMetricSketchError(t, sketch, values)
+	// 负数和 0 也要排在正确的位置
+	mixSketch := newLogMetricSketch()
+	values = []float64{-50, -10, -1, 0, 0, 1, 10, 50, 100}
+	for _, v := range values {
+		mixSketch.add(v)
+	}
+	mixSketch.add(math.NaN())
+	if mixSketch.count != float64(len(values)) {
+		t.Errorf("NaN should be ignored,count:%v", mixSketch.count)
+	}
+	checkLogMetricSketchError(t, mixSketch, values)
+}
+
+func TestLogMetricSketchMerge(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+	var values []float64
+	var sketchList []*logMetricSketch
+	for i := 0; i < 3; i++ {
+		sketch := newLogMetricSketch()
+		for j := 0; j < 2000; j++ {
+			value := random.Float64() * float64(1000*(i+1))
+			values = append(values, value)
+			sketch.add(value)
+		}
+		sketchList = append(sketchList, sketch)
+	}
+	var merged *logMetricSketch
+	for _, sketch := range sketchList {
+		merged = mergeLogMetricSketch(sketch, merged)
+	}
+	if merged.count != float64(len(values)) {
+		t.Fatalf("merged count expect %d,get:%v", len(values), merged.count)
+	}
+	checkLogMetricSketchError(t, merged, values)
+	if mergeLogMetricSketch(nil, merged) != merged {
+		t.Errorf("merge into nil should return exist sketch")
+	}
+}
+
+func TestLogMetricHistogram(t *testing.T) {
+	histogram := newLogMetricHistogram([]float64{100, 10, 50})
+	for _, v := range []float64{1, 10, 20, 60, 500, math.NaN()} {
+		histogram.add(v)
+	}
+	buckets := histogram.cumulativeBuckets()
+	if buckets[10] != 2 || buckets[50] != 3 || buckets[100] != 4 || histogram.count != 5 || histogram.sum != 591 {
+		t.Errorf("histogram illegal,buckets:%v count:%d sum:%v", buckets, histogram.count, histogram.sum)
+	}
+	other := newLogMetricHistogram([]float64{10, 50, 100})
+	other.add(5)
+	histogram.merge(other)
+	if histogram.cumulativeBuckets()[10] != 3 || histogram.count != 6 {
+		t.Errorf("merge same buckets illegal:%v", histogram.cumulativeBuckets())
+	}
+	// 桶配置变化后不合并旧数据
+	histogram.merge(newLogMetricHistogram(nil))
+	if histogram.count != 6 {
+		t.Errorf("merge different buckets should be ignored,count:%d", histogram.count)
+	}
+}
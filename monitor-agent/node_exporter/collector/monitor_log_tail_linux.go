@@ -0,0 +1,493 @@
+package collector
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+const (
+	logTailOffsetFilePath   = "data/log_tail_offset.json"
+	logTailPollInterval     = 500 * time.Millisecond
+	logTailSaveInterval     = 10 * time.Second
+	logTailOffsetExpireTime = 7 * 24 * 3600
+	logTailMaxLineBytes     = 1024 * 1024
+	logTailLineChanLength   = 1000
+)
+
+var (
+	logTailMaxCatchUpBytes = kingpin.Flag("collector.log_tail.max-catch-up-bytes", "Max bytes of log to catch up from the saved offset after restart, 0 means always start from the end of file.").Default("67108864").Int64()
+	logTailOffsets         = &logTailOffsetStore{data: make(map[string]*logTailOffsetObj), tailers: make(map[*logFileTailer]bool)}
+	logTailOffsetsOnce     sync.Once
+)
+
+type logTailFileId struct {
+	Dev   uint64
+	Inode uint64
+}
+
+// logTailOffsetObj 某个采集对象在某个文件(设备号+inode)上已处理完的位置
+type logTailOffsetObj struct {
+	Owner      string `json:"owner"`
+	Path       string `json:"path"`
+	Dev        uint64 `json:"dev"`
+	Inode      uint64 `json:"inode"`
+	Offset     int64  `json:"offset"`
+	UpdateTime int64  `json:"update_time"`
+}
+
+type logTailOffsetFileObj struct {
+	Offsets      []*logTailOffsetObj           `json:"offsets"`
+	KeywordCount map[string]map[string]float64 `json:"keyword_count"`
+}
+
+// logTailOffsetStore 检查点定期落盘,关键字计数和对应的偏移量一起保存,重启后两者保持一致
+type logTailOffsetStore struct {
+	lock         sync.RWMutex
+	data         map[string]*logTailOffsetObj
+	tailers      map[*logFileTailer]bool
+	keywordCount map[string]map[string]float64
+}
+
+func logTailOffsetKey(owner string, id logTailFileId) string {
+	return fmt.Sprintf("%s|%d:%d", owner, id.Dev, id.Inode)
+}
+
+func initLogTailOffsetStore() {
+	logTailOffsetsOnce.Do(func() {
+		logTailOffsets.load()
+		go func() {
+			t := time.NewTicker(logTailSaveInterval).C
+			for {
+				<-t
+				logTailOffsets.save()
+			}
+		}()
+	})
+}
+
+func (s *logTailOffsetStore) load() {
+	b, err := ioutil.ReadFile(logTailOffsetFilePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			level.Warn(monitorLogger).Log("log_tail -> load_offset_fail", err.Error())
+		}
+		return
+	}
+	var fileObj logTailOffsetFileObj
+	if err = json.Unmarshal(b, &fileObj); err != nil {
+		level.Warn(monitorLogger).Log("log_tail -> load_offset_fail", err.Error())
+		return
+	}
+	s.lock.Lock()
+	for _, v := range fileObj.Offsets {
+		s.data[logTailOffsetKey(v.Owner, logTailFileId{Dev: v.Dev, Inode: v.Inode})] = v
+	}
+	s.keywordCount = fileObj.KeywordCount
+	s.lock.Unlock()
+	level.Info(monitorLogger).Log("log_tail -> load_offset", fmt.Sprintf("num:%d", len(fileObj.Offsets)))
+}
+
+func (s *logTailOffsetStore) set(owner, path string, id logTailFileId, offset int64) {
+	key := logTailOffsetKey(owner, id)
+	s.lock.Lock()
+	if exist, b := s.data[key]; b {
+		exist.Path = path
+		exist.Offset = offset
+		exist.UpdateTime = time.Now().Unix()
+	} else {
+		s.data[key] = &logTailOffsetObj{Owner: owner, Path: path, Dev: id.Dev, Inode: id.Inode, Offset: offset, UpdateTime: time.Now().Unix()}
+	}
+	s.lock.Unlock()
+}
+
+// getLatest 同一个路径轮转后会有多个 inode 的记录,取最后更新的
+func (s *logTailOffsetStore) getLatest(owner, path string) (result *logTailOffsetObj) {
+	s.lock.RLock()
+	for _, v := range s.data {
+		if v.Owner != owner || v.Path != path {
+			continue
+		}
+		if result == nil || v.UpdateTime > result.UpdateTime || (v.UpdateTime == result.UpdateTime && v.Offset > result.Offset) {
+			tmpObj := *v
+			result = &tmpObj
+		}
+	}
+	s.lock.RUnlock()
+	return
+}
+
+func (s *logTailOffsetStore) setActive(t *logFileTailer, active bool) {
+	s.lock.Lock()
+	if active {
+		s.tailers[t] = true
+	} else {
+		delete(s.tailers, t)
+	}
+	s.lock.Unlock()
+}
+
+func (s *logTailOffsetStore) listTailers() (result []*logFileTailer) {
+	s.lock.RLock()
+	for t := range s.tailers {
+		result = append(result, t)
+	}
+	s.lock.RUnlock()
+	return
+}
+
+func (s *logTailOffsetStore) getKeywordCount(path string) map[string]float64 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.keywordCount[path]
+}
+
+// save 关键字采集在持有自身锁时更新计数与偏移量,这里同样在锁内取两者,
+// 遍历采集列表时持有 logKeywordHttpLock,避免与配置更新并发修改列表
+func (s *logTailOffsetStore) save() {
+	fileObj := logTailOffsetFileObj{KeywordCount: make(map[string]map[string]float64)}
+	offsetMap := make(map[string]logTailOffsetObj)
+	latestMap := make(map[string]int64)
+	activeMap := make(map[string]bool)
+	nowTime := time.Now().Unix()
+	s.lock.RLock()
+	for t := range s.tailers {
+		activeMap[t.Owner+"|"+t.Path] = true
+	}
+	for k, v := range s.data {
+		if !activeMap[v.Owner+"|"+v.Path] && nowTime-v.UpdateTime > logTailOffsetExpireTime {
+			continue
+		}
+		offsetMap[k] = *v
+	}
+	s.lock.RUnlock()
+	logKeywordHttpLock.RLock()
+	for _, job := range logKeywordCollectorJobs {
+		job.Lock.RLock()
+		countMap := make(map[string]float64)
+		for _, rule := range job.Rule {
+			countMap[rule.Keyword] = rule.Count
+		}
+		s.lock.RLock()
+		for k, v := range s.data {
+			if v.Owner == job.tailOwner() {
+				offsetMap[k] = *v
+			}
+		}
+		s.lock.RUnlock()
+		job.Lock.RUnlock()
+		fileObj.KeywordCount[job.Path] = countMap
+	}
+	logKeywordHttpLock.RUnlock()
+	// 同一路径只保留最新的 inode 记录,旧 inode 的剩余部分在切换前已经读完
+	for _, v := range offsetMap {
+		if v.UpdateTime > latestMap[v.Owner+"|"+v.Path] {
+			latestMap[v.Owner+"|"+v.Path] = v.UpdateTime
+		}
+	}
+	for k, v := range offsetMap {
+		if v.UpdateTime < latestMap[v.Owner+"|"+v.Path] {
+			s.lock.Lock()
+			delete(s.data, k)
+			s.lock.Unlock()
+			continue
+		}
+		tmpObj := v
+		fileObj.Offsets = append(fileObj.Offsets, &tmpObj)
+	}
+	b, err := json.Marshal(fileObj)
+	if err != nil {
+		level.Error(monitorLogger).Log("log_tail -> save_offset_fail", err.Error())
+		return
+	}
+	tmpFilePath := logTailOffsetFilePath + ".tmp"
+	if err = ioutil.WriteFile(tmpFilePath, b, 0644); err == nil {
+		err = os.Rename(tmpFilePath, logTailOffsetFilePath)
+	}
+	if err != nil {
+		level.Error(monitorLogger).Log("log_tail -> save_offset_fail", err.Error())
+	}
+}
+
+// restoreLogKeywordCount 有保存的计数时以保存的为准,与检查点处的偏移量对应
+func restoreLogKeywordCount(path string, rule []*logKeywordObj) {
+	countMap := logTailOffsets.getKeywordCount(path)
+	if countMap == nil {
+		return
+	}
+	for _, v := range rule {
+		if count, b := countMap[v.Keyword]; b {
+			v.Count = count
+		}
+	}
+}
+
+type logTailLine struct {
+	Text   string
+	tailer *logFileTailer
+	fileId logTailFileId
+	offset int64
+}
+
+// commit 该行处理完之后调用,记录检查点
+func (l *logTailLine) commit() {
+	logTailOffsets.set(l.tailer.Owner, l.tailer.Path, l.fileId, l.offset)
+}
+
+// logFileTailer 轮询读取日志文件,每行带上所在文件与读完该行后的偏移量;
+// 文件被轮转时先把旧文件读到末尾再切换,被截断时从头开始读
+type logFileTailer struct {
+	Owner       string
+	Path        string
+	Lines       chan *logTailLine
+	StartTime   int64
+	fromHead    bool
+	missingTime int64
+	stopChan    chan int
+	doneChan    chan int
+	file        *os.File
+	reader      *bufio.Reader
+	fileId      logTailFileId
+	offset      int64
+	partial     string
+}
+
+// newLogFileTailer fromHead 表示没有检查点时从头读,用于启动后才出现的文件
+func newLogFileTailer(owner, path string, fromHead bool) *logFileTailer {
+	initLogTailOffsetStore()
+	t := &logFileTailer{Owner: owner, Path: path, Lines: make(chan *logTailLine, logTailLineChanLength), StartTime: time.Now().Unix(), fromHead: fromHead, stopChan: make(chan int), doneChan: make(chan int)}
+	logTailOffsets.setActive(t, true)
+	go t.run()
+	return t
+}
+
+func (t *logFileTailer) Stop() {
+	close(t.stopChan)
+	<-t.doneChan
+	logTailOffsets.setActive(t, false)
+}
+
+func getLogTailFileId(info os.FileInfo) (id logTailFileId) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		id.Dev = uint64(stat.Dev)
+		id.Inode = uint64(stat.Ino)
+	}
+	return
+}
+
+func openLogTailFile(path string) (f *os.File, id logTailFileId, size int64, err error) {
+	f, err = os.Open(path)
+	if err != nil {
+		return
+	}
+	info, statErr := f.Stat()
+	if statErr != nil {
+		f.Close()
+		return nil, id, 0, statErr
+	}
+	return f, getLogTailFileId(info), info.Size(), nil
+}
+
+// findRotatedLogFile 在同目录下找检查点对应的 inode,logrotate 默认改名后留在原目录
+func findRotatedLogFile(path string, id logTailFileId) string {
+	fileList, err := ioutil.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return ""
+	}
+	for _, file := range fileList {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		if getLogTailFileId(file) == id {
+			return filepath.Join(filepath.Dir(path), file.Name())
+		}
+	}
+	return ""
+}
+
+func (t *logFileTailer) setFile(f *os.File, id logTailFileId, offset int64) {
+	if offset > 0 {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			level.Warn(monitorLogger).Log("log_tail -> seek_fail", fmt.Sprintf("path:%s,offset:%d,error:%s", t.Path, offset, err.Error()))
+			offset = 0
+		}
+	}
+	t.file = f
+	t.fileId = id
+	t.offset = offset
+	t.partial = ""
+	t.reader = bufio.NewReader(f)
+}
+
+func (t *logFileTailer) closeFile() {
+	if t.file != nil {
+		t.file.Close()
+		t.file = nil
+	}
+}
+
+// catchUp 从检查点继续读,积压超过上限时跳过前面的部分并丢掉第一个不完整的行
+func (t *logFileTailer) catchUp(f *os.File, id logTailFileId, offset, size int64) {
+	skipFlag := false
+	if offset > size {
+		offset = 0
+	}
+	if size-offset > *logTailMaxCatchUpBytes {
+		level.Warn(monitorLogger).Log("log_tail -> catch_up_exceed", fmt.Sprintf("path:%s,skip:%d", t.Path, size-offset-*logTailMaxCatchUpBytes))
+		offset = size - *logTailMaxCatchUpBytes
+		skipFlag = true
+	}
+	t.setFile(f, id, offset)
+	if skipFlag {
+		if line, err := t.reader.ReadString('\n'); err == nil {
+			t.offset += int64(len(line))
+		} else {
+			t.partial = line
+		}
+	}
+	level.Info(monitorLogger).Log("log_tail -> resume", fmt.Sprintf("path:%s,inode:%d,offset:%d,size:%d", t.Path, id.Inode, t.offset, size))
+}
+
+// init 没有检查点时与之前一样从文件末尾开始;检查点的 inode 与当前文件不同说明停止期间发生过轮转,
+// 先从旧文件的检查点读到末尾,之后由 checkFile 切换到新文件
+func (t *logFileTailer) init() {
+	checkpoint := logTailOffsets.getLatest(t.Owner, t.Path)
+	f, id, size, err := openLogTailFile(t.Path)
+	if checkpoint == nil || *logTailMaxCatchUpBytes <= 0 {
+		if err != nil {
+			return
+		}
+		if checkpoint == nil && t.fromHead && *logTailMaxCatchUpBytes > 0 {
+			t.catchUp(f, id, 0, size)
+		} else {
+			t.setFile(f, id, size)
+		}
+		return
+	}
+	checkpointId := logTailFileId{Dev: checkpoint.Dev, Inode: checkpoint.Inode}
+	if err == nil && id == checkpointId {
+		t.catchUp(f, id, checkpoint.Offset, size)
+		return
+	}
+	if rotatedPath := findRotatedLogFile(t.Path, checkpointId); rotatedPath != "" {
+		if rotatedFile, _, rotatedSize, rotatedErr := openLogTailFile(rotatedPath); rotatedErr == nil {
+			if f != nil {
+				f.Close()
+			}
+			level.Info(monitorLogger).Log("log_tail -> follow_rotated_file", fmt.Sprintf("path:%s,rotated:%s", t.Path, rotatedPath))
+			t.catchUp(rotatedFile, checkpointId, checkpoint.Offset, rotatedSize)
+			return
+		}
+	}
+	if err == nil {
+		t.catchUp(f, id, 0, size)
+	}
+}
+
+func (t *logFileTailer) run() {
+	defer close(t.doneChan)
+	defer t.closeFile()
+	t.init()
+	for {
+		if t.file != nil && !t.readLines() {
+			return
+		}
+		if !t.checkFile() {
+			return
+		}
+		select {
+		case <-t.stopChan:
+			return
+		case <-time.After(logTailPollInterval):
+		}
+	}
+}
+
+func (t *logFileTailer) send(text string) bool {
+	select {
+	case t.Lines <- &logTailLine{Text: text, tailer: t, fileId: t.fileId, offset: t.offset}:
+		return true
+	case <-t.stopChan:
+		return false
+	}
+}
+
+// readLines 读到文件末尾,末尾不完整的行留到下次;返回 false 表示已停止
+func (t *logFileTailer) readLines() bool {
+	for {
+		line, err := t.reader.ReadString('\n')
+		if err != nil {
+			t.partial += line
+			if len(t.partial) > logTailMaxLineBytes {
+				t.offset += int64(len(t.partial))
+				text := t.partial
+				t.partial = ""
+				return t.send(text)
+			}
+			if err != io.EOF {
+				level.Warn(monitorLogger).Log("log_tail -> read_fail", fmt.Sprintf("path:%s,error:%s", t.Path, err.Error()))
+			}
+			return true
+		}
+		text := t.partial + line
+		t.partial = ""
+		t.offset += int64(len(text))
+		if !t.send(strings.TrimRight(text, "\n")) {
+			return false
+		}
+	}
+}
+
+// checkFile 读到末尾后检查文件是否被轮转或截断;返回 false 表示已停止
+func (t *logFileTailer) checkFile() bool {
+	if t.file == nil {
+		// 启动时文件还不存在,出现后是新文件,从头开始读
+		if f, id, _, err := openLogTailFile(t.Path); err == nil {
+			t.setFile(f, id, 0)
+		}
+		return true
+	}
+	info, err := os.Stat(t.Path)
+	if err != nil {
+		// 文件被移走且还没有新建,继续读旧的句柄
+		return true
+	}
+	if id := getLogTailFileId(info); id != t.fileId {
+		if !t.readLines() {
+			return false
+		}
+		if t.partial != "" {
+			t.offset += int64(len(t.partial))
+			text := t.partial
+			t.partial = ""
+			if !t.send(text) {
+				return false
+			}
+		}
+		f, newId, _, openErr := openLogTailFile(t.Path)
+		if openErr != nil {
+			return true
+		}
+		level.Info(monitorLogger).Log("log_tail -> rotate", fmt.Sprintf("path:%s,oldInode:%d,newInode:%d,oldOffset:%d", t.Path, t.fileId.Inode, newId.Inode, t.offset))
+		t.closeFile()
+		t.setFile(f, newId, 0)
+		return true
+	}
+	if fileInfo, statErr := t.file.Stat(); statErr == nil && fileInfo.Size() < t.offset+int64(len(t.partial)) {
+		level.Info(monitorLogger).Log("log_tail -> truncate", fmt.Sprintf("path:%s,offset:%d,size:%d", t.Path, t.offset, fileInfo.Size()))
+		t.file.Seek(0, io.SeekStart)
+		t.setFile(t.file, t.fileId, 0)
+	}
+	return true
+}
@@ -0,0 +1,128 @@
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+// chLogTailTestDir 偏移量文件使用相对路径,测试时切到临时目录
+func chLogTailTestDir(t *testing.T) func() {
+	monitorLogger = log.NewNopLogger()
+	dir, err := ioutil.TempDir("", "log_tail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(filepath.Join(dir, "data"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	oldDir, _ := os.Getwd()
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		os.Chdir(oldDir)
+		os.RemoveAll(dir)
+	}
+}
+
+func newTestLogTailOffsetStore() *logTailOffsetStore {
+	return &logTailOffsetStore{data: make(map[string]*logTailOffsetObj), tailers: make(map[*logFileTailer]bool)}
+}
+
+func TestLogTailOffsetStoreSaveLoad(t *testing.T) {
+	defer chLogTailTestDir(t)()
+	store := newTestLogTailOffsetStore()
+	oldId, newId := logTailFileId{Dev: 1, Inode: 10}, logTailFileId{Dev: 1, Inode: 11}
+	store.set("owner_a", "/var/log/a.log", oldId, 100)
+	store.set("owner_a", "/var/log/a.log", newId, 20)
+	store.set("owner_b", "/var/log/a.log", oldId, 300)
+	store.set("owner_c", "/var/log/c.log", oldId, 50)
+	nowTime := time.Now().Unix()
+	store.data[logTailOffsetKey("owner_a", oldId)].UpdateTime = nowTime - 10
+	// 超过保留时间且没有在采集的记录不再保存
+	store.data[logTailOffsetKey("owner_c", oldId)].UpdateTime = nowTime - logTailOffsetExpireTime - 1
+	store.save()
+	if _, b := store.data[logTailOffsetKey("owner_a", oldId)]; b {
+		t.Errorf("rotated inode offset should be removed after save")
+	}
+	loadStore := newTestLogTailOffsetStore()
+	loadStore.load()
+	if len(loadStore.data) != 2 {
+		t.Fatalf("load offset num expect 2,get:%d", len(loadStore.data))
+	}
+	latest := loadStore.getLatest("owner_a", "/var/log/a.log")
+	if latest == nil || latest.Inode != 11 || latest.Offset != 20 {
+		t.Errorf("owner_a latest offset illegal:%+v", latest)
+	}
+	if other := loadStore.getLatest("owner_b", "/var/log/a.log"); other == nil || other.Offset != 300 {
+		t.Errorf("owners on same path should not affect each other:%+v", other)
+	}
+	if loadStore.getLatest("owner_c", "/var/log/c.log") != nil {
+		t.Errorf("expired offset should not be loaded")
+	}
+}
+
+func TestLogTailOffsetStoreKeywordCount(t *testing.T) {
+	defer chLogTailTestDir(t)()
+	oldJobs := logKeywordCollectorJobs
+	defer func() { logKeywordCollectorJobs = oldJobs }()
+	job := &logKeywordCollector{Path: "/var/log/k.log", Lock: new(sync.RWMutex), Rule: []*logKeywordObj{{Keyword: "error", Count: 5}, {Keyword: "panic", Count: 1}}}
+	logKeywordCollectorJobs = []*logKeywordCollector{job}
+	store := newTestLogTailOffsetStore()
+	store.set(job.tailOwner(), job.Path, logTailFileId{Dev: 1, Inode: 20}, 1024)
+	store.data[logTailOffsetKey(job.tailOwner(), logTailFileId{Dev: 1, Inode: 20})].UpdateTime = time.Now().Unix() - logTailOffsetExpireTime - 1
+	store.save()
+	loadStore := newTestLogTailOffsetStore()
+	loadStore.load()
+	// 关键字采集的偏移量和计数一起保存,不受过期时间影响
+	if latest := loadStore.getLatest(job.tailOwner(), job.Path); latest == nil || latest.Offset != 1024 {
+		t.Errorf("keyword job offset should be saved with count:%+v", latest)
+	}
+	countMap := loadStore.getKeywordCount(job.Path)
+	if countMap["error"] != 5 || countMap["panic"] != 1 {
+		t.Errorf("keyword count illegal:%v", countMap)
+	}
+}
+
+func TestLogMetricTailOwner(t *testing.T) {
+	jobA := &logMetricMonitorNeObj{Path: "/var/log/app.log", ServiceGroup: "group_a"}
+	jobB := &logMetricMonitorNeObj{Path: "/var/log/app.log", ServiceGroup: "group_b"}
+	if jobA.tailOwner() == jobB.tailOwner() {
+		t.Errorf("same path in different service group should use different tail owner")
+	}
+}
+
+func TestLogFileTailerResume(t *testing.T) {
+	defer chLogTailTestDir(t)()
+	oldCatchUpBytes := *logTailMaxCatchUpBytes
+	*logTailMaxCatchUpBytes = 1024 * 1024
+	defer func() { *logTailMaxCatchUpBytes = oldCatchUpBytes }()
+	path, _ := filepath.Abs("app.log")
+	if err := ioutil.WriteFile(path, []byte("line1\nline2\nline3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(path)
+	logTailOffsets.set("test_resume", path, getLogTailFileId(info), 6)
+	tailer := newLogFileTailer("test_resume", path, false)
+	defer tailer.Stop()
+	for _, expect := range []string{"line2", "line3"} {
+		select {
+		case line := <-tailer.Lines:
+			if line.Text != expect {
+				t.Fatalf("resume line expect %s,get:%s", expect, line.Text)
+			}
+			line.commit()
+		case <-time.After(3 * time.Second):
+			t.Fatalf("wait line %s timeout", expect)
+		}
+	}
+	if latest := logTailOffsets.getLatest("test_resume", path); latest == nil || latest.Offset != 18 {
+		t.Errorf("offset after commit expect 18,get:%+v", latest)
+	}
+}
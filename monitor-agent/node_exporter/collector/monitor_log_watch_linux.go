@@ -0,0 +1,173 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"gopkg.in/fsnotify/fsnotify.v1"
+)
+
+const (
+	logWatchPollInterval  = 10 * time.Second
+	logWatchCheckInterval = time.Minute
+	logWatchGracePeriod   = 5 * 60
+)
+
+// logPathWatcher 通配路径的文件集合随时间变化(如按日期生成的日志),
+// 目录有文件新建/删除/改名时重新匹配,inotify 不可用时定时轮询;
+// 新匹配到的文件启动读取,文件消失超过宽限期后停止,期间旧句柄仍可以读完剩余内容
+type logPathWatcher struct {
+	Owner    string
+	Pattern  string
+	DataChan chan *logTailLine
+	files    map[string]*logWatchFileObj
+	stopChan chan int
+	doneChan chan int
+}
+
+type logWatchFileObj struct {
+	tailer   *logFileTailer
+	stopChan chan int
+	doneChan chan int
+}
+
+type logTailFileDebugObj struct {
+	Owner       string `json:"owner"`
+	Path        string `json:"path"`
+	Inode       uint64 `json:"inode"`
+	Offset      int64  `json:"offset"`
+	StartTime   int64  `json:"start_time"`
+	MissingTime int64  `json:"missing_time"`
+}
+
+func newLogPathWatcher(owner, pattern string, dataChan chan *logTailLine) *logPathWatcher {
+	w := &logPathWatcher{Owner: owner, Pattern: pattern, DataChan: dataChan, files: make(map[string]*logWatchFileObj), stopChan: make(chan int), doneChan: make(chan int)}
+	go w.run()
+	return w
+}
+
+func (w *logPathWatcher) Stop() {
+	close(w.stopChan)
+	<-w.doneChan
+}
+
+func (w *logPathWatcher) run() {
+	defer close(w.doneChan)
+	var events chan fsnotify.Event
+	var errors chan error
+	checkInterval := logWatchPollInterval
+	if watcher, err := fsnotify.NewWatcher(); err != nil {
+		level.Warn(monitorLogger).Log("log_watch -> inotify_unavailable", fmt.Sprintf("pattern:%s,error:%s", w.Pattern, err.Error()))
+	} else if err = watcher.Add(filepath.Dir(w.Pattern)); err != nil {
+		watcher.Close()
+		level.Warn(monitorLogger).Log("log_watch -> inotify_unavailable", fmt.Sprintf("pattern:%s,error:%s", w.Pattern, err.Error()))
+	} else {
+		defer watcher.Close()
+		events = watcher.Events
+		errors = watcher.Errors
+		checkInterval = logWatchCheckInterval
+	}
+	w.refresh(false)
+	t := time.NewTicker(checkInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-w.stopChan:
+			for path := range w.files {
+				w.stopFile(path)
+			}
+			return
+		case event := <-events:
+			if event.Op&(fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
+				w.refresh(true)
+			}
+		case err := <-errors:
+			level.Warn(monitorLogger).Log("log_watch -> inotify_error", fmt.Sprintf("pattern:%s,error:%v", w.Pattern, err))
+		case <-t.C:
+			w.refresh(true)
+		}
+	}
+}
+
+// refresh 首次匹配的文件与之前一样从末尾开始(有检查点时从检查点继续),之后新出现的文件从头读
+func (w *logPathWatcher) refresh(fromHead bool) {
+	matchMap := make(map[string]bool)
+	for _, path := range listMatchLogPath(w.Pattern) {
+		matchMap[path] = true
+		if fileObj, b := w.files[path]; b {
+			atomic.StoreInt64(&fileObj.tailer.missingTime, 0)
+			continue
+		}
+		w.startFile(path, fromHead)
+	}
+	nowTime := time.Now().Unix()
+	for path, fileObj := range w.files {
+		if matchMap[path] {
+			continue
+		}
+		missingTime := atomic.LoadInt64(&fileObj.tailer.missingTime)
+		if missingTime == 0 {
+			atomic.StoreInt64(&fileObj.tailer.missingTime, nowTime)
+			level.Info(monitorLogger).Log("log_watch -> file_missing", fmt.Sprintf("pattern:%s,path:%s", w.Pattern, path))
+			continue
+		}
+		if nowTime-missingTime >= logWatchGracePeriod {
+			w.stopFile(path)
+		}
+	}
+}
+
+func (w *logPathWatcher) startFile(path string, fromHead bool) {
+	level.Info(monitorLogger).Log("log_watch -> start_file", fmt.Sprintf("pattern:%s,path:%s", w.Pattern, path))
+	fileObj := &logWatchFileObj{tailer: newLogFileTailer(w.Owner, path, fromHead), stopChan: make(chan int), doneChan: make(chan int)}
+	w.files[path] = fileObj
+	go func() {
+		defer close(fileObj.doneChan)
+		for {
+			select {
+			case line := <-fileObj.tailer.Lines:
+				w.DataChan <- line
+			case <-fileObj.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// stopFile 还未转发的行没有提交检查点,再次采集时会重新读到
+func (w *logPathWatcher) stopFile(path string) {
+	fileObj := w.files[path]
+	close(fileObj.stopChan)
+	<-fileObj.doneChan
+	fileObj.tailer.Stop()
+	delete(w.files, path)
+	level.Info(monitorLogger).Log("log_watch -> stop_file", fmt.Sprintf("pattern:%s,path:%s", w.Pattern, path))
+}
+
+// LogTailFilesHttpHandle 查看当前正在读取的日志文件及已处理到的位置
+func LogTailFilesHttpHandle(w http.ResponseWriter, r *http.Request) {
+	result := []*logTailFileDebugObj{}
+	for _, t := range logTailOffsets.listTailers() {
+		debugObj := logTailFileDebugObj{Owner: t.Owner, Path: t.Path, StartTime: t.StartTime, MissingTime: atomic.LoadInt64(&t.missingTime)}
+		if checkpoint := logTailOffsets.getLatest(t.Owner, t.Path); checkpoint != nil {
+			debugObj.Inode = checkpoint.Inode
+			debugObj.Offset = checkpoint.Offset
+		}
+		result = append(result, &debugObj)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Owner != result[j].Owner {
+			return result[i].Owner < result[j].Owner
+		}
+		return result[i].Path < result[j].Path
+	})
+	b, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
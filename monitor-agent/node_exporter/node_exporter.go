@@ -199,6 +199,8 @@ func main() {
 	http.HandleFunc("/process/config", collector.ProcessHttpHandle)
 	// Add business monitor handle http config
 	http.HandleFunc("/log_metric/config", collector.LogMetricMonitorHttpHandle)
+	// Show the log files being tailed
+	http.HandleFunc("/log_tail/files", collector.LogTailFilesHttpHandle)
 
 	level.Info(logger).Log("msg", "Listening on", "address", *listenAddress)
 	server := &http.Server{Addr: *listenAddress}
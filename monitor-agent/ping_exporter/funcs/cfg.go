@@ -79,7 +79,20 @@ type MetricConfig struct {
 	HttpCheckCountNum     string `json:"http_check_count_num"`
 	HttpCheckCountSuccess string `json:"http_check_count_success"`
 	HttpCheckCountFail    string `json:"http_check_count_fail"`
+	HttpCheckAssert       string `json:"http_check_assert"`
+	HttpCheckUseTime      string `json:"http_check_use_time"`
+	HttpCheckResponseSize string `json:"http_check_response_size"`
+	HttpCheckCertExpire   string `json:"http_check_cert_expire"`
+	TcpAlive              string `json:"tcp_alive"`
+	TcpUseTime            string `json:"tcp_use_time"`
+	TlsAlive              string `json:"tls_alive"`
+	TlsUseTime            string `json:"tls_use_time"`
+	TlsVerify             string `json:"tls_verify"`
+	TlsCertExpire         string `json:"tls_cert_expire"`
+	DnsAlive              string `json:"dns_alive"`
+	DnsUseTime            string `json:"dns_use_time"`
 	PingLossPercent       string `json:"ping_loss_percent"`
+	ProbeLocationQuorum   string `json:"probe_location_quorum"`
 }
 
 type GlobalConfig struct {
@@ -95,6 +108,8 @@ type GlobalConfig struct {
 	Source           SourceConfig     `json:"source"`
 	Metrics          MetricConfig     `json:"metrics"`
 	HttpCheckTimeout int              `json:"http_check_timeout"`
+	ProbeEnable      bool             `json:"probe_enable"`
+	ProbeTimeout     int              `json:"probe_timeout"`
 }
 
 var (
@@ -130,6 +145,8 @@ func ParseConfig(cfg string) error {
 		log.Fatalln("parse config file:", cfg, "fail:", err)
 		return err
 	}
+	initHttpCheckMetricName(&c.Metrics)
+	initProbeMetricName(&c.Metrics)
 	lock.Lock()
 	defer lock.Unlock()
 	config = &c
@@ -137,6 +154,52 @@ func ParseConfig(cfg string) error {
 	return nil
 }
 
+// initHttpCheckMetricName 兼容没有配置 http 拨测扩展指标名的旧配置文件
+func initHttpCheckMetricName(c *MetricConfig) {
+	if c.HttpCheckAssert == "" {
+		c.HttpCheckAssert = "http_assert"
+	}
+	if c.HttpCheckUseTime == "" {
+		c.HttpCheckUseTime = "http_time"
+	}
+	if c.HttpCheckResponseSize == "" {
+		c.HttpCheckResponseSize = "http_response_size"
+	}
+	if c.HttpCheckCertExpire == "" {
+		c.HttpCheckCertExpire = "http_cert_expire_days"
+	}
+}
+
+func initProbeMetricName(c *MetricConfig) {
+	if c.TcpAlive == "" {
+		c.TcpAlive = "tcp_alive"
+	}
+	if c.TcpUseTime == "" {
+		c.TcpUseTime = "tcp_time"
+	}
+	if c.TlsAlive == "" {
+		c.TlsAlive = "tls_alive"
+	}
+	if c.TlsUseTime == "" {
+		c.TlsUseTime = "tls_time"
+	}
+	if c.TlsVerify == "" {
+		c.TlsVerify = "tls_verify"
+	}
+	if c.TlsCertExpire == "" {
+		c.TlsCertExpire = "tls_cert_expire_days"
+	}
+	if c.DnsAlive == "" {
+		c.DnsAlive = "dns_alive"
+	}
+	if c.DnsUseTime == "" {
+		c.DnsUseTime = "dns_time"
+	}
+	if c.ProbeLocationQuorum == "" {
+		c.ProbeLocationQuorum = "probe_location_quorum"
+	}
+}
+
 func Uuid() string {
 	commandName := "/usr/sbin/dmidecode"
 	params := []string{"|", "grep UUID"}
@@ -8,13 +8,14 @@ import (
 )
 
 type exportMetricObj struct {
-	Ip      string
-	Port    int
-	Url     string
-	Method  string
-	Value   int
-	Note    string
-	UseTime float64
+	Ip        string
+	Port      int
+	Url       string
+	Method    string
+	Value     int
+	Note      string
+	UseTime   float64
+	HttpCheck *HttpCheckObj
 }
 
 var (
@@ -26,6 +27,8 @@ var (
 	exportLossPingLock     = new(sync.RWMutex)
 	exportTelnetLock       = new(sync.RWMutex)
 	exportHttpCheckLock    = new(sync.RWMutex)
+	exportProbeResults     []*ProbeObj
+	exportProbeLock        = new(sync.RWMutex)
 )
 
 func UpdatePingExportMetric(result map[string]PingResultObj, successCount int) {
@@ -69,7 +72,7 @@ func UpdateHttpCheckExportMetric(result []*HttpCheckObj, successCount int) {
 	exportHttpCheckLock.Lock()
 	exportHttpCheckMetrics = make(map[string]*exportMetricObj)
 	for _, v := range result {
-		exportHttpCheckMetrics[v.Url] = &exportMetricObj{Ip: v.Url, Url: v.Url, Method: v.Method, Value: v.StatusCode, Note: fmt.Sprintf("# HELP http check target method %s url %s \n", v.Method, v.Url)}
+		exportHttpCheckMetrics[fmt.Sprintf("%s_%s", v.Method, v.Url)] = &exportMetricObj{Ip: v.Url, Url: v.Url, Method: v.Method, Value: v.StatusCode, Note: fmt.Sprintf("# HELP http check target method %s url %s \n", v.Method, v.Url), HttpCheck: v}
 	}
 	exportHttpCheckMetrics[Config().Metrics.HttpCheckCountNum] = &exportMetricObj{Ip: Config().Metrics.HttpCheckCountNum, Url: Config().Metrics.HttpCheckCountNum, Value: len(result), Note: "# HELP http check task num \n"}
 	exportHttpCheckMetrics[Config().Metrics.HttpCheckCountSuccess] = &exportMetricObj{Ip: Config().Metrics.HttpCheckCountSuccess, Url: Config().Metrics.HttpCheckCountSuccess, Value: successCount, Note: "# HELP http check success num \n"}
@@ -77,6 +80,15 @@ func UpdateHttpCheckExportMetric(result []*HttpCheckObj, successCount int) {
 	exportHttpCheckLock.Unlock()
 }
 
+func UpdateProbeExportMetric(result []*ProbeObj) {
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+	exportProbeLock.Lock()
+	exportProbeResults = result
+	exportProbeLock.Unlock()
+}
+
 func GetExportMetric() []byte {
 	var result []byte
 	guidMap := GetSourceGuidMap()
@@ -94,6 +106,11 @@ func GetExportMetric() []byte {
 		httpCheckByte := getHttpCheckExportMetric(guidMap)
 		result = append(result, httpCheckByte...)
 	}
+	if Config().ProbeEnable {
+		probeByte := getProbeExportMetric(guidMap)
+		result = append(result, probeByte...)
+	}
+	result = append(result, getProbeQuorumExportMetric()...)
 	return result
 }
 
@@ -192,7 +209,7 @@ func getHttpCheckExportMetric(guidMap map[string][]string) []byte {
 	var tmpExportMetric exportMetricList
 	exportHttpCheckLock.RLock()
 	for _, v := range exportHttpCheckMetrics {
-		tmpExportMetric = append(tmpExportMetric, &exportMetricObj{Url: v.Url, Method: v.Method, Ip: v.Ip, Value: v.Value, Note: v.Note})
+		tmpExportMetric = append(tmpExportMetric, &exportMetricObj{Url: v.Url, Method: v.Method, Ip: v.Ip, Value: v.Value, Note: v.Note, HttpCheck: v.HttpCheck})
 	}
 	exportHttpCheckLock.RUnlock()
 	sort.Sort(tmpExportMetric)
@@ -205,11 +222,107 @@ func getHttpCheckExportMetric(guidMap map[string][]string) []byte {
 		tmpMethodUrl := fmt.Sprintf("%s_%s", v.Method, v.Url)
 		if len(guidMap[tmpMethodUrl]) > 0 {
 			for _, vv := range guidMap[tmpMethodUrl] {
-				buff.WriteString(fmt.Sprintf("%s{url=\"%s\",method=\"%s\",guid=\"%s\"} %d \n", metricString, v.Url, v.Method, vv, v.Value))
+				tmpLabels := fmt.Sprintf("url=\"%s\",method=\"%s\",guid=\"%s\"", v.Url, v.Method, vv)
+				buff.WriteString(fmt.Sprintf("%s{%s} %d \n", metricString, tmpLabels, v.Value))
+				writeHttpCheckDetailMetric(&buff, tmpLabels, v.HttpCheck)
 			}
 		} else {
-			buff.WriteString(fmt.Sprintf("%s{url=\"%s\",method=\"%s\"} %d \n", metricString, v.Url, v.Method, v.Value))
+			tmpLabels := fmt.Sprintf("url=\"%s\",method=\"%s\"", v.Url, v.Method)
+			buff.WriteString(fmt.Sprintf("%s{%s} %d \n", metricString, tmpLabels, v.Value))
+			writeHttpCheckDetailMetric(&buff, tmpLabels, v.HttpCheck)
+		}
+	}
+	return buff.Bytes()
+}
+
+// writeHttpCheckDetailMetric 断言结果 0 -> 通过, 1 -> 失败,耗时按阶段分别输出,单位毫秒,证书剩余天数只有https才有
+func writeHttpCheckDetailMetric(buff *bytes.Buffer, labels string, result *HttpCheckObj) {
+	if result == nil {
+		return
+	}
+	assertValue := 1
+	if result.Success {
+		assertValue = 0
+	}
+	buff.WriteString(fmt.Sprintf("%s{%s} %d \n", Config().Metrics.HttpCheckAssert, labels, assertValue))
+	if result.StatusCode == 2 {
+		return
+	}
+	useTimeMetric := Config().Metrics.HttpCheckUseTime
+	buff.WriteString(fmt.Sprintf("%s{%s,phase=\"dns\"} %.3f \n", useTimeMetric, labels, result.DnsTime))
+	buff.WriteString(fmt.Sprintf("%s{%s,phase=\"connect\"} %.3f \n", useTimeMetric, labels, result.ConnectTime))
+	buff.WriteString(fmt.Sprintf("%s{%s,phase=\"tls\"} %.3f \n", useTimeMetric, labels, result.TlsTime))
+	buff.WriteString(fmt.Sprintf("%s{%s,phase=\"ttfb\"} %.3f \n", useTimeMetric, labels, result.FirstByteTime))
+	buff.WriteString(fmt.Sprintf("%s{%s,phase=\"total\"} %.3f \n", useTimeMetric, labels, result.TotalTime))
+	buff.WriteString(fmt.Sprintf("%s{%s} %d \n", Config().Metrics.HttpCheckResponseSize, labels, result.ResponseSize))
+	if result.HasCert {
+		buff.WriteString(fmt.Sprintf("%s{%s} %.3f \n", Config().Metrics.HttpCheckCertExpire, labels, result.CertExpireDays))
+	}
+}
+
+func getProbeExportMetric(guidMap map[string][]string) []byte {
+	var buff bytes.Buffer
+	buff.WriteString("# HELP probe check 0 -> alive, 1 -> dead, tls verify 0 -> cert chain valid, 1 -> invalid, use time unit ms \n")
+	exportProbeLock.RLock()
+	probeList := exportProbeResults
+	exportProbeLock.RUnlock()
+	for _, v := range probeList {
+		tmpLabels := fmt.Sprintf("target=\"%s\"", v.Target)
+		if v.Type == "dns" {
+			tmpLabels = fmt.Sprintf("target=\"%s\",server=\"%s\",record_type=\"%s\"", v.QueryName, v.Target, v.RecordType)
 		}
+		if len(guidMap[v.Key]) > 0 {
+			for _, vv := range guidMap[v.Key] {
+				writeProbeMetric(&buff, fmt.Sprintf("%s,guid=\"%s\"", tmpLabels, vv), v)
+			}
+		} else {
+			writeProbeMetric(&buff, tmpLabels, v)
+		}
+	}
+	return buff.Bytes()
+}
+
+func writeProbeMetric(buff *bytes.Buffer, labels string, result *ProbeObj) {
+	aliveValue := 1
+	if result.Success {
+		aliveValue = 0
+	}
+	switch result.Type {
+	case "tcp":
+		buff.WriteString(fmt.Sprintf("%s{%s} %d \n", Config().Metrics.TcpAlive, labels, aliveValue))
+		buff.WriteString(fmt.Sprintf("%s{%s} %.3f \n", Config().Metrics.TcpUseTime, labels, result.UseTime))
+	case "tls":
+		verifyValue := 1
+		if result.CertVerify {
+			verifyValue = 0
+		}
+		buff.WriteString(fmt.Sprintf("%s{%s} %d \n", Config().Metrics.TlsAlive, labels, aliveValue))
+		buff.WriteString(fmt.Sprintf("%s{%s} %.3f \n", Config().Metrics.TlsUseTime, labels, result.UseTime))
+		if result.HasCert {
+			buff.WriteString(fmt.Sprintf("%s{%s} %d \n", Config().Metrics.TlsVerify, labels, verifyValue))
+			buff.WriteString(fmt.Sprintf("%s{%s} %.3f \n", Config().Metrics.TlsCertExpire, labels, result.CertExpireDays))
+		}
+	case "dns":
+		buff.WriteString(fmt.Sprintf("%s{%s} %d \n", Config().Metrics.DnsAlive, labels, aliveValue))
+		buff.WriteString(fmt.Sprintf("%s{%s} %.3f \n", Config().Metrics.DnsUseTime, labels, result.UseTime))
+	}
+}
+
+// getProbeQuorumExportMetric 每个拨测点都会输出一份,服务端按 e_guid 取最大值后和失败的拨测点数比较
+func getProbeQuorumExportMetric() []byte {
+	quorumMap := GetSourceQuorumMap()
+	if len(quorumMap) == 0 {
+		return nil
+	}
+	var guidList []string
+	for k := range quorumMap {
+		guidList = append(guidList, k)
+	}
+	sort.Strings(guidList)
+	var buff bytes.Buffer
+	buff.WriteString("# HELP probe location quorum, alarm when fail location num reach it \n")
+	for _, v := range guidList {
+		buff.WriteString(fmt.Sprintf("%s{guid=\"%s\"} %d \n", Config().Metrics.ProbeLocationQuorum, v, quorumMap[v]))
 	}
 	return buff.Bytes()
 }
@@ -9,9 +9,61 @@ type TelnetObj struct {
 }
 
 type HttpCheckObj struct {
-	Method     string
-	Url        string
-	StatusCode int
+	Method         string
+	Url            string
+	StatusCode     int
+	Config         *HttpCheckConfigObj
+	Success        bool    // 状态码和断言都满足
+	DnsTime        float64 // 以下耗时单位毫秒
+	ConnectTime    float64
+	TlsTime        float64
+	FirstByteTime  float64
+	TotalTime      float64
+	ResponseSize   int64
+	CertExpireDays float64
+	HasCert        bool
+}
+
+// HttpCheckConfigObj 服务端 endpoint_http 上配置的请求和断言,为空时只检查状态码
+type HttpCheckConfigObj struct {
+	Headers       map[string]string `json:"headers"`
+	Body          string            `json:"body"`
+	AuthType      string            `json:"auth_type"` // basic | bearer
+	Username      string            `json:"username"`
+	Password      string            `json:"password"`
+	Token         string            `json:"token"`
+	ExpectStatus  string            `json:"expect_status"` // 200-299,301
+	BodyRegexp    string            `json:"body_regexp"`
+	JsonPath      string            `json:"json_path"` // $.data.list[0].status
+	JsonPathValue string            `json:"json_path_value"`
+	Redirect      string            `json:"redirect"` // follow | none
+	MaxRedirects  int               `json:"max_redirects"`
+}
+
+// ProbeObj tcp/tls/dns 拨测,Key 是采集源里的 tcp://ip:port tls://ip:port dns://ip:port/query_name/record_type
+type ProbeObj struct {
+	Key            string
+	Type           string
+	Target         string // ip:port,dns 时为 dns 服务器地址
+	QueryName      string
+	RecordType     string
+	Config         *ProbeConfigObj
+	Success        bool
+	UseTime        float64 // 毫秒,tcp 为建连耗时,tls 为建连加握手耗时,dns 为查询耗时
+	CertVerify     bool
+	CertExpireDays float64
+	HasCert        bool
+}
+
+// ProbeConfigObj 服务端 endpoint_probe 上的拨测配置
+type ProbeConfigObj struct {
+	Timeout            int    `json:"timeout"`
+	Send               string `json:"send"`
+	Expect             string `json:"expect"`
+	ServerName         string `json:"server_name"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+	QueryName          string `json:"query_name"`
+	RecordType         string `json:"record_type"`
 }
 
 func DebugLog(msg string, v ...interface{}) {
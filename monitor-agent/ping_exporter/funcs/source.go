@@ -18,6 +18,10 @@ var (
 	sourceLock      sync.RWMutex
 	sourceRemoteMap map[string][]string
 	sourceGuidLock  sync.RWMutex
+	sourceHttpMap   = make(map[string]*HttpCheckConfigObj)
+	sourceProbeMap  = make(map[string]*ProbeConfigObj)
+	sourceQuorumMap = make(map[string]int)
+	probeTypeList   = []string{"tcp", "tls", "dns"}
 )
 
 type RemoteResponse struct {
@@ -25,8 +29,11 @@ type RemoteResponse struct {
 }
 
 type PingExportSourceObj struct {
-	Ip   string `json:"ip"`
-	Guid string `json:"guid"`
+	Ip        string              `json:"ip"`
+	Guid      string              `json:"guid"`
+	HttpCheck *HttpCheckConfigObj `json:"http_check,omitempty"`
+	Probe     *ProbeConfigObj     `json:"probe,omitempty"`
+	Quorum    int                 `json:"quorum,omitempty"`
 }
 
 // Note: weight参数是为了在众多数据源中识别当前数据源的数据并更新,weight越小权重越高,各数据源之间的关系是并集
@@ -170,6 +177,11 @@ func UpdateSourceRemoteData(input []*PingExportSourceObj) {
 	}
 	sourceGuidLock.Lock()
 	for _, v := range input {
+		if isProbeSource(v.Ip) {
+			sourceProbeMap[v.Ip] = v.Probe
+		} else if strings.Contains(v.Ip, "http") {
+			sourceHttpMap[v.Ip] = v.HttpCheck
+		}
 		if _, b := sourceRemoteMap[v.Ip]; b {
 			existFlag := false
 			for _, vv := range sourceRemoteMap[v.Ip] {
@@ -188,6 +200,19 @@ func UpdateSourceRemoteData(input []*PingExportSourceObj) {
 	sourceGuidLock.Unlock()
 }
 
+// UpdateSourceQuorumData 拨测点数量只由监听推送的全量配置重新生成,取消多拨测点的对象不再输出,remote拉取的配置不带quorum不能调用
+func UpdateSourceQuorumData(input []*PingExportSourceObj) {
+	quorumMap := make(map[string]int)
+	for _, v := range input {
+		if v.Quorum > 0 {
+			quorumMap[v.Guid] = v.Quorum
+		}
+	}
+	sourceGuidLock.Lock()
+	sourceQuorumMap = quorumMap
+	sourceGuidLock.Unlock()
+}
+
 func GetIpList() []string {
 	var tmpList []string
 	sourceLock.RLock()
@@ -205,7 +230,7 @@ func GetTelnetList() []*TelnetObj {
 	var tmpList []*TelnetObj
 	sourceLock.RLock()
 	for k, _ := range sourceMap {
-		if strings.Contains(k, ":") && !strings.Contains(k, "http") {
+		if strings.Contains(k, ":") && !strings.Contains(k, "http") && !isProbeSource(k) {
 			tmpSplit := strings.Split(k, ":")
 			if len(tmpSplit) > 1 {
 				i, _ := strconv.Atoi(tmpSplit[1])
@@ -221,9 +246,11 @@ func GetTelnetList() []*TelnetObj {
 
 func GetHttpCheckList() []*HttpCheckObj {
 	var tmpHttpCheckList []*HttpCheckObj
+	sourceGuidLock.RLock()
+	defer sourceGuidLock.RUnlock()
 	sourceLock.RLock()
 	for k, _ := range sourceMap {
-		if strings.Contains(k, "http") {
+		if strings.Contains(k, "http") && !isProbeSource(k) {
 			tmpMethod := "GET"
 			tmpUrl := k
 			if strings.Contains(k, "_") {
@@ -234,13 +261,70 @@ func GetHttpCheckList() []*HttpCheckObj {
 				log.Printf("get http check list,url:%s is illegal", tmpUrl)
 				continue
 			}
-			tmpHttpCheckList = append(tmpHttpCheckList, &HttpCheckObj{Method: tmpMethod, Url: tmpUrl})
+			tmpHttpCheckList = append(tmpHttpCheckList, &HttpCheckObj{Method: tmpMethod, Url: tmpUrl, Config: sourceHttpMap[k]})
 		}
 	}
 	sourceLock.RUnlock()
 	return tmpHttpCheckList
 }
 
+func isProbeSource(key string) bool {
+	for _, v := range probeTypeList {
+		if strings.HasPrefix(key, v+"://") {
+			return true
+		}
+	}
+	return false
+}
+
+// GetProbeList 从 key 里解析类型和目标,文件配置的采集源没有拨测配置时按默认方式探测
+func GetProbeList() []*ProbeObj {
+	var tmpProbeList []*ProbeObj
+	sourceGuidLock.RLock()
+	defer sourceGuidLock.RUnlock()
+	sourceLock.RLock()
+	for k, _ := range sourceMap {
+		if !isProbeSource(k) {
+			continue
+		}
+		probeObj := ProbeObj{Key: k, Type: k[:strings.Index(k, "://")], Config: sourceProbeMap[k]}
+		tmpSplit := strings.Split(k[strings.Index(k, "://")+3:], "/")
+		probeObj.Target = tmpSplit[0]
+		if probeObj.Type == "dns" {
+			if len(tmpSplit) < 2 || tmpSplit[1] == "" {
+				log.Printf("get probe list,dns key:%s is illegal", k)
+				continue
+			}
+			probeObj.QueryName = tmpSplit[1]
+			probeObj.RecordType = "A"
+			if len(tmpSplit) > 2 && tmpSplit[2] != "" {
+				probeObj.RecordType = strings.ToUpper(tmpSplit[2])
+			}
+		}
+		if !strings.Contains(probeObj.Target, ":") {
+			log.Printf("get probe list,target:%s is illegal", probeObj.Target)
+			continue
+		}
+		if probeObj.Config == nil {
+			probeObj.Config = &ProbeConfigObj{}
+		}
+		tmpProbeList = append(tmpProbeList, &probeObj)
+	}
+	sourceLock.RUnlock()
+	return tmpProbeList
+}
+
 func GetSourceGuidMap() map[string][]string {
 	return sourceRemoteMap
 }
+
+// GetSourceQuorumMap 多拨测点的对象 guid -> 失败多少个拨测点才告警
+func GetSourceQuorumMap() map[string]int {
+	result := make(map[string]int)
+	sourceGuidLock.RLock()
+	for k, v := range sourceQuorumMap {
+		result[k] = v
+	}
+	sourceGuidLock.RUnlock()
+	return result
+}
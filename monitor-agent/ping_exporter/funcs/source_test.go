@@ -0,0 +1,91 @@
+package funcs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProbeQuorumExportMetric(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ping_exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfgPath := filepath.Join(dir, "cfg.json")
+	if err = ioutil.WriteFile(cfgPath, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = ParseConfig(cfgPath); err != nil {
+		t.Fatal(err)
+	}
+	sourceRemoteMap = make(map[string][]string)
+	pushData := []*PingExportSourceObj{
+		{Ip: "10.0.0.1", Guid: "host_b", Quorum: 2},
+		{Ip: "10.0.0.2", Guid: "host_a", Quorum: 1},
+		{Ip: "10.0.0.3", Guid: "host_c"},
+	}
+	UpdateSourceRemoteData(pushData)
+	UpdateSourceQuorumData(pushData)
+	metric := string(getProbeQuorumExportMetric())
+	expectLines := []string{"probe_location_quorum{guid=\"host_a\"} 1 ", "probe_location_quorum{guid=\"host_b\"} 2 "}
+	if !strings.Contains(metric, strings.Join(expectLines, "\n")) || strings.Contains(metric, "host_c") {
+		t.Errorf("quorum metric illegal:%s", metric)
+	}
+	// remote拉取的配置不带quorum,不能清掉推送的拨测点数量
+	UpdateSourceRemoteData([]*PingExportSourceObj{{Ip: "10.0.0.1", Guid: "host_b"}, {Ip: "10.0.0.4", Guid: "host_d"}})
+	if quorumMap := GetSourceQuorumMap(); len(quorumMap) != 2 || quorumMap["host_a"] != 1 || quorumMap["host_b"] != 2 {
+		t.Errorf("remote pull should keep pushed quorum:%v", quorumMap)
+	}
+	// 推送的配置里取消了多拨测点的对象不再输出
+	UpdateSourceQuorumData([]*PingExportSourceObj{{Ip: "10.0.0.1", Guid: "host_b"}, {Ip: "10.0.0.2", Guid: "host_a", Quorum: 3}})
+	if quorumMap := GetSourceQuorumMap(); len(quorumMap) != 1 || quorumMap["host_a"] != 3 {
+		t.Errorf("quorum map should rebuild from input:%v", quorumMap)
+	}
+	UpdateSourceQuorumData([]*PingExportSourceObj{{Ip: "10.0.0.1", Guid: "host_b"}})
+	if metric = string(getProbeQuorumExportMetric()); metric != "" {
+		t.Errorf("quorum metric should be empty:%s", metric)
+	}
+}
+
+func TestGetProbeList(t *testing.T) {
+	sourceLock.Lock()
+	sourceMap = map[string]int{
+		"10.0.0.1":                             1,
+		"http://10.0.0.1:8080/health":          1,
+		"tcp://10.0.0.2:6379":                  1,
+		"tls://10.0.0.3:443":                   1,
+		"dns://10.0.0.4:53/example.com/mx":     1,
+		"dns://10.0.0.5:53/example.com":        1,
+		"dns://10.0.0.6:53/":                   1,
+		"tcp://10.0.0.7":                       1,
+		"dns://10.0.0.8:53/example.com/TXT/xx": 1,
+	}
+	sourceLock.Unlock()
+	sourceGuidLock.Lock()
+	sourceProbeMap = map[string]*ProbeConfigObj{"tcp://10.0.0.2:6379": {Send: "PING\r\n", Expect: "PONG"}}
+	sourceGuidLock.Unlock()
+	expectMap := map[string]string{
+		"tcp://10.0.0.2:6379":                  "tcp 10.0.0.2:6379  ",
+		"tls://10.0.0.3:443":                   "tls 10.0.0.3:443  ",
+		"dns://10.0.0.4:53/example.com/mx":     "dns 10.0.0.4:53 example.com MX",
+		"dns://10.0.0.5:53/example.com":        "dns 10.0.0.5:53 example.com A",
+		"dns://10.0.0.8:53/example.com/TXT/xx": "dns 10.0.0.8:53 example.com TXT",
+	}
+	probeList := GetProbeList()
+	if len(probeList) != len(expectMap) {
+		t.Errorf("probe list length should be %d,get:%d", len(expectMap), len(probeList))
+	}
+	for _, v := range probeList {
+		if result := strings.Join([]string{v.Type, v.Target, v.QueryName, v.RecordType}, " "); result != expectMap[v.Key] {
+			t.Errorf("probe key %s parse result should be %q,get:%q", v.Key, expectMap[v.Key], result)
+		}
+		if v.Config == nil {
+			t.Errorf("probe key %s config should not be nil", v.Key)
+		} else if v.Key == "tcp://10.0.0.2:6379" && v.Config.Expect != "PONG" {
+			t.Errorf("probe key %s config should come from source data", v.Key)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package http_check
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"github.com/WeBankPartners/open-monitor/monitor-agent/ping_exporter/funcs"
+	"net/http/httptrace"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var jsonPathTokenReg = regexp.MustCompile(`\.([^.\[\]]+)|\[(\d+)\]`)
+
+// httpCheckTimer 记录各阶段耗时,双栈地址会并发建连所以要加锁;有跳转时取最后一次请求的阶段耗时
+type httpCheckTimer struct {
+	lock          sync.Mutex
+	startTime     time.Time
+	dnsStart      time.Time
+	connectStart  time.Time
+	tlsStart      time.Time
+	dnsTime       float64
+	connectTime   float64
+	tlsTime       float64
+	firstByteTime float64
+}
+
+func newHttpCheckTimer() *httpCheckTimer {
+	return &httpCheckTimer{startTime: time.Now()}
+}
+
+func sinceMillisecond(t time.Time) float64 {
+	return float64(time.Since(t).Nanoseconds()) / 1e6
+}
+
+func (t *httpCheckTimer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(_ httptrace.DNSStartInfo) {
+			t.lock.Lock()
+			t.dnsStart = time.Now()
+			t.lock.Unlock()
+		},
+		DNSDone: func(_ httptrace.DNSDoneInfo) {
+			t.lock.Lock()
+			t.dnsTime = sinceMillisecond(t.dnsStart)
+			t.lock.Unlock()
+		},
+		ConnectStart: func(_, _ string) {
+			t.lock.Lock()
+			t.connectStart = time.Now()
+			t.lock.Unlock()
+		},
+		ConnectDone: func(_, _ string, err error) {
+			t.lock.Lock()
+			if err == nil {
+				t.connectTime = sinceMillisecond(t.connectStart)
+			}
+			t.lock.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			t.lock.Lock()
+			t.tlsStart = time.Now()
+			t.lock.Unlock()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, _ error) {
+			t.lock.Lock()
+			t.tlsTime = sinceMillisecond(t.tlsStart)
+			t.lock.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.lock.Lock()
+			t.firstByteTime = sinceMillisecond(t.startTime)
+			t.lock.Unlock()
+		},
+	}
+}
+
+// fill 读完响应体后调用,total 包含读取响应体的时间
+func (t *httpCheckTimer) fill(result *funcs.HttpCheckObj) {
+	t.lock.Lock()
+	result.DnsTime = t.dnsTime
+	result.ConnectTime = t.connectTime
+	result.TlsTime = t.tlsTime
+	result.FirstByteTime = t.firstByteTime
+	t.lock.Unlock()
+	result.TotalTime = sinceMillisecond(t.startTime)
+}
+
+// matchHttpCheckStatus expect 为空时和原来一样 2xx 算成功,格式 200-299,301
+func matchHttpCheckStatus(statusCode int, expect string) bool {
+	if strings.TrimSpace(expect) == "" {
+		return statusCode >= 200 && statusCode < 300
+	}
+	for _, v := range strings.Split(expect, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		startStatus, endStatus := v, v
+		if strings.Contains(v, "-") {
+			startStatus = strings.TrimSpace(v[:strings.Index(v, "-")])
+			endStatus = strings.TrimSpace(v[strings.Index(v, "-")+1:])
+		}
+		start, startErr := strconv.Atoi(startStatus)
+		end, endErr := strconv.Atoi(endStatus)
+		if startErr != nil || endErr != nil {
+			continue
+		}
+		if statusCode >= start && statusCode <= end {
+			return true
+		}
+	}
+	return false
+}
+
+func assertHttpCheckBody(body []byte, config *funcs.HttpCheckConfigObj) error {
+	if config.BodyRegexp != "" {
+		reg, err := regexp.Compile(config.BodyRegexp)
+		if err != nil {
+			return fmt.Errorf("body regexp %s illegal,%s", config.BodyRegexp, err.Error())
+		}
+		if !reg.Match(body) {
+			return fmt.Errorf("body not match regexp %s", config.BodyRegexp)
+		}
+	}
+	if config.JsonPath != "" {
+		var data interface{}
+		if err := json.Unmarshal(body, &data); err != nil {
+			return fmt.Errorf("body is not json,%s", err.Error())
+		}
+		value, ok := getJsonPathValue(data, config.JsonPath)
+		if !ok {
+			return fmt.Errorf("json path %s not found", config.JsonPath)
+		}
+		if config.JsonPathValue != "" {
+			valueString, isString := value.(string)
+			if !isString {
+				b, _ := json.Marshal(value)
+				valueString = string(b)
+			}
+			if valueString != config.JsonPathValue {
+				return fmt.Errorf("json path %s value %s not equal %s", config.JsonPath, valueString, config.JsonPathValue)
+			}
+		}
+	}
+	return nil
+}
+
+// getJsonPathValue 支持 $.a.b[0].c 这种对象字段和数组下标的写法,路径中有无法解析的部分时认为找不到
+func getJsonPathValue(data interface{}, path string) (interface{}, bool) {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+	tokenList := jsonPathTokenReg.FindAllStringSubmatch(path, -1)
+	tokenLength := 0
+	for _, token := range tokenList {
+		tokenLength += len(token[0])
+	}
+	if tokenLength != len(path) {
+		return nil, false
+	}
+	for _, token := range tokenList {
+		if token[1] != "" {
+			object, ok := data.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if data, ok = object[token[1]]; !ok {
+				return nil, false
+			}
+			continue
+		}
+		array, ok := data.([]interface{})
+		index, _ := strconv.Atoi(token[2])
+		if !ok || index >= len(array) {
+			return nil, false
+		}
+		data = array[index]
+	}
+	return data, true
+}
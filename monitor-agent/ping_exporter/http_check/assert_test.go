@@ -0,0 +1,128 @@
+package http_check
+
+import (
+	"github.com/WeBankPartners/open-monitor/monitor-agent/ping_exporter/funcs"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchHttpCheckStatus(t *testing.T) {
+	testCases := []struct {
+		statusCode int
+		expect     string
+		result     bool
+	}{
+		{200, "", true},
+		{204, " ", true},
+		{301, "", false},
+		{301, "200-299,301", true},
+		{302, "200-299,301", false},
+		{404, "404", true},
+		{500, " 400 - 599 ", true},
+		{200, "abc,2xx", false},
+	}
+	for _, v := range testCases {
+		if matchHttpCheckStatus(v.statusCode, v.expect) != v.result {
+			t.Errorf("status %d expect %s match should be %t", v.statusCode, v.expect, v.result)
+		}
+	}
+}
+
+func TestGetJsonPathValue(t *testing.T) {
+	body := `{"data":{"list":[{"status":"UP"},{"status":"DOWN","code":0}]},"ok":true}`
+	testCases := []struct {
+		path   string
+		value  string
+		result bool
+	}{
+		{"$.data.list[0].status", "UP", true},
+		{"$.data.list[1].code", "0", true},
+		{".ok", "true", true},
+		{"$.data.list[2].status", "", false},
+		{"$.data.none", "", false},
+		{"$.ok.status", "", false},
+		{"$.data[0]", "", false},
+		{"$data", "", false},
+	}
+	for _, v := range testCases {
+		err := assertHttpCheckBody([]byte(body), &funcs.HttpCheckConfigObj{JsonPath: v.path, JsonPathValue: v.value})
+		if (err == nil) != v.result {
+			t.Errorf("json path %s value %s assert result should be %t,err:%v", v.path, v.value, v.result, err)
+		}
+	}
+	if err := assertHttpCheckBody([]byte(body), &funcs.HttpCheckConfigObj{JsonPath: "$.data.list[1].status", JsonPathValue: "UP"}); err == nil {
+		t.Errorf("json path value not equal should fail")
+	}
+	if err := assertHttpCheckBody([]byte("not json"), &funcs.HttpCheckConfigObj{JsonPath: "$.data"}); err == nil {
+		t.Errorf("json path on non json body should fail")
+	}
+}
+
+func TestAssertHttpCheckBodyRegexp(t *testing.T) {
+	body := []byte(`{"status":"UP","version":"1.2.3"}`)
+	if err := assertHttpCheckBody(body, &funcs.HttpCheckConfigObj{BodyRegexp: `"version":"1\.\d+`}); err != nil {
+		t.Errorf("body regexp should match,%v", err)
+	}
+	if err := assertHttpCheckBody(body, &funcs.HttpCheckConfigObj{BodyRegexp: "DOWN"}); err == nil {
+		t.Errorf("body regexp not match should fail")
+	}
+	if err := assertHttpCheckBody(body, &funcs.HttpCheckConfigObj{BodyRegexp: "(["}); err == nil {
+		t.Errorf("illegal body regexp should fail")
+	}
+	if err := assertHttpCheckBody(body, &funcs.HttpCheckConfigObj{}); err != nil {
+		t.Errorf("empty assert config should pass,%v", err)
+	}
+}
+
+func initHttpCheckTestConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ping_exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfgPath := filepath.Join(dir, "cfg.json")
+	if err = ioutil.WriteFile(cfgPath, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = funcs.ParseConfig(cfgPath); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDoHttpCheckNew(t *testing.T) {
+	initHttpCheckTestConfig(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "/health", http.StatusFound)
+		case "/health":
+			if r.Header.Get("Authorization") != "Bearer abc" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"status":"UP"}`))
+		}
+	}))
+	defer server.Close()
+	httpClient := buildHttpClient()
+	config := &funcs.HttpCheckConfigObj{AuthType: "bearer", Token: "abc", JsonPath: "$.status", JsonPathValue: "UP"}
+	result := doHttpCheckNew(&funcs.HttpCheckObj{Method: "GET", Url: server.URL + "/redirect", Config: config}, httpClient)
+	if !result.Success || result.StatusCode != 200 || result.ResponseSize != 15 || result.TotalTime <= 0 {
+		t.Errorf("follow redirect check illegal:%+v", result)
+	}
+	// 不跟随跳转时用 3xx 响应做断言
+	noRedirectConfig := &funcs.HttpCheckConfigObj{Redirect: "none", ExpectStatus: "302"}
+	if result = doHttpCheckNew(&funcs.HttpCheckObj{Method: "GET", Url: server.URL + "/redirect", Config: noRedirectConfig}, httpClient); !result.Success || result.StatusCode != 302 {
+		t.Errorf("no redirect check illegal:%+v", result)
+	}
+	if result = doHttpCheckNew(&funcs.HttpCheckObj{Method: "GET", Url: server.URL + "/health"}, httpClient); result.Success || result.StatusCode != 401 {
+		t.Errorf("check without auth should fail:%+v", result)
+	}
+	if result = doHttpCheckNew(&funcs.HttpCheckObj{Method: "GET", Url: "http://127.0.0.1:1/health"}, httpClient); result.Success || result.StatusCode != 2 {
+		t.Errorf("request error check status should be 2:%+v", result)
+	}
+}
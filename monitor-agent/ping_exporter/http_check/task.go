@@ -3,8 +3,11 @@ package http_check
 import (
 	"crypto/tls"
 	"github.com/WeBankPartners/open-monitor/monitor-agent/ping_exporter/funcs"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"strings"
 	"sync"
@@ -18,6 +21,9 @@ var (
 	httpCheckTimeOut    = 10
 )
 
+// 断言只取响应的前4M,超过的部分只计算大小
+const httpCheckMaxBodySize = 4 * 1024 * 1024
+
 func StartHttpCheckTask() {
 	interval := funcs.Config().Interval
 	if interval < 30 {
@@ -34,6 +40,7 @@ func StartHttpCheckTask() {
 	}
 }
 
+// buildHttpClient 每次拨测都新建连接,保证 dns/connect/tls 各阶段耗时都能测到
 func buildHttpClient() *http.Client {
 	var proxy func(*http.Request) (*url.URL, error) = nil
 	if funcs.Config().HttpProxyEnable {
@@ -42,8 +49,9 @@ func buildHttpClient() *http.Client {
 		}
 	}
 	transport := &http.Transport{
-		Proxy:           proxy,
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		Proxy:             proxy,
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		DisableKeepAlives: true,
 	}
 	client := &http.Client{Transport: transport, Timeout: time.Duration(httpCheckTimeOut) * time.Second}
 	return client
@@ -60,13 +68,13 @@ func httpCheckTask() {
 	//var successCounter int
 	for _, v := range httpCheckList {
 		wg.Add(1)
-		go func(method string, url string) {
+		go func(check *funcs.HttpCheckObj) {
 			//b := doHttpCheck(method, url)
-			b := doHttpCheckNew(method, url, httpClient)
-			writeHttpCheckResult(method, url, b)
-			funcs.DebugLog("http check %s:%s result %d ", method, url, b)
+			result := doHttpCheckNew(check, httpClient)
+			writeHttpCheckResult(result)
+			funcs.DebugLog("http check %s:%s result %d success %t use time %.3f ms", result.Method, result.Url, result.StatusCode, result.Success, result.TotalTime)
 			wg.Done()
-		}(v.Method, v.Url)
+		}(v)
 	}
 	wg.Wait()
 	endTime := time.Now()
@@ -94,10 +102,10 @@ func doHttpCheck(method, url string) int {
 	return resp.StatusCode
 }
 
-func doHttpCheckNew(method, url string, httpClient *http.Client) int {
-	var resp *http.Response
-	var err error
-	body := strings.NewReader("")
+// doHttpCheckNew 请求失败时状态码为2,有响应时记录状态码、各阶段耗时、响应大小和证书剩余天数,再按配置做断言
+func doHttpCheckNew(check *funcs.HttpCheckObj, httpClient *http.Client) *funcs.HttpCheckObj {
+	result := &funcs.HttpCheckObj{Method: check.Method, Url: check.Url, Config: check.Config, StatusCode: 2}
+	method, url := check.Method, check.Url
 	methodIllegal := true
 	for _, v := range httpMethodList {
 		if v == method {
@@ -107,32 +115,83 @@ func doHttpCheckNew(method, url string, httpClient *http.Client) int {
 	}
 	if methodIllegal {
 		log.Printf("do http check -> Not support method:%s \n", method)
-		return 2
+		return result
 	}
-
-	req, err := http.NewRequest(strings.ToUpper(method), url, body)
+	config := check.Config
+	if config == nil {
+		config = &funcs.HttpCheckConfigObj{}
+	}
+	req, err := http.NewRequest(strings.ToUpper(method), url, strings.NewReader(config.Body))
 	if err != nil {
 		log.Printf("do http check -> method:%s url:%s new request error: %v \n", method, url, err)
-		return 2
+		return result
 	}
 	req.Header.Set("Content-Type", "application/json")
-	resp, err = httpClient.Do(req)
-
+	for k, v := range config.Headers {
+		req.Header.Set(k, v)
+	}
+	switch config.AuthType {
+	case "basic":
+		req.SetBasicAuth(config.Username, config.Password)
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+config.Token)
+	}
+	timer := newHttpCheckTimer()
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), timer.clientTrace()))
+	resp, err := buildRedirectClient(httpClient, config).Do(req)
 	if err != nil {
 		log.Printf("do http check -> method:%s url:%s response error: %v \n", method, url, err)
-		return 2
+		return result
 	}
-	if resp.Body != nil {
-		resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, httpCheckMaxBodySize))
+	result.ResponseSize = int64(len(body))
+	if err == nil {
+		discardSize, _ := io.Copy(ioutil.Discard, resp.Body)
+		result.ResponseSize += discardSize
 	}
-	return resp.StatusCode
+	resp.Body.Close()
+	timer.fill(result)
+	result.StatusCode = resp.StatusCode
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		result.HasCert = true
+		result.CertExpireDays = resp.TLS.PeerCertificates[0].NotAfter.Sub(time.Now()).Hours() / 24
+	}
+	if err != nil {
+		log.Printf("do http check -> method:%s url:%s read body error: %v \n", method, url, err)
+		return result
+	}
+	if !matchHttpCheckStatus(result.StatusCode, config.ExpectStatus) {
+		funcs.DebugLog("do http check -> method:%s url:%s status %d not match expect:%s", method, url, result.StatusCode, config.ExpectStatus)
+		return result
+	}
+	if assertErr := assertHttpCheckBody(body, config); assertErr != nil {
+		funcs.DebugLog("do http check -> method:%s url:%s assert fail: %s", method, url, assertErr.Error())
+		return result
+	}
+	result.Success = true
+	return result
 }
 
-func writeHttpCheckResult(method, url string, statusCode int) {
+// buildRedirectClient none 时不跟随跳转,直接拿 3xx 响应做断言;超过最大跳转次数时同样取最后一个响应
+func buildRedirectClient(httpClient *http.Client, config *funcs.HttpCheckConfigObj) *http.Client {
+	if config.Redirect != "none" && config.MaxRedirects <= 0 {
+		return httpClient
+	}
+	client := *httpClient
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if config.Redirect == "none" || len(via) >= config.MaxRedirects {
+			return http.ErrUseLastResponse
+		}
+		return nil
+	}
+	return &client
+}
+
+func writeHttpCheckResult(result *funcs.HttpCheckObj) {
 	resultLock.Lock()
-	for _, v := range httpCheckResultList {
-		if v.Method == method && v.Url == url {
-			v.StatusCode = statusCode
+	for i, v := range httpCheckResultList {
+		if v.Method == result.Method && v.Url == result.Url {
+			httpCheckResultList[i] = result
 			break
 		}
 	}
@@ -143,7 +202,7 @@ func clearHttpCheckResult(param []*funcs.HttpCheckObj) {
 	resultLock.Lock()
 	httpCheckResultList = []*funcs.HttpCheckObj{}
 	for _, v := range param {
-		httpCheckResultList = append(httpCheckResultList, &funcs.HttpCheckObj{Method: v.Method, Url: v.Url, StatusCode: 2})
+		httpCheckResultList = append(httpCheckResultList, &funcs.HttpCheckObj{Method: v.Method, Url: v.Url, Config: v.Config, StatusCode: 2})
 	}
 	resultLock.Unlock()
 }
@@ -151,10 +210,11 @@ func clearHttpCheckResult(param []*funcs.HttpCheckObj) {
 func getHttpCheckResult() (result []*funcs.HttpCheckObj, successCount int) {
 	resultLock.RLock()
 	for _, v := range httpCheckResultList {
-		if v.StatusCode >= 200 && v.StatusCode < 300 {
+		if v.Success {
 			successCount += 1
 		}
-		result = append(result, &funcs.HttpCheckObj{Method: v.Method, Url: v.Url, StatusCode: v.StatusCode})
+		tmpResult := *v
+		result = append(result, &tmpResult)
 	}
 	resultLock.RUnlock()
 	return result, successCount
@@ -54,6 +54,7 @@ func handleIpSource(w http.ResponseWriter,r *http.Request)  {
 	}
 	funcs.UpdateIpList(ips, funcs.Config().Source.Listen.Weight)
 	funcs.UpdateSourceRemoteData(param.Config)
+	funcs.UpdateSourceQuorumData(param.Config)
 	saveHttpConfigData(b)
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("success"))
@@ -86,4 +87,5 @@ func loadHttpConfigData()  {
 	}
 	funcs.UpdateIpList(ips, funcs.Config().Source.Listen.Weight)
 	funcs.UpdateSourceRemoteData(param.Config)
+	funcs.UpdateSourceQuorumData(param.Config)
 }
\ No newline at end of file
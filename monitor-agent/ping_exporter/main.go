@@ -7,6 +7,7 @@ import (
 	"log"
 	"github.com/WeBankPartners/open-monitor/monitor-agent/ping_exporter/telnet"
 	"github.com/WeBankPartners/open-monitor/monitor-agent/ping_exporter/http_check"
+	"github.com/WeBankPartners/open-monitor/monitor-agent/ping_exporter/probe"
 )
 
 func main() {
@@ -18,7 +19,7 @@ func main() {
 		log.Println("parse config fail,stop now...")
 		return
 	}
-	if !funcs.Config().PingEnable && !funcs.Config().TelnetEnable && !funcs.Config().HttpCheckEnable {
+	if !funcs.Config().PingEnable && !funcs.Config().TelnetEnable && !funcs.Config().HttpCheckEnable && !funcs.Config().ProbeEnable {
 		return
 	}
 	icmpping.TestModel = *isTest
@@ -33,5 +34,8 @@ func main() {
 	if funcs.Config().HttpCheckEnable {
 		go http_check.StartHttpCheckTask()
 	}
+	if funcs.Config().ProbeEnable {
+		go probe.StartProbeTask()
+	}
 	select {}
 }
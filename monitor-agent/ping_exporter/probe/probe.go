@@ -0,0 +1,261 @@
+package probe
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/WeBankPartners/open-monitor/monitor-agent/ping_exporter/funcs"
+	"golang.org/x/net/dns/dnsmessage"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 校验返回内容时最多读取的字节数
+const probeMaxReadSize = 64 * 1024
+
+func doTcpProbe(probeObj *funcs.ProbeObj) {
+	timeout := getProbeTimeout(probeObj.Config)
+	startTime := time.Now()
+	conn, err := net.DialTimeout("tcp", probeObj.Target, timeout)
+	if err != nil {
+		log.Printf("do tcp probe -> target:%s connect error: %v \n", probeObj.Target, err)
+		return
+	}
+	probeObj.UseTime = sinceMillisecond(startTime)
+	defer conn.Close()
+	conn.SetDeadline(startTime.Add(timeout))
+	if err = sendAndExpect(conn, probeObj.Config); err != nil {
+		log.Printf("do tcp probe -> target:%s %s \n", probeObj.Target, err.Error())
+		return
+	}
+	probeObj.Success = true
+}
+
+// doTlsProbe 先不校验证书完成握手,拿到证书链后再单独校验,这样证书有问题时也能输出过期天数
+func doTlsProbe(probeObj *funcs.ProbeObj) {
+	config := probeObj.Config
+	timeout := getProbeTimeout(config)
+	serverName := config.ServerName
+	if serverName == "" {
+		serverName, _, _ = net.SplitHostPort(probeObj.Target)
+	}
+	startTime := time.Now()
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", probeObj.Target, &tls.Config{ServerName: serverName, InsecureSkipVerify: true})
+	if err != nil {
+		log.Printf("do tls probe -> target:%s handshake error: %v \n", probeObj.Target, err)
+		return
+	}
+	probeObj.UseTime = sinceMillisecond(startTime)
+	defer conn.Close()
+	certList := conn.ConnectionState().PeerCertificates
+	if len(certList) == 0 {
+		log.Printf("do tls probe -> target:%s no peer certificate \n", probeObj.Target)
+		return
+	}
+	probeObj.HasCert = true
+	expireTime := certList[0].NotAfter
+	intermediates := x509.NewCertPool()
+	for _, v := range certList[1:] {
+		intermediates.AddCert(v)
+		if v.NotAfter.Before(expireTime) {
+			expireTime = v.NotAfter
+		}
+	}
+	probeObj.CertExpireDays = expireTime.Sub(time.Now()).Hours() / 24
+	_, verifyErr := certList[0].Verify(x509.VerifyOptions{DNSName: serverName, Intermediates: intermediates})
+	probeObj.CertVerify = verifyErr == nil
+	if verifyErr != nil && !config.InsecureSkipVerify {
+		log.Printf("do tls probe -> target:%s verify certificate error: %v \n", probeObj.Target, verifyErr)
+		return
+	}
+	conn.SetDeadline(startTime.Add(timeout))
+	if err = sendAndExpect(conn, config); err != nil {
+		log.Printf("do tls probe -> target:%s %s \n", probeObj.Target, err.Error())
+		return
+	}
+	probeObj.Success = true
+}
+
+// sendAndExpect 没有配置 send 时直接读服务端主动发的 banner(如 smtp),读到匹配 expect 的内容或超时为止
+func sendAndExpect(conn net.Conn, config *funcs.ProbeConfigObj) error {
+	if config.Send != "" {
+		if _, err := conn.Write([]byte(config.Send)); err != nil {
+			return fmt.Errorf("send error: %v", err)
+		}
+	}
+	if config.Expect == "" {
+		return nil
+	}
+	expectReg, err := regexp.Compile(config.Expect)
+	if err != nil {
+		return fmt.Errorf("expect regexp %s illegal: %v", config.Expect, err)
+	}
+	var received []byte
+	buff := make([]byte, 1024)
+	for len(received) < probeMaxReadSize {
+		n, readErr := conn.Read(buff)
+		received = append(received, buff[:n]...)
+		if expectReg.Match(received) {
+			return nil
+		}
+		if readErr != nil {
+			return fmt.Errorf("response %q not match expect %s: %v", received, config.Expect, readErr)
+		}
+	}
+	return fmt.Errorf("response not match expect %s in %d bytes", config.Expect, probeMaxReadSize)
+}
+
+// doDnsProbe 直接向 Target 指定的 dns 服务器发查询报文,不经过 /etc/hosts 和 resolv.conf 的 search 列表,
+// 有解析记录且任意一条匹配 expect 时成功
+func doDnsProbe(probeObj *funcs.ProbeObj) {
+	timeout := getProbeTimeout(probeObj.Config)
+	startTime := time.Now()
+	records, err := lookupDnsRecord(probeObj.Target, probeObj.QueryName, probeObj.RecordType, startTime.Add(timeout))
+	probeObj.UseTime = sinceMillisecond(startTime)
+	if err != nil {
+		log.Printf("do dns probe -> server:%s name:%s type:%s lookup error: %v \n", probeObj.Target, probeObj.QueryName, probeObj.RecordType, err)
+		return
+	}
+	if len(records) == 0 {
+		log.Printf("do dns probe -> server:%s name:%s type:%s no record \n", probeObj.Target, probeObj.QueryName, probeObj.RecordType)
+		return
+	}
+	if probeObj.Config.Expect != "" {
+		expectReg, regErr := regexp.Compile(probeObj.Config.Expect)
+		if regErr != nil {
+			log.Printf("do dns probe -> expect regexp %s illegal: %v \n", probeObj.Config.Expect, regErr)
+			return
+		}
+		matched := false
+		for _, v := range records {
+			if expectReg.MatchString(v) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			log.Printf("do dns probe -> server:%s name:%s records %v not match expect %s \n", probeObj.Target, probeObj.QueryName, records, probeObj.Config.Expect)
+			return
+		}
+	}
+	probeObj.Success = true
+}
+
+var dnsRecordTypeMap = map[string]dnsmessage.Type{
+	"A":     dnsmessage.TypeA,
+	"AAAA":  dnsmessage.TypeAAAA,
+	"CNAME": dnsmessage.TypeCNAME,
+	"MX":    dnsmessage.TypeMX,
+	"NS":    dnsmessage.TypeNS,
+	"TXT":   dnsmessage.TypeTXT,
+}
+
+// lookupDnsRecord 查询的域名按绝对域名处理,udp 返回被截断时改用 tcp 重查
+func lookupDnsRecord(server, name, recordType string, deadline time.Time) (result []string, err error) {
+	queryType, b := dnsRecordTypeMap[recordType]
+	if !b {
+		return nil, fmt.Errorf("record type %s not support", recordType)
+	}
+	if !strings.HasSuffix(name, ".") {
+		name = name + "."
+	}
+	queryName, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, fmt.Errorf("query name %s illegal: %v", name, err)
+	}
+	queryId := uint16(rand.Intn(65536))
+	queryMsg := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: queryId, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: queryName, Type: queryType, Class: dnsmessage.ClassINET}},
+	}
+	queryData, err := queryMsg.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("pack query message error: %v", err)
+	}
+	respData, err := exchangeDnsMessage("udp", server, queryData, deadline)
+	if err != nil {
+		return
+	}
+	var parser dnsmessage.Parser
+	respHeader, err := parser.Start(respData)
+	if err == nil && respHeader.Truncated {
+		if respData, err = exchangeDnsMessage("tcp", server, queryData, deadline); err != nil {
+			return
+		}
+		respHeader, err = parser.Start(respData)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse response message error: %v", err)
+	}
+	if respHeader.ID != queryId {
+		return nil, fmt.Errorf("response id %d not match query id %d", respHeader.ID, queryId)
+	}
+	if respHeader.RCode != dnsmessage.RCodeSuccess {
+		return nil, fmt.Errorf("response code %s", respHeader.RCode.String())
+	}
+	if err = parser.SkipAllQuestions(); err != nil {
+		return nil, fmt.Errorf("parse response question error: %v", err)
+	}
+	answers, err := parser.AllAnswers()
+	if err != nil {
+		return nil, fmt.Errorf("parse response answer error: %v", err)
+	}
+	for _, v := range answers {
+		if v.Header.Type != queryType {
+			continue
+		}
+		switch body := v.Body.(type) {
+		case *dnsmessage.AResource:
+			result = append(result, net.IP(body.A[:]).String())
+		case *dnsmessage.AAAAResource:
+			result = append(result, net.IP(body.AAAA[:]).String())
+		case *dnsmessage.CNAMEResource:
+			result = append(result, body.CNAME.String())
+		case *dnsmessage.MXResource:
+			result = append(result, fmt.Sprintf("%d %s", body.Pref, body.MX.String()))
+		case *dnsmessage.NSResource:
+			result = append(result, body.NS.String())
+		case *dnsmessage.TXTResource:
+			result = append(result, strings.Join(body.TXT, ""))
+		}
+	}
+	return
+}
+
+// exchangeDnsMessage tcp 报文前面带两个字节的长度
+func exchangeDnsMessage(network, server string, queryData []byte, deadline time.Time) (respData []byte, err error) {
+	conn, err := net.DialTimeout(network, server, time.Until(deadline))
+	if err != nil {
+		return nil, fmt.Errorf("connect %s %s error: %v", network, server, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(deadline)
+	if network == "tcp" {
+		queryData = append([]byte{byte(len(queryData) >> 8), byte(len(queryData))}, queryData...)
+	}
+	if _, err = conn.Write(queryData); err != nil {
+		return nil, fmt.Errorf("send query to %s error: %v", server, err)
+	}
+	if network == "tcp" {
+		lengthData := make([]byte, 2)
+		if _, err = io.ReadFull(conn, lengthData); err != nil {
+			return nil, fmt.Errorf("read response from %s error: %v", server, err)
+		}
+		respData = make([]byte, int(lengthData[0])<<8|int(lengthData[1]))
+		if _, err = io.ReadFull(conn, respData); err != nil {
+			return nil, fmt.Errorf("read response from %s error: %v", server, err)
+		}
+		return
+	}
+	respData = make([]byte, 65535)
+	n, err := conn.Read(respData)
+	if err != nil {
+		return nil, fmt.Errorf("read response from %s error: %v", server, err)
+	}
+	return respData[:n], nil
+}
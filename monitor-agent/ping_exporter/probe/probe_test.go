@@ -0,0 +1,124 @@
+package probe
+
+import (
+	"bytes"
+	"github.com/WeBankPartners/open-monitor/monitor-agent/ping_exporter/funcs"
+	"golang.org/x/net/dns/dnsmessage"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendAndExpect(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config *funcs.ProbeConfigObj
+		server func(conn net.Conn)
+		result bool
+	}{
+		{"no expect", &funcs.ProbeConfigObj{}, func(conn net.Conn) {}, true},
+		{"banner before send", &funcs.ProbeConfigObj{Expect: "^220 "}, func(conn net.Conn) {
+			conn.Write([]byte("220 smtp ready\r\n"))
+		}, true},
+		{"banner not match", &funcs.ProbeConfigObj{Expect: "^220 "}, func(conn net.Conn) {
+			conn.Write([]byte("554 no service\r\n"))
+			conn.Close()
+		}, false},
+		{"response after send", &funcs.ProbeConfigObj{Send: "PING\r\n", Expect: "PONG"}, func(conn net.Conn) {
+			buff := make([]byte, 6)
+			if n, _ := conn.Read(buff); string(buff[:n]) == "PING\r\n" {
+				conn.Write([]byte("+PO"))
+				conn.Write([]byte("NG\r\n"))
+			}
+		}, true},
+		{"response split across reads", &funcs.ProbeConfigObj{Expect: "ready"}, func(conn net.Conn) {
+			conn.Write([]byte("220 rea"))
+			conn.Write([]byte("dy\r\n"))
+		}, true},
+		{"timeout", &funcs.ProbeConfigObj{Expect: "PONG"}, func(conn net.Conn) {}, false},
+		{"illegal expect", &funcs.ProbeConfigObj{Expect: "("}, func(conn net.Conn) {}, false},
+		{"read size limit", &funcs.ProbeConfigObj{Expect: "END"}, func(conn net.Conn) {
+			data := bytes.Repeat([]byte("a"), 1024)
+			for i := 0; i <= probeMaxReadSize/len(data); i++ {
+				if _, err := conn.Write(data); err != nil {
+					return
+				}
+			}
+			conn.Write([]byte("END"))
+		}, false},
+	}
+	for _, v := range testCases {
+		clientConn, serverConn := net.Pipe()
+		go v.server(serverConn)
+		clientConn.SetDeadline(time.Now().Add(200 * time.Millisecond))
+		err := sendAndExpect(clientConn, v.config)
+		if (err == nil) != v.result {
+			t.Errorf("case %s result should be %t,err:%v", v.name, v.result, err)
+		}
+		if v.name == "read size limit" && (err == nil || !strings.Contains(err.Error(), "65536 bytes")) {
+			t.Errorf("case %s should stop at read size limit,err:%v", v.name, err)
+		}
+		clientConn.Close()
+		serverConn.Close()
+	}
+}
+
+func TestLookupDnsRecord(t *testing.T) {
+	serverConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer serverConn.Close()
+	go func() {
+		buff := make([]byte, 512)
+		for {
+			n, addr, readErr := serverConn.ReadFrom(buff)
+			if readErr != nil {
+				return
+			}
+			var queryMsg dnsmessage.Message
+			if queryMsg.Unpack(buff[:n]) != nil || len(queryMsg.Questions) == 0 {
+				continue
+			}
+			question := queryMsg.Questions[0]
+			respMsg := dnsmessage.Message{Header: dnsmessage.Header{ID: queryMsg.ID, Response: true}, Questions: queryMsg.Questions}
+			resHeader := dnsmessage.ResourceHeader{Name: question.Name, Type: question.Type, Class: dnsmessage.ClassINET, TTL: 60}
+			switch {
+			case question.Name.String() != "probe.example.com.":
+				respMsg.RCode = dnsmessage.RCodeNameError
+			case question.Type == dnsmessage.TypeA:
+				respMsg.Answers = append(respMsg.Answers, dnsmessage.Resource{Header: resHeader, Body: &dnsmessage.AResource{A: [4]byte{10, 0, 0, 8}}})
+			case question.Type == dnsmessage.TypeMX:
+				mxName, _ := dnsmessage.NewName("mail.example.com.")
+				respMsg.Answers = append(respMsg.Answers, dnsmessage.Resource{Header: resHeader, Body: &dnsmessage.MXResource{Pref: 10, MX: mxName}})
+			case question.Type == dnsmessage.TypeTXT:
+				respMsg.Answers = append(respMsg.Answers, dnsmessage.Resource{Header: resHeader, Body: &dnsmessage.TXTResource{TXT: []string{"v=spf1 ", "-all"}}})
+			}
+			respData, _ := respMsg.Pack()
+			serverConn.WriteTo(respData, addr)
+		}
+	}()
+	server := serverConn.LocalAddr().String()
+	testCases := []struct {
+		name       string
+		recordType string
+		result     string
+		fail       bool
+	}{
+		{"probe.example.com", "A", "10.0.0.8", false},
+		{"probe.example.com.", "A", "10.0.0.8", false},
+		{"probe.example.com", "MX", "10 mail.example.com.", false},
+		{"probe.example.com", "TXT", "v=spf1 -all", false},
+		{"probe.example.com", "AAAA", "", false},
+		{"none.example.com", "A", "", true},
+		{"localhost", "A", "", true},
+		{"probe.example.com", "SRV", "", true},
+	}
+	for _, v := range testCases {
+		records, lookupErr := lookupDnsRecord(server, v.name, v.recordType, time.Now().Add(time.Second))
+		if (lookupErr != nil) != v.fail || strings.Join(records, ",") != v.result {
+			t.Errorf("lookup %s %s expect %s fail:%t,get:%v err:%v", v.name, v.recordType, v.result, v.fail, records, lookupErr)
+		}
+	}
+}
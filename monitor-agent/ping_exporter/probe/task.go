@@ -0,0 +1,68 @@
+package probe
+
+import (
+	"github.com/WeBankPartners/open-monitor/monitor-agent/ping_exporter/funcs"
+	"log"
+	"sync"
+	"time"
+)
+
+var probeTimeOut = 5
+
+func StartProbeTask() {
+	interval := funcs.Config().Interval
+	if interval < 30 {
+		log.Println("probe interval refresh to 30s")
+		interval = 30
+	}
+	if funcs.Config().ProbeTimeout > 0 {
+		probeTimeOut = funcs.Config().ProbeTimeout
+	}
+	t := time.NewTicker(time.Second * time.Duration(interval)).C
+	for {
+		go probeTask()
+		<-t
+	}
+}
+
+func probeTask() {
+	startTime := time.Now()
+	probeList := funcs.GetProbeList()
+	wg := sync.WaitGroup{}
+	for _, v := range probeList {
+		wg.Add(1)
+		go func(probeObj *funcs.ProbeObj) {
+			defer wg.Done()
+			switch probeObj.Type {
+			case "tcp":
+				doTcpProbe(probeObj)
+			case "tls":
+				doTlsProbe(probeObj)
+			case "dns":
+				doDnsProbe(probeObj)
+			}
+			funcs.DebugLog("probe %s result %t use time %.3f ms", probeObj.Key, probeObj.Success, probeObj.UseTime)
+		}(v)
+	}
+	wg.Wait()
+	var successCount int
+	for _, v := range probeList {
+		if v.Success {
+			successCount += 1
+		}
+	}
+	useTime := float64(time.Now().Sub(startTime).Nanoseconds()) / 1e6
+	log.Printf("end probe, success num %d, fail num %d, use time %.3f ms \n", successCount, len(probeList)-successCount, useTime)
+	funcs.UpdateProbeExportMetric(probeList)
+}
+
+func getProbeTimeout(config *funcs.ProbeConfigObj) time.Duration {
+	if config.Timeout > 0 {
+		return time.Duration(config.Timeout) * time.Second
+	}
+	return time.Duration(probeTimeOut) * time.Second
+}
+
+func sinceMillisecond(t time.Time) float64 {
+	return float64(time.Since(t).Nanoseconds()) / 1e6
+}
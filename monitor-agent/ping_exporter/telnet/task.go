@@ -12,6 +12,7 @@ import (
 var (
 	telnetResultList []*funcs.TelnetObj
 	resultLock = new(sync.RWMutex)
+	telnetTimeOut = 5 * time.Second
 )
 
 func StartTelnetTask()  {
@@ -20,6 +21,9 @@ func StartTelnetTask()  {
 		log.Println("telnet interval refresh to 30s")
 		interval = 30
 	}
+	if funcs.Config().ProbeTimeout > 0 {
+		telnetTimeOut = time.Duration(funcs.Config().ProbeTimeout) * time.Second
+	}
 	t := time.NewTicker(time.Second*time.Duration(interval)).C
 	for {
 		go telnetTask()
@@ -51,7 +55,7 @@ func telnetTask()  {
 }
 
 func doTelnet(ip string,port int) bool {
-	conn,err := net.Dial("tcp", fmt.Sprintf("%s:%d", ip, port))
+	conn,err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", ip, port), telnetTimeOut)
 	if err != nil {
 		return false
 	}else{
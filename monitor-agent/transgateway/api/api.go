@@ -28,6 +28,9 @@ func InitHttpServer(port string) {
 		authApi.POST("/push/:first", transfer.AcceptPostData)
 		authApi.POST("/push/:first/:second", transfer.AcceptPostData)
 		authApi.GET("/register", transfer.AddMember)
+		authApi.POST("/write", transfer.AcceptRemoteWrite)
+		authApi.POST("/openmetrics", transfer.AcceptOpenMetrics)
+		authApi.POST("/influx/write", transfer.AcceptInfluxWrite)
 	}
 	r.GET("/metrics", transfer.DisplayMetrics)
 	r.Run(fmt.Sprintf(":%s", port))
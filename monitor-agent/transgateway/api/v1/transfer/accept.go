@@ -11,44 +11,21 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
-	"sync"
-	"time"
 )
 
 func AcceptPostData(c *gin.Context) {
 	var param m.TransRequest
 	if err := c.ShouldBindJSON(&param); err == nil {
-		tmpIndex := -1
-		for i, v := range m.DataCache {
-			if v.Token == param.UserAuthKey {
-				tmpIndex = i
-				break
-			}
+		member, err := getMember(param.UserAuthKey)
+		if err != nil {
+			util.ReturnMessage(c, util.RespJson{Code: 1, Msg: err.Error()})
+			return
 		}
-		if tmpIndex == -1 {
-			if endpointName, dcErr := util.Dncrypt(param.UserAuthKey); dcErr != nil {
-				util.ReturnMessage(c, util.RespJson{Code: 1, Msg: "Please register,token validate fail!"})
-				return
-			} else {
-				var member m.Member
-				member.Lock = *new(sync.RWMutex)
-				member.Name = endpointName
-				member.Token = param.UserAuthKey
-				member.LastUpdate = time.Now()
-				m.DataCache = append(m.DataCache, &member)
-				m.TokenCache[param.UserAuthKey] = endpointName
-				for i, v := range m.DataCache {
-					if v.Token == param.UserAuthKey {
-						tmpIndex = i
-						break
-					}
-				}
-			}
+		if !member.AllowSamples(len(param.MetricDataList)) {
+			util.ReturnMessage(c, util.RespJson{Code: 3, Msg: "Sample rate limit exceeded"})
+			return
 		}
-		m.DataCache[tmpIndex].Lock.Lock()
-		tNow := time.Now()
-		m.DataCache[tmpIndex].LastUpdate = tNow
-		m.DataCache[tmpIndex].Active = true
+		var metrics []*m.MetricObj
 		for _, v := range param.MetricDataList {
 			v.AttrName = strings.ReplaceAll(v.AttrName, ".", "_")
 			objectString := ""
@@ -56,26 +33,12 @@ func AcceptPostData(c *gin.Context) {
 				objectString = fmt.Sprintf("%s", v.Object)
 			}
 			attrId := v.AttrName + "__" + v.InterfaceName + "__" + objectString
-			tmpFlag := false
-			for ii, vv := range m.DataCache[tmpIndex].Metrics {
-				if vv.Id == attrId {
-					tmpFlag = true
-					m.DataCache[tmpIndex].Metrics[ii].Metric = v.AttrName
-					m.DataCache[tmpIndex].Metrics[ii].AttrName = v.AttrName
-					m.DataCache[tmpIndex].Metrics[ii].Value = formatMetricValueData(v.MetricValue)
-					m.DataCache[tmpIndex].Metrics[ii].HostIp = v.HostIp
-					m.DataCache[tmpIndex].Metrics[ii].InterfaceName = v.InterfaceName
-					m.DataCache[tmpIndex].Metrics[ii].Object = objectString
-					m.DataCache[tmpIndex].Metrics[ii].LastUpdate = tNow
-					m.DataCache[tmpIndex].Metrics[ii].Active = true
-					break
-				}
-			}
-			if !tmpFlag {
-				m.DataCache[tmpIndex].Metrics = append(m.DataCache[tmpIndex].Metrics, &m.MetricObj{Id: attrId, Metric: v.AttrName, AttrName: v.AttrName, Value: formatMetricValueData(v.MetricValue), HostIp: v.HostIp, InterfaceName: v.InterfaceName, Object: objectString, LastUpdate: tNow, Active: true})
-			}
+			metrics = append(metrics, &m.MetricObj{Id: attrId, Metric: v.AttrName, AttrName: v.AttrName, Value: formatMetricValueData(v.MetricValue), HostIp: v.HostIp, InterfaceName: v.InterfaceName, Object: objectString})
+		}
+		if acceptNum := member.UpsertMetrics(metrics); acceptNum < len(metrics) {
+			util.ReturnMessage(c, util.RespJson{Code: 0, Msg: fmt.Sprintf("Success,%d metrics rejected by series limit", len(metrics)-acceptNum)})
+			return
 		}
-		m.DataCache[tmpIndex].Lock.Unlock()
 		util.ReturnMessage(c, util.RespJson{Code: 0, Msg: "Success"})
 	} else {
 		util.ReturnMessage(c, util.RespJson{Code: 1, Msg: fmt.Sprintf("fail : %v", err)})
@@ -101,15 +64,10 @@ func AddMember(c *gin.Context) {
 		util.ReturnMessage(c, util.RespJson{Code: 1, Msg: "Param name cat not be null"})
 		return
 	}
-	for _, v := range m.DataCache {
-		if v.Name == sysName {
-			util.ReturnMessage(c, util.RespJson{Code: 1, Msg: "Param name already exist"})
-			return
-		}
+	if m.GetMemberByName(sysName) != nil {
+		util.ReturnMessage(c, util.RespJson{Code: 1, Msg: "Param name already exist"})
+		return
 	}
-	var member m.Member
-	member.Lock = *new(sync.RWMutex)
-	member.Name = sysName
 	token, err := util.Encrypt([]byte(sysName))
 	if err != nil {
 		util.ReturnMessage(c, util.RespJson{Code: 2, Msg: fmt.Sprintf("Create token fail %v", err)})
@@ -145,9 +103,6 @@ func AddMember(c *gin.Context) {
 			return
 		}
 	}
-	member.Token = token
-	member.LastUpdate = time.Now()
-	m.DataCache = append(m.DataCache, &member)
-	m.TokenCache[token] = sysName
+	m.AddMember(sysName, token)
 	util.ReturnMessage(c, util.RespJson{Code: 0, Msg: fmt.Sprintf("Token : %s", token)})
 }
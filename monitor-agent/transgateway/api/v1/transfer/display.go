@@ -5,24 +5,28 @@ import (
 	m "github.com/WeBankPartners/open-monitor/monitor-agent/transgateway/models"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"strings"
 )
 
 func DisplayMetrics(c *gin.Context) {
-	var outputString string
-	for _, v := range m.DataCache {
+	var builder strings.Builder
+	for _, v := range m.ListMembers() {
+		v.Lock.RLock()
 		if !v.Active {
+			v.Lock.RUnlock()
 			continue
 		}
-		v.Lock.RLock()
 		for _, vv := range v.Metrics {
 			if !vv.Active {
 				continue
 			}
-			outputString += fmt.Sprintf("# TYPE %s gauge\n", vv.Metric)
-			outputString += fmt.Sprintf("%s{system=\"%s\",host=\"%s\",interface=\"%s\",object=\"%s\"} %.3f \n", vv.Metric, v.Name, vv.HostIp, vv.InterfaceName, vv.Object, vv.Value)
+			builder.WriteString(fmt.Sprintf("# TYPE %s gauge\n", vv.Metric))
+			builder.WriteString(fmt.Sprintf("%s{system=\"%s\",host=\"%s\",interface=\"%s\",object=\"%s\"} %.3f \n", vv.Metric, v.Name, vv.HostIp, vv.InterfaceName, vv.Object, vv.Value))
 		}
 		v.Lock.RUnlock()
 	}
+	m.SeriesCache.Expose(&builder)
+	m.ExposeSelfMetrics(&builder)
 	c.Header("Transfer-Encoding", "chunked")
-	c.String(http.StatusOK, outputString)
+	c.String(http.StatusOK, builder.String())
 }
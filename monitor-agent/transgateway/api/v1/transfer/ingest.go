@@ -0,0 +1,217 @@
+package transfer
+
+import (
+	"compress/gzip"
+	"fmt"
+	m "github.com/WeBankPartners/open-monitor/monitor-agent/transgateway/models"
+	"github.com/WeBankPartners/open-monitor/monitor-agent/transgateway/remote"
+	"github.com/WeBankPartners/open-monitor/monitor-agent/transgateway/util"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/snappy"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// Prometheus 用于标记序列消失的 NaN
+	staleNaNBits = 0x7ff0000000000002
+	// 请求体大小限制,以及 snappy/gzip 解压后的大小限制
+	maxRequestBodyBytes = 32 << 20
+	maxDecodedBodyBytes = 128 << 20
+)
+
+// AcceptRemoteWrite 接收 Prometheus remote_write(snappy 压缩的 protobuf)
+func AcceptRemoteWrite(c *gin.Context) {
+	member, err := getRequestMember(c)
+	if err != nil {
+		util.ReturnMessage(c, util.RespJson{Code: 1, Msg: err.Error()})
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxRequestBodyBytes))
+	if err != nil {
+		util.ReturnMessage(c, util.RespJson{Code: 1, Msg: fmt.Sprintf("Read body fail,%s", err.Error())})
+		return
+	}
+	// 解压前先检查解压后的长度,避免小请求体申请过大的内存
+	decodedLen, err := snappy.DecodedLen(body)
+	if err == nil && decodedLen > maxDecodedBodyBytes {
+		err = fmt.Errorf("decoded length %d exceed limit %d", decodedLen, maxDecodedBodyBytes)
+	}
+	if err != nil {
+		util.ReturnMessage(c, util.RespJson{Code: 1, Msg: fmt.Sprintf("Snappy decode fail,%s", err.Error())})
+		return
+	}
+	data, err := snappy.Decode(nil, body)
+	if err != nil {
+		util.ReturnMessage(c, util.RespJson{Code: 1, Msg: fmt.Sprintf("Snappy decode fail,%s", err.Error())})
+		return
+	}
+	writeRequest, err := remote.UnmarshalWriteRequest(data)
+	if err != nil {
+		util.ReturnMessage(c, util.RespJson{Code: 1, Msg: fmt.Sprintf("Protobuf decode fail,%s", err.Error())})
+		return
+	}
+	typeMap := make(map[string]string)
+	helpMap := make(map[string]string)
+	for _, metadata := range writeRequest.Metadata {
+		typeMap[metadata.MetricFamilyName] = metadata.Type
+		helpMap[metadata.MetricFamilyName] = metadata.Help
+	}
+	var samples []*m.SeriesSample
+	for _, series := range writeRequest.Timeseries {
+		var name string
+		var labels []*m.Label
+		for _, label := range series.Labels {
+			if label.Name == "__name__" {
+				name = label.Value
+			} else {
+				labels = append(labels, label)
+			}
+		}
+		if name == "" {
+			continue
+		}
+		if err = m.ValidateSeriesName(name, labels); err != nil {
+			util.ReturnMessage(c, util.RespJson{Code: 1, Msg: err.Error()})
+			return
+		}
+		labels = m.SortLabels(labels)
+		family := m.GetFamilyName(name, typeMap)
+		for _, sample := range series.Samples {
+			if math.Float64bits(sample.Value) == staleNaNBits {
+				continue
+			}
+			samples = append(samples, &m.SeriesSample{Name: name, Family: family, Type: typeMap[family], Help: helpMap[family], Labels: labels, Value: sample.Value, Timestamp: sample.Timestamp})
+		}
+	}
+	if _, err = saveSeriesSamples(member, samples); err != nil {
+		util.ReturnMessage(c, util.RespJson{Code: 3, Msg: err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// AcceptOpenMetrics 接收 OpenMetrics/Prometheus 文本格式推送
+func AcceptOpenMetrics(c *gin.Context) {
+	member, err := getRequestMember(c)
+	if err != nil {
+		util.ReturnMessage(c, util.RespJson{Code: 1, Msg: err.Error()})
+		return
+	}
+	body, err := readRequestBody(c)
+	if err != nil {
+		util.ReturnMessage(c, util.RespJson{Code: 1, Msg: fmt.Sprintf("Read body fail,%s", err.Error())})
+		return
+	}
+	samples, err := m.ParseTextMetrics(body, strings.Contains(c.GetHeader("Content-Type"), "openmetrics"))
+	if err != nil {
+		util.ReturnMessage(c, util.RespJson{Code: 1, Msg: fmt.Sprintf("Parse metrics fail,%s", err.Error())})
+		return
+	}
+	acceptNum, err := saveSeriesSamples(member, samples)
+	if err != nil {
+		util.ReturnMessage(c, util.RespJson{Code: 3, Msg: err.Error()})
+		return
+	}
+	util.ReturnMessage(c, util.RespJson{Code: 0, Msg: fmt.Sprintf("Success,%d samples accepted,%d rejected by series limit", acceptNum, len(samples)-acceptNum)})
+}
+
+// AcceptInfluxWrite 兼容 InfluxDB 1.x 的 /write 接口
+func AcceptInfluxWrite(c *gin.Context) {
+	member, err := getRequestMember(c)
+	if err != nil {
+		util.ReturnMessage(c, util.RespJson{Code: 1, Msg: err.Error()})
+		return
+	}
+	body, err := readRequestBody(c)
+	if err != nil {
+		util.ReturnMessage(c, util.RespJson{Code: 1, Msg: fmt.Sprintf("Read body fail,%s", err.Error())})
+		return
+	}
+	samples, err := m.ParseInfluxLine(body, c.Query("precision"))
+	if err != nil {
+		util.ReturnMessage(c, util.RespJson{Code: 1, Msg: fmt.Sprintf("Parse line protocol fail,%s", err.Error())})
+		return
+	}
+	if _, err = saveSeriesSamples(member, samples); err != nil {
+		util.ReturnMessage(c, util.RespJson{Code: 3, Msg: err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func readRequestBody(c *gin.Context) ([]byte, error) {
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxRequestBodyBytes)
+	if c.GetHeader("Content-Encoding") != "gzip" {
+		return ioutil.ReadAll(body)
+	}
+	gr, err := gzip.NewReader(body)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(gr, maxDecodedBodyBytes+1))
+	if err == nil && len(data) > maxDecodedBodyBytes {
+		err = fmt.Errorf("decoded body exceed limit %d", maxDecodedBodyBytes)
+	}
+	return data, err
+}
+
+// saveSeriesSamples 样本统一加上 system 标签,与旧接口暴露的指标保持一致,返回写入的数量,超出速率限制时返回错误
+func saveSeriesSamples(member *m.Member, samples []*m.SeriesSample) (int, error) {
+	if !member.AllowSamples(len(samples)) {
+		return 0, fmt.Errorf("Sample rate limit exceeded")
+	}
+	for _, sample := range samples {
+		sample.Labels = m.SetLabel(sample.Labels, "system", member.Name)
+	}
+	member.Lock.Lock()
+	member.LastUpdate = time.Now()
+	member.Active = true
+	member.Lock.Unlock()
+	accepted := m.SeriesCache.Add(member, samples)
+	remote.Forward(accepted)
+	return len(accepted), nil
+}
+
+// getRequestToken 依次从 X-Auth-Token,Authorization(Bearer/Token),参数 token,参数 p(influx 客户端的密码) 中取 token
+func getRequestToken(c *gin.Context) string {
+	if token := c.GetHeader("X-Auth-Token"); token != "" {
+		return token
+	}
+	if authorization := c.GetHeader("Authorization"); authorization != "" {
+		for _, prefix := range []string{"Bearer ", "Token "} {
+			if strings.HasPrefix(authorization, prefix) {
+				return strings.TrimSpace(strings.TrimPrefix(authorization, prefix))
+			}
+		}
+	}
+	if token := c.Query("token"); token != "" {
+		return token
+	}
+	return c.Query("p")
+}
+
+func getRequestMember(c *gin.Context) (*m.Member, error) {
+	token := getRequestToken(c)
+	if token == "" {
+		return nil, fmt.Errorf("Please register,token can not be empty!")
+	}
+	return getMember(token)
+}
+
+// getMember 根据 token 找到接入系统,token 能解密但系统不在缓存中时自动加入
+func getMember(token string) (*m.Member, error) {
+	if member := m.GetMemberByToken(token); member != nil {
+		return member, nil
+	}
+	endpointName, dcErr := util.Dncrypt(token)
+	if dcErr != nil {
+		return nil, fmt.Errorf("Please register,token validate fail!")
+	}
+	return m.AddMember(endpointName, token), nil
+}
@@ -8,6 +8,7 @@ import (
 	"log"
 	"github.com/WeBankPartners/open-monitor/monitor-agent/transgateway/api"
 	"github.com/WeBankPartners/open-monitor/monitor-agent/transgateway/models"
+	"github.com/WeBankPartners/open-monitor/monitor-agent/transgateway/remote"
 )
 
 func main() {
@@ -15,10 +16,15 @@ func main() {
 	timeout := flag.Int64("t", 120, "data timeout")
 	dataDir := flag.String("d", "", "data save path")
 	monitorUrl := flag.String("m", "", "monitor endpoint register url")
+	remoteWriteUrl := flag.String("w", "", "prometheus remote write url")
+	seriesLimit := flag.Int64("l", 0, "max series per token, 0 means no limit")
+	rateLimit := flag.Float64("r", 0, "max samples per second per token, 0 means no limit")
+	rateBurst := flag.Float64("rb", 0, "max burst samples per token, 0 means 10 seconds of rate")
 	flag.Parse()
 	models.InitMonitorUrl(*monitorUrl, *port)
-	models.LoadCacheData(*dataDir)
+	models.InitStore(*dataDir, models.StoreLimit{SeriesLimit: *seriesLimit, RateLimit: *rateLimit, RateBurst: *rateBurst}, *timeout)
 	go models.CleanTimeoutData(*timeout)
+	remote.InitForward(*remoteWriteUrl)
 	go api.InitHttpServer(*port)
 	startSignal(os.Getpid())
 	select{}
@@ -33,8 +39,8 @@ func startSignal(pid int) {
 		log.Println("recv", s)
 		switch s {
 		case syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT:
-			log.Println("shutdown , start save file")
-			models.SaveCacheData()
+			log.Println("shutdown , start sync wal")
+			models.CloseStore()
 			log.Println("shutdown , done")
 			log.Println(pid, "exit")
 			os.Exit(0)
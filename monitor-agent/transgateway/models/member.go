@@ -0,0 +1,233 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	RejectReasonRateLimit   = "rate_limit"
+	RejectReasonSeriesLimit = "series_limit"
+)
+
+// StoreLimit 每个 token 的限制,0 表示不限制,RateBurst 为0时允许 10 秒额度的突发
+type StoreLimit struct {
+	SeriesLimit int64
+	RateLimit   float64
+	RateBurst   float64
+}
+
+var storeLimit StoreLimit
+
+// Member 一个接入系统(token),Metrics 为旧接口推送的指标,按 attrId 索引
+type Member struct {
+	Id              int
+	Name            string
+	Token           string
+	Metrics         map[string]*MetricObj
+	LastUpdate      time.Time
+	Lock            sync.RWMutex
+	Active          bool
+	limiter         *rateLimiter
+	seriesNum       int64
+	ingestNum       int64
+	rejectRateNum   int64
+	rejectSeriesNum int64
+}
+
+type memberIndex struct {
+	lock     sync.RWMutex
+	tokenMap map[string]*Member
+	nameMap  map[string]*Member
+}
+
+var members = &memberIndex{tokenMap: make(map[string]*Member), nameMap: make(map[string]*Member)}
+
+func newMember(name, token string) *Member {
+	return &Member{Name: name, Token: token, Metrics: make(map[string]*MetricObj), LastUpdate: time.Now(), limiter: newRateLimiter(storeLimit.RateLimit, storeLimit.RateBurst)}
+}
+
+func GetMemberByToken(token string) *Member {
+	members.lock.RLock()
+	member := members.tokenMap[token]
+	members.lock.RUnlock()
+	return member
+}
+
+func GetMemberByName(name string) *Member {
+	members.lock.RLock()
+	member := members.nameMap[name]
+	members.lock.RUnlock()
+	return member
+}
+
+// AddMember token 已存在时返回已有的系统
+func AddMember(name, token string) *Member {
+	members.lock.Lock()
+	member, b := members.tokenMap[token]
+	if !b {
+		member = newMember(name, token)
+		members.tokenMap[token] = member
+		members.nameMap[name] = member
+	}
+	members.lock.Unlock()
+	if !b {
+		wal.append(&walRecord{Type: walRecordMember, Token: token, Name: name, Time: member.LastUpdate})
+	}
+	return member
+}
+
+// ListMembers 按名称排序的快照
+func ListMembers() []*Member {
+	members.lock.RLock()
+	result := make([]*Member, 0, len(members.tokenMap))
+	for _, v := range members.tokenMap {
+		result = append(result, v)
+	}
+	members.lock.RUnlock()
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// AllowSamples 按 token 的样本速率限制判断本次请求能否写入,超限时整个请求拒绝
+func (member *Member) AllowSamples(num int) bool {
+	if member.limiter.allow(num) {
+		return true
+	}
+	atomic.AddInt64(&member.rejectRateNum, int64(num))
+	return false
+}
+
+func (member *Member) acquireSeries() bool {
+	if storeLimit.SeriesLimit <= 0 {
+		atomic.AddInt64(&member.seriesNum, 1)
+		return true
+	}
+	if atomic.AddInt64(&member.seriesNum, 1) > storeLimit.SeriesLimit {
+		atomic.AddInt64(&member.seriesNum, -1)
+		atomic.AddInt64(&member.rejectSeriesNum, 1)
+		return false
+	}
+	return true
+}
+
+func (member *Member) releaseSeries(num int64) {
+	atomic.AddInt64(&member.seriesNum, -num)
+}
+
+// UpsertMetrics 写入旧接口推送的指标,超出序列限制的新指标丢弃,返回写入的数量
+func (member *Member) UpsertMetrics(metrics []*MetricObj) int {
+	var accepted []MetricObj
+	tNow := time.Now()
+	member.Lock.Lock()
+	member.LastUpdate = tNow
+	member.Active = true
+	for _, v := range metrics {
+		v.LastUpdate = tNow
+		v.Active = true
+		if _, b := member.Metrics[v.Id]; !b && !member.acquireSeries() {
+			continue
+		}
+		member.Metrics[v.Id] = v
+		accepted = append(accepted, *v)
+	}
+	member.Lock.Unlock()
+	atomic.AddInt64(&member.ingestNum, int64(len(accepted)))
+	if len(accepted) > 0 {
+		wal.append(&walRecord{Type: walRecordMetric, Token: member.Token, Metrics: accepted, Time: tNow})
+	}
+	return len(accepted)
+}
+
+// restoreMetrics 回放 WAL 时使用,较旧的记录不覆盖
+func (member *Member) restoreMetrics(metrics []MetricObj, updateTime time.Time) {
+	member.Lock.Lock()
+	if updateTime.After(member.LastUpdate) {
+		member.LastUpdate = updateTime
+		member.Active = true
+	}
+	for i := range metrics {
+		metric := metrics[i]
+		exist, b := member.Metrics[metric.Id]
+		if b && exist.LastUpdate.After(metric.LastUpdate) {
+			continue
+		}
+		if !b {
+			atomic.AddInt64(&member.seriesNum, 1)
+		}
+		member.Metrics[metric.Id] = &metric
+	}
+	member.Lock.Unlock()
+}
+
+// rateLimiter 令牌桶,单个请求的样本数超过 burst 时按 burst 判断,多出的部分记为欠额从后续额度中扣除
+type rateLimiter struct {
+	lock   sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate, burst float64) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = rate * 10
+	}
+	return &rateLimiter{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+func (l *rateLimiter) allow(num int) bool {
+	if l == nil {
+		return true
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	tNow := time.Now()
+	l.tokens += tNow.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = tNow
+	cost := float64(num)
+	if cost > l.burst {
+		cost = l.burst
+	}
+	if cost > l.tokens {
+		return false
+	}
+	l.tokens -= float64(num)
+	return true
+}
+
+// ExposeSelfMetrics 输出网关自身的指标:各系统写入/拒绝的样本数,当前序列数,以及 WAL 状态
+func ExposeSelfMetrics(builder *strings.Builder) {
+	memberList := ListMembers()
+	builder.WriteString("# HELP transgateway_members Number of registered systems.\n# TYPE transgateway_members gauge\n")
+	builder.WriteString(fmt.Sprintf("transgateway_members %d\n", len(memberList)))
+	builder.WriteString("# HELP transgateway_ingested_samples_total Samples accepted per system.\n# TYPE transgateway_ingested_samples_total counter\n")
+	for _, v := range memberList {
+		builder.WriteString(fmt.Sprintf("transgateway_ingested_samples_total{system=\"%s\"} %d\n", escapeLabelValue(v.Name), atomic.LoadInt64(&v.ingestNum)))
+	}
+	builder.WriteString("# HELP transgateway_rejected_samples_total Samples rejected by per-system limits.\n# TYPE transgateway_rejected_samples_total counter\n")
+	for _, v := range memberList {
+		builder.WriteString(fmt.Sprintf("transgateway_rejected_samples_total{reason=\"%s\",system=\"%s\"} %d\n", RejectReasonRateLimit, escapeLabelValue(v.Name), atomic.LoadInt64(&v.rejectRateNum)))
+		builder.WriteString(fmt.Sprintf("transgateway_rejected_samples_total{reason=\"%s\",system=\"%s\"} %d\n", RejectReasonSeriesLimit, escapeLabelValue(v.Name), atomic.LoadInt64(&v.rejectSeriesNum)))
+	}
+	builder.WriteString("# HELP transgateway_active_series Series currently held per system.\n# TYPE transgateway_active_series gauge\n")
+	for _, v := range memberList {
+		builder.WriteString(fmt.Sprintf("transgateway_active_series{system=\"%s\"} %d\n", escapeLabelValue(v.Name), atomic.LoadInt64(&v.seriesNum)))
+	}
+	builder.WriteString("# HELP transgateway_wal_segment_bytes Size of the current WAL segment.\n# TYPE transgateway_wal_segment_bytes gauge\n")
+	builder.WriteString(fmt.Sprintf("transgateway_wal_segment_bytes %d\n", wal.getSize()))
+	builder.WriteString("# HELP transgateway_wal_errors_total WAL write failures.\n# TYPE transgateway_wal_errors_total counter\n")
+	builder.WriteString(fmt.Sprintf("transgateway_wal_errors_total %d\n", wal.getErrNum()))
+}
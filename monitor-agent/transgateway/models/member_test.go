@@ -0,0 +1,46 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBurst(t *testing.T) {
+	limiter := newRateLimiter(10, 0)
+	if limiter.burst != 100 {
+		t.Fatalf("default burst expect 100,get:%v", limiter.burst)
+	}
+	if !limiter.allow(60) || limiter.allow(60) {
+		t.Errorf("second request should be rejected after first drain 60 tokens")
+	}
+	if !limiter.allow(40) {
+		t.Errorf("request within left tokens should be allowed")
+	}
+	if newRateLimiter(10, 500).burst != 500 {
+		t.Errorf("configured burst should be used")
+	}
+	if newRateLimiter(0, 100) != nil || !(*rateLimiter)(nil).allow(1000000) {
+		t.Errorf("zero rate should not limit")
+	}
+}
+
+func TestRateLimiterLargeRequest(t *testing.T) {
+	limiter := newRateLimiter(10, 0)
+	// 超过 burst 的请求在额度满时放行,欠额从后续额度中扣除
+	if !limiter.allow(250) {
+		t.Fatalf("request larger than burst should be allowed when bucket is full")
+	}
+	if limiter.tokens != -150 {
+		t.Errorf("tokens expect -150,get:%v", limiter.tokens)
+	}
+	if limiter.allow(1) {
+		t.Errorf("request should be rejected while in debt")
+	}
+	limiter.last = limiter.last.Add(-26 * time.Second)
+	if !limiter.allow(100) {
+		t.Errorf("bucket should be full after debt repaid")
+	}
+	if limiter.allow(250) {
+		t.Errorf("large request should be rejected when bucket is not full")
+	}
+}
@@ -0,0 +1,326 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	metricNameRegexp = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRegexp  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// ValidateSeriesName 校验指标名和标签名是否符合 Prometheus 规范,不合法的序列转发后会被远端拒绝
+func ValidateSeriesName(name string, labels []*Label) error {
+	if !metricNameRegexp.MatchString(name) {
+		return fmt.Errorf("metric name %s illegal", name)
+	}
+	for _, label := range labels {
+		if !labelNameRegexp.MatchString(label.Name) {
+			return fmt.Errorf("label name %s illegal", label.Name)
+		}
+	}
+	return nil
+}
+
+// ParseTextMetrics 解析 Prometheus 文本格式与 OpenMetrics 格式,openMetrics 为 true 时时间戳单位为秒,否则为毫秒
+func ParseTextMetrics(body []byte, openMetrics bool) (result []*SeriesSample, err error) {
+	typeMap := make(map[string]string)
+	helpMap := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			fields := strings.SplitN(strings.TrimSpace(strings.TrimPrefix(line, "#")), " ", 3)
+			if len(fields) < 3 {
+				if len(fields) == 1 && fields[0] == "EOF" {
+					break
+				}
+				continue
+			}
+			switch fields[0] {
+			case "TYPE":
+				typeMap[fields[1]] = strings.ToLower(strings.TrimSpace(fields[2]))
+			case "HELP":
+				helpMap[fields[1]] = strings.NewReplacer(`\\`, `\`, `\n`, "\n").Replace(fields[2])
+			}
+			continue
+		}
+		sample, parseErr := parseSampleLine(line, openMetrics)
+		if parseErr != nil {
+			return result, fmt.Errorf("line %d:%s ", lineNum, parseErr.Error())
+		}
+		sample.Family = GetFamilyName(sample.Name, typeMap)
+		sample.Type = typeMap[sample.Family]
+		if sample.Type == "" || sample.Type == "unknown" {
+			sample.Type = MetricTypeUntyped
+		}
+		sample.Help = helpMap[sample.Family]
+		result = append(result, sample)
+	}
+	if err = scanner.Err(); err != nil {
+		err = fmt.Errorf("read body fail,%s ", err.Error())
+	}
+	return
+}
+
+// parseSampleLine 解析 name{label="value",...} value [timestamp] [# exemplar]
+func parseSampleLine(line string, openMetrics bool) (sample *SeriesSample, err error) {
+	sample = &SeriesSample{}
+	nameEnd := strings.IndexAny(line, "{ \t")
+	if nameEnd <= 0 {
+		return nil, fmt.Errorf("metric name illegal")
+	}
+	sample.Name = line[:nameEnd]
+	rest := line[nameEnd:]
+	if strings.HasPrefix(rest, "{") {
+		var labelEnd int
+		sample.Labels, labelEnd, err = parseLabels(rest)
+		if err != nil {
+			return nil, err
+		}
+		rest = rest[labelEnd:]
+	}
+	if err = ValidateSeriesName(sample.Name, sample.Labels); err != nil {
+		return nil, err
+	}
+	if exemplarIndex := strings.Index(rest, "#"); exemplarIndex >= 0 {
+		rest = rest[:exemplarIndex]
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, fmt.Errorf("sample value illegal")
+	}
+	if sample.Value, err = parseFloatValue(fields[0]); err != nil {
+		return nil, fmt.Errorf("value %s illegal", fields[0])
+	}
+	if len(fields) == 2 {
+		if openMetrics {
+			tmpTimestamp, parseErr := strconv.ParseFloat(fields[1], 64)
+			if parseErr != nil {
+				return nil, fmt.Errorf("timestamp %s illegal", fields[1])
+			}
+			sample.Timestamp = int64(math.Round(tmpTimestamp * 1000))
+		} else if sample.Timestamp, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+			return nil, fmt.Errorf("timestamp %s illegal", fields[1])
+		}
+	}
+	sample.Labels = SortLabels(sample.Labels)
+	return
+}
+
+// parseLabels 解析 {a="b",c="d"},返回标签及右括号后的位置
+func parseLabels(input string) (labels []*Label, end int, err error) {
+	i := 1
+	for i < len(input) {
+		for i < len(input) && (input[i] == ' ' || input[i] == ',') {
+			i++
+		}
+		if i < len(input) && input[i] == '}' {
+			return labels, i + 1, nil
+		}
+		nameStart := i
+		for i < len(input) && input[i] != '=' && input[i] != ' ' {
+			i++
+		}
+		name := input[nameStart:i]
+		for i < len(input) && input[i] == ' ' {
+			i++
+		}
+		if i+1 >= len(input) || input[i] != '=' || input[i+1] != '"' {
+			return nil, 0, fmt.Errorf("label %s illegal", name)
+		}
+		i += 2
+		var value strings.Builder
+		closed := false
+		for i < len(input) {
+			if input[i] == '\\' && i+1 < len(input) {
+				switch input[i+1] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(input[i+1])
+				}
+				i += 2
+				continue
+			}
+			if input[i] == '"' {
+				closed = true
+				i++
+				break
+			}
+			value.WriteByte(input[i])
+			i++
+		}
+		if !closed {
+			return nil, 0, fmt.Errorf("label %s value not closed", name)
+		}
+		labels = append(labels, &Label{Name: name, Value: value.String()})
+	}
+	return nil, 0, fmt.Errorf("labels not closed")
+}
+
+func parseFloatValue(input string) (float64, error) {
+	switch strings.ToLower(input) {
+	case "+inf", "inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	case "nan":
+		return math.NaN(), nil
+	}
+	return strconv.ParseFloat(input, 64)
+}
+
+// ParseInfluxLine 解析 InfluxDB line protocol,每个数值字段转换成一个 measurement_field 的 gauge,
+// tag 转换成标签,字符串字段忽略,precision 为 ns(默认)/us/ms/s
+func ParseInfluxLine(body []byte, precision string) (result []*SeriesSample, err error) {
+	var multiple float64
+	switch precision {
+	case "", "n", "ns":
+		multiple = 1e-6
+	case "u", "us":
+		multiple = 1e-3
+	case "ms":
+		multiple = 1
+	case "s":
+		multiple = 1e3
+	default:
+		return nil, fmt.Errorf("precision %s illegal", precision)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sections := splitInfluxLine(line)
+		if len(sections) < 2 || len(sections) > 3 {
+			return result, fmt.Errorf("line %d:format illegal ", lineNum)
+		}
+		keyParts := splitEscaped(sections[0], ',')
+		measurement := unescapeInflux(keyParts[0])
+		var labels []*Label
+		for _, tag := range keyParts[1:] {
+			tagKv := splitEscaped(tag, '=')
+			if len(tagKv) != 2 {
+				return result, fmt.Errorf("line %d:tag %s illegal ", lineNum, tag)
+			}
+			labels = append(labels, &Label{Name: SanitizeLabelName(unescapeInflux(tagKv[0])), Value: unescapeInflux(tagKv[1])})
+		}
+		labels = SortLabels(labels)
+		var timestamp int64
+		if len(sections) == 3 {
+			tmpTimestamp, parseErr := strconv.ParseInt(sections[2], 10, 64)
+			if parseErr != nil {
+				return result, fmt.Errorf("line %d:timestamp %s illegal ", lineNum, sections[2])
+			}
+			timestamp = int64(float64(tmpTimestamp) * multiple)
+		} else {
+			timestamp = time.Now().UnixNano() / 1e6
+		}
+		for _, field := range splitEscaped(sections[1], ',') {
+			fieldKv := splitEscaped(field, '=')
+			if len(fieldKv) != 2 {
+				return result, fmt.Errorf("line %d:field %s illegal ", lineNum, field)
+			}
+			value, isNumber := parseInfluxFieldValue(fieldKv[1])
+			if !isNumber {
+				continue
+			}
+			name := SanitizeMetricName(measurement + "_" + unescapeInflux(fieldKv[0]))
+			if validateErr := ValidateSeriesName(name, labels); validateErr != nil {
+				return result, fmt.Errorf("line %d:%s ", lineNum, validateErr.Error())
+			}
+			result = append(result, &SeriesSample{Name: name, Family: name, Type: MetricTypeGauge, Labels: labels, Value: value, Timestamp: timestamp})
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		err = fmt.Errorf("read body fail,%s ", err.Error())
+	}
+	return
+}
+
+func parseInfluxFieldValue(input string) (float64, bool) {
+	if strings.HasPrefix(input, "\"") {
+		return 0, false
+	}
+	switch input {
+	case "t", "T", "true", "True", "TRUE":
+		return 1, true
+	case "f", "F", "false", "False", "FALSE":
+		return 0, true
+	}
+	if strings.HasSuffix(input, "i") || strings.HasSuffix(input, "u") {
+		input = input[:len(input)-1]
+	}
+	value, err := strconv.ParseFloat(input, 64)
+	return value, err == nil
+}
+
+// splitInfluxLine 按未转义且不在引号内的空格切分
+func splitInfluxLine(line string) (result []string) {
+	var start int
+	inQuote := false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '"':
+			inQuote = !inQuote
+		case ' ':
+			if !inQuote {
+				if i > start {
+					result = append(result, line[start:i])
+				}
+				start = i + 1
+			}
+		}
+	}
+	if start < len(line) {
+		result = append(result, line[start:])
+	}
+	return
+}
+
+// splitEscaped 按未转义且不在引号内的分隔符切分,= 只切第一个
+func splitEscaped(input string, sep byte) (result []string) {
+	var start int
+	inQuote := false
+	for i := 0; i < len(input); i++ {
+		switch input[i] {
+		case '\\':
+			i++
+		case '"':
+			inQuote = !inQuote
+		case sep:
+			if !inQuote {
+				result = append(result, input[start:i])
+				start = i + 1
+				if sep == '=' {
+					return append(result, input[start:])
+				}
+			}
+		}
+	}
+	return append(result, input[start:])
+}
+
+func unescapeInflux(input string) string {
+	return strings.NewReplacer(`\,`, `,`, `\ `, ` `, `\=`, `=`, `\"`, `"`, `\\`, `\`).Replace(input)
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func formatTestSamples(samples []*SeriesSample) string {
+	var lines []string
+	for _, v := range samples {
+		var labels []string
+		for _, label := range v.Labels {
+			labels = append(labels, fmt.Sprintf("%s=%s", label.Name, label.Value))
+		}
+		lines = append(lines, fmt.Sprintf("%s{%s} %v %d %s %s", v.Name, strings.Join(labels, ","), v.Value, v.Timestamp, v.Family, v.Type))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestParseTextMetrics(t *testing.T) {
+	testCases := []struct {
+		name        string
+		body        string
+		openMetrics bool
+		expect      string
+		illegal     bool
+	}{
+		{"gauge with labels", "# TYPE cpu gauge\ncpu{b=\"2\",a=\"1\"} 0.5 1700000000000\n", false, "cpu{a=1,b=2} 0.5 1700000000000 cpu gauge", false},
+		{"untyped without labels", "up 1\n", false, "up{} 1 0 up untyped", false},
+		{"histogram family", "# TYPE req histogram\nreq_bucket{le=\"+Inf\"} 3\nreq_count 3\n", false, "req_bucket{le=+Inf} 3 0 req histogram\nreq_count{} 3 0 req histogram", false},
+		{"escaped label value", "log{msg=\"a\\\"b\\nc\"} +Inf\n", false, "log{msg=a\"b\nc} +Inf 0 log untyped", false},
+		{"openmetrics second timestamp", "# TYPE job:rate gauge\njob:rate 2 1700000000.5 # {trace_id=\"x\"} 1\n# EOF\nignore 1\n", true, "job:rate{} 2 1700000000500 job:rate gauge", false},
+		{"metric name start with digit", "1cpu 1\n", false, "", true},
+		{"metric name with dash", "cpu-used 1\n", false, "", true},
+		{"label name with colon", "cpu{a:b=\"1\"} 1\n", false, "", true},
+		{"label name start with digit", "cpu{1a=\"1\"} 1\n", false, "", true},
+		{"label not closed", "cpu{a=\"1\" 1\n", false, "", true},
+		{"value illegal", "cpu abc\n", false, "", true},
+		{"timestamp illegal", "cpu 1 1.5\n", false, "", true},
+	}
+	for _, v := range testCases {
+		samples, err := ParseTextMetrics([]byte(v.body), v.openMetrics)
+		if v.illegal {
+			if err == nil {
+				t.Errorf("%s should fail", v.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s parse fail,%v", v.name, err)
+			continue
+		}
+		if get := formatTestSamples(samples); get != v.expect {
+			t.Errorf("%s expect:\n%s\nget:\n%s", v.name, v.expect, get)
+		}
+	}
+}
+
+func TestParseInfluxLine(t *testing.T) {
+	testCases := []struct {
+		name      string
+		body      string
+		precision string
+		expect    string
+		illegal   bool
+	}{
+		{"fields to gauges", "cpu,host=a,region=b usage=0.5,idle=99i 1700000000000000000\n", "", "cpu_usage{host=a,region=b} 0.5 1700000000000 cpu_usage gauge\ncpu_idle{host=a,region=b} 99 1700000000000 cpu_idle gauge", false},
+		{"second precision", "mem used=1 1700000000\n", "s", "mem_used{} 1 1700000000000 mem_used gauge", false},
+		{"string and bool fields", "app,env=prod msg=\"a b,c\",ok=t 1700000000000\n", "ms", "app_ok{env=prod} 1 1700000000000 app_ok gauge", false},
+		{"escaped and sanitized names", "disk\\ io,dev:name=sd\\,a read-bytes=2 1700000000000\n", "ms", "disk_io_read_bytes{dev_name=sd,a} 2 1700000000000 disk_io_read_bytes gauge", false},
+		{"empty tag key dropped", "cpu,=a,host=b usage=1 1700000000000\n", "ms", "cpu_usage{host=b} 1 1700000000000 cpu_usage gauge", false},
+		{"field illegal", "cpu usage 1700000000\n", "s", "", true},
+		{"timestamp illegal", "cpu usage=1 abc\n", "", "", true},
+		{"precision illegal", "cpu usage=1\n", "m", "", true},
+	}
+	for _, v := range testCases {
+		samples, err := ParseInfluxLine([]byte(v.body), v.precision)
+		if v.illegal {
+			if err == nil {
+				t.Errorf("%s should fail", v.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s parse fail,%v", v.name, err)
+			continue
+		}
+		if get := formatTestSamples(samples); get != v.expect {
+			t.Errorf("%s expect:\n%s\nget:\n%s", v.name, v.expect, get)
+		}
+	}
+}
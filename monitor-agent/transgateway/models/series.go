@@ -0,0 +1,348 @@
+package models
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	MetricTypeCounter   = "counter"
+	MetricTypeGauge     = "gauge"
+	MetricTypeHistogram = "histogram"
+	MetricTypeSummary   = "summary"
+	MetricTypeUntyped   = "untyped"
+)
+
+// histogram/summary 由多条序列组成,按后缀归到同一个指标族
+var familySuffixList = []string{"_bucket", "_sum", "_count", "_total", "_created", "_gcount", "_gsum"}
+
+// SeriesSample 带标签的样本,由 remote_write/OpenMetrics/Influx 写入,Timestamp 为毫秒,0 表示未带时间戳
+type SeriesSample struct {
+	Name      string
+	Family    string
+	Type      string
+	Help      string
+	Labels    []*Label
+	Value     float64
+	Timestamp int64
+}
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+const seriesShardNum = 32
+
+type seriesObj struct {
+	Sample     *SeriesSample
+	Owner      *Member
+	LastUpdate time.Time
+}
+
+type seriesShard struct {
+	lock   sync.RWMutex
+	series map[string]*seriesObj
+}
+
+type familyMeta struct {
+	Type string
+	Help string
+}
+
+// SeriesStore 按 名称+标签 的哈希分片保存最新样本,指标族的类型与说明单独保存
+type SeriesStore struct {
+	shards     [seriesShardNum]*seriesShard
+	familyLock sync.RWMutex
+	families   map[string]*familyMeta
+}
+
+var SeriesCache = newSeriesStore()
+
+func newSeriesStore() *SeriesStore {
+	store := &SeriesStore{families: make(map[string]*familyMeta)}
+	for i := range store.shards {
+		store.shards[i] = &seriesShard{series: make(map[string]*seriesObj)}
+	}
+	return store
+}
+
+// SortLabels 按标签名排序,并去掉值为空的标签
+func SortLabels(labels []*Label) []*Label {
+	result := []*Label{}
+	for _, v := range labels {
+		if v.Name == "" || v.Value == "" {
+			continue
+		}
+		result = append(result, v)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// SetLabel 标签不存在时才添加,返回新的有序标签列表,不修改传入的列表
+func SetLabel(labels []*Label, name, value string) []*Label {
+	for _, v := range labels {
+		if v.Name == name {
+			return labels
+		}
+	}
+	result := make([]*Label, 0, len(labels)+1)
+	result = append(result, labels...)
+	return SortLabels(append(result, &Label{Name: name, Value: value}))
+}
+
+// GetFamilyName 根据已声明类型的指标族推断样本所属的族,如 http_duration_bucket -> http_duration
+func GetFamilyName(name string, typeMap map[string]string) string {
+	if _, b := typeMap[name]; b {
+		return name
+	}
+	for _, suffix := range familySuffixList {
+		if strings.HasSuffix(name, suffix) {
+			if _, b := typeMap[strings.TrimSuffix(name, suffix)]; b {
+				return strings.TrimSuffix(name, suffix)
+			}
+		}
+	}
+	return name
+}
+
+// SanitizeMetricName 把不符合 Prometheus 规范的字符替换为下划线
+func SanitizeMetricName(name string) string {
+	var builder strings.Builder
+	for i, r := range name {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r == '_' || r == ':' || (i > 0 && r >= '0' && r <= '9') {
+			builder.WriteRune(r)
+		} else {
+			builder.WriteRune('_')
+		}
+	}
+	return builder.String()
+}
+
+// SanitizeLabelName 标签名不允许冒号,其它规则同指标名
+func SanitizeLabelName(name string) string {
+	return strings.ReplaceAll(SanitizeMetricName(name), ":", "_")
+}
+
+func seriesKey(sample *SeriesSample) string {
+	var builder strings.Builder
+	builder.WriteString(sample.Name)
+	for _, v := range sample.Labels {
+		builder.WriteString("\xff")
+		builder.WriteString(v.Name)
+		builder.WriteString("=")
+		builder.WriteString(v.Value)
+	}
+	return builder.String()
+}
+
+func (s *SeriesStore) getShard(key string) *seriesShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return s.shards[h.Sum32()%seriesShardNum]
+}
+
+func (s *SeriesStore) updateFamily(sample *SeriesSample) {
+	s.familyLock.RLock()
+	family, b := s.families[sample.Family]
+	s.familyLock.RUnlock()
+	if b && (sample.Type == MetricTypeUntyped || sample.Type == family.Type) && (sample.Help == "" || sample.Help == family.Help) {
+		return
+	}
+	s.familyLock.Lock()
+	if family, b = s.families[sample.Family]; !b {
+		family = &familyMeta{Type: sample.Type}
+		s.families[sample.Family] = family
+	}
+	if sample.Type != MetricTypeUntyped {
+		family.Type = sample.Type
+	}
+	if sample.Help != "" {
+		family.Help = sample.Help
+	}
+	s.familyLock.Unlock()
+}
+
+// Add 写入 member 的样本,新序列超出 member 的序列限制时丢弃,返回写入成功的样本
+func (s *SeriesStore) Add(member *Member, samples []*SeriesSample) (accepted []*SeriesSample) {
+	tNow := time.Now()
+	for _, sample := range samples {
+		if sample.Family == "" {
+			sample.Family = sample.Name
+		}
+		if sample.Type == "" {
+			sample.Type = MetricTypeUntyped
+		}
+		key := seriesKey(sample)
+		shard := s.getShard(key)
+		shard.lock.Lock()
+		exist, b := shard.series[key]
+		if b && sample.Timestamp > 0 && exist.Sample.Timestamp > sample.Timestamp {
+			// 乱序的旧样本不覆盖
+			shard.lock.Unlock()
+			continue
+		}
+		if !b || exist.Owner != member {
+			if !member.acquireSeries() {
+				shard.lock.Unlock()
+				continue
+			}
+			if b {
+				exist.Owner.releaseSeries(1)
+			}
+		}
+		shard.series[key] = &seriesObj{Sample: sample, Owner: member, LastUpdate: tNow}
+		shard.lock.Unlock()
+		s.updateFamily(sample)
+		accepted = append(accepted, sample)
+	}
+	atomic.AddInt64(&member.ingestNum, int64(len(accepted)))
+	if len(accepted) > 0 {
+		wal.append(&walRecord{Type: walRecordSeries, Token: member.Token, Samples: accepted, Time: tNow})
+	}
+	return
+}
+
+// restore 回放 WAL 时使用,较旧的记录不覆盖,不受序列限制
+func (s *SeriesStore) restore(member *Member, samples []*SeriesSample, updateTime time.Time) {
+	for _, sample := range samples {
+		key := seriesKey(sample)
+		shard := s.getShard(key)
+		shard.lock.Lock()
+		exist, b := shard.series[key]
+		if b && exist.LastUpdate.After(updateTime) {
+			shard.lock.Unlock()
+			continue
+		}
+		if !b || exist.Owner != member {
+			atomic.AddInt64(&member.seriesNum, 1)
+			if b {
+				exist.Owner.releaseSeries(1)
+			}
+		}
+		shard.series[key] = &seriesObj{Sample: sample, Owner: member, LastUpdate: updateTime}
+		shard.lock.Unlock()
+		s.updateFamily(sample)
+	}
+}
+
+// CleanTimeout 删除超过 timeout 秒未更新的序列
+func (s *SeriesStore) CleanTimeout(timeout int64) {
+	tNow := time.Now().Unix()
+	for _, shard := range s.shards {
+		shard.lock.Lock()
+		for key, series := range shard.series {
+			if tNow-series.LastUpdate.Unix() > timeout {
+				series.Owner.releaseSeries(1)
+				delete(shard.series, key)
+			}
+		}
+		shard.lock.Unlock()
+	}
+}
+
+// snapshot 按 member 分组返回当前所有序列,写 WAL 检查点使用
+func (s *SeriesStore) snapshot() map[*Member][]*seriesObj {
+	result := make(map[*Member][]*seriesObj)
+	for _, shard := range s.shards {
+		shard.lock.RLock()
+		for _, series := range shard.series {
+			result[series.Owner] = append(result[series.Owner], series)
+		}
+		shard.lock.RUnlock()
+	}
+	return result
+}
+
+// Expose 输出 Prometheus 文本格式,保留原始类型与时间戳
+func (s *SeriesStore) Expose(builder *strings.Builder) {
+	familySeries := make(map[string]map[string]*SeriesSample)
+	for _, shard := range s.shards {
+		shard.lock.RLock()
+		for key, series := range shard.series {
+			if _, b := familySeries[series.Sample.Family]; !b {
+				familySeries[series.Sample.Family] = make(map[string]*SeriesSample)
+			}
+			familySeries[series.Sample.Family][key] = series.Sample
+		}
+		shard.lock.RUnlock()
+	}
+	familyNameList := []string{}
+	for name := range familySeries {
+		familyNameList = append(familyNameList, name)
+	}
+	sort.Strings(familyNameList)
+	s.familyLock.RLock()
+	defer s.familyLock.RUnlock()
+	for _, familyName := range familyNameList {
+		family, b := s.families[familyName]
+		if !b {
+			family = &familyMeta{Type: MetricTypeUntyped}
+		}
+		if family.Help != "" {
+			builder.WriteString(fmt.Sprintf("# HELP %s %s\n", familyName, escapeHelp(family.Help)))
+		}
+		builder.WriteString(fmt.Sprintf("# TYPE %s %s\n", familyName, family.Type))
+		keyList := []string{}
+		for key := range familySeries[familyName] {
+			keyList = append(keyList, key)
+		}
+		sort.Strings(keyList)
+		for _, key := range keyList {
+			builder.WriteString(formatSampleLine(familySeries[familyName][key]))
+		}
+	}
+}
+
+func formatSampleLine(sample *SeriesSample) string {
+	var builder strings.Builder
+	builder.WriteString(sample.Name)
+	if len(sample.Labels) > 0 {
+		builder.WriteString("{")
+		for i, v := range sample.Labels {
+			if i > 0 {
+				builder.WriteString(",")
+			}
+			builder.WriteString(fmt.Sprintf("%s=\"%s\"", v.Name, escapeLabelValue(v.Value)))
+		}
+		builder.WriteString("}")
+	}
+	builder.WriteString(" ")
+	builder.WriteString(formatFloat(sample.Value))
+	if sample.Timestamp > 0 {
+		builder.WriteString(" ")
+		builder.WriteString(strconv.FormatInt(sample.Timestamp, 10))
+	}
+	builder.WriteString("\n")
+	return builder.String()
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(value)
+}
+
+func escapeHelp(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(value)
+}
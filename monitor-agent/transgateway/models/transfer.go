@@ -1,19 +1,13 @@
 package models
 
 import (
-	"bytes"
 	"encoding/gob"
-	"io/ioutil"
 	"log"
 	"net"
 	"os"
-	"sync"
 	"time"
 )
 
-var DataCache []*Member
-var DataStore []*MemberStore
-var TokenCache = make(map[string]string)
 var DataCacheFile = `cache.data`
 var TokenCacheFile = `token.data`
 var MonitorUrl string
@@ -45,16 +39,7 @@ type TransResult struct {
 	SystemTime string `json:"systemTime"`
 }
 
-type Member struct {
-	Id         int
-	Name       string
-	Token      string
-	Metrics    []*MetricObj
-	LastUpdate time.Time
-	Lock       sync.RWMutex
-	Active     bool
-}
-
+// MemberStore 旧版本 cache.data 的格式,只用于迁移
 type MemberStore struct {
 	Id         int
 	Name       string
@@ -82,120 +67,79 @@ type TransGatewayRequestDto struct {
 	Address string `json:"address"`
 }
 
+// CleanTimeoutData 系统超时未推送先置为不活跃,下一轮仍未推送则清空指标;单个指标同样先置为不活跃再删除
 func CleanTimeoutData(timeout int64) {
 	t := time.NewTicker(time.Duration(60) * time.Second).C
 	for {
 		<-t
 		tNow := time.Now().Unix()
-		for i, v := range DataCache {
+		for _, v := range ListMembers() {
 			v.Lock.Lock()
 			if (tNow - v.LastUpdate.Unix()) > timeout {
 				if !v.Active {
-					DataCache[i].Metrics = []*MetricObj{}
+					v.releaseSeries(int64(len(v.Metrics)))
+					v.Metrics = make(map[string]*MetricObj)
 				} else {
-					DataCache[i].Active = false
+					v.Active = false
 				}
 			} else {
-				for _, vv := range v.Metrics {
+				for key, vv := range v.Metrics {
 					if (tNow - vv.LastUpdate.Unix()) > timeout {
-						vv.Active = false
+						if !vv.Active {
+							v.releaseSeries(1)
+							delete(v.Metrics, key)
+						} else {
+							vv.Active = false
+						}
 					}
 				}
 			}
 			v.Lock.Unlock()
 		}
+		SeriesCache.CleanTimeout(timeout)
 	}
 }
 
-func SaveCacheData() {
-	var dataBuffer bytes.Buffer
-	var tokenBuffer bytes.Buffer
-	DataStore = []*MemberStore{}
-	for _, v := range DataCache {
-		var tmpMetrics []MetricObj
-		for _, vv := range v.Metrics {
-			tmpMetrics = append(tmpMetrics, MetricObj{Id: vv.Id, Metric: vv.Metric, Value: vv.Value, InterfaceName: vv.InterfaceName, Object: vv.Object, AttrName: vv.AttrName, HostIp: vv.HostIp, LastUpdate: vv.LastUpdate, Active: vv.Active})
-		}
-		DataStore = append(DataStore, &MemberStore{Id: v.Id, Name: v.Name, Token: v.Token, Metrics: tmpMetrics, LastUpdate: v.LastUpdate, Active: v.Active})
-	}
-	enc := gob.NewEncoder(&dataBuffer)
-	err := enc.Encode(DataStore)
-	if err != nil {
-		log.Println("data cache gob encode error : ", err)
-	} else {
-		ioutil.WriteFile(DataCacheFile, dataBuffer.Bytes(), 0644)
-		log.Println("write cache.data succeed !")
-	}
-	ent := gob.NewEncoder(&tokenBuffer)
-	err = ent.Encode(TokenCache)
-	if err != nil {
-		log.Println("token cache gob encode error : ", err)
-	} else {
-		ioutil.WriteFile(TokenCacheFile, tokenBuffer.Bytes(), 0644)
-		log.Println("write token.data succeed !!")
-	}
-}
-
-func LoadCacheData(dataDir string) {
+// loadLegacyCacheData 读取旧版本退出时整体保存的 cache.data/token.data
+func loadLegacyCacheData(dataDir string) {
 	if dataDir != "" {
 		DataCacheFile = dataDir + "/" + DataCacheFile
 		TokenCacheFile = dataDir + "/" + TokenCacheFile
 	}
-	successLoadToken := false
-	successLoadData := false
+	tokenCache := make(map[string]string)
 	tokenFile, err := os.Open(TokenCacheFile)
-	if err != nil {
-		log.Println("os open token.data fail ", err)
-	} else {
-		dec := gob.NewDecoder(tokenFile)
-		err = dec.Decode(&TokenCache)
+	if err == nil {
+		err = gob.NewDecoder(tokenFile).Decode(&tokenCache)
+		tokenFile.Close()
 		if err != nil {
 			log.Println("gob decode token.data fail : ", err)
-		} else {
-			log.Println("gob decode token.data success")
-			successLoadToken = true
 		}
 	}
+	var dataStore []*MemberStore
 	dataFile, err := os.Open(DataCacheFile)
-	if err != nil {
-		log.Println("os open cache.data fail ", err)
-	} else {
-		dec := gob.NewDecoder(dataFile)
-		err = dec.Decode(&DataStore)
+	if err == nil {
+		err = gob.NewDecoder(dataFile).Decode(&dataStore)
+		dataFile.Close()
 		if err != nil {
 			log.Println("gob decode cache.data fail : ", err)
-		} else {
-			log.Println("gob decode cache.data success")
-			successLoadData = true
-			for _, v := range DataStore {
-				var member Member
-				member.Token = v.Token
-				member.Name = v.Name
-				member.Active = v.Active
-				member.LastUpdate = v.LastUpdate
-				var tmpMetrics []*MetricObj
-				for _, vv := range v.Metrics {
-					if vv.Id == "" {
-						continue
-					}
-					tmpMetrics = append(tmpMetrics, &MetricObj{Id: vv.Id, Metric: vv.Metric, Value: vv.Value, InterfaceName: vv.InterfaceName, Object: vv.Object, AttrName: vv.AttrName, HostIp: vv.HostIp, LastUpdate: vv.LastUpdate, Active: vv.Active})
-				}
-				member.Metrics = tmpMetrics
-				member.Lock = *new(sync.RWMutex)
-				DataCache = append(DataCache, &member)
-				log.Println("load ", v.Name)
+		}
+	}
+	for _, v := range dataStore {
+		member := AddMember(v.Name, v.Token)
+		var tmpMetrics []MetricObj
+		for _, vv := range v.Metrics {
+			if vv.Id != "" {
+				tmpMetrics = append(tmpMetrics, vv)
 			}
 		}
+		member.restoreMetrics(tmpMetrics, v.LastUpdate)
+		member.Active = v.Active
+		log.Println("load ", v.Name)
 	}
-	if successLoadToken && !successLoadData {
-		for k, v := range TokenCache {
-			var member Member
-			member.Token = k
-			member.Name = v
+	for k, v := range tokenCache {
+		if GetMemberByToken(k) == nil {
+			member := AddMember(v, k)
 			member.Active = true
-			member.LastUpdate = time.Now()
-			member.Lock = *new(sync.RWMutex)
-			DataCache = append(DataCache, &member)
 		}
 	}
 }
@@ -0,0 +1,306 @@
+package models
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	walRecordMember = 1
+	walRecordMetric = 2
+	walRecordSeries = 3
+
+	walSegmentPrefix      = "wal-"
+	walSyncInterval       = time.Second
+	walCheckpointInterval = 10 * time.Minute
+	walSegmentMaxSize     = 128 * 1024 * 1024
+	walSnapshotBatchSize  = 1000
+)
+
+// walRecord 每次写入一条记录,回放时按记录中的时间判断新旧
+type walRecord struct {
+	Type    int
+	Token   string
+	Name    string
+	Metrics []MetricObj
+	Samples []*SeriesSample
+	Time    time.Time
+}
+
+// writeAheadLog 分段的 gob 流,每个段文件一个 encoder,
+// 定期把当前内存数据写成检查点放到新段的开头,再删除之前的段
+type writeAheadLog struct {
+	dir        string
+	lock       sync.Mutex
+	file       *os.File
+	writer     *bufio.Writer
+	encoder    *gob.Encoder
+	segment    int
+	size       int64
+	dirty      bool
+	errNum     int64
+	checkpoint time.Time
+}
+
+var wal *writeAheadLog
+
+type countWriter struct {
+	w    io.Writer
+	size *int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	atomic.AddInt64(c.size, int64(n))
+	return n, err
+}
+
+func segmentFileName(segment int) string {
+	return fmt.Sprintf("%s%08d", walSegmentPrefix, segment)
+}
+
+func listWalSegments(dir string) (segmentList []int) {
+	fileList, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, file := range fileList {
+		if file.IsDir() || !strings.HasPrefix(file.Name(), walSegmentPrefix) {
+			continue
+		}
+		if segment, parseErr := strconv.Atoi(strings.TrimPrefix(file.Name(), walSegmentPrefix)); parseErr == nil {
+			segmentList = append(segmentList, segment)
+		}
+	}
+	sort.Ints(segmentList)
+	return
+}
+
+// append wal 未初始化(如回放过程中)时忽略
+func (w *writeAheadLog) append(record *walRecord) {
+	if w == nil {
+		return
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.encoder == nil {
+		return
+	}
+	if err := w.encoder.Encode(record); err != nil {
+		atomic.AddInt64(&w.errNum, 1)
+		log.Println("wal append fail ", err)
+		return
+	}
+	w.dirty = true
+}
+
+// openSegment 需持有锁
+func (w *writeAheadLog) openSegment(segment int) error {
+	w.closeSegment()
+	f, err := os.OpenFile(filepath.Join(w.dir, segmentFileName(segment)), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("open wal segment %d fail,%s ", segment, err.Error())
+	}
+	w.file = f
+	w.segment = segment
+	atomic.StoreInt64(&w.size, 0)
+	w.writer = bufio.NewWriterSize(f, 256*1024)
+	w.encoder = gob.NewEncoder(&countWriter{w: w.writer, size: &w.size})
+	return nil
+}
+
+// closeSegment 需持有锁
+func (w *writeAheadLog) closeSegment() {
+	if w.file == nil {
+		return
+	}
+	w.writer.Flush()
+	w.file.Sync()
+	w.file.Close()
+	w.file = nil
+	w.writer = nil
+	w.encoder = nil
+}
+
+func (w *writeAheadLog) sync() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if !w.dirty || w.writer == nil {
+		return
+	}
+	if err := w.writer.Flush(); err != nil {
+		atomic.AddInt64(&w.errNum, 1)
+		log.Println("wal flush fail ", err)
+		return
+	}
+	w.file.Sync()
+	w.dirty = false
+}
+
+// doCheckpoint 切换到新段,写入当前全部数据后删除旧段,切换后的新写入与检查点交错时回放按时间判断新旧
+func (w *writeAheadLog) doCheckpoint() {
+	w.lock.Lock()
+	oldSegment := w.segment
+	if err := w.openSegment(oldSegment + 1); err != nil {
+		w.lock.Unlock()
+		atomic.AddInt64(&w.errNum, 1)
+		log.Println(err)
+		return
+	}
+	w.checkpoint = time.Now()
+	w.lock.Unlock()
+	for _, member := range ListMembers() {
+		w.append(&walRecord{Type: walRecordMember, Token: member.Token, Name: member.Name, Time: member.LastUpdate})
+		member.Lock.RLock()
+		var metrics []MetricObj
+		for _, v := range member.Metrics {
+			metrics = append(metrics, *v)
+		}
+		updateTime := member.LastUpdate
+		member.Lock.RUnlock()
+		if len(metrics) > 0 {
+			w.append(&walRecord{Type: walRecordMetric, Token: member.Token, Metrics: metrics, Time: updateTime})
+		}
+	}
+	for member, seriesList := range SeriesCache.snapshot() {
+		for start := 0; start < len(seriesList); start += walSnapshotBatchSize {
+			end := start + walSnapshotBatchSize
+			if end > len(seriesList) {
+				end = len(seriesList)
+			}
+			// 同一批次的更新时间取最早的,回放时不会覆盖检查点之后的新样本
+			var samples []*SeriesSample
+			updateTime := seriesList[start].LastUpdate
+			for _, series := range seriesList[start:end] {
+				samples = append(samples, series.Sample)
+				if series.LastUpdate.Before(updateTime) {
+					updateTime = series.LastUpdate
+				}
+			}
+			w.append(&walRecord{Type: walRecordSeries, Token: member.Token, Samples: samples, Time: updateTime})
+		}
+	}
+	w.sync()
+	for _, segment := range listWalSegments(w.dir) {
+		if segment <= oldSegment {
+			os.Remove(filepath.Join(w.dir, segmentFileName(segment)))
+		}
+	}
+	log.Printf("wal checkpoint done,segment %d \n", oldSegment+1)
+}
+
+func (w *writeAheadLog) run() {
+	t := time.NewTicker(walSyncInterval)
+	for {
+		<-t.C
+		w.sync()
+		if time.Since(w.checkpoint) > walCheckpointInterval || atomic.LoadInt64(&w.size) > walSegmentMaxSize {
+			w.doCheckpoint()
+		}
+	}
+}
+
+func (w *writeAheadLog) close() {
+	w.lock.Lock()
+	w.closeSegment()
+	w.lock.Unlock()
+}
+
+func (w *writeAheadLog) getSize() int64 {
+	if w == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&w.size)
+}
+
+func (w *writeAheadLog) getErrNum() int64 {
+	if w == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&w.errNum)
+}
+
+// replayWal 依次回放各段,段尾不完整(进程异常退出)时丢弃该段剩余部分
+func replayWal(dir string, segmentList []int) {
+	var recordNum int
+	for _, segment := range segmentList {
+		f, err := os.Open(filepath.Join(dir, segmentFileName(segment)))
+		if err != nil {
+			log.Println("open wal segment fail ", err)
+			continue
+		}
+		dec := gob.NewDecoder(bufio.NewReader(f))
+		for {
+			var record walRecord
+			if err = dec.Decode(&record); err != nil {
+				if err != io.EOF {
+					log.Printf("wal segment %d decode fail,skip the rest:%s \n", segment, err.Error())
+				}
+				break
+			}
+			applyWalRecord(&record)
+			recordNum++
+		}
+		f.Close()
+	}
+	log.Printf("wal replay done,%d segments %d records \n", len(segmentList), recordNum)
+}
+
+func applyWalRecord(record *walRecord) {
+	member := GetMemberByToken(record.Token)
+	if member == nil {
+		if record.Type != walRecordMember {
+			return
+		}
+		member = AddMember(record.Name, record.Token)
+		member.LastUpdate = record.Time
+	}
+	switch record.Type {
+	case walRecordMetric:
+		member.restoreMetrics(record.Metrics, record.Time)
+	case walRecordSeries:
+		SeriesCache.restore(member, record.Samples, record.Time)
+	}
+}
+
+// InitStore 回放 WAL 恢复数据,没有 WAL 时尝试从旧版本的 cache.data/token.data 迁移
+func InitStore(dataDir string, limit StoreLimit, timeout int64) {
+	storeLimit = limit
+	walDir := filepath.Join(dataDir, "wal")
+	if err := os.MkdirAll(walDir, 0755); err != nil {
+		log.Println("make wal dir fail ", err)
+	}
+	segmentList := listWalSegments(walDir)
+	if len(segmentList) > 0 {
+		replayWal(walDir, segmentList)
+	} else {
+		loadLegacyCacheData(dataDir)
+	}
+	SeriesCache.CleanTimeout(timeout)
+	w := &writeAheadLog{dir: walDir}
+	if len(segmentList) > 0 {
+		w.segment = segmentList[len(segmentList)-1]
+	}
+	wal = w
+	w.doCheckpoint()
+	go w.run()
+}
+
+// CloseStore 退出前把 WAL 缓冲写入磁盘
+func CloseStore() {
+	if wal != nil {
+		wal.close()
+	}
+}
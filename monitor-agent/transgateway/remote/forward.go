@@ -0,0 +1,135 @@
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	m "github.com/WeBankPartners/open-monitor/monitor-agent/transgateway/models"
+	"github.com/golang/snappy"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+const (
+	forwardBatchSize     = 1000
+	forwardQueueSize     = 1000
+	forwardFlushInterval = 5 * time.Second
+	forwardRetryTimes    = 3
+)
+
+var (
+	forwardUrl    string
+	forwardChan   chan []*m.SeriesSample
+	forwardClient = &http.Client{Timeout: 30 * time.Second}
+)
+
+// InitForward 配置了 remote_write 地址时把收到的带标签样本转发给 Prometheus(需开启 --web.enable-remote-write-receiver)
+func InitForward(url string) {
+	if url == "" {
+		return
+	}
+	forwardUrl = url
+	forwardChan = make(chan []*m.SeriesSample, forwardQueueSize)
+	go runForward()
+	log.Println("remote write forward to ", url)
+}
+
+func ForwardEnable() bool {
+	return forwardChan != nil
+}
+
+// Forward 队列满时丢弃,不阻塞写入请求
+func Forward(samples []*m.SeriesSample) {
+	if forwardChan == nil || len(samples) == 0 {
+		return
+	}
+	select {
+	case forwardChan <- samples:
+	default:
+		log.Printf("remote write queue full,drop %d samples \n", len(samples))
+	}
+}
+
+func runForward() {
+	t := time.NewTicker(forwardFlushInterval)
+	var buffer []*m.SeriesSample
+	for {
+		select {
+		case samples := <-forwardChan:
+			buffer = append(buffer, samples...)
+			if len(buffer) < forwardBatchSize {
+				continue
+			}
+		case <-t.C:
+			if len(buffer) == 0 {
+				continue
+			}
+		}
+		if err := sendWriteRequest(buildWriteRequest(buffer)); err != nil {
+			log.Printf("remote write %d samples fail,%s \n", len(buffer), err.Error())
+		}
+		buffer = []*m.SeriesSample{}
+	}
+}
+
+func buildWriteRequest(samples []*m.SeriesSample) *WriteRequest {
+	req := &WriteRequest{}
+	nowTimestamp := time.Now().UnixNano() / 1e6
+	familyMap := make(map[string]bool)
+	for _, sample := range samples {
+		labels := []*m.Label{{Name: "__name__", Value: sample.Name}}
+		labels = append(labels, sample.Labels...)
+		sort.Slice(labels, func(i, j int) bool {
+			return labels[i].Name < labels[j].Name
+		})
+		timestamp := sample.Timestamp
+		if timestamp <= 0 {
+			timestamp = nowTimestamp
+		}
+		req.Timeseries = append(req.Timeseries, &TimeSeries{Labels: labels, Samples: []*Sample{{Value: sample.Value, Timestamp: timestamp}}})
+		if !familyMap[sample.Family] && sample.Type != "" && sample.Type != m.MetricTypeUntyped {
+			familyMap[sample.Family] = true
+			req.Metadata = append(req.Metadata, &MetricMetadata{Type: sample.Type, MetricFamilyName: sample.Family, Help: sample.Help})
+		}
+	}
+	return req
+}
+
+func sendWriteRequest(req *WriteRequest) (err error) {
+	body := snappy.Encode(nil, req.Marshal())
+	for i := 0; i < forwardRetryTimes; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(i) * time.Second)
+		}
+		var retry bool
+		retry, err = doSendWriteRequest(body)
+		if err == nil || !retry {
+			return
+		}
+	}
+	return
+}
+
+// doSendWriteRequest 5xx 与网络错误可以重试,4xx 说明数据有问题,重试也没用
+func doSendWriteRequest(body []byte) (retry bool, err error) {
+	request, err := http.NewRequest(http.MethodPost, forwardUrl, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Encoding", "snappy")
+	request.Header.Set("Content-Type", "application/x-protobuf")
+	request.Header.Set("User-Agent", "open-monitor-transgateway")
+	request.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	resp, err := forwardClient.Do(request)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode/100 == 5, fmt.Errorf("resp code:%d body:%s ", resp.StatusCode, string(respBody))
+}
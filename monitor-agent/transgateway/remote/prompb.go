@@ -0,0 +1,344 @@
+package remote
+
+import (
+	"encoding/binary"
+	"fmt"
+	m "github.com/WeBankPartners/open-monitor/monitor-agent/transgateway/models"
+	"math"
+)
+
+// 以下结构与 prometheus/prompb 的 WriteRequest 保持一致,只处理 remote_write 用到的字段,
+// 未识别的字段(exemplars,native histograms 等)按 wire type 跳过
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// prompb MetricMetadata.MetricType
+var metadataTypeNames = map[uint64]string{0: "untyped", 1: "counter", 2: "gauge", 3: "histogram", 4: "gaugehistogram", 5: "summary", 6: "info", 7: "stateset"}
+
+type WriteRequest struct {
+	Timeseries []*TimeSeries
+	Metadata   []*MetricMetadata
+}
+
+type TimeSeries struct {
+	Labels  []*m.Label
+	Samples []*Sample
+}
+
+type Sample struct {
+	Value     float64
+	Timestamp int64
+}
+
+type MetricMetadata struct {
+	Type             string
+	MetricFamilyName string
+	Help             string
+	Unit             string
+}
+
+type protoReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *protoReader) done() bool {
+	return r.pos >= len(r.buf)
+}
+
+func (r *protoReader) readVarint() (uint64, error) {
+	value, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 {
+		return 0, fmt.Errorf("varint illegal at %d", r.pos)
+	}
+	r.pos += n
+	return value, nil
+}
+
+func (r *protoReader) readTag() (fieldNum int, wireType int, err error) {
+	tag, err := r.readVarint()
+	if err != nil {
+		return
+	}
+	return int(tag >> 3), int(tag & 7), nil
+}
+
+func (r *protoReader) readBytes() ([]byte, error) {
+	length, err := r.readVarint()
+	if err != nil {
+		return nil, err
+	}
+	if length > uint64(len(r.buf)-r.pos) {
+		return nil, fmt.Errorf("length %d out of range at %d", length, r.pos)
+	}
+	data := r.buf[r.pos : r.pos+int(length)]
+	r.pos += int(length)
+	return data, nil
+}
+
+func (r *protoReader) readFixed64() (uint64, error) {
+	if len(r.buf)-r.pos < 8 {
+		return 0, fmt.Errorf("fixed64 out of range at %d", r.pos)
+	}
+	value := binary.LittleEndian.Uint64(r.buf[r.pos:])
+	r.pos += 8
+	return value, nil
+}
+
+func (r *protoReader) skip(wireType int) (err error) {
+	switch wireType {
+	case wireVarint:
+		_, err = r.readVarint()
+	case wireFixed64:
+		_, err = r.readFixed64()
+	case wireBytes:
+		_, err = r.readBytes()
+	case wireFixed32:
+		if len(r.buf)-r.pos < 4 {
+			return fmt.Errorf("fixed32 out of range at %d", r.pos)
+		}
+		r.pos += 4
+	default:
+		err = fmt.Errorf("wire type %d not support", wireType)
+	}
+	return
+}
+
+// UnmarshalWriteRequest 解码 snappy 解压后的 WriteRequest
+func UnmarshalWriteRequest(data []byte) (result *WriteRequest, err error) {
+	result = &WriteRequest{}
+	r := &protoReader{buf: data}
+	for !r.done() {
+		fieldNum, wireType, tagErr := r.readTag()
+		if tagErr != nil {
+			return nil, tagErr
+		}
+		if wireType != wireBytes || (fieldNum != 1 && fieldNum != 3) {
+			if err = r.skip(wireType); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		content, readErr := r.readBytes()
+		if readErr != nil {
+			return nil, readErr
+		}
+		if fieldNum == 1 {
+			series, decodeErr := unmarshalTimeSeries(content)
+			if decodeErr != nil {
+				return nil, fmt.Errorf("decode timeseries fail,%s ", decodeErr.Error())
+			}
+			result.Timeseries = append(result.Timeseries, series)
+		} else {
+			metadata, decodeErr := unmarshalMetadata(content)
+			if decodeErr != nil {
+				return nil, fmt.Errorf("decode metadata fail,%s ", decodeErr.Error())
+			}
+			result.Metadata = append(result.Metadata, metadata)
+		}
+	}
+	return
+}
+
+func unmarshalTimeSeries(data []byte) (result *TimeSeries, err error) {
+	result = &TimeSeries{}
+	r := &protoReader{buf: data}
+	for !r.done() {
+		fieldNum, wireType, tagErr := r.readTag()
+		if tagErr != nil {
+			return nil, tagErr
+		}
+		if wireType != wireBytes || (fieldNum != 1 && fieldNum != 2) {
+			if err = r.skip(wireType); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		content, readErr := r.readBytes()
+		if readErr != nil {
+			return nil, readErr
+		}
+		if fieldNum == 1 {
+			label, decodeErr := unmarshalLabel(content)
+			if decodeErr != nil {
+				return nil, decodeErr
+			}
+			result.Labels = append(result.Labels, label)
+		} else {
+			sample, decodeErr := unmarshalSample(content)
+			if decodeErr != nil {
+				return nil, decodeErr
+			}
+			result.Samples = append(result.Samples, sample)
+		}
+	}
+	return
+}
+
+func unmarshalLabel(data []byte) (result *m.Label, err error) {
+	result = &m.Label{}
+	r := &protoReader{buf: data}
+	for !r.done() {
+		fieldNum, wireType, tagErr := r.readTag()
+		if tagErr != nil {
+			return nil, tagErr
+		}
+		if wireType != wireBytes || (fieldNum != 1 && fieldNum != 2) {
+			if err = r.skip(wireType); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		content, readErr := r.readBytes()
+		if readErr != nil {
+			return nil, readErr
+		}
+		if fieldNum == 1 {
+			result.Name = string(content)
+		} else {
+			result.Value = string(content)
+		}
+	}
+	return
+}
+
+func unmarshalSample(data []byte) (result *Sample, err error) {
+	result = &Sample{}
+	r := &protoReader{buf: data}
+	for !r.done() {
+		fieldNum, wireType, tagErr := r.readTag()
+		if tagErr != nil {
+			return nil, tagErr
+		}
+		switch {
+		case fieldNum == 1 && wireType == wireFixed64:
+			bits, readErr := r.readFixed64()
+			if readErr != nil {
+				return nil, readErr
+			}
+			result.Value = math.Float64frombits(bits)
+		case fieldNum == 2 && wireType == wireVarint:
+			timestamp, readErr := r.readVarint()
+			if readErr != nil {
+				return nil, readErr
+			}
+			result.Timestamp = int64(timestamp)
+		default:
+			if err = r.skip(wireType); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return
+}
+
+func unmarshalMetadata(data []byte) (result *MetricMetadata, err error) {
+	// type 为 0 时按 protobuf 默认值不会编码
+	result = &MetricMetadata{Type: metadataTypeNames[0]}
+	r := &protoReader{buf: data}
+	for !r.done() {
+		fieldNum, wireType, tagErr := r.readTag()
+		if tagErr != nil {
+			return nil, tagErr
+		}
+		if fieldNum == 1 && wireType == wireVarint {
+			metricType, readErr := r.readVarint()
+			if readErr != nil {
+				return nil, readErr
+			}
+			result.Type = metadataTypeNames[metricType]
+			continue
+		}
+		if wireType != wireBytes {
+			if err = r.skip(wireType); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		content, readErr := r.readBytes()
+		if readErr != nil {
+			return nil, readErr
+		}
+		switch fieldNum {
+		case 2:
+			result.MetricFamilyName = string(content)
+		case 4:
+			result.Help = string(content)
+		case 5:
+			result.Unit = string(content)
+		}
+	}
+	return
+}
+
+type protoWriter struct {
+	buf []byte
+}
+
+func (w *protoWriter) writeVarint(value uint64) {
+	tmpBuf := make([]byte, binary.MaxVarintLen64)
+	w.buf = append(w.buf, tmpBuf[:binary.PutUvarint(tmpBuf, value)]...)
+}
+
+func (w *protoWriter) writeFixed64(value uint64) {
+	tmpBuf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(tmpBuf, value)
+	w.buf = append(w.buf, tmpBuf...)
+}
+
+func (w *protoWriter) writeTag(fieldNum, wireType int) {
+	w.writeVarint(uint64(fieldNum<<3 | wireType))
+}
+
+func (w *protoWriter) writeBytes(fieldNum int, data []byte) {
+	w.writeTag(fieldNum, wireBytes)
+	w.writeVarint(uint64(len(data)))
+	w.buf = append(w.buf, data...)
+}
+
+// Marshal 编码成 protobuf,调用方再做 snappy 压缩
+func (req *WriteRequest) Marshal() []byte {
+	w := &protoWriter{}
+	for _, series := range req.Timeseries {
+		seriesWriter := &protoWriter{}
+		for _, label := range series.Labels {
+			labelWriter := &protoWriter{}
+			labelWriter.writeBytes(1, []byte(label.Name))
+			labelWriter.writeBytes(2, []byte(label.Value))
+			seriesWriter.writeBytes(1, labelWriter.buf)
+		}
+		for _, sample := range series.Samples {
+			sampleWriter := &protoWriter{}
+			sampleWriter.writeTag(1, wireFixed64)
+			sampleWriter.writeFixed64(math.Float64bits(sample.Value))
+			sampleWriter.writeTag(2, wireVarint)
+			sampleWriter.writeVarint(uint64(sample.Timestamp))
+			seriesWriter.writeBytes(2, sampleWriter.buf)
+		}
+		w.writeBytes(1, seriesWriter.buf)
+	}
+	for _, metadata := range req.Metadata {
+		metadataWriter := &protoWriter{}
+		for k, v := range metadataTypeNames {
+			if v == metadata.Type && k > 0 {
+				metadataWriter.writeTag(1, wireVarint)
+				metadataWriter.writeVarint(k)
+				break
+			}
+		}
+		metadataWriter.writeBytes(2, []byte(metadata.MetricFamilyName))
+		if metadata.Help != "" {
+			metadataWriter.writeBytes(4, []byte(metadata.Help))
+		}
+		if metadata.Unit != "" {
+			metadataWriter.writeBytes(5, []byte(metadata.Unit))
+		}
+		w.writeBytes(3, metadataWriter.buf)
+	}
+	return w.buf
+}
@@ -0,0 +1,113 @@
+package remote
+
+import (
+	"bytes"
+	m "github.com/WeBankPartners/open-monitor/monitor-agent/transgateway/models"
+	"math"
+	"testing"
+)
+
+// up{} 1 @1000 按 prometheus/prompb 编码后的字节
+var upWriteRequestBytes = []byte{
+	0x0a, 0x1e,
+	0x0a, 0x0e, 0x0a, 0x08, '_', '_', 'n', 'a', 'm', 'e', '_', '_', 0x12, 0x02, 'u', 'p',
+	0x12, 0x0c, 0x09, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf0, 0x3f, 0x10, 0xe8, 0x07,
+}
+
+func TestUnmarshalWriteRequestFixture(t *testing.T) {
+	req, err := UnmarshalWriteRequest(upWriteRequestBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(req.Timeseries) != 1 || len(req.Timeseries[0].Labels) != 1 || len(req.Timeseries[0].Samples) != 1 {
+		t.Fatalf("decode fixture struct illegal:%+v", req.Timeseries)
+	}
+	label, sample := req.Timeseries[0].Labels[0], req.Timeseries[0].Samples[0]
+	if label.Name != "__name__" || label.Value != "up" || sample.Value != 1 || sample.Timestamp != 1000 {
+		t.Errorf("decode fixture value illegal,label:%+v sample:%+v", label, sample)
+	}
+	encodeReq := &WriteRequest{Timeseries: []*TimeSeries{{Labels: []*m.Label{{Name: "__name__", Value: "up"}}, Samples: []*Sample{{Value: 1, Timestamp: 1000}}}}}
+	if !bytes.Equal(encodeReq.Marshal(), upWriteRequestBytes) {
+		t.Errorf("encode fixture illegal:%x", encodeReq.Marshal())
+	}
+}
+
+func TestWriteRequestRoundTrip(t *testing.T) {
+	req := &WriteRequest{
+		Timeseries: []*TimeSeries{
+			{Labels: []*m.Label{{Name: "__name__", Value: "http_requests_total"}, {Name: "code", Value: "200"}},
+				Samples: []*Sample{{Value: 12.5, Timestamp: 1700000000000}, {Value: -3, Timestamp: -1}}},
+			{Labels: []*m.Label{{Name: "__name__", Value: "empty_value"}, {Name: "instance", Value: ""}},
+				Samples: []*Sample{{Value: math.Inf(1), Timestamp: 0}}},
+		},
+		Metadata: []*MetricMetadata{
+			{Type: "counter", MetricFamilyName: "http_requests_total", Help: "Total requests.", Unit: "requests"},
+			{Type: "untyped", MetricFamilyName: "empty_value"},
+		},
+	}
+	result, err := UnmarshalWriteRequest(req.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Timeseries) != 2 || len(result.Metadata) != 2 {
+		t.Fatalf("round trip length illegal,timeseries:%d metadata:%d", len(result.Timeseries), len(result.Metadata))
+	}
+	for i, series := range req.Timeseries {
+		resultSeries := result.Timeseries[i]
+		if len(resultSeries.Labels) != len(series.Labels) || len(resultSeries.Samples) != len(series.Samples) {
+			t.Fatalf("round trip series %d length illegal", i)
+		}
+		for j, label := range series.Labels {
+			if *resultSeries.Labels[j] != *label {
+				t.Errorf("round trip label illegal,expect:%+v get:%+v", label, resultSeries.Labels[j])
+			}
+		}
+		for j, sample := range series.Samples {
+			if *resultSeries.Samples[j] != *sample {
+				t.Errorf("round trip sample illegal,expect:%+v get:%+v", sample, resultSeries.Samples[j])
+			}
+		}
+	}
+	for i, metadata := range req.Metadata {
+		if *result.Metadata[i] != *metadata {
+			t.Errorf("round trip metadata illegal,expect:%+v get:%+v", metadata, result.Metadata[i])
+		}
+	}
+}
+
+func TestUnmarshalWriteRequestSkipUnknownField(t *testing.T) {
+	seriesWriter := &protoWriter{}
+	labelWriter := &protoWriter{}
+	labelWriter.writeBytes(1, []byte("__name__"))
+	labelWriter.writeBytes(2, []byte("up"))
+	seriesWriter.writeBytes(1, labelWriter.buf)
+	// exemplars(3) 和一个 fixed32 字段都应跳过
+	seriesWriter.writeBytes(3, []byte{0x0a, 0x00})
+	seriesWriter.writeTag(9, wireFixed32)
+	seriesWriter.buf = append(seriesWriter.buf, 0x01, 0x02, 0x03, 0x04)
+	w := &protoWriter{}
+	w.writeBytes(1, seriesWriter.buf)
+	w.writeTag(5, wireVarint)
+	w.writeVarint(300)
+	result, err := UnmarshalWriteRequest(w.buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Timeseries) != 1 || len(result.Timeseries[0].Labels) != 1 || len(result.Timeseries[0].Samples) != 0 {
+		t.Errorf("skip unknown field illegal:%+v", result.Timeseries)
+	}
+}
+
+func TestUnmarshalWriteRequestIllegal(t *testing.T) {
+	for i := 1; i < len(upWriteRequestBytes); i++ {
+		if _, err := UnmarshalWriteRequest(upWriteRequestBytes[:i]); err == nil {
+			t.Errorf("truncated data with length %d should fail", i)
+		}
+	}
+	if _, err := UnmarshalWriteRequest([]byte{0x0b}); err == nil {
+		t.Errorf("unsupported wire type should fail")
+	}
+	if _, err := UnmarshalWriteRequest([]byte{0x0a, 0xff, 0xff, 0xff, 0xff, 0x0f}); err == nil {
+		t.Errorf("length out of range should fail")
+	}
+}
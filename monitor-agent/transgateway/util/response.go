@@ -18,6 +18,8 @@ func ReturnMessage(c *gin.Context, resp RespJson)  {
 		statusCode = http.StatusOK
 	}else if resp.Code == 1 {
 		statusCode = http.StatusBadRequest
+	}else if resp.Code == 3 {
+		statusCode = http.StatusTooManyRequests
 	}else{
 		statusCode = http.StatusInternalServerError
 	}
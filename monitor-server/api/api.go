@@ -140,6 +140,9 @@ func init() {
 		&handlerFuncObj{Url: "/alarm/problem/query", Method: http.MethodPost, HandlerFunc: alarm.QueryProblemAlarm, ApiCode: "alarm_problem_query"},
 		&handlerFuncObj{Url: "/alarm/problem/page", Method: http.MethodPost, HandlerFunc: alarm.QueryProblemAlarmByPage, ApiCode: "alarm_problem_page"},
 		&handlerFuncObj{Url: "/alarm/problem/close", Method: http.MethodPost, HandlerFunc: alarm.CloseAlarm, ApiCode: "alarm_problem_close"},
+		&handlerFuncObj{Url: "/alarm/problem/ack", Method: http.MethodPost, HandlerFunc: alarm.AckAlarm, ApiCode: "alarm_problem_ack"},
+		&handlerFuncObj{Url: "/alarm/problem/unack", Method: http.MethodPost, HandlerFunc: alarm.UnackAlarm, ApiCode: "alarm_problem_unack"},
+		&handlerFuncObj{Url: "/alarm/problem/ack/log", Method: http.MethodGet, HandlerFunc: alarm.ListAlarmAckLog, ApiCode: "alarm_problem_ack_log"},
 		&handlerFuncObj{Url: "/alarm/problem/history", Method: http.MethodPost, HandlerFunc: alarm.QueryHistoryAlarm, ApiCode: "alarm_problem_history"},
 		&handlerFuncObj{Url: "/alarm/problem/message", Method: http.MethodPost, HandlerFunc: alarm.UpdateAlarmCustomMessage, ApiCode: "alarm_problem_message"},
 		&handlerFuncObj{Url: "/alarm/problem/notify", Method: http.MethodPost, HandlerFunc: alarm.NotifyAlarm, ApiCode: "alarm_problem_notify"},
@@ -264,6 +267,23 @@ func init() {
 		&handlerFuncObj{Url: "/alarm/strategy", Method: http.MethodPut, HandlerFunc: alarmv2.UpdateAlarmStrategy, ApiCode: "alarm_strategy_update"},
 		&handlerFuncObj{Url: "/alarm/strategy/:strategyGuid", Method: http.MethodDelete, HandlerFunc: alarmv2.DeleteAlarmStrategy, ApiCode: "alarm_strategy_delete_by_strategy_guid"},
 		&handlerFuncObj{Url: "/alarm/event/callback/list", Method: http.MethodGet, HandlerFunc: alarmv2.ListCallbackEvent, ApiCode: "alarm_event_callback_list"},
+		&handlerFuncObj{Url: "/alarm/notify_template/query", Method: http.MethodPost, HandlerFunc: alarmv2.QueryNotifyTemplate, ApiCode: "alarm_notify_template_query"},
+		&handlerFuncObj{Url: "/alarm/notify_template/preview", Method: http.MethodPost, HandlerFunc: alarmv2.PreviewNotifyTemplate, ApiCode: "alarm_notify_template_preview"},
+		&handlerFuncObj{Url: "/alarm/notify_template/:templateGuid", Method: http.MethodGet, HandlerFunc: alarmv2.GetNotifyTemplate, ApiCode: "alarm_notify_template_get_by_template_guid"},
+		&handlerFuncObj{Url: "/alarm/notify_template", Method: http.MethodPost, HandlerFunc: alarmv2.CreateNotifyTemplate, ApiCode: "alarm_notify_template_create"},
+		&handlerFuncObj{Url: "/alarm/notify_template", Method: http.MethodPut, HandlerFunc: alarmv2.UpdateNotifyTemplate, ApiCode: "alarm_notify_template_update"},
+		&handlerFuncObj{Url: "/alarm/notify_template/:templateGuid", Method: http.MethodDelete, HandlerFunc: alarmv2.DeleteNotifyTemplate, ApiCode: "alarm_notify_template_delete_by_template_guid"},
+		&handlerFuncObj{Url: "/alarm/silence/query", Method: http.MethodPost, HandlerFunc: alarmv2.QueryAlarmSilence, ApiCode: "alarm_silence_query"},
+		&handlerFuncObj{Url: "/alarm/silence", Method: http.MethodPost, HandlerFunc: alarmv2.CreateAlarmSilence, ApiCode: "alarm_silence_create"},
+		&handlerFuncObj{Url: "/alarm/silence", Method: http.MethodPut, HandlerFunc: alarmv2.UpdateAlarmSilence, ApiCode: "alarm_silence_update"},
+		&handlerFuncObj{Url: "/alarm/silence/:silenceGuid", Method: http.MethodDelete, HandlerFunc: alarmv2.DeleteAlarmSilence, ApiCode: "alarm_silence_delete_by_silence_guid"},
+		&handlerFuncObj{Url: "/alarm/inhibit/query", Method: http.MethodPost, HandlerFunc: alarmv2.QueryAlarmInhibitRule, ApiCode: "alarm_inhibit_query"},
+		&handlerFuncObj{Url: "/alarm/inhibit", Method: http.MethodPost, HandlerFunc: alarmv2.CreateAlarmInhibitRule, ApiCode: "alarm_inhibit_create"},
+		&handlerFuncObj{Url: "/alarm/inhibit", Method: http.MethodPut, HandlerFunc: alarmv2.UpdateAlarmInhibitRule, ApiCode: "alarm_inhibit_update"},
+		&handlerFuncObj{Url: "/alarm/inhibit/:inhibitGuid", Method: http.MethodDelete, HandlerFunc: alarmv2.DeleteAlarmInhibitRule, ApiCode: "alarm_inhibit_delete_by_inhibit_guid"},
+		&handlerFuncObj{Url: "/alarm/incident/query", Method: http.MethodPost, HandlerFunc: alarmv2.QueryAlarmIncident, ApiCode: "alarm_incident_query"},
+		&handlerFuncObj{Url: "/alarm/incident/close", Method: http.MethodPost, HandlerFunc: alarmv2.CloseAlarmIncident, ApiCode: "alarm_incident_close"},
+		&handlerFuncObj{Url: "/alarm/incident/:incidentId", Method: http.MethodGet, HandlerFunc: alarmv2.GetAlarmIncident, ApiCode: "alarm_incident_get_by_incident_id"},
 		&handlerFuncObj{Url: "/alarm/strategy/export/:queryType/:guid", Method: http.MethodGet, HandlerFunc: alarmv2.ExportAlarmStrategy, ApiCode: "alarm_strategy_export_by_query_type_and_guid"},
 		&handlerFuncObj{Url: "/alarm/strategy/import/:queryType/:guid", Method: http.MethodPost, HandlerFunc: alarmv2.ImportAlarmStrategy, ApiCode: "alarm_strategy_import_by_query_type_and_guid"},
 		&handlerFuncObj{Url: "/monitor/endpoint/query", Method: http.MethodGet, HandlerFunc: monitor.ListEndpoint, ApiCode: "monitor_endpoint_query"},
@@ -323,6 +343,10 @@ func init() {
 		&handlerFuncObj{Url: "/config/remote/write", Method: http.MethodPost, HandlerFunc: config_new.RemoteWriteConfigCreate, ApiCode: "config_remote_write_post"},
 		&handlerFuncObj{Url: "/config/remote/write", Method: http.MethodPut, HandlerFunc: config_new.RemoteWriteConfigUpdate, ApiCode: "config_remote_write_put"},
 		&handlerFuncObj{Url: "/config/remote/write", Method: http.MethodDelete, HandlerFunc: config_new.RemoteWriteConfigDelete, ApiCode: "config_remote_write_delete"},
+		&handlerFuncObj{Url: "/config/probe/location", Method: http.MethodGet, HandlerFunc: config_new.ProbeLocationList, ApiCode: "config_probe_location_get"},
+		&handlerFuncObj{Url: "/config/probe/location", Method: http.MethodPost, HandlerFunc: config_new.ProbeLocationCreate, ApiCode: "config_probe_location_post"},
+		&handlerFuncObj{Url: "/config/probe/location", Method: http.MethodPut, HandlerFunc: config_new.ProbeLocationUpdate, ApiCode: "config_probe_location_put"},
+		&handlerFuncObj{Url: "/config/probe/location", Method: http.MethodDelete, HandlerFunc: config_new.ProbeLocationDelete, ApiCode: "config_probe_location_delete"},
 		&handlerFuncObj{Url: "/config/type/query", Method: http.MethodGet, HandlerFunc: monitor.QueryTypeConfigList, ApiCode: "config_type_query"},
 		&handlerFuncObj{Url: "/config/type", Method: http.MethodPost, HandlerFunc: monitor.AddTypeConfig, ApiCode: "config_type_add"},
 		&handlerFuncObj{Url: "/config/type-batch", Method: http.MethodPost, HandlerFunc: monitor.BatchAddTypeConfig, ApiCode: "config_type_batch_add"},
@@ -379,6 +403,7 @@ func InitHttpServer() {
 	r.GET(fmt.Sprintf("%s/demo", urlPrefix), dashboard.DisplayWatermark)
 	r.POST(fmt.Sprintf("%s/webhook", urlPrefix), alarm.AcceptAlert)
 	r.POST(fmt.Sprintf("%s/openapi/alarm/send", urlPrefix), alarm.OpenAlarmApi)
+	r.POST(fmt.Sprintf("%s/openapi/alertmanager/webhook", urlPrefix), alarm.AcceptAlertmanagerWebhook)
 	entityApi := r.Group(fmt.Sprintf("%s/entities", urlPrefix), user.AuthRequired())
 	{
 		entityApi.POST("/alarm/query", alarm.QueryEntityAlarm)
@@ -56,7 +56,7 @@ func DeregisterJob(endpointObj m.EndpointTable, operator string) error {
 	var err error
 	guid := endpointObj.Guid
 	pingExporterFlag := false
-	if endpointObj.ExportType == "ping" || endpointObj.ExportType == "telnet" || endpointObj.ExportType == "http" {
+	if m.IsPingExporterType(endpointObj.ExportType) {
 		pingExporterFlag = true
 	}
 	if endpointObj.AddressAgent != "" && pingExporterFlag == false {
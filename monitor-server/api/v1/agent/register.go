@@ -106,6 +106,19 @@ func AgentRegister(param m.RegisterParamNew, operator string) (validateMessage,
 	}
 	var rData returnData
 	var stepList []int
+	var probeLocationList []*m.ProbeLocationTable
+	var probeQuorum int
+	if len(param.ProbeLocations) > 0 {
+		if !m.IsPingExporterType(param.Type) {
+			return fmt.Sprintf("%s endpoint can not use probe locations", param.Type), guid, nil
+		}
+		probeLocationList, probeQuorum, err = db.CheckProbeLocation(param.ProbeLocations, param.ProbeQuorum)
+		if err != nil {
+			return err.Error(), guid, nil
+		}
+		// address_agent 记第一个拨测点,下发采集源和服务发现时按所有拨测点处理
+		param.ExportAddress = probeLocationList[0].Address
+	}
 	switch param.Type {
 	case "host":
 		rData = hostRegister(param)
@@ -123,6 +136,8 @@ func AgentRegister(param m.RegisterParamNew, operator string) (validateMessage,
 		rData = telnetRegister(param)
 	case "http":
 		rData = httpRegister(param)
+	case m.ProbeTypeTcp, m.ProbeTypeTls, m.ProbeTypeDns:
+		rData = probeRegister(param)
 	case "windows":
 		rData = windowsRegister(param)
 	case "snmp":
@@ -147,6 +162,15 @@ func AgentRegister(param m.RegisterParamNew, operator string) (validateMessage,
 	if err != nil {
 		return validateMessage, guid, err
 	}
+	if m.IsPingExporterType(param.Type) {
+		if err = db.UpdateEndpointProbeLocation(guid, probeLocationList, probeQuorum); err != nil {
+			return validateMessage, guid, err
+		}
+		// 多拨测点的对象放到按 quorum 告警的默认组,避免单个拨测点网络问题就告警
+		if len(probeLocationList) > 0 && rData.defaultGroup != "" {
+			rData.defaultGroup = fmt.Sprintf("default_%s_location_group", param.Type)
+		}
+	}
 	if rData.fetchMetric {
 		if rData.storeMetric {
 			err = db.RegisterEndpointMetric(rData.endpoint.Id, rData.metricList)
@@ -643,6 +667,15 @@ func httpRegister(param m.RegisterParamNew) returnData {
 	result.endpoint.Address = fmt.Sprintf("%s:%s", param.Ip, param.Port)
 	result.endpoint.ExportType = param.Type
 	result.endpoint.Step = defaultStep
+	var checkConfig string
+	if param.HttpCheck != nil {
+		if err := param.HttpCheck.Validate(); err != nil {
+			result.validateMessage = err.Error()
+			return result
+		}
+		b, _ := json.Marshal(param.HttpCheck)
+		checkConfig = string(b)
+	}
 	result.extendParam = m.EndpointExtendParamObj{Enable: true, HttpMethod: param.Method, HttpUrl: param.Url}
 	if param.ExportAddress != "" {
 		param.ExportAddress = formatExportAddress(param.ExportAddress)
@@ -654,7 +687,7 @@ func httpRegister(param m.RegisterParamNew) returnData {
 	result.addDefaultGroup = true
 	result.agentManager = false
 	var eho []*m.EndpointHttpTable
-	eho = append(eho, &m.EndpointHttpTable{EndpointGuid: result.endpoint.Guid, Url: param.Url, Method: param.Method})
+	eho = append(eho, &m.EndpointHttpTable{EndpointGuid: result.endpoint.Guid, Url: param.Url, Method: param.Method, CheckConfig: checkConfig})
 	err := db.UpdateEndpointHttp(eho)
 	if err != nil {
 		result.err = err
@@ -662,6 +695,51 @@ func httpRegister(param m.RegisterParamNew) returnData {
 	return result
 }
 
+// probeRegister tcp/tls 的目标是 ip:port,dns 的 ip:port 是 dns 服务器,端口默认53
+func probeRegister(param m.RegisterParamNew) returnData {
+	var result returnData
+	if mid.IsIllegalName(param.Name) {
+		result.validateMessage = "param instance name illegal"
+		return result
+	}
+	if param.Type == m.ProbeTypeDns && param.Port == "" {
+		param.Port = "53"
+	}
+	if param.Ip == "" || param.Port == "" {
+		result.validateMessage = fmt.Sprintf("%s probe ip/port can not empty ", param.Type)
+		return result
+	}
+	if param.Probe == nil {
+		param.Probe = &m.ProbeConfigObj{}
+	}
+	if err := param.Probe.Validate(param.Type); err != nil {
+		result.validateMessage = err.Error()
+		return result
+	}
+	result.endpoint.Guid = fmt.Sprintf("%s_%s_%s", param.Name, param.Ip, param.Type)
+	result.endpoint.Name = param.Name
+	result.endpoint.Ip = param.Ip
+	result.endpoint.Address = fmt.Sprintf("%s:%s", param.Ip, param.Port)
+	result.endpoint.ExportType = param.Type
+	result.endpoint.Step = defaultStep
+	result.extendParam = m.EndpointExtendParamObj{Enable: true, Ip: param.Ip, Port: param.Port}
+	if param.ExportAddress != "" {
+		param.ExportAddress = formatExportAddress(param.ExportAddress)
+		result.endpoint.AddressAgent = param.ExportAddress
+		result.fetchMetric = true
+		result.extendParam.ExportAddress = param.ExportAddress
+	}
+	result.defaultGroup = fmt.Sprintf("default_%s_group", param.Type)
+	result.addDefaultGroup = true
+	result.agentManager = false
+	b, _ := json.Marshal(param.Probe)
+	err := db.UpdateEndpointProbe(&m.EndpointProbeTable{EndpointGuid: result.endpoint.Guid, ProbeType: param.Type, Target: result.endpoint.Address, Config: string(b)})
+	if err != nil {
+		result.err = err
+	}
+	return result
+}
+
 func windowsRegister(param m.RegisterParamNew) returnData {
 	var result returnData
 	result.endpoint.Step = defaultStep
@@ -819,6 +897,10 @@ func otherExporterRegister(param m.RegisterParamNew) returnData {
 	result.endpoint.Address = fmt.Sprintf("%s:%s", param.Ip, param.Port)
 	result.fetchMetric = true
 	result.agentManager = false
+	// postgresql,sqlserver 等数据库对象带上实例地址和账号,供 db_data_exporter 执行自定义 sql 指标,其它类型不保存账号
+	if param.User != "" && m.IsDbType(param.Type) {
+		result.extendParam = m.BuildDbExtendParam(&param)
+	}
 	return result
 }
 
@@ -169,6 +169,7 @@ func buildNewAlarm(param *m.AMRespAlert, nowTime time.Time) (alarm m.AlarmHandle
 		alarm.EndValue = alertValue
 		alarm.End = nowTime
 		alarm.AlarmConditionGuid = alarmConditionGuid
+		alarm.InhibitBy = existAlarm.InhibitBy
 	} else if operation == "add" {
 		if !db.InActiveWindowList(strategyObj.ActiveWindow) {
 			return alarm, fmt.Errorf("Alarm:%s not in active window:%s ", strategyObj.Guid, strategyObj.ActiveWindow)
@@ -614,6 +615,7 @@ func QueryProblemAlarmByPage(c *gin.Context) {
 		UserRoles:           mid.GetOperateUserRoles(c),
 		Token:               c.GetHeader("Authorization"),
 		Query:               param.Query,
+		AckStatus:           param.AckStatus,
 	})
 	if err != nil {
 		mid.ReturnQueryTableError(c, "alarm", err)
@@ -719,6 +721,46 @@ func CloseAlarm(c *gin.Context) {
 	mid.ReturnSuccess(c)
 }
 
+func AckAlarm(c *gin.Context) {
+	var param m.AlarmAckParam
+	if err := c.ShouldBindJSON(&param); err != nil {
+		mid.ReturnValidateError(c, err.Error())
+		return
+	}
+	if err := db.AckAlarm(&param, mid.GetOperateUser(c)); err != nil {
+		mid.ReturnHandleError(c, err.Error(), err)
+		return
+	}
+	mid.ReturnSuccess(c)
+}
+
+func UnackAlarm(c *gin.Context) {
+	var param m.AlarmAckParam
+	if err := c.ShouldBindJSON(&param); err != nil {
+		mid.ReturnValidateError(c, err.Error())
+		return
+	}
+	if err := db.UnackAlarm(&param, mid.GetOperateUser(c)); err != nil {
+		mid.ReturnHandleError(c, err.Error(), err)
+		return
+	}
+	mid.ReturnSuccess(c)
+}
+
+func ListAlarmAckLog(c *gin.Context) {
+	alarmId, _ := strconv.Atoi(c.Query("id"))
+	if alarmId <= 0 {
+		mid.ReturnParamEmptyError(c, "id")
+		return
+	}
+	result, err := db.ListAlarmAckLog(alarmId)
+	if err != nil {
+		mid.ReturnHandleError(c, err.Error(), err)
+		return
+	}
+	mid.ReturnSuccessData(c, result)
+}
+
 func UpdateAlarmCustomMessage(c *gin.Context) {
 	var param m.UpdateAlarmCustomMessageDto
 	if err := c.ShouldBindJSON(&param); err != nil {
@@ -23,7 +23,7 @@ func doMonitorEngineRuleJob() {
 	log.Logger.Debug("doAlarmEngineRuleJob")
 	var err error
 	var alarmStrategyMetricRows []*models.AlarmStrategyMetric
-	var existAlarmRows []*models.AlarmTable
+	var existAlarmRows []*models.AlarmHandleObj
 	defer func() {
 		if err != nil {
 			log.Logger.Warn("doAlarmEngineRuleJob fail", log.Error(err))
@@ -41,12 +41,12 @@ func doMonitorEngineRuleJob() {
 	}
 	var alarmList []*models.AlarmHandleObj
 	for _, row := range alarmStrategyMetricRows {
-		condition, threshold, illegal := analyzeCondition(row.Condition)
+		conditionObj, illegal := analyzeCondition(row.Condition)
 		if illegal {
 			log.Logger.Info("doAlarmEngineRuleJob condition illegal", log.String("alarmStrategyMetric", row.Guid), log.String("condition", row.Condition))
 			continue
 		}
-		alarmObjList, tmpErr := buildMonitorEngineAlarm(row, condition, threshold, existAlarmRows)
+		alarmObjList, tmpErr := buildMonitorEngineAlarm(row, conditionObj, existAlarmRows)
 		if tmpErr != nil {
 			log.Logger.Warn("doAlarmEngineRuleJob buildMonitorEngineAlarm fail", log.Error(tmpErr))
 		} else if len(alarmObjList) > 0 {
@@ -69,24 +69,15 @@ func doMonitorEngineRuleJob() {
 	}
 }
 
-func analyzeCondition(conditionConfig string) (condition string, threshold float64, illegal bool) {
+func analyzeCondition(conditionConfig string) (conditionObj *models.AlarmConditionObj, illegal bool) {
 	if len(conditionConfig) < 2 {
 		illegal = true
 		return
 	}
-	if strings.Contains(conditionConfig, "=") {
-		condition = conditionConfig[:2]
-		conditionConfig = conditionConfig[2:]
-	} else {
-		condition = conditionConfig[:1]
-		conditionConfig = conditionConfig[1:]
-	}
-	floatValue, err := strconv.ParseFloat(conditionConfig, 64)
+	conditionObj, err := models.ParseAlarmCondition(conditionConfig)
 	if err != nil {
 		illegal = true
-		return
 	}
-	threshold = floatValue
 	return
 }
 
@@ -111,8 +102,17 @@ func analyzeLast(lastConfig string) (timeSec int64) {
 	return
 }
 
-func compareFloatValue(inputValue, threshold float64, condition string) (match bool) {
-	switch condition {
+func compareFloatValue(inputValue float64, conditionObj *models.AlarmConditionObj) (match bool) {
+	switch conditionObj.Type {
+	case models.AlarmConditionAbsent:
+		// 查询表达式只返回消失的序列,有值就是命中
+		return true
+	case models.AlarmConditionRange:
+		inRange := inputValue >= conditionObj.Min && inputValue <= conditionObj.Max
+		return inRange != conditionObj.Outside
+	}
+	threshold := conditionObj.Threshold
+	switch conditionObj.Operator {
 	case ">":
 		if inputValue > threshold {
 			match = true
@@ -141,7 +141,7 @@ func compareFloatValue(inputValue, threshold float64, condition string) (match b
 	return
 }
 
-func buildMonitorEngineAlarm(alarmStrategyMetric *models.AlarmStrategyMetric, condition string, threshold float64, existAlarmRows []*models.AlarmTable) (alarmObjList []*models.AlarmHandleObj, err error) {
+func buildMonitorEngineAlarm(alarmStrategyMetric *models.AlarmStrategyMetric, conditionObj *models.AlarmConditionObj, existAlarmRows []*models.AlarmHandleObj) (alarmObjList []*models.AlarmHandleObj, err error) {
 	lastSec := analyzeLast(alarmStrategyMetric.Last)
 	if lastSec == 0 {
 		err = fmt.Errorf("lastConfig:%s illegal", alarmStrategyMetric.Last)
@@ -149,11 +149,12 @@ func buildMonitorEngineAlarm(alarmStrategyMetric *models.AlarmStrategyMetric, co
 	}
 	endTime := time.Now().Unix()
 	startTime := endTime - lastSec
-	queryData, queryErr := datasource.QueryPrometheusRange(alarmStrategyMetric.MonitorEngineExpr, startTime, endTime, 10)
+	queryData, queryErr := datasource.QueryPrometheusRange(conditionObj.BuildValueExpr(alarmStrategyMetric.MonitorEngineExpr, alarmStrategyMetric.Last), startTime, endTime, 10)
 	if queryErr != nil {
 		err = fmt.Errorf("query prometheus data fail,%s ", queryErr.Error())
 		return
 	}
+	queryTagsMap := make(map[string]bool)
 	for _, queryObj := range queryData.Result {
 		alarmObj := &models.AlarmHandleObj{}
 		delete(queryObj.Metric, "__name__")
@@ -163,13 +164,14 @@ func buildMonitorEngineAlarm(alarmStrategyMetric *models.AlarmStrategyMetric, co
 			continue
 		}
 		tmpExistAlarm := matchMonitorEngineExistAlarm(queryObj.Metric, existAlarmRows, tmpTags, alarmStrategyMetric)
+		queryTagsMap[tmpTags] = true
 		firingMatch := false
 		firingNonMatch := false
 		var startValue, endValue float64
 		for _, v := range queryObj.Values {
 			tmpValue, tmpParseErr := strconv.ParseFloat(v[1].(string), 64)
 			if tmpParseErr == nil {
-				if !compareFloatValue(tmpValue, threshold, condition) {
+				if !compareFloatValue(tmpValue, conditionObj) {
 					firingNonMatch = true
 					endValue = tmpValue
 					break
@@ -244,16 +246,30 @@ func buildMonitorEngineAlarm(alarmStrategyMetric *models.AlarmStrategyMetric, co
 			alarmObjList = append(alarmObjList, alarmObj)
 		}
 	}
+	// 无数据告警在序列重新上报后查不到结果,需要主动恢复
+	if conditionObj.Type == models.AlarmConditionAbsent {
+		for _, v := range existAlarmRows {
+			if !isMonitorEngineAlarmOf(v, alarmStrategyMetric) || queryTagsMap[v.Tags] {
+				continue
+			}
+			alarmObjList = append(alarmObjList, &models.AlarmHandleObj{AlarmTable: models.AlarmTable{Id: v.Id, AlarmStrategy: v.AlarmStrategy, StrategyId: v.StrategyId, Status: "ok", End: time.Now()}})
+		}
+	}
 	return
 }
 
-func matchMonitorEngineExistAlarm(metaMap map[string]string, existAlarmRows []*models.AlarmTable, tags string, alarmStrategyMetric *models.AlarmStrategyMetric) (existAlarm *models.AlarmTable) {
+func matchMonitorEngineExistAlarm(metaMap map[string]string, existAlarmRows []*models.AlarmHandleObj, tags string, alarmStrategyMetric *models.AlarmStrategyMetric) (existAlarm *models.AlarmTable) {
 	existAlarm = &models.AlarmTable{}
 	for _, v := range existAlarmRows {
-		if v.AlarmStrategy == alarmStrategyMetric.AlarmStrategy && v.Tags == tags {
-			existAlarm = v
+		if isMonitorEngineAlarmOf(v, alarmStrategyMetric) && v.Tags == tags {
+			existAlarm = &v.AlarmTable
 			break
 		}
 	}
 	return
 }
+
+// isMonitorEngineAlarmOf 同一个告警策略下有多条阈值时,只处理本条阈值产生的告警
+func isMonitorEngineAlarmOf(alarmObj *models.AlarmHandleObj, alarmStrategyMetric *models.AlarmStrategyMetric) bool {
+	return alarmObj.AlarmStrategy == alarmStrategyMetric.AlarmStrategy && alarmObj.AlarmConditionCrcHash == alarmStrategyMetric.CrcHash
+}
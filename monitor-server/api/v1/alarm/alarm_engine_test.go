@@ -0,0 +1,52 @@
+package alarm
+
+import (
+	"github.com/WeBankPartners/open-monitor/monitor-server/models"
+	"testing"
+)
+
+func TestCompareFloatValue(t *testing.T) {
+	cases := []struct {
+		condition string
+		value     float64
+		match     bool
+	}{
+		{condition: ">90", value: 91, match: true},
+		{condition: ">90", value: 90, match: false},
+		{condition: "<=10", value: 10, match: true},
+		{condition: "!=0", value: 0, match: false},
+		{condition: "between 10 and 20", value: 15, match: true},
+		{condition: "between 10 and 20", value: 25, match: false},
+		{condition: "not between 10 and 20", value: 25, match: true},
+		{condition: "not between 10 and 20", value: 10, match: false},
+		{condition: "absent", value: 0, match: true},
+		{condition: "change_1h>=50", value: 50, match: true},
+	}
+	for _, c := range cases {
+		conditionObj, err := models.ParseAlarmCondition(c.condition)
+		if err != nil {
+			t.Fatalf("parse condition %s fail: %v", c.condition, err)
+		}
+		if match := compareFloatValue(c.value, conditionObj); match != c.match {
+			t.Errorf("condition %s value %v match %v, expect %v", c.condition, c.value, match, c.match)
+		}
+	}
+}
+
+func TestMatchMonitorEngineExistAlarm(t *testing.T) {
+	existAlarmRows := []*models.AlarmHandleObj{
+		{AlarmTable: models.AlarmTable{Id: 1, AlarmStrategy: "s1", Tags: "a"}, AlarmConditionCrcHash: "crc_cpu"},
+		{AlarmTable: models.AlarmTable{Id: 2, AlarmStrategy: "s1", Tags: "a"}, AlarmConditionCrcHash: "crc_mem"},
+	}
+	memMetric := &models.AlarmStrategyMetric{AlarmStrategy: "s1", CrcHash: "crc_mem"}
+	if existAlarm := matchMonitorEngineExistAlarm(nil, existAlarmRows, "a", memMetric); existAlarm.Id != 2 {
+		t.Errorf("match exist alarm id %d, expect 2", existAlarm.Id)
+	}
+	if existAlarm := matchMonitorEngineExistAlarm(nil, existAlarmRows, "b", memMetric); existAlarm.Id != 0 {
+		t.Errorf("alarm with other tags should not match, got id %d", existAlarm.Id)
+	}
+	otherMetric := &models.AlarmStrategyMetric{AlarmStrategy: "s1", CrcHash: "crc_disk"}
+	if isMonitorEngineAlarmOf(existAlarmRows[0], otherMetric) {
+		t.Errorf("alarm of other metric row should not match")
+	}
+}
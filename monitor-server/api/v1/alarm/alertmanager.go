@@ -0,0 +1,172 @@
+package alarm
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	mid "github.com/WeBankPartners/open-monitor/monitor-server/middleware"
+	"github.com/WeBankPartners/open-monitor/monitor-server/middleware/log"
+	m "github.com/WeBankPartners/open-monitor/monitor-server/models"
+	"github.com/WeBankPartners/open-monitor/monitor-server/services/db"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AcceptAlertmanagerWebhook 接收外部prometheus/alertmanager的webhook(v4),按标签映射后和内部告警走同样的处理流程
+func AcceptAlertmanagerWebhook(c *gin.Context) {
+	receiverConfig := m.Config().AlertmanagerReceiver
+	if !receiverConfig.Enable {
+		mid.ReturnError(c, errors.New("alertmanager receiver is disable"), http.StatusForbidden)
+		return
+	}
+	// 开启接收时必须配置token,避免任何人都能伪造告警
+	if receiverConfig.Token == "" {
+		mid.ReturnError(c, errors.New("alertmanager receiver token is not configured"), http.StatusForbidden)
+		return
+	}
+	requestToken := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if requestToken == "" {
+		requestToken = c.Query("token")
+	}
+	if subtle.ConstantTimeCompare([]byte(requestToken), []byte(receiverConfig.Token)) != 1 {
+		mid.ReturnError(c, errors.New("alertmanager receiver token illegal"), http.StatusUnauthorized)
+		return
+	}
+	var param m.AlterManagerRespObj
+	if err := c.ShouldBindJSON(&param); err != nil {
+		mid.ReturnValidateError(c, err.Error())
+		return
+	}
+	if len(param.Alerts) == 0 {
+		mid.ReturnSuccess(c)
+		return
+	}
+	log.Logger.Debug("accept alertmanager webhook", log.String("receiver", param.Receiver), log.String("groupKey", param.GroupKey), log.Int("alertNum", len(param.Alerts)))
+	nowTime := time.Now()
+	var alarms []*m.AlarmHandleObj
+	for _, v := range param.Alerts {
+		tmpV := v
+		tmpAlarm, tmpErr := buildAlertmanagerAlarm(&tmpV, &receiverConfig, nowTime)
+		if tmpErr != nil {
+			log.Logger.Warn("Accept alertmanager alert handle fail", log.String("fingerprint", v.Fingerprint), log.Error(tmpErr))
+			continue
+		}
+		alarms = append(alarms, &tmpAlarm)
+	}
+	alarms = db.UpdateAlarms(alarms)
+	for _, v := range alarms {
+		// 没有映射到告警策略的告警只进告警中心,不发通知
+		if v.AlarmStrategy == "" || v.NotifyEnable == 0 {
+			continue
+		}
+		go db.NotifyStrategyAlarm(v)
+	}
+	mid.ReturnSuccess(c)
+}
+
+func buildAlertmanagerAlarm(param *m.AMRespAlert, receiverConfig *m.AlertmanagerReceiverConfig, nowTime time.Time) (alarm m.AlarmHandleObj, err error) {
+	alarm = m.AlarmHandleObj{}
+	alertName := param.Labels["alertname"]
+	if alertName == "" {
+		return alarm, fmt.Errorf("alert labels have no alertname ")
+	}
+	if alarm.Tags, err = getNewAlarmTags(param); err != nil {
+		return
+	}
+	if alarm.Endpoint, err = getAlertmanagerAlarmEndpoint(param, receiverConfig); err != nil {
+		return
+	}
+	alarm.SMetric = alertName
+	alarm.AlarmName = alertName
+	alarm.SExpr = param.GeneratorURL
+	alarm.SPriority = receiverConfig.PriorityMap[strings.ToLower(param.Labels[receiverConfig.PriorityLabel])]
+	alarm.Content = param.Annotations["description"]
+	if alarm.Content == "" {
+		alarm.Content = param.Annotations["summary"]
+	}
+	if alarm.Content == "" {
+		alarm.Content = param.Annotations["message"]
+	}
+	strategyGuid := param.Labels[receiverConfig.StrategyLabel]
+	if strategyGuid == "" {
+		strategyGuid = receiverConfig.StrategyMap[alertName]
+	}
+	if strategyGuid == "" {
+		strategyGuid = receiverConfig.DefaultStrategy
+	}
+	if strategyGuid != "" {
+		strategyObj, getStrategyErr := db.GetSimpleAlarmStrategy(strategyGuid)
+		if getStrategyErr != nil {
+			log.Logger.Warn("Alertmanager alert strategy not found,ignore notify", log.String("alertname", alertName), log.String("strategy", strategyGuid), log.Error(getStrategyErr))
+		} else {
+			alarm.AlarmStrategy = strategyObj.Guid
+			alarm.NotifyEnable = strategyObj.NotifyEnable
+			alarm.NotifyDelay = strategyObj.NotifyDelaySecond
+			if alarm.SPriority == "" {
+				alarm.SPriority = strategyObj.Priority
+			}
+		}
+	}
+	if alarm.SPriority == "" {
+		alarm.SPriority = "medium"
+	}
+	alertValue, _ := strconv.ParseFloat(param.Annotations["value"], 64)
+	existAlarm, _ := db.GetAlarmObj(&m.AlarmTable{Endpoint: alarm.Endpoint, Tags: alarm.Tags, SMetric: alarm.SMetric, AlarmStrategy: alarm.AlarmStrategy, Status: "firing"})
+	if param.Status == "firing" {
+		if existAlarm.Id > 0 {
+			return alarm, fmt.Errorf("Accept alertmanager alert,firing repeat,do nothing! ")
+		}
+		alarm.Status = "firing"
+		alarm.StartValue = alertValue
+		alarm.Start = param.StartsAt.Local()
+		if param.StartsAt.IsZero() {
+			alarm.Start = nowTime
+		}
+	} else if param.Status == "resolved" {
+		if existAlarm.Id <= 0 {
+			return alarm, fmt.Errorf("Accept alertmanager alert,cat not add resolved,do nothing! ")
+		}
+		alarm.Id = existAlarm.Id
+		alarm.InhibitBy = existAlarm.InhibitBy
+		alarm.Status = "ok"
+		alarm.EndValue = alertValue
+		alarm.End = param.EndsAt.Local()
+		if param.EndsAt.IsZero() || param.EndsAt.After(nowTime) {
+			alarm.End = nowTime
+		}
+	} else {
+		return alarm, fmt.Errorf("Accept alertmanager alert status:%s illegal! ", param.Status)
+	}
+	return
+}
+
+// getAlertmanagerAlarmEndpoint 按配置的标签顺序找监控对象,找不到时直接用标签值作为告警对象
+func getAlertmanagerAlarmEndpoint(param *m.AMRespAlert, receiverConfig *m.AlertmanagerReceiverConfig) (endpoint string, err error) {
+	for _, labelKey := range receiverConfig.EndpointLabels {
+		labelValue := param.Labels[labelKey]
+		if labelValue == "" {
+			continue
+		}
+		endpointObj, getErr := db.GetEndpointNewByLabelValue(labelValue)
+		if getErr != nil {
+			if endpoint == "" {
+				endpoint = labelValue
+			}
+			continue
+		}
+		if endpointObj.AlarmEnable == 0 {
+			return "", fmt.Errorf("Endpoint %s alarm is disable ", endpointObj.Guid)
+		}
+		if !db.CheckEndpointActiveAlert(endpointObj.Guid) {
+			return "", fmt.Errorf("Endpoint %s in alert maintain window ", endpointObj.Guid)
+		}
+		return endpointObj.Guid, nil
+	}
+	if endpoint == "" {
+		err = fmt.Errorf("alert labels have no endpoint message ")
+	}
+	return
+}
@@ -0,0 +1,72 @@
+package config_new
+
+import (
+	"github.com/WeBankPartners/open-monitor/monitor-server/middleware"
+	"github.com/WeBankPartners/open-monitor/monitor-server/models"
+	"github.com/WeBankPartners/open-monitor/monitor-server/services/db"
+	"github.com/gin-gonic/gin"
+	"strings"
+)
+
+func ProbeLocationList(c *gin.Context) {
+	result, err := db.ProbeLocationList()
+	if err != nil {
+		middleware.ReturnHandleError(c, err.Error(), err)
+	} else {
+		middleware.ReturnSuccessData(c, result)
+	}
+}
+
+func ProbeLocationCreate(c *gin.Context) {
+	var param models.ProbeLocationTable
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnValidateError(c, err.Error())
+		return
+	}
+	if !middleware.IsIllegalNormalInput(param.Guid) {
+		middleware.ReturnValidateError(c, "Param guid is illegal")
+		return
+	}
+	if !strings.Contains(param.Address, ":") {
+		middleware.ReturnValidateError(c, "Param address should be ping_exporter ip:port")
+		return
+	}
+	err := db.ProbeLocationCreate(param, middleware.GetOperateUser(c))
+	if err != nil {
+		middleware.ReturnHandleError(c, err.Error(), err)
+	} else {
+		middleware.ReturnSuccess(c)
+	}
+}
+
+func ProbeLocationUpdate(c *gin.Context) {
+	var param models.ProbeLocationTable
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnValidateError(c, err.Error())
+		return
+	}
+	if !strings.Contains(param.Address, ":") {
+		middleware.ReturnValidateError(c, "Param address should be ping_exporter ip:port")
+		return
+	}
+	err := db.ProbeLocationUpdate(param, middleware.GetOperateUser(c))
+	if err != nil {
+		middleware.ReturnHandleError(c, err.Error(), err)
+	} else {
+		middleware.ReturnSuccess(c)
+	}
+}
+
+func ProbeLocationDelete(c *gin.Context) {
+	guid := c.Query("guid")
+	if guid == "" {
+		middleware.ReturnParamEmptyError(c, "guid")
+		return
+	}
+	err := db.ProbeLocationDelete(guid)
+	if err != nil {
+		middleware.ReturnHandleError(c, err.Error(), err)
+	} else {
+		middleware.ReturnSuccess(c)
+	}
+}
@@ -439,7 +439,9 @@ func getPieData(paramConfig *m.PieChartConfigObj) (result []*m.QueryMonitorData,
 	} else {
 		for _, queryObj := range result {
 			log.Logger.Info("queryObj", log.JsonObj("data", queryObj))
-			ds.PrometheusData(queryObj)
+			if queryErr, _, _ := db.QueryStitchedData(queryObj, "", 0); queryErr != nil {
+				log.Logger.Warn("query pie archive data fail", log.Error(queryErr))
+			}
 		}
 	}
 	return
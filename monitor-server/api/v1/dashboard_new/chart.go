@@ -426,7 +426,7 @@ func GetChartComparisonQueryData(queryList []*models.QueryMonitorData, param mod
 	}
 	calcTypeMap := convertArray2Map(param.CalcType)
 	for _, query := range queryList {
-		if query.Cluster != "" && query.Cluster != "default" {
+		if query.Cluster != "" && query.Cluster != "default" && !ds.IsRoutedCluster(query.Cluster) {
 			query.Cluster = db.GetClusterAddress(query.Cluster)
 		}
 		curResultList := mergePrometheusData(param.CalcPeriod, param.CalcMethod, ds.PrometheusData(query))
@@ -542,26 +542,20 @@ func GetChartQueryData(queryList []*models.QueryMonitorData, param *models.Chart
 	var err error
 	var logType string
 	archiveQueryFlag := false
-	if param.Start < (time.Now().Unix()-models.Config().ArchiveMysql.LocalStorageMaxDay*86400) && db.ArchiveEnable {
+	if param.Start < db.GetArchiveBoundary() && db.ArchiveEnable {
 		archiveQueryFlag = true
 	}
 	startTimestamp := float64(param.Start * 1000)
 	endTimestamp := float64(param.End * 1000)
+	// 拼接了归档数据的序列已按 maxPoint 降采样,不再做第二次聚合
+	stitchedSerialMap := make(map[*models.SerialModel]bool)
 	for _, query := range queryList {
 		log.Logger.Debug("Query param", log.JsonObj("param", query))
-		if query.Cluster != "" && query.Cluster != "default" {
-			query.Cluster = db.GetClusterAddress(query.Cluster)
+		if param.Datasource != "" {
+			query.Datasource = param.Datasource
 		}
-		if archiveQueryFlag {
-			// Query db archive data
-			tmpErr, tmpStep, tmpSerials := db.GetArchiveData(query, param.Aggregate)
-			if tmpErr != nil {
-				err = tmpErr
-				break
-			}
-			serials = append(serials, tmpSerials...)
-			param.Step = tmpStep
-			continue
+		if query.Cluster != "" && query.Cluster != "default" && !ds.IsRoutedCluster(query.Cluster) {
+			query.Cluster = db.GetClusterAddress(query.Cluster)
 		}
 		if param.LineType == 2 {
 			query.ComparisonFlag = "Y"
@@ -573,36 +567,16 @@ func GetChartQueryData(queryList []*models.QueryMonitorData, param *models.Chart
 			}
 			query.ServiceConfiguration = logType
 		}
-		tmpSerials := ds.PrometheusData(query)
-		// 如果归档数据可用，尝试从归档数据中补全数据
-		if db.ArchiveEnable {
-			if len(tmpSerials) > 0 {
-				if len(tmpSerials[0].Data) > 0 {
-					tmpSerialDataStart := int64(tmpSerials[0].Data[0][0]) / 1000
-					// 如果查出来的数据时间和查询时间对不上，说明缺了一些数据，尝试从归档数据中去查
-					if tmpSerialDataStart > (param.Start + 120) {
-						_, _, tmpArchiveSerials := db.GetArchiveData(&models.QueryMonitorData{Start: query.Start, End: tmpSerialDataStart, Endpoint: query.Endpoint, Metric: query.Metric, Legend: query.Legend, CompareLegend: query.CompareLegend, SameEndpoint: query.SameEndpoint}, param.Aggregate)
-						for _, tmpSerial := range tmpArchiveSerials {
-							if len(tmpSerial.Data) > 0 {
-								param.Aggregate = "none"
-								for si, vv := range tmpSerials {
-									if tmpSerial.Name == vv.Name {
-										tmpSerials[si].Data = append(tmpSerial.Data, vv.Data...)
-									}
-								}
-							}
-						}
-					}
-				}
-			} else {
-				tmpErr, tmpStep, tmpSerials := db.GetArchiveData(&models.QueryMonitorData{Start: query.Start, End: query.End, Endpoint: query.Endpoint, Metric: query.Metric, Legend: query.Legend, CompareLegend: query.CompareLegend, SameEndpoint: query.SameEndpoint}, param.Aggregate)
-				if tmpErr != nil {
-					err = tmpErr
-					break
-				}
-				serials = append(serials, tmpSerials...)
-				param.Step = tmpStep
-				continue
+		// 跨越本地保留边界时自动拼接归档数据与 Prometheus 数据
+		tmpErr, tmpStep, tmpSerials := db.QueryStitchedData(query, param.Aggregate, param.MaxPoint)
+		if tmpErr != nil {
+			err = tmpErr
+			break
+		}
+		if tmpStep > 0 {
+			param.Step = tmpStep
+			for _, subSerial := range tmpSerials {
+				stitchedSerialMap[subSerial] = true
 			}
 		}
 		// 如果数据前后不是开始结束时间，补齐前后两个点
@@ -626,6 +600,9 @@ func GetChartQueryData(queryList []*models.QueryMonitorData, param *models.Chart
 		}
 		serials = append(serials, tmpSerials...)
 	}
+	if !archiveQueryFlag && param.LineType != 2 && (param.Compare == nil || param.Compare.CompareSubTime <= 0) {
+		serials = append(serials, getAnomalyBandSerials(queryList, param.AlarmStrategy)...)
+	}
 	// handle serials data
 	//agg := 0
 	//if param.Aggregate != "none" {
@@ -655,7 +632,7 @@ func GetChartQueryData(queryList []*models.QueryMonitorData, param *models.Chart
 		if result.Title == "${auto}" {
 			result.Title = s.Name[:strings.Index(s.Name, "{")]
 		}
-		if param.Aggregate != "none" && param.AggStep > 10 {
+		if param.Aggregate != "none" && param.AggStep > 10 && !stitchedSerialMap[s] {
 			log.Logger.Debug("AggregateNew", log.Int64("aggStep", param.AggStep), log.String("agg", param.Aggregate))
 			tempData := s.Data
 			s.Data = models.Aggregate(s.Data, param.AggStep, param.Aggregate)
@@ -687,6 +664,53 @@ func GetChartQueryData(queryList []*models.QueryMonitorData, param *models.Chart
 	return err
 }
 
+// getAnomalyBandSerials 动态基线告警的上下边界线,方便看出告警原因,
+// 没有指定告警策略时按查询的对象找它所在组的告警策略
+func getAnomalyBandSerials(queryList []*models.QueryMonitorData, alarmStrategy string) (serials []*models.SerialModel) {
+	var strategyConditionMap map[string]*models.AlarmConditionObj
+	var err error
+	if alarmStrategy != "" {
+		if strategyConditionMap, err = db.GetAlarmStrategyAnomalyConditions(alarmStrategy); err != nil {
+			log.Logger.Error("Get alarm strategy anomaly conditions fail", log.String("alarmStrategy", alarmStrategy), log.Error(err))
+			return
+		}
+	}
+	endpointConditionMap := make(map[string]map[string]*models.AlarmConditionObj)
+	for _, query := range queryList {
+		if len(query.Metric) == 0 || query.PromQ == "" {
+			continue
+		}
+		conditionMap := strategyConditionMap
+		if alarmStrategy == "" {
+			if len(query.Endpoint) != 1 || query.Endpoint[0] == "" {
+				continue
+			}
+			var b bool
+			if conditionMap, b = endpointConditionMap[query.Endpoint[0]]; !b {
+				if conditionMap, err = db.GetEndpointAnomalyConditions(query.Endpoint[0]); err != nil {
+					log.Logger.Error("Get endpoint anomaly conditions fail", log.String("endpoint", query.Endpoint[0]), log.Error(err))
+				}
+				endpointConditionMap[query.Endpoint[0]] = conditionMap
+			}
+		}
+		conditionObj, ok := conditionMap[query.Metric[0]]
+		if !ok {
+			continue
+		}
+		lowerExpr, upperExpr := conditionObj.BuildBandExpr(query.PromQ)
+		bandExprList := []string{lowerExpr, upperExpr}
+		for i, bandName := range []string{"lower", "upper"} {
+			bandQuery := *query
+			bandQuery.PromQ = bandExprList[i]
+			for _, bandSerial := range ds.PrometheusData(&bandQuery) {
+				bandSerial.Name = strings.Replace(bandSerial.Name, "$metric", query.Metric[0], -1) + "_baseline_" + bandName
+				serials = append(serials, bandSerial)
+			}
+		}
+	}
+	return
+}
+
 // GetComparisonChartData 获取同环比预览数据
 func GetComparisonChartData(c *gin.Context) {
 	var param models.ComparisonChartQueryParam
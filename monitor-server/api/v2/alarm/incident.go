@@ -0,0 +1,51 @@
+package alarm
+
+import (
+	"github.com/WeBankPartners/open-monitor/monitor-server/middleware"
+	"github.com/WeBankPartners/open-monitor/monitor-server/models"
+	"github.com/WeBankPartners/open-monitor/monitor-server/services/db"
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+func QueryAlarmIncident(c *gin.Context) {
+	var param models.AlarmIncidentQueryParam
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnValidateError(c, err.Error())
+		return
+	}
+	result, err := db.QueryAlarmIncident(&param)
+	if err != nil {
+		middleware.ReturnHandleError(c, err.Error(), err)
+	} else {
+		middleware.ReturnSuccessData(c, result)
+	}
+}
+
+func GetAlarmIncident(c *gin.Context) {
+	incidentId, err := strconv.Atoi(c.Param("incidentId"))
+	if err != nil || incidentId <= 0 {
+		middleware.ReturnValidateError(c, "incidentId illegal")
+		return
+	}
+	result, err := db.GetAlarmIncidentDetail(incidentId)
+	if err != nil {
+		middleware.ReturnHandleError(c, err.Error(), err)
+	} else {
+		middleware.ReturnSuccessData(c, result)
+	}
+}
+
+func CloseAlarmIncident(c *gin.Context) {
+	var param models.AlarmIncidentCloseParam
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnValidateError(c, err.Error())
+		return
+	}
+	err := db.CloseAlarmIncident(param.Id, middleware.GetOperateUser(c))
+	if err != nil {
+		middleware.ReturnHandleError(c, err.Error(), err)
+	} else {
+		middleware.ReturnSuccess(c)
+	}
+}
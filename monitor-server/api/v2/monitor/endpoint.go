@@ -58,6 +58,10 @@ func GetEndpoint(c *gin.Context) {
 		if err == nil {
 			result.User = extendObj.User
 			result.Password = extendObj.Password
+			if models.IsDbType(endpointObj.MonitorType) {
+				result.DbIp = extendObj.Ip
+				result.DbPort = extendObj.Port
+			}
 			result.ProcessName = extendObj.ProcessName
 			if endpointObj.MonitorType == "process" {
 				result.Tags = extendObj.ProcessTags
@@ -65,9 +69,25 @@ func GetEndpoint(c *gin.Context) {
 			result.ExportAddress = extendObj.ExportAddress
 			result.Url = extendObj.HttpUrl
 			result.Method = extendObj.HttpMethod
+			if models.IsProbeType(endpointObj.MonitorType) {
+				if endpointProbeList, queryErr := db.GetEndpointProbe(guid); queryErr == nil && len(endpointProbeList) > 0 {
+					result.Probe, _ = models.ParseProbeConfig(endpointProbeList[0].Config)
+				}
+			}
+			if endpointObj.MonitorType == "http" {
+				if endpointHttpList, queryErr := db.GetEndpointHttp(guid); queryErr == nil && len(endpointHttpList) > 0 {
+					result.HttpCheck, _ = models.ParseHttpCheckConfig(endpointHttpList[0].CheckConfig)
+				}
+			}
 			result.ProxyExporter = extendObj.ProxyExporter
 		}
 	}
+	if models.IsPingExporterType(endpointObj.MonitorType) {
+		if endpointLocation, queryErr := db.GetEndpointProbeLocation(guid); queryErr == nil && endpointLocation != nil {
+			result.ProbeLocations = endpointLocation.LocationList()
+			result.ProbeQuorum = endpointLocation.Quorum
+		}
+	}
 	middleware.ReturnSuccessData(c, result)
 }
 
@@ -100,6 +120,18 @@ func UpdateEndpoint(c *gin.Context) {
 		err = queryErr
 		return
 	}
+	var probeLocationList []*models.ProbeLocationTable
+	var probeQuorum int
+	if len(param.ProbeLocations) > 0 {
+		if !models.IsPingExporterType(param.Type) {
+			err = fmt.Errorf("%s endpoint can not use probe locations", param.Type)
+			return
+		}
+		if probeLocationList, probeQuorum, err = db.CheckProbeLocation(param.ProbeLocations, param.ProbeQuorum); err != nil {
+			return
+		}
+		param.ExportAddress = probeLocationList[0].Address
+	}
 	var newEndpoint models.EndpointNewTable
 	switch param.Type {
 	case "host":
@@ -118,6 +150,8 @@ func UpdateEndpoint(c *gin.Context) {
 		newEndpoint, err = telnetEndpointUpdate(&param, &endpointObj)
 	case "http":
 		newEndpoint, err = httpEndpointUpdate(&param, &endpointObj)
+	case models.ProbeTypeTcp, models.ProbeTypeTls, models.ProbeTypeDns:
+		newEndpoint, err = probeEndpointUpdate(&param, &endpointObj)
 	case "windows":
 		newEndpoint, err = windowsEndpointUpdate(&param, &endpointObj)
 	case "snmp":
@@ -131,12 +165,21 @@ func UpdateEndpoint(c *gin.Context) {
 		log.Logger.Error("Update endpoint fail", log.Error(err))
 		return
 	}
+	locationChange := false
+	if models.IsPingExporterType(param.Type) {
+		if locationChange, err = updateEndpointProbeLocation(guid, param.Type, middleware.GetOperateUser(c), probeLocationList, probeQuorum); err != nil {
+			return
+		}
+	}
 	if newEndpoint.Guid == "" {
-		if endpointObj.Step == param.Step {
+		if endpointObj.Step == param.Step && !locationChange {
 			// no change
 			return
 		} else {
 			newEndpoint = models.EndpointNewTable{Guid: endpointObj.Guid, AgentAddress: endpointObj.AgentAddress, EndpointAddress: endpointObj.EndpointAddress, Step: param.Step, ExtendParam: endpointObj.ExtendParam}
+			if len(probeLocationList) > 0 {
+				newEndpoint.AgentAddress = param.ExportAddress
+			}
 		}
 	} else {
 		newEndpoint.Step = param.Step
@@ -147,8 +190,14 @@ func UpdateEndpoint(c *gin.Context) {
 	if err != nil {
 		return
 	}
+	// 数据库实例地址或账号变化后重新下发 db_data_exporter 配置
+	if models.IsDbType(param.Type) && endpointObj.ExtendParam != newEndpoint.ExtendParam {
+		if err = db.SyncDbMetric(false); err != nil {
+			return
+		}
+	}
 	// update sd file if step change
-	if endpointObj.Step != param.Step || endpointObj.AgentAddress != newEndpoint.AgentAddress {
+	if endpointObj.Step != param.Step || endpointObj.AgentAddress != newEndpoint.AgentAddress || locationChange {
 		stepList := []int{endpointObj.Step}
 		if endpointObj.Step != param.Step {
 			stepList = append(stepList, param.Step)
@@ -239,25 +288,102 @@ func telnetEndpointUpdate(param *models.RegisterParamNew, endpoint *models.Endpo
 }
 
 func httpEndpointUpdate(param *models.RegisterParamNew, endpoint *models.EndpointNewTable) (newEndpoint models.EndpointNewTable, err error) {
+	var checkConfig string
+	if param.HttpCheck != nil {
+		if err = param.HttpCheck.Validate(); err != nil {
+			return
+		}
+		b, _ := json.Marshal(param.HttpCheck)
+		checkConfig = string(b)
+	}
+	if param.Url != "" && param.Method != "" {
+		if err = db.UpdateEndpointHttp([]*models.EndpointHttpTable{{EndpointGuid: endpoint.Guid, Url: param.Url, Method: param.Method, CheckConfig: checkConfig}}); err != nil {
+			return
+		}
+	}
 	newExtParamObj := models.EndpointExtendParamObj{Enable: true, HttpUrl: param.Url, HttpMethod: param.Method, ExportAddress: param.ExportAddress}
 	b, _ := json.Marshal(newExtParamObj)
 	newEndpoint = models.EndpointNewTable{Guid: endpoint.Guid, EndpointAddress: endpoint.EndpointAddress, AgentAddress: param.ExportAddress, ExtendParam: string(b)}
 	return
 }
 
+func probeEndpointUpdate(param *models.RegisterParamNew, endpoint *models.EndpointNewTable) (newEndpoint models.EndpointNewTable, err error) {
+	if param.Probe == nil {
+		param.Probe = &models.ProbeConfigObj{}
+	}
+	if err = param.Probe.Validate(param.Type); err != nil {
+		return
+	}
+	if param.Type == models.ProbeTypeDns && param.Port == "" {
+		param.Port = "53"
+	}
+	newAddress := endpoint.EndpointAddress
+	if param.Ip != "" && param.Port != "" {
+		newAddress = fmt.Sprintf("%s:%s", param.Ip, param.Port)
+	}
+	b, _ := json.Marshal(param.Probe)
+	if err = db.UpdateEndpointProbe(&models.EndpointProbeTable{EndpointGuid: endpoint.Guid, ProbeType: param.Type, Target: newAddress, Config: string(b)}); err != nil {
+		return
+	}
+	newExtParamObj := models.EndpointExtendParamObj{Enable: true, Ip: param.Ip, Port: param.Port, ExportAddress: param.ExportAddress}
+	b, _ = json.Marshal(newExtParamObj)
+	newEndpoint = models.EndpointNewTable{Guid: endpoint.Guid, AgentAddress: param.ExportAddress, EndpointAddress: newAddress, ExtendParam: string(b)}
+	return
+}
+
+// updateEndpointProbeLocation 返回拨测点或 quorum 是否有变化,有变化时需要重新生成服务发现;
+// 开启或取消多拨测点时同时调整对象所在的默认组
+func updateEndpointProbeLocation(guid, endpointType, operator string, locations []*models.ProbeLocationTable, quorum int) (change bool, err error) {
+	oldLocation, err := db.GetEndpointProbeLocation(guid)
+	if err != nil {
+		return
+	}
+	var locationList []string
+	for _, v := range locations {
+		locationList = append(locationList, v.Guid)
+	}
+	if oldLocation == nil {
+		change = len(locationList) > 0
+	} else {
+		change = oldLocation.Locations != strings.Join(locationList, ",") || oldLocation.Quorum != quorum
+	}
+	if !change {
+		return
+	}
+	if err = db.UpdateEndpointProbeLocation(guid, locations, quorum); err != nil {
+		return
+	}
+	if (oldLocation != nil) != (len(locationList) > 0) {
+		changeGroupList, moveErr := db.MoveEndpointProbeLocationGroup(guid, endpointType, len(locationList) > 0, operator)
+		if moveErr != nil {
+			log.Logger.Error("Move endpoint default group fail", log.String("endpoint", guid), log.Error(moveErr))
+		}
+		for _, v := range changeGroupList {
+			db.SyncPrometheusRuleFile(v, false)
+		}
+	}
+	return
+}
+
 func snmpEndpointUpdate(param *models.RegisterParamNew, endpoint *models.EndpointNewTable) (newEndpoint models.EndpointNewTable, err error) {
 	return
 }
 
 func otherEndpointUpdate(param *models.RegisterParamNew, endpoint *models.EndpointNewTable) (newEndpoint models.EndpointNewTable, err error) {
-	if strings.Contains(endpoint.AgentAddress, ":") {
-		if param.Port == endpoint.AgentAddress[strings.LastIndex(endpoint.AgentAddress, ":")+1:] {
-			return
-		} else {
-			newAddress := fmt.Sprintf("%s:%s", param.Ip, param.Port)
-			newEndpoint = models.EndpointNewTable{Guid: endpoint.Guid, AgentAddress: newAddress, EndpointAddress: newAddress}
-		}
+	newAgentAddress, newEndpointAddress, newExtendParam := endpoint.AgentAddress, endpoint.EndpointAddress, endpoint.ExtendParam
+	if strings.Contains(endpoint.AgentAddress, ":") && param.Port != endpoint.AgentAddress[strings.LastIndex(endpoint.AgentAddress, ":")+1:] {
+		newAgentAddress = fmt.Sprintf("%s:%s", param.Ip, param.Port)
+		newEndpointAddress = newAgentAddress
+	}
+	// 数据库对象的实例地址和账号与 exporter 地址分开保存,修改端口时不能丢掉
+	if param.User != "" && models.IsDbType(param.Type) {
+		b, _ := json.Marshal(models.BuildDbExtendParam(param))
+		newExtendParam = string(b)
+	}
+	if newAgentAddress == endpoint.AgentAddress && newExtendParam == endpoint.ExtendParam {
+		return
 	}
+	newEndpoint = models.EndpointNewTable{Guid: endpoint.Guid, AgentAddress: newAgentAddress, EndpointAddress: newEndpointAddress, ExtendParam: newExtendParam}
 	return
 }
 
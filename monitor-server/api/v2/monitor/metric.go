@@ -29,6 +29,9 @@ var defaultMonitorTypeMetricMap = map[string][]string{
 	"mysql":   {"mysql_alive", "mysql_requests", "db_count_change", "db_monitor_count", "mysql_threads_max", "mysql_buffer_status", "mysql_threads_connected", "mysql_connect_used_percent"},
 	"jvm":     {"jvm_gc_time", "tomcat_request", "jvm_thread_count", "gc_marksweep_time", "tomcat_connection", "jvm_memory_heap_max", "jvm_memory_heap_used", "heap_mem_used_percent"},
 	"http":    {"http_status"},
+	"tcp":     {"tcp_alive", "tcp_time"},
+	"tls":     {"tls_alive", "tls_time", "tls_verify", "tls_cert_expire_days"},
+	"dns":     {"dns_alive", "dns_time"},
 }
 
 func ListMetric(c *gin.Context) {
@@ -11,7 +11,7 @@ import (
 
 // systemMonitorTypeMap 系统类型配置
 var systemMonitorTypeList = []string{"host", "mysql", "redis", "java", "tomcat", "nginx", "ping", "pingext",
-	"telnet", "telnetext", "http", "httpext", "windows", "snmp", "process", "pod"}
+	"telnet", "telnetext", "http", "httpext", "windows", "snmp", "process", "pod", "tcp", "tls", "dns"}
 
 func QueryTypeConfigList(c *gin.Context) {
 	var err error
@@ -36,6 +36,10 @@ func CreateDBKeywordConfig(c *gin.Context) {
 		middleware.ReturnServerHandleError(c, models.GetMessageMap(c).AlertNameRepeatError)
 		return
 	}
+	if param.DbType, err = models.BuildDbType(param.DbType); err != nil {
+		middleware.ReturnValidateError(c, err.Error())
+		return
+	}
 	if len(param.ActiveWindowList) > 0 {
 		param.ActiveWindow = strings.Join(param.ActiveWindowList, ",")
 	}
@@ -70,6 +74,10 @@ func UpdateDBKeywordConfig(c *gin.Context) {
 		middleware.ReturnServerHandleError(c, models.GetMessageMap(c).AlertNameRepeatError)
 		return
 	}
+	if param.DbType, err = models.BuildDbType(param.DbType); err != nil {
+		middleware.ReturnValidateError(c, err.Error())
+		return
+	}
 	if len(param.ActiveWindowList) > 0 {
 		param.ActiveWindow = strings.Join(param.ActiveWindowList, ",")
 	}
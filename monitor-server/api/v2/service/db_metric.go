@@ -49,6 +49,16 @@ func CreateDbMetricMonitor(c *gin.Context) {
 		middleware.ReturnValidateError(c, "metric param invalid")
 		return
 	}
+	dbType, dbTypeErr := models.BuildDbType(param.DbType)
+	if dbTypeErr != nil {
+		middleware.ReturnValidateError(c, dbTypeErr.Error())
+		return
+	}
+	param.DbType = dbType
+	if err := param.ValidateColumns(); err != nil {
+		middleware.ReturnValidateError(c, err.Error())
+		return
+	}
 	param.MetricSql = strings.TrimSpace(param.MetricSql)
 	param.MetricSql = strings.ReplaceAll(param.MetricSql, "\n", " ")
 	err := db.CreateDbMetric(&param, middleware.GetOperateUser(c))
@@ -70,6 +80,16 @@ func UpdateDbMetricMonitor(c *gin.Context) {
 		middleware.ReturnValidateError(c, err.Error())
 		return
 	}
+	dbType, dbTypeErr := models.BuildDbType(param.DbType)
+	if dbTypeErr != nil {
+		middleware.ReturnValidateError(c, dbTypeErr.Error())
+		return
+	}
+	param.DbType = dbType
+	if err := param.ValidateColumns(); err != nil {
+		middleware.ReturnValidateError(c, err.Error())
+		return
+	}
 	param.MetricSql = strings.TrimSpace(param.MetricSql)
 	param.MetricSql = strings.ReplaceAll(param.MetricSql, "\n", " ")
 	err := db.UpdateDbMetric(&param, middleware.GetOperateUser(c))
@@ -52,6 +52,9 @@ func CreateLogKeywordMonitor(c *gin.Context) {
 			break
 		}
 	}
+	if err == nil {
+		err = validateLogMultiline(param.Multiline)
+	}
 	if err != nil {
 		middleware.ReturnValidateError(c, err.Error())
 		return
@@ -74,6 +77,10 @@ func UpdateLogKeywordMonitor(c *gin.Context) {
 		middleware.ReturnValidateError(c, fmt.Sprintf("Path:%s illegal ", param.LogPath))
 		return
 	}
+	if err := validateLogMultiline(param.Multiline); err != nil {
+		middleware.ReturnValidateError(c, err.Error())
+		return
+	}
 	var endpointList []string
 	for _, v := range db.ListLogKeywordEndpointRel(param.Guid) {
 		endpointList = append(endpointList, v.SourceEndpoint)
@@ -66,6 +66,28 @@ func validateLogPath(input string) error {
 	return nil
 }
 
+// validateLogMultiline 多行合并的开始行规则与续行规则只能配置一个,agent 用 go 正则匹配
+func validateLogMultiline(input *models.LogMultilineConfig) error {
+	if input == nil {
+		return nil
+	}
+	if input.StartPattern != "" && input.ContinuePattern != "" {
+		return fmt.Errorf("multiline start_pattern and continue_pattern can not both set")
+	}
+	for _, v := range []string{input.StartPattern, input.ContinuePattern} {
+		if v == "" {
+			continue
+		}
+		if _, err := regexp.Compile(v); err != nil {
+			return fmt.Errorf("multiline pattern:%s illegal,%s", v, err.Error())
+		}
+	}
+	if input.MaxLines < 0 || input.FlushTimeout < 0 {
+		return fmt.Errorf("multiline max_lines and flush_timeout can not be negative")
+	}
+	return nil
+}
+
 func CreateLogMetricMonitor(c *gin.Context) {
 	var param models.LogMetricMonitorCreateDto
 	var list []*models.LogMetricMonitorTable
@@ -82,6 +104,9 @@ func CreateLogMetricMonitor(c *gin.Context) {
 			break
 		}
 	}
+	if err == nil {
+		err = validateLogMultiline(param.Multiline)
+	}
 	if err != nil {
 		middleware.ReturnValidateError(c, err.Error())
 		return
@@ -126,6 +151,10 @@ func UpdateLogMetricMonitor(c *gin.Context) {
 		middleware.ReturnValidateError(c, err.Error())
 		return
 	}
+	if err = validateLogMultiline(param.Multiline); err != nil {
+		middleware.ReturnValidateError(c, err.Error())
+		return
+	}
 	// 校验路径是否重复
 	if list, err = db.GetLogMetricMonitorByCond([]string{param.LogPath}, param.Guid, param.ServiceGroup); err != nil {
 		middleware.ReturnServerHandleError(c, err)
@@ -0,0 +1,361 @@
+// Package archivefile 归档数据的本地列式文件格式,archive_mysql_tool 写入,monitor-server 读取,
+// 目录结构为 分区/指标/对象,每个对象一个压缩后的基础文件(.gz)和一个追加写的段文件(.seg)
+// archive_mysql_tool 的 vendor 目录里有一份拷贝,修改格式后需要同步更新
+package archivefile
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	BaseFileSuffix    = ".gz"
+	SegmentFileSuffix = ".seg"
+)
+
+// Row 归档数据的一行
+type Row struct {
+	Endpoint string
+	Metric   string
+	Tags     string
+	UnixTime int64
+	Avg      float64
+	Min      float64
+	Max      float64
+	P95      float64
+	Sum      float64
+	P50      float64
+	P90      float64
+	P99      float64
+	Count    int64
+	Last     float64
+}
+
+// ColumnFile 列式存储的一组数据,gob编码后gzip压缩
+type ColumnFile struct {
+	Tags     []string
+	UnixTime []int64
+	Avg      []float64
+	Min      []float64
+	Max      []float64
+	P95      []float64
+	Sum      []float64
+	P50      []float64
+	P90      []float64
+	P99      []float64
+	Count    []int64
+	Last     []float64
+}
+
+func (c *ColumnFile) AppendRow(row *Row) {
+	c.Tags = append(c.Tags, row.Tags)
+	c.UnixTime = append(c.UnixTime, row.UnixTime)
+	c.Avg = append(c.Avg, row.Avg)
+	c.Min = append(c.Min, row.Min)
+	c.Max = append(c.Max, row.Max)
+	c.P95 = append(c.P95, row.P95)
+	c.Sum = append(c.Sum, row.Sum)
+	c.P50 = append(c.P50, row.P50)
+	c.P90 = append(c.P90, row.P90)
+	c.P99 = append(c.P99, row.P99)
+	c.Count = append(c.Count, row.Count)
+	c.Last = append(c.Last, row.Last)
+}
+
+func (c *ColumnFile) Rows(endpoint, metric string) (rows []*Row) {
+	for i := range c.UnixTime {
+		rows = append(rows, &Row{Endpoint: endpoint, Metric: metric, Tags: c.Tags[i], UnixTime: c.UnixTime[i], Avg: c.Avg[i], Min: c.Min[i], Max: c.Max[i],
+			P95: c.P95[i], Sum: c.Sum[i], P50: c.P50[i], P90: c.P90[i], P99: c.P99[i], Count: c.Count[i], Last: c.Last[i]})
+	}
+	return
+}
+
+// baseFile 基础文件,SegmentOffset 为合并时已经并入的段文件长度,读取段文件时跳过这部分
+type baseFile struct {
+	SegmentOffset int64
+	Data          ColumnFile
+}
+
+// segmentRecord 段文件中的一条记录,每条是一个独立的gzip块,先删除 [ClearStart,ClearEnd) 的数据再追加 Data
+type segmentRecord struct {
+	ClearStart int64
+	ClearEnd   int64
+	Data       ColumnFile
+}
+
+// Series 一个分区中一个指标下一个对象的数据文件
+type Series struct {
+	Dir      string
+	Endpoint string
+	Metric   string
+}
+
+func NewSeries(dir, partition, metric, endpoint string) *Series {
+	return &Series{Dir: filepath.Join(dir, partition, url.PathEscape(metric)), Endpoint: endpoint, Metric: metric}
+}
+
+func (s *Series) basePath() string {
+	return filepath.Join(s.Dir, url.PathEscape(s.Endpoint)+BaseFileSuffix)
+}
+
+func (s *Series) segmentPath() string {
+	return filepath.Join(s.Dir, url.PathEscape(s.Endpoint)+SegmentFileSuffix)
+}
+
+// ListSeries 列出分区下的所有数据文件,metric 不为空时只列该指标
+func ListSeries(dir, partition, metric string) (result []*Series, err error) {
+	partitionDir := filepath.Join(dir, partition)
+	var metricDirList []string
+	if metric != "" {
+		metricDirList = []string{url.PathEscape(metric)}
+	} else {
+		fileList, readErr := ioutil.ReadDir(partitionDir)
+		if readErr != nil {
+			if !os.IsNotExist(readErr) {
+				err = readErr
+			}
+			return
+		}
+		for _, file := range fileList {
+			if file.IsDir() {
+				metricDirList = append(metricDirList, file.Name())
+			}
+		}
+	}
+	for _, metricDir := range metricDirList {
+		metricName, unescapeErr := url.PathUnescape(metricDir)
+		if unescapeErr != nil {
+			continue
+		}
+		fileList, readErr := ioutil.ReadDir(filepath.Join(partitionDir, metricDir))
+		if readErr != nil {
+			if os.IsNotExist(readErr) {
+				continue
+			}
+			return result, readErr
+		}
+		endpointMap := make(map[string]bool)
+		for _, file := range fileList {
+			endpointName := strings.TrimSuffix(strings.TrimSuffix(file.Name(), BaseFileSuffix), SegmentFileSuffix)
+			if file.IsDir() || endpointName == file.Name() || endpointMap[endpointName] {
+				continue
+			}
+			endpointMap[endpointName] = true
+			if endpoint, unescapeErr := url.PathUnescape(endpointName); unescapeErr == nil {
+				result = append(result, &Series{Dir: filepath.Join(partitionDir, metricDir), Endpoint: endpoint, Metric: metricName})
+			}
+		}
+	}
+	return
+}
+
+// Read 读取基础文件并按顺序回放段文件,段文件先打开,保证与合并并发时不会丢数据或重复
+func (s *Series) Read() (rows []*Row, err error) {
+	segmentFile, err := os.Open(s.segmentPath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return
+		}
+		segmentFile, err = nil, nil
+	} else {
+		defer segmentFile.Close()
+	}
+	base, err := readBaseFile(s.basePath())
+	if err != nil {
+		return
+	}
+	rows = base.Data.Rows(s.Endpoint, s.Metric)
+	if segmentFile == nil {
+		return
+	}
+	if _, err = segmentFile.Seek(base.SegmentOffset, io.SeekStart); err != nil {
+		return
+	}
+	err = readSegmentRecords(bufio.NewReader(segmentFile), func(record *segmentRecord) {
+		if record.ClearEnd > record.ClearStart {
+			rows = filterRows(rows, record.ClearStart, record.ClearEnd)
+		}
+		rows = append(rows, record.Data.Rows(s.Endpoint, s.Metric)...)
+	})
+	if err != nil {
+		err = fmt.Errorf("read segment file:%s fail,%v ", s.segmentPath(), err)
+	}
+	return
+}
+
+// AppendRows 追加写数据,调用方需要保证同一个 Series 的写入和合并串行执行
+func (s *Series) AppendRows(rows []*Row) error {
+	record := segmentRecord{}
+	for _, row := range rows {
+		record.Data.AppendRow(row)
+	}
+	return s.appendRecord(&record)
+}
+
+// AppendClear 追加一条删除 [start,end) 数据的记录
+func (s *Series) AppendClear(start, end int64) error {
+	return s.appendRecord(&segmentRecord{ClearStart: start, ClearEnd: end})
+}
+
+// Compact 把段文件合并进基础文件,用于分区不再写入之后
+func (s *Series) Compact() error {
+	segmentInfo, err := os.Stat(s.segmentPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	rows, err := s.Read()
+	if err != nil {
+		return err
+	}
+	base := baseFile{SegmentOffset: segmentInfo.Size()}
+	for _, row := range rows {
+		base.Data.AppendRow(row)
+	}
+	if err = writeBaseFile(s.basePath(), &base); err != nil {
+		return err
+	}
+	return os.Remove(s.segmentPath())
+}
+
+func (s *Series) appendRecord(record *segmentRecord) error {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return err
+	}
+	if err := s.resetSegmentOffset(); err != nil {
+		return err
+	}
+	// 整条记录一次写入,读取时只可能遇到最后一条不完整
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if err := gob.NewEncoder(gw).Encode(record); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.segmentPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(buf.Bytes())
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		err = fmt.Errorf("append segment file:%s fail,%v ", s.segmentPath(), err)
+	}
+	return err
+}
+
+// resetSegmentOffset 合并后段文件已删除,重新写入前把基础文件记录的段偏移清零
+func (s *Series) resetSegmentOffset() error {
+	if _, err := os.Stat(s.segmentPath()); err == nil || !os.IsNotExist(err) {
+		return err
+	}
+	base, err := readBaseFile(s.basePath())
+	if err != nil || base.SegmentOffset == 0 {
+		return err
+	}
+	base.SegmentOffset = 0
+	return writeBaseFile(s.basePath(), base)
+}
+
+func filterRows(rows []*Row, start, end int64) (result []*Row) {
+	for _, row := range rows {
+		if row.UnixTime < start || row.UnixTime >= end {
+			result = append(result, row)
+		}
+	}
+	return
+}
+
+func readBaseFile(path string) (*baseFile, error) {
+	content := baseFile{}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &content, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("read gzip file:%s fail,%v ", path, err)
+	}
+	defer gr.Close()
+	if err = gob.NewDecoder(gr).Decode(&content); err != nil {
+		return nil, fmt.Errorf("decode file:%s fail,%v ", path, err)
+	}
+	return &content, nil
+}
+
+// writeBaseFile 先写临时文件再改名,避免读到写了一半的文件
+func writeBaseFile(path string, content *baseFile) error {
+	tmpPath := path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	gw := gzip.NewWriter(f)
+	err = gob.NewEncoder(gw).Encode(content)
+	if err == nil {
+		err = gw.Close()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("write file:%s fail,%v ", path, err)
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// readSegmentRecords 逐个gzip块解码,最后一条记录不完整时(写入中)忽略
+func readSegmentRecords(reader *bufio.Reader, handle func(record *segmentRecord)) error {
+	gr, err := gzip.NewReader(reader)
+	if err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		return err
+	}
+	defer gr.Close()
+	for {
+		gr.Multistream(false)
+		record := segmentRecord{}
+		if err = gob.NewDecoder(gr).Decode(&record); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return nil
+			}
+			return err
+		}
+		// 读完当前块剩余内容并校验,未写完的块会在这里报错
+		if _, err = io.Copy(ioutil.Discard, gr); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return nil
+			}
+			return err
+		}
+		handle(&record)
+		if err = gr.Reset(reader); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
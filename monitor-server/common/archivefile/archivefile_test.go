@@ -0,0 +1,97 @@
+package archivefile
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func buildRows(endpoint, metric string, start, num int64) (rows []*Row) {
+	for i := int64(0); i < num; i++ {
+		rows = append(rows, &Row{Endpoint: endpoint, Metric: metric, Tags: "instance=\"a\"", UnixTime: start + i*60, Avg: float64(i), P95: float64(i), Count: 1})
+	}
+	return
+}
+
+func TestSeriesAppendAndCompact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archivefile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	series := NewSeries(dir, "archive_2024_01_01", "node_cpu/used", "host_1")
+	if err = series.AppendRows(buildRows("host_1", "node_cpu/used", 0, 5)); err != nil {
+		t.Fatal(err)
+	}
+	if err = series.AppendRows(buildRows("host_1", "node_cpu/used", 300, 5)); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := series.Read()
+	if err != nil || len(rows) != 10 {
+		t.Fatalf("read after append expect 10 rows,get:%d err:%v", len(rows), err)
+	}
+	if rows[9].UnixTime != 540 || rows[9].Endpoint != "host_1" || rows[9].Metric != "node_cpu/used" {
+		t.Errorf("last row illegal:%+v", rows[9])
+	}
+	if err = series.AppendClear(0, 300); err != nil {
+		t.Fatal(err)
+	}
+	if rows, _ = series.Read(); len(rows) != 5 || rows[0].UnixTime != 300 {
+		t.Fatalf("read after clear expect 5 rows from 300,get:%d", len(rows))
+	}
+	if err = series.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(series.segmentPath()); !os.IsNotExist(err) {
+		t.Errorf("segment file should be removed after compact")
+	}
+	if rows, _ = series.Read(); len(rows) != 5 {
+		t.Fatalf("read after compact expect 5 rows,get:%d", len(rows))
+	}
+	// 合并后重新写入,基础文件的段偏移需要清零
+	if err = series.AppendRows(buildRows("host_1", "node_cpu/used", 600, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if rows, _ = series.Read(); len(rows) != 7 || rows[6].UnixTime != 660 {
+		t.Fatalf("read after reopen expect 7 rows,get:%d", len(rows))
+	}
+	seriesList, err := ListSeries(dir, "archive_2024_01_01", "")
+	if err != nil || len(seriesList) != 1 || seriesList[0].Endpoint != "host_1" || seriesList[0].Metric != "node_cpu/used" {
+		t.Fatalf("list series illegal:%v err:%v", seriesList, err)
+	}
+}
+
+func TestSeriesReadWithUnfinishedRecord(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archivefile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	series := NewSeries(dir, "archive_2024_01_01", "mem", "host_1")
+	if err = series.AppendRows(buildRows("host_1", "mem", 0, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if err = series.AppendRows(buildRows("host_1", "mem", 180, 3)); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(series.segmentPath())
+	// 模拟第二条记录正在写入
+	if err = os.Truncate(series.segmentPath(), info.Size()-10); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := series.Read()
+	if err != nil || len(rows) != 3 {
+		t.Fatalf("read with unfinished record expect 3 rows,get:%d err:%v", len(rows), err)
+	}
+}
+
+func TestSeriesReadNotExist(t *testing.T) {
+	rows, err := NewSeries(os.TempDir(), "archive_not_exist", "mem", "host_1").Read()
+	if err != nil || len(rows) != 0 {
+		t.Errorf("read not exist series expect empty,get:%d err:%v", len(rows), err)
+	}
+	seriesList, err := ListSeries(os.TempDir(), "archive_not_exist", "")
+	if err != nil || len(seriesList) != 0 {
+		t.Errorf("list not exist partition expect empty,get:%d err:%v", len(seriesList), err)
+	}
+}
@@ -0,0 +1,133 @@
+// Package cronexpr 解析标准5段式cron表达式(分 时 日 月 周),只用于判断某个时间点是否命中
+package cronexpr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Schedule struct {
+	minute   uint64
+	hour     uint64
+	dom      uint64
+	month    uint64
+	dow      uint64
+	domStar  bool
+	dowStar  bool
+	rawInput string
+}
+
+type fieldBound struct {
+	min   int
+	max   int
+	names map[string]int
+}
+
+var fieldBounds = []fieldBound{{0, 59, nil}, {0, 23, nil}, {1, 31, nil}, {1, 12, monthNames}, {0, 7, dowNames}}
+
+// 月和周可以用英文缩写,不区分大小写
+var (
+	monthNames = map[string]int{"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6, "JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12}
+	dowNames   = map[string]int{"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6}
+)
+
+func Parse(input string) (*Schedule, error) {
+	fields := strings.Fields(input)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expr:%s illegal,need 5 fields ", input)
+	}
+	result := &Schedule{rawInput: input}
+	bitList := make([]uint64, 5)
+	for i, field := range fields {
+		bits, err := parseField(field, fieldBounds[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron expr:%s field:%s illegal,%s ", input, field, err.Error())
+		}
+		bitList[i] = bits
+	}
+	result.minute, result.hour, result.dom, result.month, result.dow = bitList[0], bitList[1], bitList[2], bitList[3], bitList[4]
+	// 周日可以写成0或者7
+	if result.dow&(1<<7) > 0 {
+		result.dow |= 1
+	}
+	result.domStar = fields[2] == "*" || fields[2] == "?"
+	result.dowStar = fields[4] == "*" || fields[4] == "?"
+	return result, nil
+}
+
+// Match 判断时间点(精确到分钟)是否命中表达式,日和周同时指定时任意一个命中即可
+func (s *Schedule) Match(t time.Time) bool {
+	if s.minute&(1<<uint(t.Minute())) == 0 || s.hour&(1<<uint(t.Hour())) == 0 || s.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	domMatch := s.dom&(1<<uint(t.Day())) > 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) > 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// LastMatchWithin 在 [t-duration, t] 内往前找最近一次命中的时间
+func (s *Schedule) LastMatchWithin(t time.Time, duration time.Duration) (time.Time, bool) {
+	cur := t.Truncate(time.Minute)
+	limit := t.Add(-duration)
+	for !cur.Before(limit) {
+		if s.Match(cur) {
+			return cur, true
+		}
+		cur = cur.Add(-time.Minute)
+	}
+	return time.Time{}, false
+}
+
+func (s *Schedule) String() string {
+	return s.rawInput
+}
+
+func parseField(field string, bound fieldBound) (bits uint64, err error) {
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if stepIndex := strings.Index(part, "/"); stepIndex >= 0 {
+			if step, err = strconv.Atoi(part[stepIndex+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("step:%s illegal", part[stepIndex+1:])
+			}
+			part = part[:stepIndex]
+		}
+		start, end := bound.min, bound.max
+		if part != "*" && part != "?" {
+			rangeList := strings.SplitN(part, "-", 2)
+			if start, err = parseValue(rangeList[0], bound); err != nil {
+				return 0, err
+			}
+			end = start
+			if len(rangeList) == 2 {
+				if end, err = parseValue(rangeList[1], bound); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				end = bound.max
+			}
+		}
+		if start < bound.min || end > bound.max || start > end {
+			return 0, fmt.Errorf("range %d-%d out of bound %d-%d", start, end, bound.min, bound.max)
+		}
+		for i := start; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(input string, bound fieldBound) (int, error) {
+	if v, b := bound.names[strings.ToUpper(input)]; b {
+		return v, nil
+	}
+	v, err := strconv.Atoi(input)
+	if err != nil {
+		return 0, fmt.Errorf("value:%s illegal", input)
+	}
+	return v, nil
+}
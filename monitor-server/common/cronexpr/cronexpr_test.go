@@ -0,0 +1,177 @@
+package cronexpr
+
+import (
+	"testing"
+	"time"
+)
+
+func buildBits(values ...int) (bits uint64) {
+	for _, v := range values {
+		bits |= 1 << uint(v)
+	}
+	return
+}
+
+func buildRangeBits(start, end, step int) (bits uint64) {
+	for i := start; i <= end; i += step {
+		bits |= 1 << uint(i)
+	}
+	return
+}
+
+func TestParseField(t *testing.T) {
+	const (
+		minuteField = 0
+		hourField   = 1
+		domField    = 2
+		monthField  = 3
+		dowField    = 4
+	)
+	testCases := []struct {
+		field      string
+		fieldIndex int
+		bits       uint64
+		fail       bool
+	}{
+		{"*", hourField, buildRangeBits(0, 23, 1), false},
+		{"?", domField, buildRangeBits(1, 31, 1), false},
+		{"5", minuteField, buildBits(5), false},
+		{"1-5", dowField, buildRangeBits(1, 5, 1), false},
+		{"*/15", minuteField, buildBits(0, 15, 30, 45), false},
+		{"10/20", minuteField, buildBits(10, 30, 50), false},
+		{"1-10/3", domField, buildBits(1, 4, 7, 10), false},
+		{"1,3,5-7", domField, buildBits(1, 3, 5, 6, 7), false},
+		{"JAN-MAR", monthField, buildBits(1, 2, 3), false},
+		{"jun,Dec", monthField, buildBits(6, 12), false},
+		{"mon-fri", dowField, buildRangeBits(1, 5, 1), false},
+		{"SUN,7", dowField, buildBits(0, 7), false},
+		{"60", minuteField, 0, true},
+		{"0", domField, 0, true},
+		{"13", monthField, 0, true},
+		{"8", dowField, 0, true},
+		{"5-1", hourField, 0, true},
+		{"*/0", minuteField, 0, true},
+		{"*/x", minuteField, 0, true},
+		{"abc", minuteField, 0, true},
+		{"MON", monthField, 0, true},
+		{"JAN", dowField, 0, true},
+		{"1,", hourField, 0, true},
+		{"1-", hourField, 0, true},
+		{"-1", hourField, 0, true},
+	}
+	for _, v := range testCases {
+		bits, err := parseField(v.field, fieldBounds[v.fieldIndex])
+		if (err != nil) != v.fail || bits != v.bits {
+			t.Errorf("field %s index %d expect bits %b fail:%t,get:%b err:%v", v.field, v.fieldIndex, v.bits, v.fail, bits, err)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		input string
+		fail  bool
+	}{
+		{"* * * * *", false},
+		{"  30  9 * *  MON-FRI ", false},
+		{"0 0 1,15 * ?", false},
+		{"0 0 L * *", true},
+		{"* * * *", true},
+		{"* * * * * *", true},
+		{"61 * * * *", true},
+		{"* 24 * * *", true},
+		{"* * 32 * *", true},
+		{"", true},
+	}
+	for _, v := range testCases {
+		schedule, err := Parse(v.input)
+		if (err != nil) != v.fail {
+			t.Errorf("parse %q fail should be %t,err:%v", v.input, v.fail, err)
+		}
+		if err == nil && schedule.String() != v.input {
+			t.Errorf("parse %q string should keep raw input,get:%q", v.input, schedule.String())
+		}
+	}
+	schedule, _ := Parse("0 0 * * 7")
+	if schedule.dow != buildBits(0, 7) {
+		t.Errorf("dow 7 should also match sunday,get:%b", schedule.dow)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	testCases := []struct {
+		input  string
+		time   string
+		result bool
+	}{
+		{"30 9 * * MON-FRI", "2024-01-01 09:30", true},
+		{"30 9 * * MON-FRI", "2024-01-06 09:30", false},
+		{"30 9 * * MON-FRI", "2024-01-01 09:31", false},
+		{"30 9 * * MON-FRI", "2024-01-01 10:30", false},
+		// 日和周都指定时任意一个命中即可
+		{"0 0 1 * MON", "2024-01-01 00:00", true},
+		{"0 0 1 * MON", "2024-02-01 00:00", true},
+		{"0 0 1 * MON", "2024-01-08 00:00", true},
+		{"0 0 1 * MON", "2024-01-09 00:00", false},
+		{"0 0 */10 * 1", "2024-01-11 00:00", true},
+		{"0 0 */10 * 1", "2024-01-08 00:00", true},
+		{"0 0 */10 * 1", "2024-01-09 00:00", false},
+		// 日或周是 * 时另一个必须命中
+		{"0 0 * * 0", "2024-01-07 00:00", true},
+		{"0 0 * * 7", "2024-01-07 00:00", true},
+		{"0 0 * * 0", "2024-01-08 00:00", false},
+		{"0 0 15 * *", "2024-01-15 00:00", true},
+		{"0 0 15 ? *", "2024-01-15 00:00", true},
+		{"0 0 15 * *", "2024-01-16 00:00", false},
+		// 月份边界
+		{"0 0 31 * *", "2024-04-30 00:00", false},
+		{"0 0 31 * *", "2024-05-31 00:00", true},
+		{"0 0 29 FEB *", "2024-02-29 00:00", true},
+		{"0 0 1 MAR *", "2024-02-29 00:00", false},
+		{"0 0 * JAN,DEC *", "2024-12-31 00:00", true},
+		{"0 0 * JAN,DEC *", "2024-11-30 00:00", false},
+	}
+	for _, v := range testCases {
+		schedule, err := Parse(v.input)
+		if err != nil {
+			t.Fatalf("parse %s fail:%v", v.input, err)
+		}
+		tmpTime, _ := time.Parse("2006-01-02 15:04", v.time)
+		if schedule.Match(tmpTime) != v.result {
+			t.Errorf("%s match %s should be %t", v.input, v.time, v.result)
+		}
+	}
+}
+
+func TestLastMatchWithin(t *testing.T) {
+	testCases := []struct {
+		input    string
+		time     string
+		duration time.Duration
+		result   string
+		found    bool
+	}{
+		{"0 0 1 * *", "2024-03-01 00:30:00", time.Hour, "2024-03-01 00:00", true},
+		{"0 0 1 * *", "2024-03-01 00:00:45", time.Minute, "2024-03-01 00:00", true},
+		{"*/5 * * * *", "2024-03-01 10:17:00", time.Hour, "2024-03-01 10:15", true},
+		{"0 10 * * *", "2024-01-01 11:00:00", time.Hour, "2024-01-01 10:00", true},
+		{"0 12 * * *", "2024-01-01 11:00:00", time.Hour, "", false},
+		{"0 9 * * *", "2024-01-01 11:00:00", time.Hour, "", false},
+		// 往前找时跨月、跨年
+		{"59 23 * * *", "2024-03-01 00:05:00", 10 * time.Minute, "2024-02-29 23:59", true},
+		{"59 23 * * *", "2023-03-01 00:05:00", 10 * time.Minute, "2023-02-28 23:59", true},
+		{"30 23 31 DEC *", "2025-01-01 00:10:00", time.Hour, "2024-12-31 23:30", true},
+		{"0 0 31 * *", "2024-05-01 00:10:00", 48 * time.Hour, "", false},
+	}
+	for _, v := range testCases {
+		schedule, err := Parse(v.input)
+		if err != nil {
+			t.Fatalf("parse %s fail:%v", v.input, err)
+		}
+		tmpTime, _ := time.Parse("2006-01-02 15:04:05", v.time)
+		matchTime, found := schedule.LastMatchWithin(tmpTime, v.duration)
+		if found != v.found || (found && matchTime.Format("2006-01-02 15:04") != v.result) {
+			t.Errorf("%s last match within %s before %s should be %s found:%t,get:%s found:%t", v.input, v.duration, v.time, v.result, v.found, matchTime, found)
+		}
+	}
+}
@@ -4,10 +4,16 @@ import (
 	"bytes"
 	"crypto/tls"
 	"fmt"
+	"mime"
 	"regexp"
 	"strings"
 )
 
+const (
+	ContentTypeText = "text/plain"
+	ContentTypeHtml = "text/html"
+)
+
 type MailSender struct {
 	SenderName   string
 	SenderMail   string
@@ -17,6 +23,7 @@ type MailSender struct {
 	SSL          bool
 	Auth         Auth
 	ByStartTLS   bool
+	ContentType  string
 }
 
 func (ms *MailSender) Init() error {
@@ -64,7 +71,7 @@ func (ms *MailSender) Send(subject, content string, addressee []string) error {
 			err = ms.sendTLSMail(subject, content, addressee)
 		}
 	} else {
-		err = SendMail(ms.AuthServer, ms.Auth, ms.SenderMail, addressee, mailQQMessage(addressee, subject, content, ms.SenderName, ms.SenderMail))
+		err = SendMail(ms.AuthServer, ms.Auth, ms.SenderMail, addressee, mailQQMessage(addressee, subject, content, ms.SenderName, ms.SenderMail, ms.ContentType))
 	}
 	return err
 }
@@ -108,7 +115,7 @@ func (ms *MailSender) sendStartTLSMail(subject, content string, addressee []stri
 	if err != nil {
 		return fmt.Errorf("client data init error: %v", err)
 	}
-	_, err = w.Write(mailQQMessage(addressee, subject, content, ms.SenderName, ms.SenderMail))
+	_, err = w.Write(mailQQMessage(addressee, subject, content, ms.SenderName, ms.SenderMail, ms.ContentType))
 	if err != nil {
 		return fmt.Errorf("write message error: %v", err)
 	}
@@ -153,7 +160,7 @@ func (ms *MailSender) sendTLSMail(subject, content string, addressee []string) e
 	if err != nil {
 		return fmt.Errorf("client data init error: %v", err)
 	}
-	_, err = w.Write(mailQQMessage(addressee, subject, content, ms.SenderName, ms.SenderMail))
+	_, err = w.Write(mailQQMessage(addressee, subject, content, ms.SenderName, ms.SenderMail, ms.ContentType))
 	if err != nil {
 		return fmt.Errorf("write message error: %v", err)
 	}
@@ -165,15 +172,18 @@ func (ms *MailSender) sendTLSMail(subject, content string, addressee []string) e
 	return err
 }
 
-func mailQQMessage(addressee []string, subject, content, senderName, senderMail string) []byte {
+func mailQQMessage(addressee []string, subject, content, senderName, senderMail, contentType string) []byte {
+	if contentType == "" {
+		contentType = ContentTypeText
+	}
 	var buff bytes.Buffer
 	buff.WriteString("To:")
 	buff.WriteString(strings.Join(addressee, ","))
 	buff.WriteString("\r\nFrom:")
 	buff.WriteString(senderName + "<" + senderMail + ">")
 	buff.WriteString("\r\nSubject:")
-	buff.WriteString(subject)
-	buff.WriteString("\r\nContent-Type:text/plain;charset=UTF-8\r\n\r\n")
+	buff.WriteString(mime.BEncoding.Encode("UTF-8", subject))
+	buff.WriteString("\r\nContent-Type:" + contentType + ";charset=UTF-8\r\n\r\n")
 	buff.WriteString(content)
 	return buff.Bytes()
 }
@@ -33,6 +33,7 @@ func main() {
 	if m.Config().Alert.Enable {
 		other.InitSmtpMail()
 	}
+	db.StartAlarmWebhookWorker()
 	go api.InitClusterApi()
 	go db.InitPrometheusConfig()
 	go db.InitSysParameter()
@@ -44,6 +45,8 @@ func main() {
 	go alarm.StartAlarmEngineCron()
 	go db.SyncDbMetric(true)
 	go db.StartCallCronJob()
+	go db.StartNotifyEscalateCronJob()
+	go db.StartAlarmSilenceExpireCronJob()
 	go db.StartNotifyPingExport()
 	go api.InitDependenceParam()
 	go db.StartInitAlarmUniqueTags()
@@ -89,7 +89,12 @@ func ValidatePost(c *gin.Context, obj interface{}, ex ...string) bool {
 }
 
 func IsIllegalCond(str string) bool {
-	return regCond.MatchString(str)
+	if regCond.MatchString(str) {
+		return true
+	}
+	// 范围、环比、斜率、无数据等条件
+	_, err := models.ParseAlarmCondition(str)
+	return str != "" && err == nil
 }
 
 func IsIllegalLast(str string) bool {
@@ -12,25 +12,31 @@ type RegisterParam struct {
 }
 
 type RegisterParamNew struct {
-	Guid             string `json:"guid"`
-	Type             string `json:"type"`
-	Name             string `json:"name"`
-	Ip               string `json:"ip"`
-	Port             string `json:"port"`
-	User             string `json:"user"`
-	Password         string `json:"password"`
-	Method           string `json:"method"`
-	Url              string `json:"url"`
-	AddDefaultGroup  bool   `json:"add_default_group"`
-	DefaultGroupName string `json:"default_group_name"`
-	AgentManager     bool   `json:"agent_manager"`
-	FetchMetric      bool   `json:"fetch_metric"`
-	Step             int    `json:"step"`
-	ExportAddress    string `json:"export_address"`
-	Cluster          string `json:"cluster"`
-	ProxyExporter    string `json:"proxy_exporter"`
-	ProcessName      string `json:"process_name"`
-	Tags             string `json:"tags"`
+	Guid             string              `json:"guid"`
+	Type             string              `json:"type"`
+	Name             string              `json:"name"`
+	Ip               string              `json:"ip"`
+	Port             string              `json:"port"`
+	User             string              `json:"user"`
+	Password         string              `json:"password"`
+	DbIp             string              `json:"db_ip"`   // postgresql,sqlserver 数据库实例地址,ip/port 为 exporter 地址
+	DbPort           string              `json:"db_port"` // postgresql,sqlserver 数据库实例端口
+	Method           string              `json:"method"`
+	Url              string              `json:"url"`
+	AddDefaultGroup  bool                `json:"add_default_group"`
+	DefaultGroupName string              `json:"default_group_name"`
+	AgentManager     bool                `json:"agent_manager"`
+	FetchMetric      bool                `json:"fetch_metric"`
+	Step             int                 `json:"step"`
+	ExportAddress    string              `json:"export_address"`
+	Cluster          string              `json:"cluster"`
+	ProxyExporter    string              `json:"proxy_exporter"`
+	ProcessName      string              `json:"process_name"`
+	Tags             string              `json:"tags"`
+	HttpCheck        *HttpCheckConfigObj `json:"http_check"`
+	Probe            *ProbeConfigObj     `json:"probe"`
+	ProbeLocations   []string            `json:"probe_locations"`
+	ProbeQuorum      int                 `json:"probe_quorum"`
 }
 
 type RegisterConsulParam struct {
@@ -102,8 +108,11 @@ type PingExporterSourceDto struct {
 }
 
 type PingExportSourceObj struct {
-	Ip   string `json:"ip"`
-	Guid string `json:"guid"`
+	Ip        string              `json:"ip"`
+	Guid      string              `json:"guid"`
+	HttpCheck *HttpCheckConfigObj `json:"http_check,omitempty"`
+	Probe     *ProbeConfigObj     `json:"probe,omitempty"`
+	Quorum    int                 `json:"quorum,omitempty"`
 }
 
 type TelnetSourceQuery struct {
@@ -69,6 +69,11 @@ type AlarmTable struct {
 	AlarmStrategy string    `json:"alarm_strategy"`
 	NotifyId      string    `json:"notify_id"`
 	AlarmName     string    `json:"alarm_name"`
+	AckUser       string    `json:"ack_user"`
+	AckTime       time.Time `json:"ack_time"`
+	AckMsg        string    `json:"ack_msg"`
+	Silence       string    `json:"silence"`
+	InhibitBy     int       `json:"inhibit_by"`
 }
 
 type SortAlarmList []*AlarmTable
@@ -97,47 +102,54 @@ type AlarmHandleObj struct {
 }
 
 type AlarmProblemQuery struct {
-	Id                 int                   `json:"id"`
-	StrategyId         int                   `json:"strategy_id"`
-	Endpoint           string                `json:"endpoint"`
-	EndpointGuid       string                `json:"endpoint_guid"`
-	Status             string                `json:"status"`
-	SMetric            string                `json:"s_metric"`
-	SExpr              string                `json:"s_expr"`
-	SCond              string                `json:"s_cond"`
-	SLast              string                `json:"s_last"`
-	SPriority          string                `json:"s_priority"`
-	Content            string                `json:"content"`
-	Tags               string                `json:"tags"`
-	StartValue         float64               `json:"start_value"`
-	Start              time.Time             `json:"start"`
-	StartString        string                `json:"start_string"`
-	EndValue           float64               `json:"end_value"`
-	End                time.Time             `json:"end"`
-	EndString          string                `json:"end_string"`
-	IsLogMonitor       bool                  `json:"is_log_monitor"`
-	Path               string                `json:"path"`
-	Keyword            string                `json:"keyword"`
-	IsCustom           bool                  `json:"is_custom"`
-	CloseType          string                `json:"close_type"`
-	CloseMsg           string                `json:"close_msg"`
-	CloseUser          string                `json:"close_user"`
-	CustomMessage      string                `json:"custom_message"`
-	EndpointTags       string                `json:"endpoint_tags"`
-	AlarmStrategy      string                `json:"alarm_strategy"`
-	Title              string                `json:"title"`
-	SystemId           string                `json:"system_id"`
-	NotifyId           string                `json:"notify_id"`
-	NotifyMessage      string                `json:"notify_message"`
-	NotifyCallbackName string                `json:"notify_callback_name"`
-	NotifyStatus       string                `json:"notify_status"`
-	NotifyPermission   string                `json:"notify_permission"` // yes表示有权限
-	AlarmObjName       string                `json:"alarm_obj_name"`
-	AlarmName          string                `json:"alarm_name"`
-	AlarmDetail        string                `json:"alarm_detail"`
-	AlarmMetricList    []string              `json:"alarm_metric_list"`
-	StrategyGroups     []*AlarmStrategyGroup `json:"strategy_groups"`
-	Log                string                `json:"log"`
+	Id                 int                            `json:"id"`
+	StrategyId         int                            `json:"strategy_id"`
+	Endpoint           string                         `json:"endpoint"`
+	EndpointGuid       string                         `json:"endpoint_guid"`
+	Status             string                         `json:"status"`
+	SMetric            string                         `json:"s_metric"`
+	SExpr              string                         `json:"s_expr"`
+	SCond              string                         `json:"s_cond"`
+	SLast              string                         `json:"s_last"`
+	SPriority          string                         `json:"s_priority"`
+	Content            string                         `json:"content"`
+	Tags               string                         `json:"tags"`
+	StartValue         float64                        `json:"start_value"`
+	Start              time.Time                      `json:"start"`
+	StartString        string                         `json:"start_string"`
+	EndValue           float64                        `json:"end_value"`
+	End                time.Time                      `json:"end"`
+	EndString          string                         `json:"end_string"`
+	IsLogMonitor       bool                           `json:"is_log_monitor"`
+	Path               string                         `json:"path"`
+	Keyword            string                         `json:"keyword"`
+	IsCustom           bool                           `json:"is_custom"`
+	CloseType          string                         `json:"close_type"`
+	CloseMsg           string                         `json:"close_msg"`
+	CloseUser          string                         `json:"close_user"`
+	CustomMessage      string                         `json:"custom_message"`
+	EndpointTags       string                         `json:"endpoint_tags"`
+	AlarmStrategy      string                         `json:"alarm_strategy"`
+	Title              string                         `json:"title"`
+	SystemId           string                         `json:"system_id"`
+	NotifyId           string                         `json:"notify_id"`
+	NotifyMessage      string                         `json:"notify_message"`
+	NotifyCallbackName string                         `json:"notify_callback_name"`
+	NotifyStatus       string                         `json:"notify_status"`
+	NotifyPermission   string                         `json:"notify_permission"` // yes表示有权限
+	AlarmObjName       string                         `json:"alarm_obj_name"`
+	AlarmName          string                         `json:"alarm_name"`
+	AlarmDetail        string                         `json:"alarm_detail"`
+	AlarmMetricList    []string                       `json:"alarm_metric_list"`
+	StrategyGroups     []*AlarmStrategyGroup          `json:"strategy_groups"`
+	Log                string                         `json:"log"`
+	EscalateLogs       []*AlarmNotifyEscalateLogTable `json:"escalate_logs"`
+	AckUser            string                         `json:"ack_user"`
+	AckTime            time.Time                      `json:"-"`
+	AckTimeString      string                         `json:"ack_time"`
+	AckMsg             string                         `json:"ack_msg"`
+	Silence            string                         `json:"silence"`
+	InhibitBy          int                            `json:"inhibit_by"`
 }
 
 type UpdateAlarmCustomMessageDto struct {
@@ -463,6 +475,7 @@ type EndpointHttpTable struct {
 	EndpointGuid string `json:"endpoint_guid"`
 	Method       string `json:"method"`
 	Url          string `json:"url"`
+	CheckConfig  string `json:"check_config"`
 }
 
 type LogMonitorTags struct {
@@ -489,6 +502,7 @@ type QueryProblemAlarmPageDto struct {
 	AlarmName         []string  `json:"alarm_name"`
 	CustomDashboardId int       `json:"custom_dashboard_id"`
 	Query             string    `json:"query"`
+	AckStatus         string    `json:"ack_status"` // acked | unacked
 }
 
 type QueryHistoryAlarmParam struct {
@@ -595,6 +609,21 @@ type AlarmCloseParam struct {
 	Priority  []string `json:"priority"`
 }
 
+type AlarmAckParam struct {
+	Id      int    `json:"id" binding:"required"`
+	Message string `json:"message"`
+}
+
+type AlarmAckLogTable struct {
+	Id            int       `json:"id" xorm:"id"`
+	AlarmId       int       `json:"alarm_id" xorm:"alarm_id"`
+	Action        string    `json:"action" xorm:"action"` // ack | unack
+	Operator      string    `json:"operator" xorm:"operator"`
+	Message       string    `json:"message" xorm:"message"`
+	CreateTime    time.Time `json:"-" xorm:"create_time"`
+	CreateTimeStr string    `json:"create_time" xorm:"-"`
+}
+
 type AlarmCondition struct {
 	Guid          string    `json:"guid" xorm:"guid"`                    // 唯一标识
 	AlarmStrategy string    `json:"alarmStrategy" xorm:"alarm_strategy"` // 告警配置表
@@ -766,6 +795,7 @@ type QueryAlarmCondition struct {
 	UserRoles           []string
 	Token               string
 	Query               string // 支持告警任意搜索
+	AckStatus           string // acked:已确认 unacked:未确认
 }
 
 type AlarmFiring struct {
@@ -811,3 +841,46 @@ func (s SimpleAlarmSort) Less(i, j int) bool {
 	}
 	return true
 }
+
+type AlarmWebhookPayload struct {
+	EventId       string                   `json:"eventId"`
+	Notify        string                   `json:"notify"`
+	Param         string                   `json:"param"`
+	AlarmId       int                      `json:"alarmId"`
+	Status        string                   `json:"status"`
+	AlarmName     string                   `json:"alarmName"`
+	AlarmStrategy string                   `json:"alarmStrategy"`
+	StrategyName  string                   `json:"strategyName"`
+	Endpoint      string                   `json:"endpoint"`
+	EndpointIp    string                   `json:"endpointIp"`
+	EndpointType  string                   `json:"endpointType"`
+	EndpointTags  string                   `json:"endpointTags"`
+	Metric        string                   `json:"metric"`
+	Expr          string                   `json:"expr"`
+	Cond          string                   `json:"cond"`
+	Last          string                   `json:"last"`
+	Priority      string                   `json:"priority"`
+	Content       string                   `json:"content"`
+	Tags          map[string]string        `json:"tags"`
+	Start         string                   `json:"start"`
+	StartValue    float64                  `json:"startValue"`
+	End           string                   `json:"end"`
+	EndValue      float64                  `json:"endValue"`
+	Detail        []*AlarmWebhookDetailObj `json:"detail"`
+	Title         string                   `json:"title"`
+	Message       string                   `json:"message"`
+	MessageType   string                   `json:"messageType"`
+	IncidentId    int                      `json:"incidentId,omitempty"`
+	SendTime      string                   `json:"sendTime"`
+}
+
+type AlarmWebhookDetailObj struct {
+	Metric     string            `json:"metric"`
+	Cond       string            `json:"cond"`
+	Last       string            `json:"last"`
+	Start      string            `json:"start"`
+	StartValue float64           `json:"startValue"`
+	End        string            `json:"end"`
+	EndValue   float64           `json:"endValue"`
+	Tags       map[string]string `json:"tags"`
+}
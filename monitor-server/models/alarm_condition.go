@@ -0,0 +1,161 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 告警条件类型,阈值条件兼容原来的 >90 这种写法
+const (
+	AlarmConditionThreshold = "threshold" // >90
+	AlarmConditionRange     = "range"     // between 10 and 90 | not between 10 and 90
+	AlarmConditionChange    = "change"    // change_1d>20 和昨天同一时间比变化百分比,支持m/h/d/w
+	AlarmConditionSlope     = "slope"     // slope>5 last窗口内每秒变化率
+	AlarmConditionAbsent    = "absent"    // absent 序列消失
+	AlarmConditionAnomaly   = "anomaly"   // band_1d 3 | season_1w 3 动态基线,偏离基线超过k倍标准差告警,只由监控引擎计算
+
+	AlarmBaselineBand   = "band"   // 最近一段时间的滚动均值±k倍标准差
+	AlarmBaselineSeason = "season" // 上个周期同一时段的均值±k倍标准差
+
+	// 序列消失检测往前看的时间,超过该时间仍没数据的序列不再告警
+	AlarmConditionAbsentLookback = "1h"
+	// 环比时取对比时间点前后这段时间的平均值,避免单点抖动
+	AlarmConditionChangeWindow = "5m"
+	// 周期基线取上个周期同一时段这么长时间的数据计算均值和标准差
+	AlarmConditionSeasonWindow = "1h"
+)
+
+var (
+	regConditionThreshold = regexp.MustCompile(`^(>=|<=|==|!=|>|<)(-?\d+(\.\d+)?)$`)
+	regConditionRange     = regexp.MustCompile(`^(not\s+)?between\s+(-?\d+(\.\d+)?)\s+and\s+(-?\d+(\.\d+)?)$`)
+	regConditionChange    = regexp.MustCompile(`^change_(\d+[mhdw])\s*(>=|<=|==|!=|>|<)\s*(-?\d+(\.\d+)?)$`)
+	regConditionSlope     = regexp.MustCompile(`^slope\s*(>=|<=|==|!=|>|<)\s*(-?\d+(\.\d+)?)$`)
+	regConditionAnomaly   = regexp.MustCompile(`^(band|season)_(\d+[mhdw])\s+(\d+(\.\d+)?)$`)
+)
+
+type AlarmConditionObj struct {
+	Type      string
+	Operator  string
+	Threshold float64
+	Min       float64
+	Max       float64
+	Outside   bool   // range条件是否是范围外告警
+	Offset    string // change条件对比的时间偏移,band基线的窗口,season基线的周期
+	Baseline  string // anomaly条件的基线类型
+}
+
+// ParseAlarmCondition 解析告警条件,空条件表示有值就告警
+func ParseAlarmCondition(input string) (result *AlarmConditionObj, err error) {
+	input = strings.ToLower(strings.TrimSpace(input))
+	result = &AlarmConditionObj{Type: AlarmConditionThreshold}
+	if input == "" {
+		return
+	}
+	if input == AlarmConditionAbsent {
+		result.Type = AlarmConditionAbsent
+		return
+	}
+	if matchList := regConditionRange.FindStringSubmatch(input); len(matchList) > 0 {
+		result.Type = AlarmConditionRange
+		result.Outside = matchList[1] != ""
+		result.Min, _ = strconv.ParseFloat(matchList[2], 64)
+		result.Max, _ = strconv.ParseFloat(matchList[4], 64)
+		if result.Min > result.Max {
+			err = fmt.Errorf("condition:%s illegal,min value can not bigger than max ", input)
+		}
+		return
+	}
+	if matchList := regConditionChange.FindStringSubmatch(input); len(matchList) > 0 {
+		result.Type = AlarmConditionChange
+		result.Offset = matchList[1]
+		result.Operator = matchList[2]
+		result.Threshold, _ = strconv.ParseFloat(matchList[3], 64)
+		return
+	}
+	if matchList := regConditionSlope.FindStringSubmatch(input); len(matchList) > 0 {
+		result.Type = AlarmConditionSlope
+		result.Operator = matchList[1]
+		result.Threshold, _ = strconv.ParseFloat(matchList[2], 64)
+		return
+	}
+	if matchList := regConditionAnomaly.FindStringSubmatch(input); len(matchList) > 0 {
+		// 值为偏离基线的标准差倍数,超过k倍即告警
+		result.Type = AlarmConditionAnomaly
+		result.Baseline = matchList[1]
+		result.Offset = matchList[2]
+		result.Operator = ">"
+		result.Threshold, _ = strconv.ParseFloat(matchList[3], 64)
+		if result.Threshold <= 0 {
+			err = fmt.Errorf("condition:%s illegal,k must bigger than 0 ", input)
+		}
+		return
+	}
+	if matchList := regConditionThreshold.FindStringSubmatch(strings.ReplaceAll(input, " ", "")); len(matchList) > 0 {
+		result.Operator = matchList[1]
+		result.Threshold, _ = strconv.ParseFloat(matchList[2], 64)
+		return
+	}
+	err = fmt.Errorf("condition:%s illegal ", input)
+	return
+}
+
+// BuildValueExpr 返回用来和阈值比较的表达式,监控引擎直接查询该表达式
+func (c *AlarmConditionObj) BuildValueExpr(metricExpr, last string) string {
+	switch c.Type {
+	case AlarmConditionChange:
+		compareExpr := fmt.Sprintf("avg_over_time((%s)[%s:1m] offset %s)", metricExpr, AlarmConditionChangeWindow, c.Offset)
+		// 对比值为0时没有变化百分比,过滤掉避免得到Inf/NaN
+		return fmt.Sprintf("((%s) - %s) / (%s != 0) * 100", metricExpr, compareExpr, compareExpr)
+	case AlarmConditionSlope:
+		return fmt.Sprintf("deriv((%s)[%s:])", metricExpr, last)
+	case AlarmConditionAnomaly:
+		meanExpr, stddevExpr := c.buildBaselineExpr(metricExpr)
+		return fmt.Sprintf("abs((%s) - %s) / %s", metricExpr, meanExpr, stddevExpr)
+	case AlarmConditionAbsent:
+		// 一段时间内有过数据但当前没有数据的序列,保留原有标签方便定位对象
+		return fmt.Sprintf("max_over_time((%s)[%s:1m]) unless (%s)", metricExpr, AlarmConditionAbsentLookback, metricExpr)
+	}
+	return "(" + metricExpr + ")"
+}
+
+// BuildRuleExpr 返回prometheus告警规则表达式
+func (c *AlarmConditionObj) BuildRuleExpr(metricExpr, last string) string {
+	valueExpr := c.BuildValueExpr(metricExpr, last)
+	switch c.Type {
+	case AlarmConditionRange:
+		if c.Outside {
+			return fmt.Sprintf("(%s < %s) or (%s > %s)", valueExpr, formatConditionValue(c.Min), valueExpr, formatConditionValue(c.Max))
+		}
+		return fmt.Sprintf("(%s >= %s) and (%s <= %s)", valueExpr, formatConditionValue(c.Min), valueExpr, formatConditionValue(c.Max))
+	case AlarmConditionAbsent:
+		return valueExpr
+	}
+	if c.Operator == "" {
+		return valueExpr
+	}
+	return fmt.Sprintf("%s %s %s", valueExpr, c.Operator, formatConditionValue(c.Threshold))
+}
+
+// BuildBandExpr 返回动态基线的上下边界表达式,用于图表展示
+func (c *AlarmConditionObj) BuildBandExpr(metricExpr string) (lowerExpr, upperExpr string) {
+	meanExpr, stddevExpr := c.buildBaselineExpr(metricExpr)
+	lowerExpr = fmt.Sprintf("%s - %s * %s", meanExpr, formatConditionValue(c.Threshold), stddevExpr)
+	upperExpr = fmt.Sprintf("%s + %s * %s", meanExpr, formatConditionValue(c.Threshold), stddevExpr)
+	return
+}
+
+func (c *AlarmConditionObj) buildBaselineExpr(metricExpr string) (meanExpr, stddevExpr string) {
+	rangeExpr := fmt.Sprintf("(%s)[%s:1m]", metricExpr, c.Offset)
+	if c.Baseline == AlarmBaselineSeason {
+		rangeExpr = fmt.Sprintf("(%s)[%s:1m] offset %s", metricExpr, AlarmConditionSeasonWindow, c.Offset)
+	}
+	meanExpr = fmt.Sprintf("avg_over_time(%s)", rangeExpr)
+	stddevExpr = fmt.Sprintf("stddev_over_time(%s)", rangeExpr)
+	return
+}
+
+func formatConditionValue(input float64) string {
+	return strconv.FormatFloat(input, 'f', -1, 64)
+}
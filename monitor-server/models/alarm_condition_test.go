@@ -0,0 +1,86 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAlarmCondition(t *testing.T) {
+	cases := []struct {
+		input    string
+		condType string
+		operator string
+		value    float64
+		illegal  bool
+	}{
+		{input: ">90", condType: AlarmConditionThreshold, operator: ">", value: 90},
+		{input: "<= -1.5", condType: AlarmConditionThreshold, operator: "<=", value: -1.5},
+		{input: "", condType: AlarmConditionThreshold},
+		{input: "change_1d>20", condType: AlarmConditionChange, operator: ">", value: 20},
+		{input: "slope < 0.5", condType: AlarmConditionSlope, operator: "<", value: 0.5},
+		{input: "absent", condType: AlarmConditionAbsent},
+		{input: "band_1h 3", condType: AlarmConditionAnomaly, operator: ">", value: 3},
+		{input: "between 90 and 10", illegal: true},
+		{input: "band_1h 0", illegal: true},
+		{input: "abc", illegal: true},
+	}
+	for _, c := range cases {
+		result, err := ParseAlarmCondition(c.input)
+		if c.illegal {
+			if err == nil {
+				t.Errorf("condition %q should be illegal", c.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("condition %q parse fail: %v", c.input, err)
+			continue
+		}
+		if result.Type != c.condType || result.Operator != c.operator || result.Threshold != c.value {
+			t.Errorf("condition %q parse result %+v not match", c.input, result)
+		}
+	}
+}
+
+func TestParseAlarmConditionRange(t *testing.T) {
+	result, err := ParseAlarmCondition("Not Between 10 and 90")
+	if err != nil {
+		t.Fatalf("parse range condition fail: %v", err)
+	}
+	if result.Type != AlarmConditionRange || !result.Outside || result.Min != 10 || result.Max != 90 {
+		t.Errorf("range condition parse result %+v not match", result)
+	}
+	expr := result.BuildRuleExpr("up", "1m")
+	if expr != "((up) < 10) or ((up) > 90)" {
+		t.Errorf("range rule expr %s not match", expr)
+	}
+}
+
+func TestBuildRuleExpr(t *testing.T) {
+	cases := map[string]string{
+		">90":          "(up) > 90",
+		"slope>5":      "deriv((up)[5m:]) > 5",
+		"absent":       "max_over_time((up)[1h:1m]) unless (up)",
+		"change_1d>20": "((up) - avg_over_time((up)[5m:1m] offset 1d)) / (avg_over_time((up)[5m:1m] offset 1d) != 0) * 100 > 20",
+	}
+	for input, expect := range cases {
+		conditionObj, err := ParseAlarmCondition(input)
+		if err != nil {
+			t.Fatalf("parse condition %s fail: %v", input, err)
+		}
+		if expr := conditionObj.BuildRuleExpr("up", "5m"); expr != expect {
+			t.Errorf("condition %s rule expr %s, expect %s", input, expr, expect)
+		}
+	}
+}
+
+func TestBuildBandExpr(t *testing.T) {
+	conditionObj, err := ParseAlarmCondition("season_1w 2.5")
+	if err != nil {
+		t.Fatalf("parse condition fail: %v", err)
+	}
+	lowerExpr, upperExpr := conditionObj.BuildBandExpr("up")
+	if !strings.Contains(lowerExpr, "[1h:1m] offset 1w") || !strings.HasPrefix(upperExpr, "avg_over_time") || !strings.Contains(upperExpr, "+ 2.5 *") {
+		t.Errorf("season band expr not match,lower:%s upper:%s", lowerExpr, upperExpr)
+	}
+}
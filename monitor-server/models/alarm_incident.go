@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+const (
+	IncidentGroupByEndpoint      = "endpoint"
+	IncidentGroupByServiceGroup  = "service_group"
+	IncidentGroupByEndpointGroup = "endpoint_group"
+	IncidentGroupByAlarmStrategy = "alarm_strategy"
+	IncidentGroupByTagPrefix     = "tag:"
+)
+
+type AlarmIncidentTable struct {
+	Id          int       `json:"id" xorm:"id"`
+	GroupKey    string    `json:"group_key" xorm:"group_key"`
+	GroupLabel  string    `json:"group_label" xorm:"group_label"`
+	Status      string    `json:"status" xorm:"status"` // firing | ok | closed
+	AlarmCount  int       `json:"alarm_count" xorm:"alarm_count"`
+	Notify      string    `json:"notify" xorm:"notify"`
+	Start       time.Time `json:"-" xorm:"start"`
+	End         time.Time `json:"-" xorm:"end"`
+	CloseUser   string    `json:"close_user" xorm:"close_user"`
+	UpdateTime  time.Time `json:"-" xorm:"update_time"`
+	StartString string    `json:"start" xorm:"-"`
+	EndString   string    `json:"end" xorm:"-"`
+}
+
+type AlarmIncidentRelTable struct {
+	Id         int       `json:"id" xorm:"id"`
+	Incident   int       `json:"incident" xorm:"incident"`
+	AlarmId    int       `json:"alarm_id" xorm:"alarm_id"`
+	CreateTime time.Time `json:"create_time" xorm:"create_time"`
+}
+
+type AlarmIncidentQueryParam struct {
+	Status     string    `json:"status"`
+	GroupLabel string    `json:"group_label"`
+	Page       *PageInfo `json:"page"`
+}
+
+type AlarmIncidentQueryResult struct {
+	Data []*AlarmIncidentTable `json:"data"`
+	Page *PageInfo             `json:"page"`
+}
+
+type AlarmIncidentDetail struct {
+	AlarmIncidentTable
+	Alarms []*AlarmProblemQuery `json:"alarms"`
+}
+
+type AlarmIncidentCloseParam struct {
+	Id int `json:"id" binding:"required"`
+}
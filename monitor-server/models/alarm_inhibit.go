@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// 抑制规则里equal_labels支持的特殊标签,其余按告警标签比较
+const (
+	InhibitEqualEndpoint     = "endpoint"
+	InhibitEqualServiceGroup = "service_group"
+)
+
+type AlarmInhibitRuleTable struct {
+	Guid           string               `json:"guid" xorm:"guid"`
+	Name           string               `json:"name" xorm:"name" binding:"required"`
+	SourceMatchers string               `json:"-" xorm:"source_matchers"`
+	TargetMatchers string               `json:"-" xorm:"target_matchers"`
+	EqualLabels    string               `json:"-" xorm:"equal_labels"`
+	Source         *AlarmInhibitMatcher `json:"source" xorm:"-" binding:"required"`
+	Target         *AlarmInhibitMatcher `json:"target" xorm:"-" binding:"required"`
+	EqualLabelList []string             `json:"equal_labels" xorm:"-"`
+	Enable         int                  `json:"enable" xorm:"enable"`
+	CreateUser     string               `json:"create_user" xorm:"create_user"`
+	UpdateUser     string               `json:"update_user" xorm:"update_user"`
+	CreateTime     time.Time            `json:"-" xorm:"create_time"`
+	UpdateTime     time.Time            `json:"-" xorm:"update_time"`
+	CreateTimeStr  string               `json:"create_time" xorm:"-"`
+	UpdateTimeStr  string               `json:"update_time" xorm:"-"`
+}
+
+// AlarmInhibitMatcher 源告警和目标告警的匹配条件,为空的字段不参与匹配
+type AlarmInhibitMatcher struct {
+	AlarmStrategy string                 `json:"alarm_strategy"`
+	Metric        string                 `json:"metric"`
+	Priority      string                 `json:"priority"`
+	Tags          []*AlarmSilenceMatcher `json:"tags"`
+}
+
+type AlarmInhibitRuleQueryParam struct {
+	Name   string `json:"name"`
+	Enable string `json:"enable"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+const (
+	SilenceScopeEndpoint      = "endpoint"
+	SilenceScopeEndpointGroup = "endpoint_group"
+	SilenceScopeServiceGroup  = "service_group"
+	SilenceScopeAlarmStrategy = "alarm_strategy"
+	SilenceScopeTag           = "tag"
+
+	SilenceStatusActive  = "active"
+	SilenceStatusExpired = "expired"
+
+	SilenceMatchEqual        = "="
+	SilenceMatchNotEqual     = "!="
+	SilenceMatchRegex        = "=~"
+	SilenceMatchNotRegex     = "!~"
+	SilenceMaxDurationMinute = 7 * 24 * 60
+)
+
+type AlarmSilenceTable struct {
+	Guid           string                 `json:"guid" xorm:"guid"`
+	Name           string                 `json:"name" xorm:"name" binding:"required"`
+	Reason         string                 `json:"reason" xorm:"reason"`
+	ScopeType      string                 `json:"scope_type" xorm:"scope_type"`
+	ScopeValue     string                 `json:"scope_value" xorm:"scope_value"`
+	TagMatchers    string                 `json:"-" xorm:"tag_matchers"`
+	TagMatcherList []*AlarmSilenceMatcher `json:"tag_matchers" xorm:"-"`
+	StartTime      time.Time              `json:"-" xorm:"start_time"`
+	EndTime        time.Time              `json:"-" xorm:"end_time"`
+	StartTimeStr   string                 `json:"start_time" xorm:"-" binding:"required"`
+	EndTimeStr     string                 `json:"end_time" xorm:"-" binding:"required"`
+	CronExpr       string                 `json:"cron_expr" xorm:"cron_expr"` // 周期屏蔽,为空表示一次性屏蔽
+	Duration       int                    `json:"duration" xorm:"duration"`   // 周期屏蔽每次持续分钟数
+	Status         string                 `json:"status" xorm:"status"`
+	CreateUser     string                 `json:"create_user" xorm:"create_user"`
+	UpdateUser     string                 `json:"update_user" xorm:"update_user"`
+	CreateTime     time.Time              `json:"-" xorm:"create_time"`
+	UpdateTime     time.Time              `json:"-" xorm:"update_time"`
+	CreateTimeStr  string                 `json:"create_time" xorm:"-"`
+	UpdateTimeStr  string                 `json:"update_time" xorm:"-"`
+}
+
+// AlarmSilenceMatcher 标签匹配,key除了告警标签外还支持 endpoint/metric/priority/alarm_name
+type AlarmSilenceMatcher struct {
+	Key      string `json:"key"`
+	Operator string `json:"operator"` // = | != | =~ | !~
+	Value    string `json:"value"`
+}
+
+type AlarmSilenceQueryParam struct {
+	Name       string `json:"name"`
+	ScopeType  string `json:"scope_type"`
+	ScopeValue string `json:"scope_value"`
+	Status     string `json:"status"`
+}
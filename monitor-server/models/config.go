@@ -79,6 +79,15 @@ type DataSourceConfig struct {
 	Servers    []*DatasourceServers `json:"servers"`
 	DivideTime int64                `json:"divide_time"`
 	WaitTime   int                  `json:"wait_time"`
+	Routes     []*DatasourceRoute   `json:"routes"`
+}
+
+// DatasourceRoute 把对象、层级对象、集群映射到指定数据源,命中多个数据源时并发查询后合并
+type DatasourceRoute struct {
+	Datasource    string   `json:"datasource"`
+	Endpoints     []string `json:"endpoints"`
+	ServiceGroups []string `json:"service_groups"`
+	Clusters      []string `json:"clusters"`
 }
 
 type DependenceConfig struct {
@@ -99,11 +108,21 @@ type AgentConfig struct {
 }
 
 type DatasourceServers struct {
-	Id    int    `json:"id"`
-	Type  string `json:"type"`
-	Env   string `json:"env"`
-	Host  string `json:"host"`
-	Token string `json:"token"`
+	Id                int    `json:"id"`
+	Name              string `json:"name"`
+	Type              string `json:"type"` // prometheus | thanos | victoriametrics
+	Env               string `json:"env"`
+	Host              string `json:"host"`
+	Token             string `json:"token"`
+	Scheme            string `json:"scheme"`
+	PathPrefix        string `json:"path_prefix"` // 如 victoriametrics 集群版的 /select/0/prometheus
+	Default           bool   `json:"default"`
+	BasicAuthUser     string `json:"basic_auth_user"`
+	BasicAuthPassword string `json:"basic_auth_password"`
+	TlsSkipVerify     bool   `json:"tls_skip_verify"`
+	TlsCaFile         string `json:"tls_ca_file"`
+	TlsCertFile       string `json:"tls_cert_file"`
+	TlsKeyFile        string `json:"tls_key_file"`
 }
 
 type PrometheusConfig struct {
@@ -159,6 +178,40 @@ type ArchiveMysqlConfig struct {
 	Timeout            int    `json:"timeout"`
 	LocalStorageMaxDay int64  `json:"local_storage_max_day"`
 	FiveMinStartDay    int64  `json:"five_min_start_day"`
+	HourStartDay       int64  `json:"hour_start_day"`  // 查询起点早于该天数时查小时层级,0为不使用
+	DayStartDay        int64  `json:"day_start_day"`   // 查询起点早于该天数时查天层级,0为不使用
+	QueryMaxPoint      int    `json:"query_max_point"` // 跨归档查询时每条序列最多返回的点数,超过则降采样
+	Storage            string `json:"storage"`         // 归档存储类型 mysql(默认) | file,需与 archive_mysql_tool 一致
+	FileDir            string `json:"file_dir"`        // file 类型时 archive_mysql_tool 写入的目录
+}
+
+type AlarmWebhookConfig struct {
+	Secret        string `json:"secret"`
+	Timeout       int    `json:"timeout"`
+	RetryTimes    int    `json:"retry_times"`
+	RetryInterval int    `json:"retry_interval"`
+	WorkerNum     int    `json:"worker_num"` // 并发推送的协程数,默认5
+	QueueSize     int    `json:"queue_size"` // 待推送队列长度,队列满时丢弃并记录日志,默认1000
+}
+
+// AlarmIncidentConfig 告警聚合,group_by 支持 endpoint/service_group/alarm_strategy/endpoint_group/tag:xxx
+type AlarmIncidentConfig struct {
+	Enable      bool     `json:"enable"`
+	GroupBy     []string `json:"group_by"`
+	GroupWait   int      `json:"group_wait"`   // 首条告警后等待多少秒再发聚合通知
+	GroupWindow int      `json:"group_window"` // 多少秒内的告警聚合到同一个事件
+}
+
+// AlertmanagerReceiverConfig 接收外部alertmanager告警,通过标签映射到监控对象和告警策略
+type AlertmanagerReceiverConfig struct {
+	Enable          bool              `json:"enable"`
+	Token           string            `json:"token"`            // 请求头 Authorization: Bearer <token>,开启时必填
+	EndpointLabels  []string          `json:"endpoint_labels"`  // 按顺序取标签值匹配对象的guid/agent_address/ip
+	StrategyLabel   string            `json:"strategy_label"`   // 标签值为告警策略guid
+	StrategyMap     map[string]string `json:"strategy_map"`     // alertname -> 告警策略guid
+	DefaultStrategy string            `json:"default_strategy"` // 都没匹配上时用该策略的通知配置
+	PriorityLabel   string            `json:"priority_label"`
+	PriorityMap     map[string]string `json:"priority_map"` // 标签值 -> high/medium/low
 }
 
 type CapacityServerConfig struct {
@@ -167,29 +220,33 @@ type CapacityServerConfig struct {
 }
 
 type GlobalConfig struct {
-	IsPluginMode                 string              `json:"is_plugin_mode"`
-	Http                         *HttpConfig         `json:"http"`
-	Log                          LogConfig           `json:"log"`
-	Store                        StoreConfig         `json:"store"`
-	Datasource                   DataSourceConfig    `json:"datasource"`
-	LimitIp                      []string            `json:"limitIp"`
-	Dependence                   []*DependenceConfig `json:"dependence"`
-	Prometheus                   PrometheusConfig    `json:"prometheus"`
-	TagBlacklist                 []string            `json:"tag_blacklist"`
-	Agent                        []*AgentConfig      `json:"agent"`
-	Alert                        AlertConfig         `json:"alert"`
-	Peer                         PeerConfig          `json:"peer"`
-	CronJob                      CronJobConfig       `json:"cron_job"`
-	SdFile                       SdFileConfig        `json:"sd_file"`
-	ArchiveMysql                 ArchiveMysqlConfig  `json:"archive_mysql"`
-	ProcessCheckList             []string            `json:"process_check_list"`
-	DefaultAdminRole             string              `json:"default_admin_role"`
-	AlarmAliveMaxDay             string              `json:"alarm_alive_max_day"`
-	MonitorAlarmMailEnable       string              `json:"monitor_alarm_mail_enable"`
-	MonitorAlarmCallbackLevelMin string              `json:"monitor_alarm_callback_level_min"`
-	MonitorNotifyTreeventEnable  string              `json:"monitor_notify_treevent_enable"`
-	EncryptSeed                  string              `json:"encrypt_seed"`
-	MenuApiMap                   MenuApiMapConfig    `json:"menu_api_map"`
+	IsPluginMode                 string                     `json:"is_plugin_mode"`
+	Http                         *HttpConfig                `json:"http"`
+	Log                          LogConfig                  `json:"log"`
+	Store                        StoreConfig                `json:"store"`
+	Datasource                   DataSourceConfig           `json:"datasource"`
+	LimitIp                      []string                   `json:"limitIp"`
+	Dependence                   []*DependenceConfig        `json:"dependence"`
+	Prometheus                   PrometheusConfig           `json:"prometheus"`
+	TagBlacklist                 []string                   `json:"tag_blacklist"`
+	Agent                        []*AgentConfig             `json:"agent"`
+	Alert                        AlertConfig                `json:"alert"`
+	Peer                         PeerConfig                 `json:"peer"`
+	CronJob                      CronJobConfig              `json:"cron_job"`
+	SdFile                       SdFileConfig               `json:"sd_file"`
+	ArchiveMysql                 ArchiveMysqlConfig         `json:"archive_mysql"`
+	ProcessCheckList             []string                   `json:"process_check_list"`
+	DefaultAdminRole             string                     `json:"default_admin_role"`
+	AlarmAliveMaxDay             string                     `json:"alarm_alive_max_day"`
+	MonitorAlarmMailEnable       string                     `json:"monitor_alarm_mail_enable"`
+	MonitorAlarmCallbackLevelMin string                     `json:"monitor_alarm_callback_level_min"`
+	MonitorNotifyTreeventEnable  string                     `json:"monitor_notify_treevent_enable"`
+	EncryptSeed                  string                     `json:"encrypt_seed"`
+	MenuApiMap                   MenuApiMapConfig           `json:"menu_api_map"`
+	AlarmWebhook                 AlarmWebhookConfig         `json:"alarm_webhook"`
+	AlarmDashboardUrl            string                     `json:"alarm_dashboard_url"`
+	AlarmIncident                AlarmIncidentConfig        `json:"alarm_incident"`
+	AlertmanagerReceiver         AlertmanagerReceiverConfig `json:"alertmanager_receiver"`
 }
 
 type MenuApiMapConfig struct {
@@ -283,6 +340,39 @@ func InitConfig(cfg string) {
 	} else {
 		NotifyTreeventEnable = false
 	}
+	if config.AlarmWebhook.Timeout <= 0 {
+		config.AlarmWebhook.Timeout = 10
+	}
+	if config.AlarmWebhook.RetryTimes <= 0 {
+		config.AlarmWebhook.RetryTimes = 3
+	}
+	if config.AlarmWebhook.WorkerNum <= 0 {
+		config.AlarmWebhook.WorkerNum = 5
+	}
+	if config.AlarmWebhook.QueueSize <= 0 {
+		config.AlarmWebhook.QueueSize = 1000
+	}
+	if config.ArchiveMysql.QueryMaxPoint <= 0 {
+		config.ArchiveMysql.QueryMaxPoint = 1440
+	}
+	if len(config.AlarmIncident.GroupBy) == 0 {
+		config.AlarmIncident.GroupBy = []string{"endpoint"}
+	}
+	if config.AlarmIncident.GroupWindow <= 0 {
+		config.AlarmIncident.GroupWindow = 300
+	}
+	if len(config.AlertmanagerReceiver.EndpointLabels) == 0 {
+		config.AlertmanagerReceiver.EndpointLabels = []string{"e_guid", "endpoint", "instance"}
+	}
+	if config.AlertmanagerReceiver.StrategyLabel == "" {
+		config.AlertmanagerReceiver.StrategyLabel = "strategy_guid"
+	}
+	if config.AlertmanagerReceiver.PriorityLabel == "" {
+		config.AlertmanagerReceiver.PriorityLabel = "severity"
+	}
+	if len(config.AlertmanagerReceiver.PriorityMap) == 0 {
+		config.AlertmanagerReceiver.PriorityMap = map[string]string{"critical": "high", "error": "high", "warning": "medium", "info": "low"}
+	}
 	if config.MonitorAlarmCallbackLevelMin == "" {
 		config.MonitorAlarmCallbackLevelMin = "high"
 	}
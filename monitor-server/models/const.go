@@ -11,6 +11,7 @@ const (
 	UrlPrefix               = "/monitor"
 	RsaPemPath              = "/data/certs/rsa_key"
 	LogMetricName           = "node_log_metric_monitor_value"
+	LogMetricHistogramName  = "node_log_metric_monitor_histogram"
 	DBMonitorMetricName     = "db_monitor_value"
 	SPAlertMailKey          = "alert_mail"
 	SPMetricTemplate        = "metric_template"
@@ -22,6 +23,16 @@ const (
 	AlarmNotifyAutoMode   = "auto"
 	AlarmNotifyManualMode = "manual"
 
+	AlarmEscalateActionRepeat   = "repeat"
+	AlarmEscalateActionEscalate = "escalate"
+	AlarmEscalateStatusActive   = "active"
+	AlarmEscalateStatusDone     = "done"
+
+	AlarmAckActionAck     = "ack"
+	AlarmAckActionUnack   = "unack"
+	AlarmAckStatusAcked   = "acked"
+	AlarmAckStatusUnacked = "unacked"
+
 	AuthTokenHeader = "Authorization"
 	ContextApiCode  = "apiCode"
 	HomePage        = "HOME_PAGE"
@@ -276,6 +276,9 @@ type ChartQueryParam struct {
 	CustomChartGuid        string                  `json:"custom_chart_guid"`
 	LineType               int                     `json:"lineType"` // lineType=2 表示同环比数据
 	CalcServiceGroupEnable bool                    `json:"calc_service_group_enable"`
+	AlarmStrategy          string                  `json:"alarm_strategy"` // 带上该告警策略动态基线的上下边界,为空时按对象所在组的告警策略
+	Datasource             string                  `json:"datasource"`     // 指定数据源名称, * 表示查询所有数据源
+	MaxPoint               int                     `json:"max_point"`      // 每条序列最多返回的点数,为空取配置 archive_mysql.query_max_point
 }
 
 type ChartQueryConfigObj struct {
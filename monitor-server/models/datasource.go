@@ -26,6 +26,7 @@ type QueryMonitorData struct {
 	PieDisplayTag        string    `json:"pie_display_tag"`
 	ComparisonFlag       string    `json:"comparison_flag"`
 	ServiceConfiguration string    `json:"service_configuration"` // 业务配置, custom 表示自定义
+	Datasource           string    `json:"datasource"`            // 指定数据源名称, * 表示查询所有数据源
 }
 
 type PrometheusParam struct {
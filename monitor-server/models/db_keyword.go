@@ -13,6 +13,8 @@ type DbKeywordMonitor struct {
 	ActiveWindow     string   `json:"active_window" xorm:"active_window"` // 生效时间段
 	Step             int      `json:"step" xorm:"step"`                   // 采集间隔
 	MonitorType      string   `json:"monitor_type" xorm:"monitor_type"`   // 监控类型
+	DbType           string   `json:"db_type" xorm:"db_type"`             // 数据库类型
+	DbName           string   `json:"db_name" xorm:"db_name"`             // 数据库名
 	CreateUser       string   `json:"create_user" xorm:"create_user"`     // 创建人
 	UpdateUser       string   `json:"update_user" xorm:"update_user"`     // 更新人
 	CreateTime       string   `json:"create_time" xorm:"create_time"`     // 创建时间
@@ -1,5 +1,17 @@
 package models
 
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	regDbLabelColumn = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	// db_data_exporter 输出时已经使用的标签,sql 标签列不能重名
+	dbReservedLabelList = []string{"key", "t_endpoint", "address", "service_group", "column", "db_keyword_guid", "e_guid", "guid", "instance", "job"}
+)
+
 type DbMetricMonitorTable struct {
 	Guid         string `json:"guid" xorm:"guid"`
 	ServiceGroup string `json:"service_group" xorm:"service_group"`
@@ -8,6 +20,11 @@ type DbMetricMonitorTable struct {
 	DisplayName  string `json:"display_name" xorm:"display_name"`
 	Step         int64  `json:"step" xorm:"step"`
 	MonitorType  string `json:"monitor_type" xorm:"monitor_type"`
+	DbType       string `json:"db_type" xorm:"db_type"`
+	DbName       string `json:"db_name" xorm:"db_name"`
+	LabelColumns string `json:"label_columns" xorm:"label_columns"`
+	ValueColumns string `json:"value_columns" xorm:"value_columns"`
+	MaxSeries    int    `json:"max_series" xorm:"max_series"`
 	UpdateTime   string `json:"update_time" xorm:"update_time"`
 	UpdateUser   string `json:"update_user" xorm:"update_user"`
 }
@@ -33,11 +50,55 @@ type DbMetricMonitorObj struct {
 	DisplayName      string                      `json:"display_name"`
 	Step             int64                       `json:"step"`
 	MonitorType      string                      `json:"monitor_type"`
+	DbType           string                      `json:"db_type"`
+	DbName           string                      `json:"db_name"`
+	LabelColumns     []string                    `json:"label_columns"` // 作为标签的列
+	ValueColumns     []string                    `json:"value_columns"` // 作为取值的列,为空时取第一行第一列
+	MaxSeries        int                         `json:"max_series"`    // 最大序列数,为空时用 db_data_exporter 的默认值
 	UpdateTime       string                      `json:"update_time"`
 	UpdateUser       string                      `json:"update_user"`
 	EndpointRel      []*DbMetricEndpointRelTable `json:"endpoint_rel"`
 }
 
+// ValidateColumns 标签列会作为 prometheus 标签名,取值列用 column 标签区分
+func (d *DbMetricMonitorObj) ValidateColumns() error {
+	if d.MaxSeries < 0 {
+		return fmt.Errorf("max_series can not less than 0")
+	}
+	if len(d.LabelColumns) > 0 && len(d.ValueColumns) == 0 {
+		return fmt.Errorf("value_columns can not empty when label_columns is set")
+	}
+	existMap := make(map[string]bool)
+	for _, v := range d.LabelColumns {
+		if !regDbLabelColumn.MatchString(v) {
+			return fmt.Errorf("label column:%s illegal,should match %s", v, regDbLabelColumn.String())
+		}
+		// __ 开头的标签是 prometheus 内部保留的
+		if strings.HasPrefix(v, "__") {
+			return fmt.Errorf("label column:%s can not start with __", v)
+		}
+		for _, reserved := range dbReservedLabelList {
+			if v == reserved {
+				return fmt.Errorf("label column:%s is reserved", v)
+			}
+		}
+		if existMap[v] {
+			return fmt.Errorf("column:%s duplicate", v)
+		}
+		existMap[v] = true
+	}
+	for _, v := range d.ValueColumns {
+		if !regDbLabelColumn.MatchString(v) {
+			return fmt.Errorf("value column:%s illegal,should match %s", v, regDbLabelColumn.String())
+		}
+		if existMap[v] {
+			return fmt.Errorf("column:%s duplicate", v)
+		}
+		existMap[v] = true
+	}
+	return nil
+}
+
 type DbMetricMonitorQueryObj struct {
 	Guid           string `json:"guid" xorm:"guid"`
 	ServiceGroup   string `json:"service_group" xorm:"service_group"`
@@ -46,6 +107,11 @@ type DbMetricMonitorQueryObj struct {
 	DisplayName    string `json:"display_name" xorm:"display_name"`
 	Step           int64  `json:"step" xorm:"step"`
 	MonitorType    string `json:"monitor_type" xorm:"monitor_type"`
+	DbType         string `json:"db_type" xorm:"db_type"`
+	DbName         string `json:"db_name" xorm:"db_name"`
+	LabelColumns   string `json:"label_columns" xorm:"label_columns"`
+	ValueColumns   string `json:"value_columns" xorm:"value_columns"`
+	MaxSeries      int    `json:"max_series" xorm:"max_series"`
 	SourceEndpoint string `json:"source_endpoint" xorm:"source_endpoint"`
 	TargetEndpoint string `json:"target_endpoint" xorm:"target_endpoint"`
 }
@@ -56,6 +122,8 @@ type DbKeywordMonitorQueryObj struct {
 	QuerySql       string `json:"query_sql" xorm:"query_sql"`
 	Step           int64  `json:"step" xorm:"step"`
 	MonitorType    string `json:"monitor_type" xorm:"monitor_type"`
+	DbType         string `json:"db_type" xorm:"db_type"`
+	DbName         string `json:"db_name" xorm:"db_name"`
 	Content        string `json:"content" xorm:"content"`
 	Priority       string `json:"priority" xorm:"priority"`
 	Name           string `json:"name" xorm:"name"`
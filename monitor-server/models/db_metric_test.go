@@ -0,0 +1,28 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestDbMetricValidateColumns(t *testing.T) {
+	testCases := []struct {
+		labelColumns []string
+		valueColumns []string
+		fail         bool
+	}{
+		{[]string{"schema_name"}, []string{"rows", "size"}, false},
+		{nil, nil, false},
+		{[]string{"schema_name"}, nil, true},
+		{[]string{"__name__"}, []string{"rows"}, true},
+		{[]string{"__schema"}, []string{"rows"}, true},
+		{[]string{"instance"}, []string{"rows"}, true},
+		{[]string{"1abc"}, []string{"rows"}, true},
+		{[]string{"rows"}, []string{"rows"}, true},
+	}
+	for _, v := range testCases {
+		err := (&DbMetricMonitorObj{LabelColumns: v.labelColumns, ValueColumns: v.valueColumns}).ValidateColumns()
+		if (err != nil) != v.fail {
+			t.Errorf("label:%v value:%v expect fail:%t,get err:%v", v.labelColumns, v.valueColumns, v.fail, err)
+		}
+	}
+}
@@ -1,5 +1,63 @@
 package models
 
+import (
+	"fmt"
+	"strings"
+)
+
+// db_data_exporter 支持的数据库类型,为空时按 mysql 处理
+const (
+	DbTypeMysql     = "mysql"
+	DbTypePostgres  = "postgresql"
+	DbTypeSqlServer = "sqlserver"
+)
+
+var DbTypeList = []string{DbTypeMysql, DbTypePostgres, DbTypeSqlServer}
+
+func IsDbType(dbType string) bool {
+	for _, v := range DbTypeList {
+		if v == dbType {
+			return true
+		}
+	}
+	return false
+}
+
+// GetDbDefaultPort 数据库类型的默认端口
+func GetDbDefaultPort(dbType string) string {
+	switch dbType {
+	case DbTypePostgres:
+		return "5432"
+	case DbTypeSqlServer:
+		return "1433"
+	}
+	return "3306"
+}
+
+// BuildDbExtendParam postgresql,sqlserver 对象的 ip:port 是 exporter 的采集地址,数据库实例地址单独填写,
+// 为空时使用对象ip和该类型的默认端口
+func BuildDbExtendParam(param *RegisterParamNew) EndpointExtendParamObj {
+	result := EndpointExtendParamObj{Enable: true, Ip: param.DbIp, Port: param.DbPort, User: param.User, Password: param.Password}
+	if result.Ip == "" {
+		result.Ip = param.Ip
+	}
+	if result.Port == "" {
+		result.Port = GetDbDefaultPort(param.Type)
+	}
+	return result
+}
+
+// BuildDbType 校验数据库类型,返回补上默认值后的类型
+func BuildDbType(dbType string) (string, error) {
+	if dbType == "" {
+		return DbTypeMysql, nil
+	}
+	if IsDbType(dbType) {
+		return dbType, nil
+	}
+	return "", fmt.Errorf("db type:%s not support,should be one of %s", dbType, strings.Join(DbTypeList, ","))
+}
+
 type DbMonitorTable struct {
 	Id           int    `json:"id"`
 	EndpointGuid string `json:"endpoint_guid"`
@@ -17,18 +75,22 @@ type DbMonitorUpdateDto struct {
 }
 
 type DbMonitorTaskObj struct {
-	DbType       string `json:"db_type"`
-	Endpoint     string `json:"endpoint"`
-	Name         string `json:"name"`
-	Server       string `json:"server"`
-	Port         string `json:"port"`
-	User         string `json:"user"`
-	Password     string `json:"password"`
-	Sql          string `json:"sql"`
-	Step         int64  `json:"step"`
-	ServiceGroup string `json:"service_group"`
-	KeywordGuid  string `json:"keyword_guid"`
-	KeywordCount int64  `json:"keyword_count"`
+	DbType       string   `json:"db_type"`
+	Endpoint     string   `json:"endpoint"`
+	Name         string   `json:"name"`
+	Server       string   `json:"server"`
+	Port         string   `json:"port"`
+	User         string   `json:"user"`
+	Password     string   `json:"password"`
+	DbName       string   `json:"db_name"`
+	Sql          string   `json:"sql"`
+	Step         int64    `json:"step"`
+	ServiceGroup string   `json:"service_group"`
+	KeywordGuid  string   `json:"keyword_guid"`
+	KeywordCount int64    `json:"keyword_count"`
+	LabelColumns []string `json:"label_columns"`
+	ValueColumns []string `json:"value_columns"`
+	MaxSeries    int      `json:"max_series"`
 }
 
 type DbMonitorConfigQuery struct {
@@ -34,7 +34,11 @@ type NotifyTable struct {
 	CallbackParam      string   `json:"callback_param" xorm:"callback_param"`
 	ProcCallbackMode   string   `json:"proc_callback_mode" xorm:"proc_callback_mode"` // 回调模式 -> manual(手动) | auto(自动)
 	Description        string   `json:"description" xorm:"description"`
+	NotifyInterval     int      `json:"notify_interval" xorm:"notify_interval"` // 重复通知间隔(分钟)
+	EscalateDelay      int      `json:"escalate_delay" xorm:"escalate_delay"`   // 升级时间(分钟)
+	EscalateRoles      string   `json:"escalate_roles" xorm:"escalate_roles"`
 	AffectServiceGroup []string `json:"-" xorm:"-"`
+	MailRoles          []string `json:"-" xorm:"-"` // 指定邮件接收角色,升级通知时使用
 }
 
 type NotifyRoleRelTable struct {
@@ -58,6 +62,9 @@ type NotifyObj struct {
 	NotifyRoles      []string `json:"notify_roles"`
 	ProcCallbackMode string   `json:"proc_callback_mode" xorm:"proc_callback_mode"` // 回调模式 -> manual(手动) | auto(自动)
 	Description      string   `json:"description" xorm:"description"`
+	NotifyInterval   int      `json:"notify_interval" xorm:"notify_interval"`
+	EscalateDelay    int      `json:"escalate_delay" xorm:"escalate_delay"`
+	EscalateRoles    []string `json:"escalate_roles"`
 }
 
 type PageInfo struct {
@@ -205,6 +212,31 @@ type AlarmNotifyTable struct {
 	UpdatedTime       time.Time `json:"updated_time" xorm:"updated_time"`
 }
 
+type AlarmNotifyEscalateTable struct {
+	AlarmId        int       `json:"alarm_id" xorm:"alarm_id"`
+	Notify         string    `json:"notify" xorm:"notify"`
+	NotifyCount    int       `json:"notify_count" xorm:"notify_count"`
+	Escalated      int       `json:"escalated" xorm:"escalated"`
+	NextNotifyTime time.Time `json:"next_notify_time" xorm:"next_notify_time"`
+	EscalateTime   time.Time `json:"escalate_time" xorm:"escalate_time"`
+	Status         string    `json:"status" xorm:"status"`
+	CreateTime     time.Time `json:"create_time" xorm:"create_time"`
+	UpdateTime     time.Time `json:"update_time" xorm:"update_time"`
+}
+
+type AlarmNotifyEscalateLogTable struct {
+	Id            int       `json:"id" xorm:"id"`
+	AlarmId       int       `json:"alarm_id" xorm:"alarm_id"`
+	Notify        string    `json:"notify" xorm:"notify"`
+	Step          int       `json:"step" xorm:"step"`
+	Action        string    `json:"action" xorm:"action"` // repeat | escalate
+	Roles         string    `json:"roles" xorm:"roles"`
+	Result        string    `json:"result" xorm:"result"`
+	Message       string    `json:"message" xorm:"message"`
+	CreateTime    time.Time `json:"-" xorm:"create_time"`
+	CreateTimeStr string    `json:"create_time" xorm:"-"`
+}
+
 type StrategyConditionObj struct {
 	Metric     string       `json:"metric"`
 	MetricName string       `json:"metric_name"`
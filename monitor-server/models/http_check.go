@@ -0,0 +1,102 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	HttpCheckAuthBasic      = "basic"
+	HttpCheckAuthBearer     = "bearer"
+	HttpCheckRedirectNone   = "none"
+	HttpCheckRedirectFollow = "follow"
+)
+
+var regHttpCheckJsonPath = regexp.MustCompile(`^\$(\.[^.\[\]]+|\[\d+\])*$`)
+
+// HttpCheckConfigObj http拨测的请求和断言配置,存在 endpoint_http.check_config,随采集源下发给 ping_exporter
+type HttpCheckConfigObj struct {
+	Headers       map[string]string `json:"headers,omitempty"`
+	Body          string            `json:"body,omitempty"`
+	AuthType      string            `json:"auth_type,omitempty"` // basic | bearer
+	Username      string            `json:"username,omitempty"`
+	Password      string            `json:"password,omitempty"`
+	Token         string            `json:"token,omitempty"`
+	ExpectStatus  string            `json:"expect_status,omitempty"` // 200-299,301 为空时2xx算成功
+	BodyRegexp    string            `json:"body_regexp,omitempty"`
+	JsonPath      string            `json:"json_path,omitempty"`       // $.data.list[0].status
+	JsonPathValue string            `json:"json_path_value,omitempty"` // 为空时只要求路径存在
+	Redirect      string            `json:"redirect,omitempty"`        // follow | none
+	MaxRedirects  int               `json:"max_redirects,omitempty"`
+}
+
+// ParseHttpCheckConfig 空字符串返回 nil,表示按原来的方式只检查状态码
+func ParseHttpCheckConfig(input string) (result *HttpCheckConfigObj, err error) {
+	if strings.TrimSpace(input) == "" {
+		return
+	}
+	result = &HttpCheckConfigObj{}
+	if err = json.Unmarshal([]byte(input), result); err != nil {
+		err = fmt.Errorf("http check config unmarshal fail,%s ", err.Error())
+	}
+	return
+}
+
+func (c *HttpCheckConfigObj) Validate() error {
+	switch c.AuthType {
+	case "":
+	case HttpCheckAuthBasic:
+		if c.Username == "" {
+			return fmt.Errorf("http check basic auth username can not empty")
+		}
+	case HttpCheckAuthBearer:
+		if c.Token == "" {
+			return fmt.Errorf("http check bearer auth token can not empty")
+		}
+	default:
+		return fmt.Errorf("http check auth_type:%s illegal,support basic,bearer", c.AuthType)
+	}
+	if c.Redirect != "" && c.Redirect != HttpCheckRedirectFollow && c.Redirect != HttpCheckRedirectNone {
+		return fmt.Errorf("http check redirect:%s illegal,support follow,none", c.Redirect)
+	}
+	if c.MaxRedirects < 0 {
+		return fmt.Errorf("http check max_redirects can not less than 0")
+	}
+	if _, err := ParseHttpCheckExpectStatus(c.ExpectStatus); err != nil {
+		return err
+	}
+	if c.BodyRegexp != "" {
+		if _, err := regexp.Compile(c.BodyRegexp); err != nil {
+			return fmt.Errorf("http check body_regexp illegal,%s ", err.Error())
+		}
+	}
+	if c.JsonPath != "" && !regHttpCheckJsonPath.MatchString(c.JsonPath) {
+		return fmt.Errorf("http check json_path:%s illegal,example: $.data.list[0].status", c.JsonPath)
+	}
+	return nil
+}
+
+// ParseHttpCheckExpectStatus 解析 200-299,301 这种状态码范围,返回 [][2]int{{200,299},{301,301}}
+func ParseHttpCheckExpectStatus(input string) (result [][2]int, err error) {
+	for _, v := range strings.Split(input, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		startStatus, endStatus := v, v
+		if strings.Contains(v, "-") {
+			startStatus = strings.TrimSpace(v[:strings.Index(v, "-")])
+			endStatus = strings.TrimSpace(v[strings.Index(v, "-")+1:])
+		}
+		start, startErr := strconv.Atoi(startStatus)
+		end, endErr := strconv.Atoi(endStatus)
+		if startErr != nil || endErr != nil || start < 100 || end > 599 || start > end {
+			return nil, fmt.Errorf("http check expect_status:%s illegal,example: 200-299,301", input)
+		}
+		result = append(result, [2]int{start, end})
+	}
+	return
+}
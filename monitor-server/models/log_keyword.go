@@ -8,6 +8,7 @@ type LogKeywordMonitorTable struct {
 	LogPath      string `json:"log_path"`
 	MonitorType  string `json:"monitor_type"`
 	UpdateTime   string `json:"update_time"`
+	Multiline    string `json:"-"`
 }
 
 type LogKeywordConfigTable struct {
@@ -47,6 +48,7 @@ type LogKeywordMonitorObj struct {
 	KeywordList  []*LogKeywordConfigTable      `json:"keyword_list"`
 	EndpointRel  []*LogKeywordEndpointRelTable `json:"endpoint_rel"`
 	Notify       *NotifyObj                    `json:"notify"`
+	Multiline    *LogMultilineConfig           `json:"multiline"`
 }
 
 type LogKeywordMonitorCreateObj struct {
@@ -56,6 +58,7 @@ type LogKeywordMonitorCreateObj struct {
 	MonitorType  string                        `json:"monitor_type"`
 	KeywordList  []*LogKeywordConfigTable      `json:"keyword_list"`
 	EndpointRel  []*LogKeywordEndpointRelTable `json:"endpoint_rel"`
+	Multiline    *LogMultilineConfig           `json:"multiline"`
 }
 
 type LogKeywordHttpRuleObj struct {
@@ -66,8 +69,9 @@ type LogKeywordHttpRuleObj struct {
 }
 
 type LogKeywordHttpDto struct {
-	Path     string                   `json:"path"`
-	Keywords []*LogKeywordHttpRuleObj `json:"keywords"`
+	Path      string                   `json:"path"`
+	Keywords  []*LogKeywordHttpRuleObj `json:"keywords"`
+	Multiline *LogMultilineConfig      `json:"multiline"`
 }
 
 type LogKeywordFetchObj struct {
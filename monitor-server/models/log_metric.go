@@ -15,6 +15,16 @@ type LogMetricMonitorTable struct {
 	MetricType   string `json:"metric_type" xorm:"metric_type"`
 	MonitorType  string `json:"monitor_type" xorm:"monitor_type"`
 	UpdateTime   string `json:"update_time" xorm:"update_time"`
+	Multiline    string `json:"-" xorm:"multiline"`
+}
+
+// LogMultilineConfig 多行日志合并配置,StartPattern 匹配新事件的第一行,ContinuePattern 匹配事件的后续行,二选一;
+// FlushTimeout 单位秒,MaxLines 与 FlushTimeout 为 0 时 agent 使用默认值
+type LogMultilineConfig struct {
+	StartPattern    string `json:"start_pattern"`
+	ContinuePattern string `json:"continue_pattern"`
+	MaxLines        int    `json:"max_lines"`
+	FlushTimeout    int64  `json:"flush_timeout"`
 }
 
 type LogMetricJsonTable struct {
@@ -49,6 +59,8 @@ type LogMetricConfigTable struct {
 	AutoAlarm        bool      `json:"auto_alarm" xorm:"auto_alarm"`
 	RangeConfig      string    `json:"range_config" xorm:"range_config"`
 	ColorGroup       string    `json:"color_group" xorm:"color_group"`
+	Buckets          string    `json:"buckets" xorm:"buckets"`
+	Quantile         float64   `json:"quantile" xorm:"quantile"`
 	FullMetric       string    `json:"full_metric" xorm:"-"`
 }
 
@@ -73,6 +85,8 @@ type LogMetricConfigDto struct {
 	AutoAlarm        bool        `json:"auto_alarm"`
 	RangeConfig      interface{} `json:"range_config"`
 	ColorGroup       string      `json:"color_group"`
+	Buckets          string      `json:"buckets"`
+	Quantile         float64     `json:"quantile"`
 	FullMetric       string      `json:"full_metric"`
 }
 
@@ -112,6 +126,7 @@ type LogMetricMonitorObj struct {
 	MetricConfigList []*LogMetricConfigObj        `json:"metric_config_list"`
 	EndpointRel      []*LogMetricEndpointRelTable `json:"endpoint_rel"`
 	MetricGroups     []*LogMetricGroupObj         `json:"metric_groups"`
+	Multiline        *LogMultilineConfig          `json:"multiline" xorm:"-"`
 }
 
 type LogMetricJsonObj struct {
@@ -136,6 +151,8 @@ type LogMetricConfigObj struct {
 	Regular          string                     `json:"regular" xorm:"regular"`
 	AggType          string                     `json:"agg_type" xorm:"agg_type"`
 	Step             int64                      `json:"step" xorm:"step"`
+	Buckets          string                     `json:"buckets" xorm:"buckets"`
+	Quantile         float64                    `json:"quantile" xorm:"quantile"`
 	StringMap        []*LogMetricStringMapTable `json:"string_map"`
 	ServiceGroup     string                     `json:"service_group"`
 	MonitorType      string                     `json:"monitor_type"`
@@ -155,6 +172,7 @@ type LogMetricMonitorCreateDto struct {
 	MetricType   string                       `json:"metric_type" xorm:"metric_type"`
 	MonitorType  string                       `json:"monitor_type" xorm:"monitor_type"`
 	EndpointRel  []*LogMetricEndpointRelTable `json:"endpoint_rel"`
+	Multiline    *LogMultilineConfig          `json:"multiline"`
 }
 
 type LogMetricNodeExporterResponse struct {
@@ -169,6 +187,7 @@ type LogMetricMonitorNeObj struct {
 	JsonConfig        []*LogMetricJsonNeObj  `json:"config"`
 	MetricConfig      []*LogMetricNeObj      `json:"custom"`
 	MetricGroupConfig []*LogMetricGroupNeObj `json:"metric_group_config"`
+	Multiline         *LogMultilineConfig    `json:"multiline"`
 }
 
 type LogMetricJsonNeObj struct {
@@ -187,6 +206,7 @@ type LogMetricNeObj struct {
 	StringMap    []*LogMetricStringMapNeObj `json:"string_map"`
 	TagConfig    []*LogMetricConfigTag      `json:"tag_config"`
 	LogParamName string                     `json:"log_param_name"`
+	Buckets      []float64                  `json:"buckets"`
 }
 
 type LogMetricStringMapNeObj struct {
@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+const (
+	NotifyChannelMail    = "mail"
+	NotifyChannelEvent   = "event"
+	NotifyChannelWebhook = "webhook"
+
+	NotifyContentTypeText = "text"
+	NotifyContentTypeHtml = "html"
+
+	NotifyTemplateActionAll = "all"
+)
+
+type NotifyTemplateTable struct {
+	Guid          string    `json:"guid" xorm:"guid"`
+	Name          string    `json:"name" xorm:"name" binding:"required"`
+	AlarmStrategy string    `json:"alarm_strategy" xorm:"alarm_strategy"`
+	EndpointGroup string    `json:"endpoint_group" xorm:"endpoint_group"`
+	ServiceGroup  string    `json:"service_group" xorm:"service_group"`
+	AlarmAction   string    `json:"alarm_action" xorm:"alarm_action"` // firing | ok | all
+	Channel       string    `json:"channel" xorm:"channel"`           // mail | event | webhook
+	ContentType   string    `json:"content_type" xorm:"content_type"` // text | html
+	Subject       string    `json:"subject" xorm:"subject"`
+	Content       string    `json:"content" xorm:"content"`
+	IsDefault     int       `json:"is_default" xorm:"is_default"`
+	CreateUser    string    `json:"create_user" xorm:"create_user"`
+	UpdateUser    string    `json:"update_user" xorm:"update_user"`
+	CreateTime    time.Time `json:"-" xorm:"create_time"`
+	UpdateTime    time.Time `json:"-" xorm:"update_time"`
+	CreateTimeStr string    `json:"create_time" xorm:"-"`
+	UpdateTimeStr string    `json:"update_time" xorm:"-"`
+}
+
+type NotifyTemplateQueryParam struct {
+	Name          string `json:"name"`
+	AlarmStrategy string `json:"alarm_strategy"`
+	EndpointGroup string `json:"endpoint_group"`
+	ServiceGroup  string `json:"service_group"`
+	Channel       string `json:"channel"`
+}
+
+type NotifyTemplatePreviewParam struct {
+	Template NotifyTemplateTable `json:"template"`
+	AlarmId  int                 `json:"alarm_id"`
+}
+
+type NotifyTemplatePreviewResult struct {
+	Subject     string `json:"subject"`
+	Content     string `json:"content"`
+	ContentType string `json:"content_type"`
+}
+
+// NotifyTemplateData 通知模版渲染时可用的数据
+type NotifyTemplateData struct {
+	AlarmHandleObj
+	StrategyName    string
+	EndpointName    string
+	EndpointIp      string
+	EndpointType    string
+	EndpointTagList []string
+	TagMap          map[string]string
+	DetailList      []*AlarmDetailData
+	DashboardUrl    string
+	StartTime       string
+	EndTime         string
+	Now             string
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ping_exporter 上除了 ping/telnet/http 之外的拨测类型
+const (
+	ProbeTypeTcp = "tcp" // 建连耗时,可以发送内容并校验返回的 banner
+	ProbeTypeTls = "tls" // 握手耗时,证书链校验和证书过期天数
+	ProbeTypeDns = "dns" // 向指定的 dns 服务器查询并校验解析记录
+)
+
+var (
+	ProbeTypeList       = []string{ProbeTypeTcp, ProbeTypeTls, ProbeTypeDns}
+	ProbeDnsRecordTypes = []string{"A", "AAAA", "CNAME", "MX", "NS", "TXT"}
+)
+
+// ProbeConfigObj 拨测配置,存在 endpoint_probe.config,随采集源下发给 ping_exporter
+type ProbeConfigObj struct {
+	Timeout            int    `json:"timeout,omitempty"`              // 秒,为空时用 ping_exporter 的默认值
+	Send               string `json:"send,omitempty"`                 // tcp 建连后发送的内容,如 PING\r\n
+	Expect             string `json:"expect,omitempty"`               // tcp 返回内容或 dns 解析记录需要匹配的正则
+	ServerName         string `json:"server_name,omitempty"`          // tls 的 SNI 和证书校验的域名,为空时用 ip
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"` // tls 证书链校验失败时仍算探测成功
+	QueryName          string `json:"query_name,omitempty"`           // dns 查询的域名
+	RecordType         string `json:"record_type,omitempty"`          // dns 记录类型,默认 A
+}
+
+type EndpointProbeTable struct {
+	Id           int    `json:"id"`
+	EndpointGuid string `json:"endpoint_guid"`
+	ProbeType    string `json:"probe_type"`
+	Target       string `json:"target"`
+	Config       string `json:"config"`
+}
+
+// IsPingExporterType 这些类型的数据由 ping_exporter 采集,没有单独的 exporter
+func IsPingExporterType(exportType string) bool {
+	if exportType == "ping" || exportType == "telnet" || exportType == "http" {
+		return true
+	}
+	return IsProbeType(exportType)
+}
+
+func IsProbeType(exportType string) bool {
+	for _, v := range ProbeTypeList {
+		if v == exportType {
+			return true
+		}
+	}
+	return false
+}
+
+func ParseProbeConfig(input string) (result *ProbeConfigObj, err error) {
+	result = &ProbeConfigObj{}
+	if strings.TrimSpace(input) == "" {
+		return
+	}
+	if err = json.Unmarshal([]byte(input), result); err != nil {
+		err = fmt.Errorf("probe config unmarshal fail,%s ", err.Error())
+	}
+	return
+}
+
+func (c *ProbeConfigObj) Validate(probeType string) error {
+	if c.Timeout < 0 {
+		return fmt.Errorf("probe timeout can not less than 0")
+	}
+	if c.Expect != "" {
+		if _, err := regexp.Compile(c.Expect); err != nil {
+			return fmt.Errorf("probe expect regexp illegal,%s ", err.Error())
+		}
+	}
+	if probeType != ProbeTypeDns {
+		return nil
+	}
+	if c.QueryName == "" {
+		return fmt.Errorf("dns probe query_name can not empty")
+	}
+	if c.RecordType == "" {
+		c.RecordType = "A"
+	}
+	c.RecordType = strings.ToUpper(c.RecordType)
+	for _, v := range ProbeDnsRecordTypes {
+		if v == c.RecordType {
+			return nil
+		}
+	}
+	return fmt.Errorf("dns probe record_type:%s illegal,support %s", c.RecordType, strings.Join(ProbeDnsRecordTypes, ","))
+}
+
+// BuildProbeSourceKey ping_exporter 按这个 key 识别拨测类型和目标,
+// tcp://ip:port tls://ip:port dns://ip:port/query_name/record_type
+func BuildProbeSourceKey(probeType, target string, config *ProbeConfigObj) string {
+	if probeType == ProbeTypeDns {
+		return fmt.Sprintf("%s://%s/%s/%s", probeType, target, config.QueryName, config.RecordType)
+	}
+	return fmt.Sprintf("%s://%s", probeType, target)
+}
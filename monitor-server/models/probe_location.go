@@ -0,0 +1,49 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ProbeLocationTable 拨测点,每个拨测点对应一个 ping_exporter,同一个拨测对象可以从多个拨测点同时探测
+type ProbeLocationTable struct {
+	Guid        string    `json:"guid" xorm:"guid" binding:"required"`
+	DisplayName string    `json:"display_name" xorm:"display_name"`
+	Address     string    `json:"address" xorm:"address" binding:"required"` // ping_exporter 的 ip:port
+	Description string    `json:"description" xorm:"description"`
+	CreateUser  string    `json:"create_user" xorm:"create_user"`
+	UpdateUser  string    `json:"update_user" xorm:"update_user"`
+	CreateAt    time.Time `json:"-" xorm:"create_at"`
+	UpdateAt    time.Time `json:"-" xorm:"update_at"`
+	CreateTime  string    `json:"create_time" xorm:"-"`
+	UpdateTime  string    `json:"update_time" xorm:"-"`
+}
+
+// EndpointProbeLocationTable 拨测对象使用的拨测点,Locations 逗号分隔,失败的拨测点数达到 Quorum 才告警
+type EndpointProbeLocationTable struct {
+	EndpointGuid string `json:"endpoint_guid" xorm:"endpoint_guid"`
+	Locations    string `json:"locations" xorm:"locations"`
+	Quorum       int    `json:"quorum" xorm:"quorum"`
+}
+
+func (e *EndpointProbeLocationTable) LocationList() []string {
+	var result []string
+	for _, v := range strings.Split(e.Locations, ",") {
+		if v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// BuildProbeQuorum quorum 为空时默认过半数的拨测点失败才算失败
+func BuildProbeQuorum(quorum, locationNum int) (int, error) {
+	if quorum == 0 {
+		return locationNum/2 + 1, nil
+	}
+	if quorum < 0 || quorum > locationNum {
+		return 0, fmt.Errorf("probe quorum:%d illegal,should between 1 and location num %d", quorum, locationNum)
+	}
+	return quorum, nil
+}
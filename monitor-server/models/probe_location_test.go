@@ -0,0 +1,29 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestBuildProbeQuorum(t *testing.T) {
+	testCases := []struct {
+		quorum      int
+		locationNum int
+		result      int
+		fail        bool
+	}{
+		{0, 1, 1, false},
+		{0, 2, 2, false},
+		{0, 3, 2, false},
+		{0, 4, 3, false},
+		{2, 3, 2, false},
+		{3, 3, 3, false},
+		{4, 3, 0, true},
+		{-1, 3, 0, true},
+	}
+	for _, v := range testCases {
+		result, err := BuildProbeQuorum(v.quorum, v.locationNum)
+		if (err != nil) != v.fail || result != v.result {
+			t.Errorf("quorum %d with %d locations expect %d fail:%t,get:%d err:%v", v.quorum, v.locationNum, v.result, v.fail, result, err)
+		}
+	}
+}
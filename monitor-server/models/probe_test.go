@@ -0,0 +1,49 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestProbeConfigValidate(t *testing.T) {
+	testCases := []struct {
+		probeType  string
+		config     ProbeConfigObj
+		recordType string
+		fail       bool
+	}{
+		{ProbeTypeTcp, ProbeConfigObj{Send: "PING\r\n", Expect: "PONG"}, "", false},
+		{ProbeTypeTcp, ProbeConfigObj{Timeout: -1}, "", true},
+		{ProbeTypeTcp, ProbeConfigObj{Expect: "("}, "", true},
+		{ProbeTypeTls, ProbeConfigObj{ServerName: "example.com"}, "", false},
+		{ProbeTypeTls, ProbeConfigObj{RecordType: "xx"}, "xx", false},
+		{ProbeTypeDns, ProbeConfigObj{QueryName: "example.com"}, "A", false},
+		{ProbeTypeDns, ProbeConfigObj{QueryName: "example.com", RecordType: "mx"}, "MX", false},
+		{ProbeTypeDns, ProbeConfigObj{QueryName: "example.com", RecordType: "SRV"}, "SRV", true},
+		{ProbeTypeDns, ProbeConfigObj{RecordType: "A"}, "A", true},
+		{ProbeTypeDns, ProbeConfigObj{QueryName: "example.com", Expect: "["}, "", true},
+	}
+	for _, v := range testCases {
+		err := v.config.Validate(v.probeType)
+		if (err != nil) != v.fail || v.config.RecordType != v.recordType {
+			t.Errorf("%s probe config %+v validate fail should be %t and record type %s,err:%v", v.probeType, v.config, v.fail, v.recordType, err)
+		}
+	}
+}
+
+func TestBuildProbeSourceKey(t *testing.T) {
+	testCases := []struct {
+		probeType string
+		target    string
+		config    *ProbeConfigObj
+		result    string
+	}{
+		{ProbeTypeTcp, "10.0.0.1:6379", &ProbeConfigObj{Send: "PING\r\n"}, "tcp://10.0.0.1:6379"},
+		{ProbeTypeTls, "10.0.0.1:443", &ProbeConfigObj{ServerName: "example.com"}, "tls://10.0.0.1:443"},
+		{ProbeTypeDns, "10.0.0.1:53", &ProbeConfigObj{QueryName: "example.com", RecordType: "MX"}, "dns://10.0.0.1:53/example.com/MX"},
+	}
+	for _, v := range testCases {
+		if result := BuildProbeSourceKey(v.probeType, v.target, v.config); result != v.result {
+			t.Errorf("probe source key should be %s,get:%s", v.result, result)
+		}
+	}
+}
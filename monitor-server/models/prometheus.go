@@ -43,17 +43,18 @@ type FileSdConfig []*FileSdObj
 type ServiceDiscoverFileList []*ServiceDiscoverFileObj
 
 type ServiceDiscoverFileObj struct {
-	Guid    string `json:"guid"`
-	Address string `json:"address"`
-	Step    int    `json:"step"`
-	Cluster string `json:"cluster"`
+	Guid          string `json:"guid"`
+	Address       string `json:"address"`
+	Step          int    `json:"step"`
+	Cluster       string `json:"cluster"`
+	ProbeLocation string `json:"probe_location"`
 }
 
 func (s ServiceDiscoverFileList) TurnToFileSdConfigByte(step int) []byte {
 	var result []*FileSdObj
 	for _, v := range s {
 		if v.Step == step {
-			result = append(result, &FileSdObj{Targets: []string{v.Address}, Labels: FileSdLabel{EGuid: v.Guid}})
+			result = append(result, &FileSdObj{Targets: []string{v.Address}, Labels: FileSdLabel{EGuid: v.Guid, ProbeLocation: v.ProbeLocation}})
 		}
 	}
 	b, _ := json.Marshal(result)
@@ -66,5 +67,6 @@ type FileSdObj struct {
 }
 
 type FileSdLabel struct {
-	EGuid string `json:"e_guid"`
+	EGuid         string `json:"e_guid"`
+	ProbeLocation string `json:"probe_location,omitempty"`
 }
@@ -2,6 +2,9 @@ package datasource
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"sync"
@@ -22,12 +25,17 @@ type DataSource struct {
 	WithCredentials   bool
 	IsDefault         bool
 	ReadOnly          bool
+	TlsSkipVerify     bool
+	TlsCaFile         string
+	TlsCertFile       string
+	TlsKeyFile        string
 	Created time.Time
 	Updated time.Time
 }
 
+// proxyTransportCache 按数据源名称和地址缓存 transport,不同数据源的 TLS 客户端证书互不影响
 type proxyTransportCache struct {
-	cache map[int]cachedTransport
+	cache map[string]cachedTransport
 	sync.Mutex
 }
 
@@ -41,10 +49,12 @@ type DataSourceParam struct {
 	DataSource  *DataSource
 	Host  string
 	Token  string
+	Name  string
+	Type  string
 }
 
 var ptc = proxyTransportCache{
-	cache: make(map[int]cachedTransport),
+	cache: make(map[string]cachedTransport),
 }
 
 func (ds *DataSource) GetHttpClient() (*http.Client, error) {
@@ -64,16 +74,17 @@ func (ds *DataSource) GetHttpTransport() (*http.Transport, error) {
 	ptc.Lock()
 	defer ptc.Unlock()
 
-	if t, present := ptc.cache[ds.Id]; present && ds.Updated.Equal(t.updated) {
+	cacheKey := ds.transportCacheKey()
+	if t, present := ptc.cache[cacheKey]; present && ds.Updated.Equal(t.updated) {
 		return t.Transport, nil
 	}
 
+	tlsConfig, err := ds.buildTlsConfig()
+	if err != nil {
+		return nil, err
+	}
 	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			//InsecureSkipVerify: tlsSkipVerify,
-			InsecureSkipVerify: false,
-			Renegotiation:      tls.RenegotiateFreelyAsClient,
-		},
+		TLSClientConfig: tlsConfig,
 		Proxy: http.ProxyFromEnvironment,
 		Dial: (&net.Dialer{
 			Timeout:   30 * time.Second,
@@ -86,10 +97,57 @@ func (ds *DataSource) GetHttpTransport() (*http.Transport, error) {
 		IdleConnTimeout:       90 * time.Second,
 	}
 
-	ptc.cache[ds.Id] = cachedTransport{
+	ptc.cache[cacheKey] = cachedTransport{
 		Transport: transport,
 		updated:   ds.Updated,
 	}
 
 	return transport, nil
 }
+
+func (ds *DataSource) transportCacheKey() string {
+	return fmt.Sprintf("%s^%s", ds.Name, ds.Url)
+}
+
+func (ds *DataSource) buildTlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: ds.TlsSkipVerify,
+		Renegotiation:      tls.RenegotiateFreelyAsClient,
+	}
+	if ds.TlsCaFile != "" {
+		caBytes, err := ioutil.ReadFile(ds.TlsCaFile)
+		if err != nil {
+			return nil, fmt.Errorf("read datasource ca file fail,%s ", err.Error())
+		}
+		caPool := x509.NewCertPool()
+		if !caPool.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("datasource ca file:%s contains no valid certificate ", ds.TlsCaFile)
+		}
+		tlsConfig.RootCAs = caPool
+	}
+	if ds.TlsCertFile != "" && ds.TlsKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(ds.TlsCertFile, ds.TlsKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load datasource client certificate fail,%s ", err.Error())
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// setAuth 数据源配置了basic auth时优先使用,否则有token时以Bearer方式携带
+func (p *DataSourceParam) setAuth(req *http.Request) {
+	if p.DataSource != nil && p.DataSource.BasicAuth {
+		req.SetBasicAuth(p.DataSource.BasicAuthUser, p.DataSource.BasicAuthPassword)
+	} else if p.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.Token)
+	}
+}
+
+// apiUrl 拼接数据源的 Prometheus 兼容接口地址
+func (p *DataSourceParam) apiUrl(path string) string {
+	if p.DataSource != nil && p.DataSource.Url != "" {
+		return p.DataSource.Url + path
+	}
+	return fmt.Sprintf("http://%s%s", p.Host, path)
+}
@@ -19,23 +19,24 @@ import (
 var promDS DataSourceParam
 
 func InitPrometheusDatasource() {
-	t := time.Now()
-	cfg := *m.Config().Datasource.Servers[0]
-	opentsdbDS := &DataSource{Id: cfg.Id, Name: cfg.Type, Url: fmt.Sprintf("http://%s", cfg.Host), IsDefault: true, Updated: t}
-	promDS = DataSourceParam{DataSource: opentsdbDS, Host: cfg.Host, Token: cfg.Token}
+	initPrometheusBackendRegistry()
 }
 
 var PieLegendBlackName = []string{"job", "instance", "__name__", "e_guid"}
 
 func PrometheusData(query *m.QueryMonitorData) []*m.SerialModel {
 	log.Logger.Debug("prometheus data query", log.JsonObj("queryParam", query))
+	backendList := routePrometheusBackend(query)
+	if len(backendList) == 1 {
+		return queryPrometheusBackendData(backendList[0], query)
+	}
+	return fanOutPrometheusData(backendList, query)
+}
+
+func queryPrometheusBackendData(backend *DataSourceParam, query *m.QueryMonitorData) []*m.SerialModel {
 	serials := []*m.SerialModel{}
 	urlParams := url.Values{}
-	hostAddress := promDS.Host
-	if query.Cluster != "" && query.Cluster != "default" {
-		hostAddress = query.Cluster
-	}
-	requestUrl, err := url.Parse(fmt.Sprintf("http://%s/api/v1/query_range", hostAddress))
+	requestUrl, err := url.Parse(backend.apiUrl("/api/v1/query_range"))
 	if err != nil {
 		log.Logger.Error("Make url fail", log.Error(err))
 		return serials
@@ -63,14 +64,15 @@ func PrometheusData(query *m.QueryMonitorData) []*m.SerialModel {
 		return serials
 	}
 	req.Header.Set("Content-Type", "application/json")
-	httpClient, err := promDS.DataSource.GetHttpClient()
+	backend.setAuth(req)
+	httpClient, err := backend.DataSource.GetHttpClient()
 	if err != nil {
-		log.Logger.Error("Get httpClient fail", log.Error(err))
+		log.Logger.Error("Get httpClient fail", log.String("datasource", backend.Name), log.Error(err))
 		return serials
 	}
 	res, err := ctxhttp.Do(context.Background(), httpClient, req)
 	if err != nil {
-		log.Logger.Error("Http request fail", log.Error(err))
+		log.Logger.Error("Http request fail", log.String("datasource", backend.Name), log.Error(err))
 		return serials
 	}
 	body, err := ioutil.ReadAll(res.Body)
@@ -81,7 +83,7 @@ func PrometheusData(query *m.QueryMonitorData) []*m.SerialModel {
 	}
 	//log.Logger.Debug("prometheus data result", log.String("response", string(body)))
 	if res.StatusCode/100 != 2 {
-		log.Logger.Warn("Request fail with bad status", log.String("status", res.Status))
+		log.Logger.Warn("Request fail with bad status", log.String("datasource", backend.Name), log.String("status", res.Status))
 		return serials
 	}
 	var data m.PrometheusResponse
@@ -128,8 +130,7 @@ func PrometheusData(query *m.QueryMonitorData) []*m.SerialModel {
 }
 
 func CheckPrometheusQL(promQl string) error {
-	hostAddress := promDS.Host
-	requestUrl, _ := url.Parse(fmt.Sprintf("http://%s/api/v1/query_range", hostAddress))
+	requestUrl, _ := url.Parse(promDS.apiUrl("/api/v1/query_range"))
 	nowTime := time.Now().Unix()
 	urlParams := url.Values{}
 	urlParams.Set("start", strconv.FormatInt(nowTime-10, 10))
@@ -142,6 +143,7 @@ func CheckPrometheusQL(promQl string) error {
 		return fmt.Errorf("Failed to create request:%s ", err.Error())
 	}
 	req.Header.Set("Content-Type", "application/json")
+	promDS.setAuth(req)
 	httpClient, getClientErr := promDS.DataSource.GetHttpClient()
 	if getClientErr != nil {
 		return fmt.Errorf("Get httpClient fail:%s ", getClientErr.Error())
@@ -495,7 +497,7 @@ func QueryPromSeries(promQL string) (result []map[string]string, err error) {
 	//	}
 	//}
 	promQL = getPromQlMainExpr(promQL)
-	requestUrl, urlParseErr := url.Parse(fmt.Sprintf("http://%s/api/v1/series", promDS.Host))
+	requestUrl, urlParseErr := url.Parse(promDS.apiUrl("/api/v1/series"))
 	if urlParseErr != nil {
 		return result, fmt.Errorf("Url parse fail,%s ", urlParseErr.Error())
 	}
@@ -504,6 +506,7 @@ func QueryPromSeries(promQL string) (result []map[string]string, err error) {
 	requestUrl.RawQuery = urlParams.Encode()
 	req, _ := http.NewRequest(http.MethodGet, requestUrl.String(), nil)
 	req.Header.Set("Content-Type", "application/json")
+	promDS.setAuth(req)
 	httpClient, getClientErr := promDS.DataSource.GetHttpClient()
 	if getClientErr != nil {
 		return result, fmt.Errorf("Get httpClient fail,%s ", getClientErr.Error())
@@ -544,7 +547,7 @@ func getPromQlMainExpr(input string) (output string) {
 
 // QueryPrometheusRange start/end/step second value
 func QueryPrometheusRange(promQL string, start, end, step int64) (result *m.PrometheusData, err error) {
-	requestUrl, urlParseErr := url.Parse(fmt.Sprintf("http://%s/api/v1/query_range", promDS.Host))
+	requestUrl, urlParseErr := url.Parse(promDS.apiUrl("/api/v1/query_range"))
 	if urlParseErr != nil {
 		return result, fmt.Errorf("Url parse fail,%s ", urlParseErr.Error())
 	}
@@ -556,6 +559,7 @@ func QueryPrometheusRange(promQL string, start, end, step int64) (result *m.Prom
 	requestUrl.RawQuery = urlParams.Encode()
 	req, _ := http.NewRequest(http.MethodGet, requestUrl.String(), nil)
 	req.Header.Set("Content-Type", "application/json")
+	promDS.setAuth(req)
 	httpClient, getClientErr := promDS.DataSource.GetHttpClient()
 	if getClientErr != nil {
 		return result, fmt.Errorf("Get httpClient fail,%s ", getClientErr.Error())
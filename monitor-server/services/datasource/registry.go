@@ -0,0 +1,196 @@
+package datasource
+
+import (
+	"fmt"
+	"github.com/WeBankPartners/open-monitor/monitor-server/middleware/log"
+	m "github.com/WeBankPartners/open-monitor/monitor-server/models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	promBackendList          []*DataSourceParam
+	promBackendMap           map[string]*DataSourceParam
+	promEndpointRouteMap     map[string][]*DataSourceParam
+	promServiceGroupRouteMap map[string][]*DataSourceParam
+	promClusterRouteMap      map[string][]*DataSourceParam
+)
+
+// initPrometheusBackendRegistry 按配置初始化所有 Prometheus 兼容数据源(prometheus/thanos/victoriametrics)及路由关系,
+// 配置了default的数据源(没有则取第一个)作为默认数据源
+func initPrometheusBackendRegistry() {
+	t := time.Now()
+	promBackendList = []*DataSourceParam{}
+	promBackendMap = make(map[string]*DataSourceParam)
+	defaultIndex := 0
+	for i, cfg := range m.Config().Datasource.Servers {
+		backend := buildPrometheusBackend(cfg, i, t)
+		if _, b := promBackendMap[backend.Name]; b {
+			log.Logger.Warn("datasource name duplicate,ignore", log.String("name", backend.Name))
+			continue
+		}
+		if cfg.Default {
+			defaultIndex = len(promBackendList)
+		}
+		promBackendList = append(promBackendList, backend)
+		promBackendMap[backend.Name] = backend
+	}
+	if len(promBackendList) == 0 {
+		log.Logger.Error("datasource servers config is empty")
+		return
+	}
+	promBackendList[defaultIndex].DataSource.IsDefault = true
+	promDS = *promBackendList[defaultIndex]
+	promEndpointRouteMap = make(map[string][]*DataSourceParam)
+	promServiceGroupRouteMap = make(map[string][]*DataSourceParam)
+	promClusterRouteMap = make(map[string][]*DataSourceParam)
+	for _, route := range m.Config().Datasource.Routes {
+		backend, b := promBackendMap[route.Datasource]
+		if !b {
+			log.Logger.Warn("datasource route config illegal,can not find datasource", log.String("datasource", route.Datasource))
+			continue
+		}
+		for _, v := range route.Endpoints {
+			promEndpointRouteMap[v] = append(promEndpointRouteMap[v], backend)
+		}
+		for _, v := range route.ServiceGroups {
+			promServiceGroupRouteMap[v] = append(promServiceGroupRouteMap[v], backend)
+		}
+		for _, v := range route.Clusters {
+			promClusterRouteMap[v] = append(promClusterRouteMap[v], backend)
+		}
+	}
+	log.Logger.Info("init prometheus datasource done", log.Int("num", len(promBackendList)), log.String("default", promDS.Name))
+}
+
+func buildPrometheusBackend(cfg *m.DatasourceServers, index int, updated time.Time) *DataSourceParam {
+	id := cfg.Id
+	if id <= 0 {
+		id = index + 1
+	}
+	name := cfg.Name
+	if name == "" {
+		name = fmt.Sprintf("%s_%d", cfg.Type, id)
+	}
+	scheme := cfg.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	dataSource := &DataSource{Id: id, Name: name, Type: cfg.Type, Updated: updated,
+		Url:           fmt.Sprintf("%s://%s%s", scheme, cfg.Host, strings.TrimSuffix(cfg.PathPrefix, "/")),
+		BasicAuth:     cfg.BasicAuthUser != "",
+		BasicAuthUser: cfg.BasicAuthUser, BasicAuthPassword: cfg.BasicAuthPassword,
+		TlsSkipVerify: cfg.TlsSkipVerify, TlsCaFile: cfg.TlsCaFile, TlsCertFile: cfg.TlsCertFile, TlsKeyFile: cfg.TlsKeyFile}
+	return &DataSourceParam{DataSource: dataSource, Host: cfg.Host, Token: cfg.Token, Name: name, Type: cfg.Type}
+}
+
+// IsRoutedCluster 集群是否配置了数据源路由,配置了则不再按集群表解析成 Prometheus 地址
+func IsRoutedCluster(cluster string) bool {
+	_, b := promClusterRouteMap[cluster]
+	return b
+}
+
+// routePrometheusBackend 按查询指定的数据源、集群、对象、层级对象路由到对应的数据源,命中多个时并发查询
+func routePrometheusBackend(query *m.QueryMonitorData) []*DataSourceParam {
+	if query.Datasource == "*" {
+		return promBackendList
+	}
+	if query.Datasource != "" {
+		if backend, b := promBackendMap[query.Datasource]; b {
+			return []*DataSourceParam{backend}
+		}
+		log.Logger.Warn("can not find query datasource,use default", log.String("datasource", query.Datasource))
+		return []*DataSourceParam{&promDS}
+	}
+	if query.Cluster != "" && query.Cluster != "default" {
+		if backendList, b := promClusterRouteMap[query.Cluster]; b {
+			return backendList
+		}
+		// 兼容旧的集群模式,此时 cluster 为已解析好的 Prometheus 地址
+		legacyDataSource := &DataSource{Name: query.Cluster, Url: fmt.Sprintf("http://%s", query.Cluster), Updated: promDS.DataSource.Updated}
+		return []*DataSourceParam{{DataSource: legacyDataSource, Host: query.Cluster, Name: query.Cluster}}
+	}
+	matchMap := make(map[string]bool)
+	useDefault := false
+	for _, endpoint := range query.Endpoint {
+		if backendList, b := promEndpointRouteMap[endpoint]; b {
+			for _, backend := range backendList {
+				matchMap[backend.Name] = true
+			}
+		} else {
+			useDefault = true
+		}
+	}
+	if backendList, b := promServiceGroupRouteMap[query.ServiceGroupName]; b && query.ServiceGroupName != "" {
+		for _, backend := range backendList {
+			matchMap[backend.Name] = true
+		}
+	}
+	if len(matchMap) == 0 {
+		return []*DataSourceParam{&promDS}
+	}
+	if useDefault {
+		matchMap[promDS.Name] = true
+	}
+	result := []*DataSourceParam{}
+	for _, backend := range promBackendList {
+		if matchMap[backend.Name] {
+			result = append(result, backend)
+		}
+	}
+	return result
+}
+
+// fanOutPrometheusData 并发查询多个数据源,同名序列合并数据点(相同时间点以配置顺序靠前的数据源为准)
+func fanOutPrometheusData(backendList []*DataSourceParam, query *m.QueryMonitorData) []*m.SerialModel {
+	resultList := make([][]*m.SerialModel, len(backendList))
+	queryList := make([]*m.QueryMonitorData, len(backendList))
+	wg := sync.WaitGroup{}
+	for i, backend := range backendList {
+		tmpQuery := *query
+		queryList[i] = &tmpQuery
+		wg.Add(1)
+		go func(index int, b *DataSourceParam) {
+			defer wg.Done()
+			resultList[index] = queryPrometheusBackendData(b, queryList[index])
+		}(i, backend)
+	}
+	wg.Wait()
+	if query.ChartType == "pie" {
+		for _, tmpQuery := range queryList {
+			query.PieData.Legend = append(query.PieData.Legend, tmpQuery.PieData.Legend...)
+			query.PieData.Data = append(query.PieData.Data, tmpQuery.PieData.Data...)
+		}
+		return []*m.SerialModel{}
+	}
+	serials := []*m.SerialModel{}
+	serialMap := make(map[string]*m.SerialModel)
+	for _, tmpSerials := range resultList {
+		for _, serial := range tmpSerials {
+			existSerial, b := serialMap[serial.Name]
+			if !b {
+				serialMap[serial.Name] = serial
+				serials = append(serials, serial)
+				continue
+			}
+			existSerial.Data = mergeSerialData(existSerial.Data, serial.Data)
+		}
+	}
+	return serials
+}
+
+func mergeSerialData(existData, newData m.DataSort) m.DataSort {
+	timeMap := make(map[float64]bool)
+	for _, v := range existData {
+		timeMap[v[0]] = true
+	}
+	for _, v := range newData {
+		if !timeMap[v[0]] {
+			existData = append(existData, v)
+		}
+	}
+	sort.Sort(existData)
+	return existData
+}
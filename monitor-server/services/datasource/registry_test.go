@@ -0,0 +1,103 @@
+package datasource
+
+import (
+	m "github.com/WeBankPartners/open-monitor/monitor-server/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func initRegistryTestBackend() {
+	t := time.Now()
+	promBackendList = []*DataSourceParam{}
+	promBackendMap = make(map[string]*DataSourceParam)
+	for i, name := range []string{"a", "b", "c"} {
+		backend := buildPrometheusBackend(&m.DatasourceServers{Name: name, Type: "prometheus", Host: name + ":9090"}, i, t)
+		promBackendList = append(promBackendList, backend)
+		promBackendMap[name] = backend
+	}
+	promDS = *promBackendList[0]
+	promEndpointRouteMap = map[string][]*DataSourceParam{"e1": {promBackendMap["b"]}}
+	promServiceGroupRouteMap = map[string][]*DataSourceParam{"sg": {promBackendMap["c"]}}
+	promClusterRouteMap = map[string][]*DataSourceParam{"k": {promBackendMap["b"], promBackendMap["c"]}}
+}
+
+func getBackendNames(backendList []*DataSourceParam) string {
+	var nameList []string
+	for _, v := range backendList {
+		nameList = append(nameList, v.Name)
+	}
+	return strings.Join(nameList, ",")
+}
+
+func TestRoutePrometheusBackend(t *testing.T) {
+	initRegistryTestBackend()
+	testCases := []struct {
+		name   string
+		query  m.QueryMonitorData
+		expect string
+	}{
+		{"all datasource", m.QueryMonitorData{Datasource: "*"}, "a,b,c"},
+		{"specify datasource", m.QueryMonitorData{Datasource: "b", Endpoint: []string{"e1"}}, "b"},
+		{"route cluster", m.QueryMonitorData{Cluster: "k"}, "b,c"},
+		{"default cluster", m.QueryMonitorData{Cluster: "default"}, "a"},
+		{"route endpoint", m.QueryMonitorData{Endpoint: []string{"e1"}}, "b"},
+		{"endpoint without route also query default", m.QueryMonitorData{Endpoint: []string{"e2", "e1"}}, "a,b"},
+		{"route service group", m.QueryMonitorData{Endpoint: []string{"e2"}, ServiceGroupName: "sg"}, "a,c"},
+		{"no route", m.QueryMonitorData{Endpoint: []string{"e2"}}, "a"},
+	}
+	for _, v := range testCases {
+		if get := getBackendNames(routePrometheusBackend(&v.query)); get != v.expect {
+			t.Errorf("%s expect %s,get:%s", v.name, v.expect, get)
+		}
+	}
+	// 旧的集群模式直接用集群地址查询
+	legacyList := routePrometheusBackend(&m.QueryMonitorData{Cluster: "10.0.0.1:9090"})
+	if len(legacyList) != 1 || legacyList[0].DataSource.Url != "http://10.0.0.1:9090" || legacyList[0].DataSource.Id != 0 {
+		t.Errorf("legacy cluster backend illegal:%+v", legacyList)
+	}
+}
+
+func TestGetHttpTransportByName(t *testing.T) {
+	updated := time.Now()
+	dsA := &DataSource{Id: 1, Name: "a", Url: "http://a:9090", Updated: updated}
+	dsB := &DataSource{Id: 1, Name: "b", Url: "http://b:9090", Updated: updated, TlsSkipVerify: true}
+	transportA, err := dsA.GetHttpTransport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	transportB, err := dsB.GetHttpTransport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// id 相同的不同数据源不能共用 transport
+	if transportA == transportB || !transportB.TLSClientConfig.InsecureSkipVerify || transportA.TLSClientConfig.InsecureSkipVerify {
+		t.Errorf("datasource with same id should not share transport")
+	}
+	if transportCache, _ := dsA.GetHttpTransport(); transportCache != transportA {
+		t.Errorf("same datasource should reuse transport")
+	}
+	dsA.Updated = updated.Add(time.Second)
+	if transportNew, _ := dsA.GetHttpTransport(); transportNew == transportA {
+		t.Errorf("updated datasource should rebuild transport")
+	}
+}
+
+func TestMergeSerialData(t *testing.T) {
+	existData := m.DataSort{{3000, 3}, {1000, 1}}
+	newData := m.DataSort{{1000, 10}, {2000, 20}, {4000, 40}}
+	// 相同时间点保留先查到的数据,合并后按时间排序
+	result := mergeSerialData(existData, newData)
+	expect := m.DataSort{{1000, 1}, {2000, 20}, {3000, 3}, {4000, 40}}
+	if len(result) != len(expect) {
+		t.Fatalf("merge result num expect %d,get:%d", len(expect), len(result))
+	}
+	for i, v := range result {
+		if v[0] != expect[i][0] || v[1] != expect[i][1] {
+			t.Errorf("point %d expect %v,get:%v", i, expect[i], v)
+		}
+	}
+	if result = mergeSerialData(m.DataSort{}, newData); len(result) != 3 {
+		t.Errorf("merge into empty data illegal:%v", result)
+	}
+}
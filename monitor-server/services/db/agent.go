@@ -179,6 +179,8 @@ func DeleteEndpoint(guid, operator string) error {
 	actions = append(actions, &Action{Sql: "delete from custom_chart_series_tag where dashboard_chart_config in (select guid from custom_chart_series where endpoint=?)", Param: []interface{}{guid}})
 	actions = append(actions, &Action{Sql: "delete from custom_chart_series_config where dashboard_chart_config in (select guid from custom_chart_series where endpoint=?)", Param: []interface{}{guid}})
 	actions = append(actions, &Action{Sql: "delete from custom_chart_series where endpoint=?", Param: []interface{}{guid}})
+	actions = append(actions, &Action{Sql: "delete from endpoint_probe where endpoint_guid=?", Param: []interface{}{guid}})
+	actions = append(actions, &Action{Sql: "delete from endpoint_probe_location where endpoint_guid=?", Param: []interface{}{guid}})
 	actions = append(actions, &Action{Sql: "delete from endpoint_new where guid=?", Param: []interface{}{guid}})
 	err := Transaction(actions)
 	if err != nil {
@@ -634,7 +636,7 @@ func UpdateEndpointHttp(param []*m.EndpointHttpTable) error {
 	var actions []*Action
 	actions = append(actions, &Action{Sql: "DELETE FROM endpoint_http WHERE endpoint_guid=?", Param: []interface{}{param[0].EndpointGuid}})
 	for _, v := range param {
-		actions = append(actions, &Action{Sql: "INSERT INTO endpoint_http(`endpoint_guid`,`method`,`url`) VALUE (?,?,?)", Param: []interface{}{v.EndpointGuid, v.Method, v.Url}})
+		actions = append(actions, &Action{Sql: "INSERT INTO endpoint_http(`endpoint_guid`,`method`,`url`,`check_config`) VALUE (?,?,?,?)", Param: []interface{}{v.EndpointGuid, v.Method, v.Url, v.CheckConfig}})
 	}
 	err := Transaction(actions)
 	if err != nil {
@@ -643,6 +645,33 @@ func UpdateEndpointHttp(param []*m.EndpointHttpTable) error {
 	return err
 }
 
+func UpdateEndpointProbe(param *m.EndpointProbeTable) error {
+	var actions []*Action
+	actions = append(actions, &Action{Sql: "DELETE FROM endpoint_probe WHERE endpoint_guid=?", Param: []interface{}{param.EndpointGuid}})
+	actions = append(actions, &Action{Sql: "INSERT INTO endpoint_probe(`endpoint_guid`,`probe_type`,`target`,`config`) VALUE (?,?,?,?)", Param: []interface{}{param.EndpointGuid, param.ProbeType, param.Target, param.Config}})
+	err := Transaction(actions)
+	if err != nil {
+		log.Logger.Error("Update endpoint probe fail", log.Error(err))
+	}
+	return err
+}
+
+func GetEndpointProbe(endpointGuid string) (result []*m.EndpointProbeTable, err error) {
+	err = x.SQL("SELECT id,endpoint_guid,probe_type,target,config FROM endpoint_probe WHERE endpoint_guid=?", endpointGuid).Find(&result)
+	if err != nil {
+		err = fmt.Errorf("Query endpoint probe table fail,%s ", err.Error())
+	}
+	return
+}
+
+func GetEndpointHttp(endpointGuid string) (result []*m.EndpointHttpTable, err error) {
+	err = x.SQL("SELECT id,endpoint_guid,`method`,url,check_config FROM endpoint_http WHERE endpoint_guid=?", endpointGuid).Find(&result)
+	if err != nil {
+		err = fmt.Errorf("Query endpoint http table fail,%s ", err.Error())
+	}
+	return
+}
+
 func GetPingExporterSource() []*m.PingExportSourceObj {
 	result := []*m.PingExportSourceObj{}
 	var endpointTable []*m.EndpointTable
@@ -659,17 +688,41 @@ func GetPingExporterSource() []*m.PingExportSourceObj {
 			}
 		}
 	}
+	var endpointProbeTable []*m.EndpointProbeTable
+	x.SQL("SELECT t1.id,t1.endpoint_guid,t1.probe_type,t1.target,t1.config FROM endpoint_probe t1 join endpoint t2 on t1.endpoint_guid=t2.guid where t2.address_agent=''").Find(&endpointProbeTable)
+	for _, v := range endpointProbeTable {
+		result = append(result, buildProbeSourceObj(v))
+	}
 	var endpointHttpTable []*m.EndpointHttpTable
-	x.SQL("SELECT t1.id,t1.endpoint_guid,t1.`method`,t1.url FROM endpoint_http t1 join endpoint t2 on t1.endpoint_guid=t2.guid where t2.address_agent=''").Find(&endpointHttpTable)
+	x.SQL("SELECT t1.id,t1.endpoint_guid,t1.`method`,t1.url,t1.check_config FROM endpoint_http t1 join endpoint t2 on t1.endpoint_guid=t2.guid where t2.address_agent=''").Find(&endpointHttpTable)
 	if len(endpointHttpTable) > 0 {
 		for _, v := range endpointHttpTable {
-			tmpUrl := fmt.Sprintf("%s_%s", strings.ToUpper(v.Method), v.Url)
-			result = append(result, &m.PingExportSourceObj{Ip: tmpUrl, Guid: v.EndpointGuid})
+			result = append(result, buildHttpCheckSourceObj(v))
 		}
 	}
 	return result
 }
 
+// buildHttpCheckSourceObj 拨测配置解析失败时仍按只检查状态码的方式下发
+func buildHttpCheckSourceObj(endpointHttp *m.EndpointHttpTable) *m.PingExportSourceObj {
+	result := m.PingExportSourceObj{Ip: fmt.Sprintf("%s_%s", strings.ToUpper(endpointHttp.Method), endpointHttp.Url), Guid: endpointHttp.EndpointGuid}
+	httpCheck, err := m.ParseHttpCheckConfig(endpointHttp.CheckConfig)
+	if err != nil {
+		log.Logger.Warn("Build http check source fail", log.String("endpoint", endpointHttp.EndpointGuid), log.Error(err))
+	} else {
+		result.HttpCheck = httpCheck
+	}
+	return &result
+}
+
+func buildProbeSourceObj(endpointProbe *m.EndpointProbeTable) *m.PingExportSourceObj {
+	probeConfig, err := m.ParseProbeConfig(endpointProbe.Config)
+	if err != nil {
+		log.Logger.Warn("Build probe source fail", log.String("endpoint", endpointProbe.EndpointGuid), log.Error(err))
+	}
+	return &m.PingExportSourceObj{Ip: m.BuildProbeSourceKey(endpointProbe.ProbeType, endpointProbe.Target, probeConfig), Guid: endpointProbe.EndpointGuid, Probe: probeConfig}
+}
+
 func UpdateAgentManagerTable(endpoint m.EndpointTable, user, password, configFile, binPath string, isAdd bool) error {
 	var actions []*Action
 	actions = append(actions, &Action{Sql: fmt.Sprintf("DELETE FROM agent_manager WHERE endpoint_guid='%s'", endpoint.Guid)})
@@ -502,6 +502,11 @@ func GetAlarms(cond m.QueryAlarmCondition) (error, m.AlarmProblemList) {
 		params = append(params, []interface{}{fmt.Sprintf("%%%s%%", cond.Query), fmt.Sprintf("%%%s%%", cond.Query),
 			fmt.Sprintf("%%%s%%", cond.Query), fmt.Sprintf("%%%s%%", cond.Query), fmt.Sprintf("%%%s%%", cond.Query)}...)
 	}
+	if cond.AckStatus == m.AlarmAckStatusAcked {
+		whereSql += " and ack_user is not null and ack_user<>'' "
+	} else if cond.AckStatus == m.AlarmAckStatusUnacked {
+		whereSql += " and (ack_user is null or ack_user='') "
+	}
 
 	sql := "SELECT * FROM alarm where 1=1 " + whereSql + " ORDER BY id DESC "
 	if cond.Limit > 0 {
@@ -516,6 +521,9 @@ func GetAlarms(cond m.QueryAlarmCondition) (error, m.AlarmProblemList) {
 	for _, v := range result {
 		v.StartString = v.Start.Format(m.DatetimeFormat)
 		v.EndString = v.End.Format(m.DatetimeFormat)
+		if !v.AckTime.IsZero() {
+			v.AckTimeString = v.AckTime.Format(m.DatetimeFormat)
+		}
 		if v.AlarmName == "" {
 			v.AlarmName = v.Content
 		}
@@ -614,7 +622,8 @@ func GetAlarms(cond m.QueryAlarmCondition) (error, m.AlarmProblemList) {
 			}
 		}
 	}
-	if cond.ExtOpenAlarm && len(cond.MetricFilterList) == 0 && len(cond.EndpointFilterList) == 0 {
+	// 自定义告警没有确认状态,按已确认过滤时不带上
+	if cond.ExtOpenAlarm && len(cond.MetricFilterList) == 0 && len(cond.EndpointFilterList) == 0 && cond.AckStatus != m.AlarmAckStatusAcked {
 		for _, v := range GetOpenAlarm(m.CustomAlarmQueryParam{Enable: true, Status: "problem", Start: "", End: "", Level: cond.PriorityList, AlterTitleList: cond.AlarmNameFilterList, Query: cond.Query}) {
 			result = append(result, v)
 		}
@@ -764,6 +773,7 @@ func UpdateAlarms(alarms []*m.AlarmHandleObj) []*m.AlarmHandleObj {
 			//}
 		}
 	}
+	ApplyAlarmInhibitRules(successAlarms)
 	return successAlarms
 }
 
@@ -1517,6 +1527,9 @@ func QueryAlarmBySql(sql string, params []interface{}, customQueryParam m.Custom
 		for _, v := range alarmQuery {
 			v.StartString = v.Start.Format(m.DatetimeFormat)
 			v.EndString = v.End.Format(m.DatetimeFormat)
+			if !v.AckTime.IsZero() {
+				v.AckTimeString = v.AckTime.Format(m.DatetimeFormat)
+			}
 			if v.SMetric == "log_monitor" || v.SMetric == "db_keyword_monitor" {
 				if v.SMetric == "log_monitor" {
 					logKeywordConfigList = append(logKeywordConfigList, v.AlarmStrategy)
@@ -1755,6 +1768,9 @@ func QueryHistoryAlarm(param m.QueryHistoryAlarmParam) (err error, result m.Alar
 		customQueryParam.Enable = true
 	}
 	err, result = QueryAlarmBySql(sql, params, customQueryParam, param.Page)
+	if err == nil {
+		fillAlarmEscalateLogs(result.Data)
+	}
 	return err, result
 }
 
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"fmt"
+	"github.com/WeBankPartners/open-monitor/monitor-server/middleware/log"
+	"github.com/WeBankPartners/open-monitor/monitor-server/models"
+	"time"
+)
+
+// AckAlarm 确认告警,告警保持firing直到指标恢复,同时停止重复通知和升级
+func AckAlarm(param *models.AlarmAckParam, operator string) (err error) {
+	alarmRow, err := getFiringAlarmForAck(param.Id)
+	if err != nil {
+		return
+	}
+	if alarmRow.AckUser != "" {
+		err = fmt.Errorf("alarm:%d already acked by %s ", param.Id, alarmRow.AckUser)
+		return
+	}
+	nowTime := time.Now()
+	var actions []*Action
+	actions = append(actions, &Action{Sql: "update alarm set ack_user=?,ack_time=?,ack_msg=? where id=?", Param: []interface{}{operator, nowTime, param.Message, param.Id}})
+	actions = append(actions, &Action{Sql: "update alarm_notify_escalate set status=?,update_time=? where alarm_id=?", Param: []interface{}{models.AlarmEscalateStatusDone, nowTime, param.Id}})
+	actions = append(actions, getAlarmAckLogInsertAction(param.Id, models.AlarmAckActionAck, operator, param.Message, nowTime))
+	err = Transaction(actions)
+	if err != nil {
+		err = fmt.Errorf("ack alarm fail,%s ", err.Error())
+	}
+	return
+}
+
+// UnackAlarm 取消确认
+func UnackAlarm(param *models.AlarmAckParam, operator string) (err error) {
+	alarmRow, err := getFiringAlarmForAck(param.Id)
+	if err != nil {
+		return
+	}
+	if alarmRow.AckUser == "" {
+		err = fmt.Errorf("alarm:%d is not acked ", param.Id)
+		return
+	}
+	nowTime := time.Now()
+	var actions []*Action
+	actions = append(actions, &Action{Sql: "update alarm set ack_user=null,ack_time=null,ack_msg=null where id=?", Param: []interface{}{param.Id}})
+	actions = append(actions, getAlarmAckLogInsertAction(param.Id, models.AlarmAckActionUnack, operator, param.Message, nowTime))
+	err = Transaction(actions)
+	if err != nil {
+		err = fmt.Errorf("unack alarm fail,%s ", err.Error())
+		return
+	}
+	rearmAlarmNotifyEscalate(param.Id, nowTime)
+	return
+}
+
+// rearmAlarmNotifyEscalate 取消确认后按原通知配置重新开始重复通知和升级,升级延迟从取消确认时开始算
+func rearmAlarmNotifyEscalate(alarmId int, nowTime time.Time) {
+	var escalateRows []*models.AlarmNotifyEscalateTable
+	if err := x.SQL("select alarm_id,notify from alarm_notify_escalate where alarm_id=?", alarmId).Find(&escalateRows); err != nil {
+		log.Logger.Error("Query alarm notify escalate fail", log.Int("alarmId", alarmId), log.Error(err))
+		return
+	}
+	if len(escalateRows) == 0 {
+		return
+	}
+	notifyRow, err := getSimpleNotify(escalateRows[0].Notify)
+	if err != nil {
+		log.Logger.Warn("Rearm alarm notify escalate get notify fail", log.Int("alarmId", alarmId), log.Error(err))
+		return
+	}
+	startAlarmNotifyEscalate(&notifyRow, &models.AlarmHandleObj{AlarmTable: models.AlarmTable{Id: alarmId, Start: nowTime}})
+}
+
+func ListAlarmAckLog(alarmId int) (result []*models.AlarmAckLogTable, err error) {
+	result = []*models.AlarmAckLogTable{}
+	err = x.SQL("select * from alarm_ack_log where alarm_id=? order by id", alarmId).Find(&result)
+	if err != nil {
+		err = fmt.Errorf("query alarm ack log fail,%s ", err.Error())
+		return
+	}
+	for _, v := range result {
+		v.CreateTimeStr = v.CreateTime.Format(models.DatetimeFormat)
+	}
+	return
+}
+
+func getFiringAlarmForAck(alarmId int) (alarmRow *models.AlarmTable, err error) {
+	var alarmRows []*models.AlarmTable
+	err = x.SQL("select id,status,ack_user from alarm where id=?", alarmId).Find(&alarmRows)
+	if err != nil {
+		err = fmt.Errorf("query alarm table fail,%s ", err.Error())
+		return
+	}
+	if len(alarmRows) == 0 {
+		err = fmt.Errorf("can not find alarm with id:%d ", alarmId)
+		return
+	}
+	alarmRow = alarmRows[0]
+	if alarmRow.Status != "firing" {
+		err = fmt.Errorf("alarm:%d status is %s,only firing alarm can be acked ", alarmId, alarmRow.Status)
+	}
+	return
+}
+
+func getAlarmAckLogInsertAction(alarmId int, action, operator, message string, nowTime time.Time) *Action {
+	return &Action{Sql: "insert into alarm_ack_log(alarm_id,action,operator,message,create_time) value (?,?,?,?,?)", Param: []interface{}{alarmId, action, operator, message, nowTime}}
+}
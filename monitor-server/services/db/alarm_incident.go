@@ -0,0 +1,352 @@
+package db
+
+import (
+	"fmt"
+	"github.com/WeBankPartners/open-monitor/monitor-server/middleware/log"
+	"github.com/WeBankPartners/open-monitor/monitor-server/models"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var incidentLock = new(sync.Mutex)
+
+// handleAlarmIncident 把告警聚合到事件里,返回true表示通知已由事件处理,告警本身不用再单独通知
+func handleAlarmIncident(notify *models.NotifyTable, alarmObj *models.AlarmHandleObj) bool {
+	if alarmObj.Id <= 0 {
+		return false
+	}
+	if alarmObj.Status == "firing" {
+		return handleFiringAlarmIncident(notify, alarmObj)
+	}
+	return handleRecoverAlarmIncident(notify, alarmObj)
+}
+
+func handleFiringAlarmIncident(notify *models.NotifyTable, alarmObj *models.AlarmHandleObj) bool {
+	incidentConfig := models.Config().AlarmIncident
+	groupKey, groupLabel := buildIncidentGroupKey(alarmObj, incidentConfig.GroupBy)
+	nowTime := time.Now()
+	incidentLock.Lock()
+	var incidentRows []*models.AlarmIncidentTable
+	err := x.SQL("select * from alarm_incident where group_key=? and status='firing' and `start`>=? order by id desc limit 1", groupKey, nowTime.Add(time.Duration(-incidentConfig.GroupWindow)*time.Second)).Find(&incidentRows)
+	if err != nil {
+		incidentLock.Unlock()
+		log.Logger.Error("Query alarm incident fail", log.String("groupKey", groupKey), log.Error(err))
+		return false
+	}
+	if len(incidentRows) > 0 {
+		err = addAlarmIncidentMember(incidentRows[0].Id, alarmObj.Id, nowTime)
+		incidentLock.Unlock()
+		if err != nil {
+			log.Logger.Error("Add alarm to incident fail", log.Int("incident", incidentRows[0].Id), log.Int("alarmId", alarmObj.Id), log.Error(err))
+			return false
+		}
+		log.Logger.Info("Alarm join incident,ignore single notify", log.Int("incident", incidentRows[0].Id), log.Int("alarmId", alarmObj.Id))
+		// 首次聚合通知已经发出后才加入的告警,补发一条带全部成员的事件通知
+		if nowTime.Sub(incidentRows[0].Start) > time.Duration(incidentConfig.GroupWait)*time.Second {
+			if incidentObj, memberAlarms, getErr := getAlarmIncidentWithMembers(incidentRows[0].Id); getErr == nil {
+				notifyIncidentAction(notify, incidentObj, memberAlarms)
+			}
+		}
+		return true
+	}
+	execResult, err := x.Exec("insert into alarm_incident(group_key,group_label,status,alarm_count,notify,`start`,update_time) value (?,?,?,?,?,?,?)", groupKey, groupLabel, "firing", 0, notify.Guid, nowTime, nowTime)
+	if err != nil {
+		incidentLock.Unlock()
+		log.Logger.Error("Insert alarm incident fail", log.String("groupKey", groupKey), log.Error(err))
+		return false
+	}
+	incidentId, _ := execResult.LastInsertId()
+	err = addAlarmIncidentMember(int(incidentId), alarmObj.Id, nowTime)
+	incidentLock.Unlock()
+	if err != nil {
+		log.Logger.Error("Add alarm to incident fail", log.Int("incident", int(incidentId)), log.Int("alarmId", alarmObj.Id), log.Error(err))
+		return false
+	}
+	// 等待一段时间收集同组的告警后再统一通知
+	if incidentConfig.GroupWait > 0 {
+		time.Sleep(time.Duration(incidentConfig.GroupWait) * time.Second)
+	}
+	incidentObj, memberAlarms, err := getAlarmIncidentWithMembers(int(incidentId))
+	if err != nil {
+		log.Logger.Error("Get alarm incident members fail", log.Int("incident", int(incidentId)), log.Error(err))
+		return false
+	}
+	if len(memberAlarms) <= 1 {
+		return false
+	}
+	notifyIncidentAction(notify, incidentObj, memberAlarms)
+	return true
+}
+
+func handleRecoverAlarmIncident(notify *models.NotifyTable, alarmObj *models.AlarmHandleObj) bool {
+	var incidentRows []*models.AlarmIncidentTable
+	err := x.SQL("select * from alarm_incident where id in (select incident from alarm_incident_rel where alarm_id=?)", alarmObj.Id).Find(&incidentRows)
+	if err != nil || len(incidentRows) == 0 {
+		return false
+	}
+	incidentObj := incidentRows[0]
+	if incidentObj.Status != "firing" {
+		return incidentObj.AlarmCount > 1
+	}
+	queryRows, err := x.QueryString("select count(1) as num from alarm where status='firing' and id in (select alarm_id from alarm_incident_rel where incident=?)", incidentObj.Id)
+	if err != nil || len(queryRows) == 0 {
+		log.Logger.Error("Query incident firing alarm count fail", log.Int("incident", incidentObj.Id), log.Error(err))
+		return false
+	}
+	if queryRows[0]["num"] != "0" {
+		return incidentObj.AlarmCount > 1
+	}
+	// 成员全部恢复后事件恢复,多个成员同时恢复时只有更新成功的那个发通知
+	execResult, err := x.Exec("update alarm_incident set status='ok',`end`=?,update_time=? where id=? and status='firing'", time.Now(), time.Now(), incidentObj.Id)
+	if err != nil {
+		log.Logger.Error("Update alarm incident status fail", log.Int("incident", incidentObj.Id), log.Error(err))
+		return false
+	}
+	if affectNum, _ := execResult.RowsAffected(); affectNum == 0 {
+		return incidentObj.AlarmCount > 1
+	}
+	if incidentObj.AlarmCount <= 1 {
+		return false
+	}
+	incidentObj, memberAlarms, err := getAlarmIncidentWithMembers(incidentObj.Id)
+	if err != nil {
+		log.Logger.Error("Get alarm incident members fail", log.Int("incident", incidentRows[0].Id), log.Error(err))
+		return true
+	}
+	notifyIncidentAction(notify, incidentObj, memberAlarms)
+	return true
+}
+
+func addAlarmIncidentMember(incidentId, alarmId int, nowTime time.Time) error {
+	var actions []*Action
+	actions = append(actions, &Action{Sql: "insert into alarm_incident_rel(incident,alarm_id,create_time) value (?,?,?)", Param: []interface{}{incidentId, alarmId, nowTime}})
+	actions = append(actions, &Action{Sql: "update alarm_incident set alarm_count=alarm_count+1,update_time=? where id=?", Param: []interface{}{nowTime, incidentId}})
+	return Transaction(actions)
+}
+
+// isIncidentRepresentative 告警是否是所在事件里最先加入且仍未恢复的成员
+func isIncidentRepresentative(alarmId int) bool {
+	representAlarmId, err := getIncidentFiringRepresentative(alarmId)
+	if err != nil {
+		log.Logger.Error("Query incident representative alarm fail", log.Int("alarmId", alarmId), log.Error(err))
+		return false
+	}
+	return representAlarmId == alarmId
+}
+
+// getIncidentFiringRepresentative 告警所在事件里最先加入且仍未恢复的成员,没有时返回0
+func getIncidentFiringRepresentative(alarmId int) (representAlarmId int, err error) {
+	queryRows, queryErr := x.QueryString("select t1.alarm_id from alarm_incident_rel t1 join alarm t2 on t1.alarm_id=t2.id where t1.incident in (select incident from alarm_incident_rel where alarm_id=?) and t2.status='firing' order by t1.id limit 1", alarmId)
+	if queryErr != nil {
+		err = fmt.Errorf("query incident firing alarm fail,%s ", queryErr.Error())
+		return
+	}
+	if len(queryRows) > 0 {
+		representAlarmId, _ = strconv.Atoi(queryRows[0]["alarm_id"])
+	}
+	return
+}
+
+// buildIncidentGroupKey 按配置的聚合字段拼出聚合键
+func buildIncidentGroupKey(alarmObj *models.AlarmHandleObj, groupBy []string) (groupKey, groupLabel string) {
+	var keyList, labelList []string
+	var tagMap map[string]string
+	for _, groupField := range groupBy {
+		var value string
+		switch {
+		case groupField == models.IncidentGroupByEndpoint:
+			value = alarmObj.Endpoint
+		case groupField == models.IncidentGroupByAlarmStrategy:
+			value = alarmObj.AlarmStrategy
+		case groupField == models.IncidentGroupByEndpointGroup:
+			if queryRows, _ := x.QueryString("select endpoint_group from alarm_strategy where guid=?", alarmObj.AlarmStrategy); len(queryRows) > 0 {
+				value = queryRows[0]["endpoint_group"]
+			}
+		case groupField == models.IncidentGroupByServiceGroup:
+			serviceGroupList := getAlarmAffectServiceGroupList(alarmObj)
+			sort.Strings(serviceGroupList)
+			value = strings.Join(serviceGroupList, ",")
+		case strings.HasPrefix(groupField, models.IncidentGroupByTagPrefix):
+			if tagMap == nil {
+				tagMap = convertAlarmTagsToMap(alarmObj.Tags)
+			}
+			value = tagMap[groupField[len(models.IncidentGroupByTagPrefix):]]
+		default:
+			continue
+		}
+		keyList = append(keyList, groupField+"="+value)
+		if value != "" {
+			labelList = append(labelList, value)
+		}
+	}
+	groupKey = strings.Join(keyList, "^")
+	groupLabel = strings.Join(labelList, " ")
+	return
+}
+
+func getAlarmIncidentWithMembers(incidentId int) (incidentObj *models.AlarmIncidentTable, memberAlarms []*models.AlarmTable, err error) {
+	var incidentRows []*models.AlarmIncidentTable
+	if err = x.SQL("select * from alarm_incident where id=?", incidentId).Find(&incidentRows); err != nil {
+		err = fmt.Errorf("query alarm incident fail,%s ", err.Error())
+		return
+	}
+	if len(incidentRows) == 0 {
+		err = fmt.Errorf("can not find alarm incident with id:%d ", incidentId)
+		return
+	}
+	incidentObj = incidentRows[0]
+	buildAlarmIncidentDisplay(incidentObj)
+	if err = x.SQL("select * from alarm where id in (select alarm_id from alarm_incident_rel where incident=?) order by id", incidentId).Find(&memberAlarms); err != nil {
+		err = fmt.Errorf("query alarm incident members fail,%s ", err.Error())
+	}
+	return
+}
+
+// notifyIncidentAction 发送一条包含所有成员告警的聚合通知
+func notifyIncidentAction(notify *models.NotifyTable, incidentObj *models.AlarmIncidentTable, memberAlarms []*models.AlarmTable) {
+	log.Logger.Info("Start notify incident", log.Int("incident", incidentObj.Id), log.String("notify", notify.Guid), log.Int("alarmNum", len(memberAlarms)))
+	subject, content := buildIncidentNotifyMessage(incidentObj, memberAlarms)
+	leaderAlarm := &models.AlarmHandleObj{AlarmTable: *memberAlarms[0]}
+	if models.AlarmMailEnable {
+		if toAddress := getNotifyMailAddress(notify, leaderAlarm.Id); len(toAddress) > 0 {
+			mailSender, err := newAlarmMailSender()
+			if err == nil {
+				err = mailSender.Send(subject, content, toAddress)
+			}
+			if err != nil {
+				log.Logger.Error("Notify incident mail fail", log.Int("incident", incidentObj.Id), log.Error(err))
+			}
+		}
+	}
+	if notify.CallbackUrl != "" {
+		payload := buildAlarmWebhookPayload(notify, leaderAlarm)
+		payload.EventId = fmt.Sprintf("incident-%d-%s-%s", incidentObj.Id, incidentObj.Status, notify.Guid)
+		payload.IncidentId = incidentObj.Id
+		payload.Status = incidentObj.Status
+		payload.Title, payload.Message, payload.MessageType = subject, content, models.NotifyContentTypeText
+		payload.Detail = []*models.AlarmWebhookDetailObj{}
+		for _, v := range memberAlarms {
+			payload.Detail = append(payload.Detail, &models.AlarmWebhookDetailObj{Metric: v.SMetric, Cond: v.SCond, Last: v.SLast, Start: formatWebhookTime(v.Start), StartValue: v.StartValue, End: formatWebhookTime(v.End), EndValue: v.EndValue, Tags: convertAlarmTagsToMap(v.Tags)})
+		}
+		if err := postAlarmWebhookPayload(notify, payload); err != nil {
+			log.Logger.Error("Notify incident webhook fail", log.Int("incident", incidentObj.Id), log.Error(err))
+		}
+	}
+}
+
+func buildIncidentNotifyMessage(incidentObj *models.AlarmIncidentTable, memberAlarms []*models.AlarmTable) (subject, content string) {
+	subject = fmt.Sprintf("[%s][incident] %s %d alarms", incidentObj.Status, incidentObj.GroupLabel, len(memberAlarms))
+	content = fmt.Sprintf("Incident:%d\r\nGroup:%s\r\nStatus:%s\r\nStart:%s\r\nTime:%s\r\nAlarms:\r\n", incidentObj.Id, incidentObj.GroupLabel, incidentObj.Status, incidentObj.StartString, time.Now().Format(models.DatetimeFormat))
+	for _, v := range memberAlarms {
+		alarmName := v.AlarmName
+		if alarmName == "" {
+			alarmName = v.Content
+		}
+		content += fmt.Sprintf("[%s][%s] Endpoint:%s Metric:%s Name:%s Value:%.3f%s Start:%s\r\n", v.Status, v.SPriority, v.Endpoint, v.SMetric, alarmName, v.StartValue, v.SCond, v.Start.Format(models.DatetimeFormat))
+	}
+	return
+}
+
+func QueryAlarmIncident(param *models.AlarmIncidentQueryParam) (result models.AlarmIncidentQueryResult, err error) {
+	result = models.AlarmIncidentQueryResult{Data: []*models.AlarmIncidentTable{}, Page: &models.PageInfo{}}
+	var filterSqlList []string
+	var filterParams []interface{}
+	if param.Status != "" {
+		filterSqlList = append(filterSqlList, "status=?")
+		filterParams = append(filterParams, param.Status)
+	}
+	if param.GroupLabel != "" {
+		filterSqlList = append(filterSqlList, "group_label like ?")
+		filterParams = append(filterParams, fmt.Sprintf("%%%s%%", param.GroupLabel))
+	}
+	baseSql := "select * from alarm_incident"
+	if len(filterSqlList) > 0 {
+		baseSql += " where " + strings.Join(filterSqlList, " and ")
+	}
+	baseSql += " order by id desc"
+	if param.Page != nil && param.Page.PageSize > 0 {
+		result.Page.StartIndex = param.Page.StartIndex
+		result.Page.PageSize = param.Page.PageSize
+		result.Page.TotalRows = queryCount(baseSql, filterParams...)
+		baseSql += " limit ?,?"
+		filterParams = append(filterParams, param.Page.StartIndex, param.Page.PageSize)
+	}
+	if err = x.SQL(baseSql, filterParams...).Find(&result.Data); err != nil {
+		err = fmt.Errorf("query alarm incident fail,%s ", err.Error())
+		return
+	}
+	for _, v := range result.Data {
+		buildAlarmIncidentDisplay(v)
+	}
+	return
+}
+
+func GetAlarmIncidentDetail(incidentId int) (result *models.AlarmIncidentDetail, err error) {
+	incidentObj, _, err := getAlarmIncidentWithMembers(incidentId)
+	if err != nil {
+		return
+	}
+	result = &models.AlarmIncidentDetail{AlarmIncidentTable: *incidentObj, Alarms: []*models.AlarmProblemQuery{}}
+	err, result.Alarms = QueryAlarmByIncident(incidentId)
+	return
+}
+
+// QueryAlarmByIncident 事件成员告警,格式和告警列表一致
+func QueryAlarmByIncident(incidentId int) (err error, result []*models.AlarmProblemQuery) {
+	result = []*models.AlarmProblemQuery{}
+	err = x.SQL("select * from alarm where id in (select alarm_id from alarm_incident_rel where incident=?) order by id", incidentId).Find(&result)
+	if err != nil {
+		err = fmt.Errorf("query alarm incident members fail,%s ", err.Error())
+		return
+	}
+	for _, v := range result {
+		v.StartString = v.Start.Format(models.DatetimeFormat)
+		v.EndString = v.End.Format(models.DatetimeFormat)
+		if !v.AckTime.IsZero() {
+			v.AckTimeString = v.AckTime.Format(models.DatetimeFormat)
+		}
+		if v.AlarmName == "" {
+			v.AlarmName = v.Content
+		}
+	}
+	return
+}
+
+// CloseAlarmIncident 关闭事件下所有未恢复的告警
+func CloseAlarmIncident(incidentId int, operator string) (err error) {
+	incidentObj, memberAlarms, err := getAlarmIncidentWithMembers(incidentId)
+	if err != nil {
+		return
+	}
+	if incidentObj.Status == "closed" {
+		return fmt.Errorf("incident:%d already closed ", incidentId)
+	}
+	var actions []*Action
+	for _, v := range memberAlarms {
+		if v.Status != "firing" {
+			continue
+		}
+		closeActions, closeErr := CloseAlarm(models.AlarmCloseParam{Id: v.Id})
+		if closeErr != nil {
+			return closeErr
+		}
+		actions = append(actions, closeActions...)
+	}
+	nowTime := time.Now()
+	actions = append(actions, &Action{Sql: "update alarm_incident set status='closed',close_user=?,`end`=?,update_time=? where id=?", Param: []interface{}{operator, nowTime, nowTime, incidentId}})
+	err = Transaction(actions)
+	if err != nil {
+		err = fmt.Errorf("close alarm incident fail,%s ", err.Error())
+	}
+	return
+}
+
+func buildAlarmIncidentDisplay(incidentObj *models.AlarmIncidentTable) {
+	incidentObj.StartString = incidentObj.Start.Format(models.DatetimeFormat)
+	if !incidentObj.End.IsZero() {
+		incidentObj.EndString = incidentObj.End.Format(models.DatetimeFormat)
+	}
+}
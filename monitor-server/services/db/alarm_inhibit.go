@@ -0,0 +1,299 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/WeBankPartners/go-common-lib/guid"
+	"github.com/WeBankPartners/open-monitor/monitor-server/middleware/log"
+	"github.com/WeBankPartners/open-monitor/monitor-server/models"
+	"regexp"
+	"strings"
+	"time"
+)
+
+func ListAlarmInhibitRule(param *models.AlarmInhibitRuleQueryParam) (result []*models.AlarmInhibitRuleTable, err error) {
+	result = []*models.AlarmInhibitRuleTable{}
+	var filterSqlList []string
+	var filterParams []interface{}
+	if param.Name != "" {
+		filterSqlList = append(filterSqlList, "name like ?")
+		filterParams = append(filterParams, fmt.Sprintf("%%%s%%", param.Name))
+	}
+	if param.Enable != "" {
+		filterSqlList = append(filterSqlList, "enable=?")
+		filterParams = append(filterParams, param.Enable)
+	}
+	baseSql := "select * from alarm_inhibit_rule"
+	if len(filterSqlList) > 0 {
+		baseSql += " where " + strings.Join(filterSqlList, " and ")
+	}
+	err = x.SQL(baseSql+" order by update_time desc", filterParams...).Find(&result)
+	if err != nil {
+		err = fmt.Errorf("query alarm inhibit rule table fail,%s ", err.Error())
+		return
+	}
+	for _, v := range result {
+		buildAlarmInhibitRuleDisplay(v)
+	}
+	return
+}
+
+// ValidateAlarmInhibitRule 校验并序列化抑制规则
+func ValidateAlarmInhibitRule(param *models.AlarmInhibitRuleTable) (err error) {
+	param.Name = strings.TrimSpace(param.Name)
+	for _, matcher := range []*models.AlarmInhibitMatcher{param.Source, param.Target} {
+		if matcher.AlarmStrategy == "" && matcher.Metric == "" && matcher.Priority == "" && len(matcher.Tags) == 0 {
+			return fmt.Errorf("source and target matcher can not empty ")
+		}
+		for _, v := range matcher.Tags {
+			if v.Key == "" {
+				return fmt.Errorf("tag matcher key can not empty ")
+			}
+			if v.Operator == "" {
+				v.Operator = models.SilenceMatchEqual
+			}
+			switch v.Operator {
+			case models.SilenceMatchEqual, models.SilenceMatchNotEqual:
+			case models.SilenceMatchRegex, models.SilenceMatchNotRegex:
+				if _, regErr := regexp.Compile(v.Value); regErr != nil {
+					return fmt.Errorf("tag matcher value:%s regexp illegal,%s ", v.Value, regErr.Error())
+				}
+			default:
+				return fmt.Errorf("tag matcher operator:%s illegal ", v.Operator)
+			}
+		}
+	}
+	var equalLabels []string
+	for _, v := range param.EqualLabelList {
+		if v = strings.TrimSpace(v); v != "" {
+			equalLabels = append(equalLabels, v)
+		}
+	}
+	sourceBytes, _ := json.Marshal(param.Source)
+	targetBytes, _ := json.Marshal(param.Target)
+	param.SourceMatchers = string(sourceBytes)
+	param.TargetMatchers = string(targetBytes)
+	param.EqualLabels = strings.Join(equalLabels, ",")
+	if param.Enable != 0 {
+		param.Enable = 1
+	}
+	return
+}
+
+func CreateAlarmInhibitRule(param *models.AlarmInhibitRuleTable, operator string) (err error) {
+	nowTime := time.Now()
+	param.Guid = "inhibit_" + guid.CreateGuid()
+	_, err = x.Exec("insert into alarm_inhibit_rule(guid,name,source_matchers,target_matchers,equal_labels,enable,create_user,update_user,create_time,update_time) value (?,?,?,?,?,?,?,?,?,?)",
+		param.Guid, param.Name, param.SourceMatchers, param.TargetMatchers, param.EqualLabels, param.Enable, operator, operator, nowTime, nowTime)
+	if err != nil {
+		err = fmt.Errorf("insert alarm inhibit rule fail,%s ", err.Error())
+	}
+	return
+}
+
+func UpdateAlarmInhibitRule(param *models.AlarmInhibitRuleTable, operator string) (err error) {
+	execResult, execErr := x.Exec("update alarm_inhibit_rule set name=?,source_matchers=?,target_matchers=?,equal_labels=?,enable=?,update_user=?,update_time=? where guid=?",
+		param.Name, param.SourceMatchers, param.TargetMatchers, param.EqualLabels, param.Enable, operator, time.Now(), param.Guid)
+	if execErr != nil {
+		return fmt.Errorf("update alarm inhibit rule fail,%s ", execErr.Error())
+	}
+	if affectNum, _ := execResult.RowsAffected(); affectNum == 0 {
+		err = fmt.Errorf("can not find alarm inhibit rule with guid:%s ", param.Guid)
+	}
+	return
+}
+
+func DeleteAlarmInhibitRule(ruleGuid string) (err error) {
+	_, err = x.Exec("delete from alarm_inhibit_rule where guid=?", ruleGuid)
+	if err != nil {
+		err = fmt.Errorf("delete alarm inhibit rule fail,%s ", err.Error())
+	}
+	return
+}
+
+// ApplyAlarmInhibitRules 告警入库后计算抑制关系:新的firing告警找抑制它的源告警,恢复的源告警释放被它抑制的告警
+func ApplyAlarmInhibitRules(alarms []*models.AlarmHandleObj) {
+	if len(alarms) == 0 {
+		return
+	}
+	ruleList := getEnableAlarmInhibitRules()
+	if len(ruleList) == 0 {
+		return
+	}
+	for _, v := range alarms {
+		if v.Id <= 0 || v.Status != "firing" {
+			continue
+		}
+		if sourceId := findAlarmInhibitSource(ruleList, &v.AlarmTable); sourceId > 0 {
+			v.InhibitBy = sourceId
+			if _, err := x.Exec("update alarm set inhibit_by=? where id=?", sourceId, v.Id); err != nil {
+				log.Logger.Error("Update alarm inhibit_by fail", log.Int("alarmId", v.Id), log.Error(err))
+			}
+			log.Logger.Info("Alarm inhibited", log.Int("alarmId", v.Id), log.Int("source", sourceId))
+		}
+	}
+	for _, v := range alarms {
+		if v.Id > 0 && v.Status != "firing" {
+			releaseInhibitedAlarms(ruleList, v.Id)
+		}
+	}
+}
+
+// checkAlarmInhibit 被抑制的告警不发通知,恢复告警跟随firing时的抑制结果
+func checkAlarmInhibit(alarmObj *models.AlarmHandleObj) bool {
+	if alarmObj.Id <= 0 {
+		return false
+	}
+	if alarmObj.Status == "firing" {
+		return alarmObj.InhibitBy > 0
+	}
+	queryRows, err := x.QueryString("select inhibit_by from alarm where id=?", alarmObj.Id)
+	if err != nil || len(queryRows) == 0 {
+		return false
+	}
+	return queryRows[0]["inhibit_by"] != "" && queryRows[0]["inhibit_by"] != "0"
+}
+
+// releaseInhibitedAlarms 源告警恢复后,被它抑制的告警如果没有其它源告警抑制则补发通知
+func releaseInhibitedAlarms(ruleList []*models.AlarmInhibitRuleTable, sourceId int) {
+	var inhibitedRows []*models.AlarmTable
+	if err := x.SQL("select * from alarm where inhibit_by=? and status='firing'", sourceId).Find(&inhibitedRows); err != nil {
+		log.Logger.Error("Query inhibited alarms fail", log.Int("source", sourceId), log.Error(err))
+		return
+	}
+	for _, row := range inhibitedRows {
+		row.InhibitBy = findAlarmInhibitSource(ruleList, row)
+		if _, err := x.Exec("update alarm set inhibit_by=? where id=?", row.InhibitBy, row.Id); err != nil {
+			log.Logger.Error("Update alarm inhibit_by fail", log.Int("alarmId", row.Id), log.Error(err))
+			continue
+		}
+		if row.InhibitBy > 0 {
+			continue
+		}
+		log.Logger.Info("Alarm inhibit released", log.Int("alarmId", row.Id), log.Int("source", sourceId))
+		if row.AlarmStrategy == "" {
+			continue
+		}
+		if strategyRow, getErr := GetSimpleAlarmStrategy(row.AlarmStrategy); getErr != nil || strategyRow.NotifyEnable == 0 {
+			continue
+		}
+		go NotifyStrategyAlarm(&models.AlarmHandleObj{AlarmTable: *row})
+	}
+}
+
+// findAlarmInhibitSource 返回抑制目标告警的源告警id,源告警本身不能处于被抑制状态,避免互相抑制
+func findAlarmInhibitSource(ruleList []*models.AlarmInhibitRuleTable, targetRow *models.AlarmTable) int {
+	targetTagMap := buildAlarmMatchTagMap(targetRow)
+	for _, rule := range ruleList {
+		if !matchAlarmInhibitMatcher(rule.Target, targetRow, targetTagMap) {
+			continue
+		}
+		filterSql := "select * from alarm where status='firing' and inhibit_by=0 and id<>?"
+		filterParams := []interface{}{targetRow.Id}
+		if rule.Source.AlarmStrategy != "" {
+			filterSql += " and alarm_strategy=?"
+			filterParams = append(filterParams, rule.Source.AlarmStrategy)
+		}
+		if rule.Source.Metric != "" {
+			filterSql += " and s_metric=?"
+			filterParams = append(filterParams, rule.Source.Metric)
+		}
+		if rule.Source.Priority != "" {
+			filterSql += " and s_priority=?"
+			filterParams = append(filterParams, rule.Source.Priority)
+		}
+		if len(rule.EqualLabelList) == 1 && rule.EqualLabelList[0] == models.InhibitEqualEndpoint {
+			filterSql += " and endpoint=?"
+			filterParams = append(filterParams, targetRow.Endpoint)
+		}
+		var sourceRows []*models.AlarmTable
+		if err := x.SQL(filterSql+" order by id", filterParams...).Find(&sourceRows); err != nil {
+			log.Logger.Error("Query inhibit source alarms fail", log.String("rule", rule.Guid), log.Error(err))
+			continue
+		}
+		for _, sourceRow := range sourceRows {
+			sourceTagMap := buildAlarmMatchTagMap(sourceRow)
+			if !matchAlarmInhibitMatcher(rule.Source, sourceRow, sourceTagMap) {
+				continue
+			}
+			if matchAlarmInhibitEqualLabels(rule.EqualLabelList, sourceRow, targetRow, sourceTagMap, targetTagMap) {
+				return sourceRow.Id
+			}
+		}
+	}
+	return 0
+}
+
+func matchAlarmInhibitMatcher(matcher *models.AlarmInhibitMatcher, alarmRow *models.AlarmTable, tagMap map[string]string) bool {
+	if matcher.AlarmStrategy != "" && matcher.AlarmStrategy != alarmRow.AlarmStrategy {
+		return false
+	}
+	if matcher.Metric != "" && matcher.Metric != alarmRow.SMetric {
+		return false
+	}
+	if matcher.Priority != "" && matcher.Priority != alarmRow.SPriority {
+		return false
+	}
+	return matchAlarmSilenceTags(matcher.Tags, tagMap)
+}
+
+func matchAlarmInhibitEqualLabels(equalLabels []string, sourceRow, targetRow *models.AlarmTable, sourceTagMap, targetTagMap map[string]string) bool {
+	for _, label := range equalLabels {
+		if label != models.InhibitEqualServiceGroup {
+			if sourceTagMap[label] != targetTagMap[label] {
+				return false
+			}
+			continue
+		}
+		// 层级对象按交集判断,比如数据库和应用属于同一个系统
+		sourceServiceGroupMap := make(map[string]bool)
+		for _, v := range getAlarmAffectServiceGroupList(&models.AlarmHandleObj{AlarmTable: *sourceRow}) {
+			sourceServiceGroupMap[v] = true
+		}
+		matchFlag := false
+		for _, v := range getAlarmAffectServiceGroupList(&models.AlarmHandleObj{AlarmTable: *targetRow}) {
+			if sourceServiceGroupMap[v] {
+				matchFlag = true
+				break
+			}
+		}
+		if !matchFlag {
+			return false
+		}
+	}
+	return true
+}
+
+func getEnableAlarmInhibitRules() (result []*models.AlarmInhibitRuleTable) {
+	var ruleRows []*models.AlarmInhibitRuleTable
+	if err := x.SQL("select * from alarm_inhibit_rule where enable=1").Find(&ruleRows); err != nil {
+		log.Logger.Error("Query alarm inhibit rule fail", log.Error(err))
+		return
+	}
+	for _, v := range ruleRows {
+		buildAlarmInhibitRuleDisplay(v)
+		result = append(result, v)
+	}
+	return
+}
+
+func buildAlarmInhibitRuleDisplay(rule *models.AlarmInhibitRuleTable) {
+	rule.Source = &models.AlarmInhibitMatcher{}
+	rule.Target = &models.AlarmInhibitMatcher{}
+	rule.EqualLabelList = []string{}
+	if rule.SourceMatchers != "" {
+		if err := json.Unmarshal([]byte(rule.SourceMatchers), rule.Source); err != nil {
+			log.Logger.Error("Alarm inhibit rule source matchers illegal", log.String("rule", rule.Guid), log.Error(err))
+		}
+	}
+	if rule.TargetMatchers != "" {
+		if err := json.Unmarshal([]byte(rule.TargetMatchers), rule.Target); err != nil {
+			log.Logger.Error("Alarm inhibit rule target matchers illegal", log.String("rule", rule.Guid), log.Error(err))
+		}
+	}
+	if rule.EqualLabels != "" {
+		rule.EqualLabelList = strings.Split(rule.EqualLabels, ",")
+	}
+	rule.CreateTimeStr = rule.CreateTime.Format(models.DatetimeFormat)
+	rule.UpdateTimeStr = rule.UpdateTime.Format(models.DatetimeFormat)
+}
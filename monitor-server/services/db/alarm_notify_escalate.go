@@ -0,0 +1,245 @@
+package db
+
+import (
+	"fmt"
+	"github.com/WeBankPartners/open-monitor/monitor-server/middleware/log"
+	"github.com/WeBankPartners/open-monitor/monitor-server/models"
+	"strings"
+	"time"
+)
+
+// startAlarmNotifyEscalate 告警首次通知后,如果通知配置了重复通知或者升级,记录升级状态交给定时任务继续处理
+func startAlarmNotifyEscalate(notify *models.NotifyTable, alarmObj *models.AlarmHandleObj) {
+	if notify.Guid == "" || notify.Guid == "defaultNotify" || alarmObj.Id <= 0 {
+		return
+	}
+	repeatEnable := notify.NotifyNum > 1 && notify.NotifyInterval > 0
+	escalateEnable := notify.EscalateDelay > 0 && notify.EscalateRoles != ""
+	if !repeatEnable && !escalateEnable {
+		return
+	}
+	nowTime := time.Now()
+	var nextNotifyTime, escalateTime time.Time
+	if repeatEnable {
+		nextNotifyTime = nowTime.Add(time.Duration(notify.NotifyInterval) * time.Minute)
+	}
+	if escalateEnable {
+		escalateTime = alarmObj.Start
+		if escalateTime.IsZero() || escalateTime.Unix() <= 0 {
+			escalateTime = nowTime
+		}
+		escalateTime = escalateTime.Add(time.Duration(notify.EscalateDelay) * time.Minute)
+	}
+	var actions []*Action
+	actions = append(actions, &Action{Sql: "delete from alarm_notify_escalate where alarm_id=?", Param: []interface{}{alarmObj.Id}})
+	actions = append(actions, &Action{Sql: "insert into alarm_notify_escalate(alarm_id,notify,notify_count,escalated,next_notify_time,escalate_time,status,create_time,update_time) value (?,?,?,?,?,?,?,?,?)",
+		Param: []interface{}{alarmObj.Id, notify.Guid, 1, 0, escalateTimeValue(nextNotifyTime), escalateTimeValue(escalateTime), models.AlarmEscalateStatusActive, nowTime, nowTime}})
+	if err := Transaction(actions); err != nil {
+		log.Logger.Error("Start alarm notify escalate fail", log.Int("alarmId", alarmObj.Id), log.String("notify", notify.Guid), log.Error(err))
+	}
+}
+
+func StartNotifyEscalateCronJob() {
+	t := time.NewTicker(time.Minute).C
+	for {
+		<-t
+		doNotifyEscalateJob()
+	}
+}
+
+func doNotifyEscalateJob() {
+	nowTime := time.Now()
+	var escalateRows []*models.AlarmNotifyEscalateTable
+	err := x.SQL("select * from alarm_notify_escalate where status=? and (next_notify_time<=? or (escalated=0 and escalate_time<=?))", models.AlarmEscalateStatusActive, nowTime, nowTime).Find(&escalateRows)
+	if err != nil {
+		log.Logger.Error("Query alarm notify escalate fail", log.Error(err))
+		return
+	}
+	for _, row := range escalateRows {
+		// 多实例部署时用update_time抢占,避免重复发送
+		execResult, execErr := x.Exec("update alarm_notify_escalate set update_time=? where alarm_id=? and update_time=?", nowTime, row.AlarmId, row.UpdateTime)
+		if execErr != nil {
+			log.Logger.Error("Lock alarm notify escalate fail", log.Int("alarmId", row.AlarmId), log.Error(execErr))
+			continue
+		}
+		if affectNum, _ := execResult.RowsAffected(); affectNum == 0 {
+			continue
+		}
+		handleAlarmNotifyEscalate(row, nowTime)
+	}
+}
+
+func handleAlarmNotifyEscalate(row *models.AlarmNotifyEscalateTable, nowTime time.Time) {
+	var alarmRows []*models.AlarmTable
+	err := x.SQL("select * from alarm where id=?", row.AlarmId).Find(&alarmRows)
+	if err != nil {
+		log.Logger.Error("Notify escalate query alarm fail", log.Int("alarmId", row.AlarmId), log.Error(err))
+		return
+	}
+	// 事件的代表告警恢复了但其它成员还在告警,重复通知和升级交给最早的仍在告警的成员
+	if len(alarmRows) > 0 && alarmRows[0].Status == "ok" {
+		if nextAlarmRow := handoverIncidentNotifyEscalate(row); nextAlarmRow != nil {
+			alarmRows[0] = nextAlarmRow
+		}
+	}
+	// 告警已恢复、已被手动关闭或者已被确认,结束升级
+	if len(alarmRows) == 0 || alarmRows[0].Status != "firing" || alarmRows[0].AckUser != "" {
+		finishAlarmNotifyEscalate(row.AlarmId)
+		return
+	}
+	notifyRow, err := getSimpleNotify(row.Notify)
+	if err != nil {
+		log.Logger.Warn("Notify escalate get notify fail,finish escalate", log.Int("alarmId", row.AlarmId), log.Error(err))
+		finishAlarmNotifyEscalate(row.AlarmId)
+		return
+	}
+	alarmObj := &models.AlarmHandleObj{AlarmTable: *alarmRows[0]}
+	// 屏蔽期间不发送,顺延到下一轮
+	if silenceRow := matchAlarmSilence(&alarmObj.AlarmTable, nowTime); silenceRow != nil {
+		log.Logger.Info("Notify escalate skip with alarm silence", log.Int("alarmId", row.AlarmId), log.String("silence", silenceRow.Guid))
+		if !row.NextNotifyTime.IsZero() && !row.NextNotifyTime.After(nowTime) {
+			x.Exec("update alarm_notify_escalate set next_notify_time=? where alarm_id=?", nowTime.Add(time.Duration(notifyRow.NotifyInterval)*time.Minute), row.AlarmId)
+		}
+		return
+	}
+	if !row.NextNotifyTime.IsZero() && !row.NextNotifyTime.After(nowTime) {
+		row.NotifyCount += 1
+		notifyRow.AffectServiceGroup = getAlarmAffectServiceGroupList(alarmObj)
+		sendErr := notifyRepeatAction(&notifyRow, alarmObj)
+		addAlarmNotifyEscalateLog(row, models.AlarmEscalateActionRepeat, strings.Join(getNotifyRoles(notifyRow.Guid), ","), sendErr)
+		if row.NotifyCount >= notifyRow.NotifyNum || notifyRow.NotifyInterval <= 0 {
+			row.NextNotifyTime = time.Time{}
+		} else {
+			row.NextNotifyTime = nowTime.Add(time.Duration(notifyRow.NotifyInterval) * time.Minute)
+		}
+	}
+	if row.Escalated == 0 && !row.EscalateTime.IsZero() && !row.EscalateTime.After(nowTime) {
+		row.Escalated = 1
+		escalateNotify := notifyRow
+		escalateNotify.MailRoles = splitNotifyEscalateRoles(notifyRow.EscalateRoles)
+		// 升级只发邮件,和正常通知一样受邮件开关控制
+		if models.AlarmMailEnable && len(escalateNotify.MailRoles) > 0 {
+			sendErr := notifyMailAction(&escalateNotify, alarmObj)
+			addAlarmNotifyEscalateLog(row, models.AlarmEscalateActionEscalate, notifyRow.EscalateRoles, sendErr)
+		}
+	}
+	status := models.AlarmEscalateStatusActive
+	if row.NextNotifyTime.IsZero() && (row.Escalated == 1 || row.EscalateTime.IsZero()) {
+		status = models.AlarmEscalateStatusDone
+	}
+	_, err = x.Exec("update alarm_notify_escalate set notify_count=?,escalated=?,next_notify_time=?,status=?,update_time=? where alarm_id=?",
+		row.NotifyCount, row.Escalated, escalateTimeValue(row.NextNotifyTime), status, time.Now(), row.AlarmId)
+	if err != nil {
+		log.Logger.Error("Update alarm notify escalate fail", log.Int("alarmId", row.AlarmId), log.Error(err))
+	}
+}
+
+// handoverIncidentNotifyEscalate 把升级记录转给告警所在事件里新的代表告警,返回新的代表告警
+func handoverIncidentNotifyEscalate(row *models.AlarmNotifyEscalateTable) *models.AlarmTable {
+	nextAlarmId, err := getIncidentFiringRepresentative(row.AlarmId)
+	if err != nil {
+		log.Logger.Error("Get incident next representative alarm fail", log.Int("alarmId", row.AlarmId), log.Error(err))
+		return nil
+	}
+	if nextAlarmId <= 0 {
+		return nil
+	}
+	var alarmRows []*models.AlarmTable
+	if err = x.SQL("select * from alarm where id=?", nextAlarmId).Find(&alarmRows); err != nil || len(alarmRows) == 0 {
+		log.Logger.Error("Notify escalate query incident representative alarm fail", log.Int("alarmId", nextAlarmId), log.Error(err))
+		return nil
+	}
+	var actions []*Action
+	actions = append(actions, &Action{Sql: "delete from alarm_notify_escalate where alarm_id=?", Param: []interface{}{nextAlarmId}})
+	actions = append(actions, &Action{Sql: "update alarm_notify_escalate set alarm_id=? where alarm_id=?", Param: []interface{}{nextAlarmId, row.AlarmId}})
+	if err = Transaction(actions); err != nil {
+		log.Logger.Error("Handover incident notify escalate fail", log.Int("alarmId", row.AlarmId), log.Int("nextAlarmId", nextAlarmId), log.Error(err))
+		return nil
+	}
+	log.Logger.Info("Incident representative alarm recover,handover notify escalate", log.Int("alarmId", row.AlarmId), log.Int("nextAlarmId", nextAlarmId))
+	row.AlarmId = nextAlarmId
+	return alarmRows[0]
+}
+
+// notifyRepeatAction 重复通知只发邮件和webhook,不重复触发编排
+func notifyRepeatAction(notify *models.NotifyTable, alarmObj *models.AlarmHandleObj) (err error) {
+	var errList []string
+	if models.AlarmMailEnable {
+		if mailErr := notifyMailAction(notify, alarmObj); mailErr != nil {
+			errList = append(errList, "mail:"+mailErr.Error())
+		}
+	}
+	if notify.CallbackUrl != "" {
+		if webhookErr := notifyWebhookAction(notify, alarmObj); webhookErr != nil {
+			errList = append(errList, "webhook:"+webhookErr.Error())
+		}
+	}
+	if len(errList) > 0 {
+		err = fmt.Errorf("%s ", strings.Join(errList, ";"))
+	}
+	return
+}
+
+func addAlarmNotifyEscalateLog(row *models.AlarmNotifyEscalateTable, action, roles string, sendErr error) {
+	result, message := "success", ""
+	if sendErr != nil {
+		result, message = "fail", sendErr.Error()
+		log.Logger.Error("Notify escalate send fail", log.Int("alarmId", row.AlarmId), log.String("action", action), log.Error(sendErr))
+	}
+	_, err := x.Exec("insert into alarm_notify_escalate_log(alarm_id,notify,step,action,roles,result,message,create_time) value (?,?,?,?,?,?,?,?)",
+		row.AlarmId, row.Notify, row.NotifyCount, action, roles, result, message, time.Now())
+	if err != nil {
+		log.Logger.Error("Insert alarm notify escalate log fail", log.Int("alarmId", row.AlarmId), log.Error(err))
+	}
+}
+
+func finishAlarmNotifyEscalate(alarmId int) {
+	if _, err := x.Exec("update alarm_notify_escalate set status=?,update_time=? where alarm_id=?", models.AlarmEscalateStatusDone, time.Now(), alarmId); err != nil {
+		log.Logger.Error("Finish alarm notify escalate fail", log.Int("alarmId", alarmId), log.Error(err))
+	}
+}
+
+// fillAlarmEscalateLogs 告警历史里带上重复通知和升级记录
+func fillAlarmEscalateLogs(alarmList []*models.AlarmProblemQuery) {
+	var alarmIdList []string
+	alarmMap := make(map[int]*models.AlarmProblemQuery)
+	for _, v := range alarmList {
+		if v.Id <= 0 || v.IsCustom {
+			continue
+		}
+		alarmIdList = append(alarmIdList, fmt.Sprintf("%d", v.Id))
+		alarmMap[v.Id] = v
+	}
+	if len(alarmIdList) == 0 {
+		return
+	}
+	filterSql, filterParam := createListParams(alarmIdList, "")
+	var logRows []*models.AlarmNotifyEscalateLogTable
+	if err := x.SQL("select * from alarm_notify_escalate_log where alarm_id in ("+filterSql+") order by id", filterParam...).Find(&logRows); err != nil {
+		log.Logger.Error("Query alarm notify escalate log fail", log.Error(err))
+		return
+	}
+	for _, v := range logRows {
+		v.CreateTimeStr = v.CreateTime.Format(models.DatetimeFormat)
+		if alarmObj, b := alarmMap[v.AlarmId]; b {
+			alarmObj.EscalateLogs = append(alarmObj.EscalateLogs, v)
+		}
+	}
+}
+
+func splitNotifyEscalateRoles(input string) (roles []string) {
+	roles = []string{}
+	for _, v := range strings.Split(input, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			roles = append(roles, v)
+		}
+	}
+	return
+}
+
+func escalateTimeValue(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
@@ -0,0 +1,299 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/WeBankPartners/go-common-lib/guid"
+	"github.com/WeBankPartners/open-monitor/monitor-server/common/cronexpr"
+	"github.com/WeBankPartners/open-monitor/monitor-server/middleware/log"
+	"github.com/WeBankPartners/open-monitor/monitor-server/models"
+	"regexp"
+	"strings"
+	"time"
+)
+
+func ListAlarmSilence(param *models.AlarmSilenceQueryParam) (result []*models.AlarmSilenceTable, err error) {
+	result = []*models.AlarmSilenceTable{}
+	var filterSqlList []string
+	var filterParams []interface{}
+	if param.Name != "" {
+		filterSqlList = append(filterSqlList, "name like ?")
+		filterParams = append(filterParams, fmt.Sprintf("%%%s%%", param.Name))
+	}
+	if param.ScopeType != "" {
+		filterSqlList = append(filterSqlList, "scope_type=?")
+		filterParams = append(filterParams, param.ScopeType)
+	}
+	if param.ScopeValue != "" {
+		filterSqlList = append(filterSqlList, "scope_value=?")
+		filterParams = append(filterParams, param.ScopeValue)
+	}
+	if param.Status != "" {
+		filterSqlList = append(filterSqlList, "status=?")
+		filterParams = append(filterParams, param.Status)
+	}
+	baseSql := "select * from alarm_silence"
+	if len(filterSqlList) > 0 {
+		baseSql += " where " + strings.Join(filterSqlList, " and ")
+	}
+	err = x.SQL(baseSql+" order by update_time desc", filterParams...).Find(&result)
+	if err != nil {
+		err = fmt.Errorf("query alarm silence table fail,%s ", err.Error())
+		return
+	}
+	for _, v := range result {
+		buildAlarmSilenceDisplay(v)
+	}
+	return
+}
+
+// ValidateAlarmSilence 校验并补全屏蔽规则
+func ValidateAlarmSilence(param *models.AlarmSilenceTable) (err error) {
+	param.Name = strings.TrimSpace(param.Name)
+	if param.ScopeType == "" {
+		param.ScopeType = models.SilenceScopeTag
+	}
+	switch param.ScopeType {
+	case models.SilenceScopeEndpoint, models.SilenceScopeEndpointGroup, models.SilenceScopeServiceGroup, models.SilenceScopeAlarmStrategy:
+		if param.ScopeValue == "" {
+			return fmt.Errorf("scope_value can not empty when scope_type is %s ", param.ScopeType)
+		}
+	case models.SilenceScopeTag:
+		param.ScopeValue = ""
+		if len(param.TagMatcherList) == 0 {
+			return fmt.Errorf("tag_matchers can not empty when scope_type is tag ")
+		}
+	default:
+		return fmt.Errorf("scope_type:%s illegal ", param.ScopeType)
+	}
+	for _, v := range param.TagMatcherList {
+		if v.Key == "" {
+			return fmt.Errorf("tag matcher key can not empty ")
+		}
+		if v.Operator == "" {
+			v.Operator = models.SilenceMatchEqual
+		}
+		switch v.Operator {
+		case models.SilenceMatchEqual, models.SilenceMatchNotEqual:
+		case models.SilenceMatchRegex, models.SilenceMatchNotRegex:
+			if _, regErr := regexp.Compile(v.Value); regErr != nil {
+				return fmt.Errorf("tag matcher value:%s regexp illegal,%s ", v.Value, regErr.Error())
+			}
+		default:
+			return fmt.Errorf("tag matcher operator:%s illegal ", v.Operator)
+		}
+	}
+	matcherBytes, _ := json.Marshal(param.TagMatcherList)
+	param.TagMatchers = string(matcherBytes)
+	if param.StartTime, err = time.ParseInLocation(models.DatetimeFormat, param.StartTimeStr, time.Local); err != nil {
+		return fmt.Errorf("start_time:%s illegal,%s ", param.StartTimeStr, err.Error())
+	}
+	if param.EndTime, err = time.ParseInLocation(models.DatetimeFormat, param.EndTimeStr, time.Local); err != nil {
+		return fmt.Errorf("end_time:%s illegal,%s ", param.EndTimeStr, err.Error())
+	}
+	if !param.EndTime.After(param.StartTime) {
+		return fmt.Errorf("end_time must after start_time ")
+	}
+	param.CronExpr = strings.TrimSpace(param.CronExpr)
+	if param.CronExpr != "" {
+		if _, err = cronexpr.Parse(param.CronExpr); err != nil {
+			return
+		}
+		if param.Duration <= 0 || param.Duration > models.SilenceMaxDurationMinute {
+			return fmt.Errorf("duration must between 1 and %d minutes when cron_expr set ", models.SilenceMaxDurationMinute)
+		}
+	} else {
+		param.Duration = 0
+	}
+	param.Status = models.SilenceStatusActive
+	if param.EndTime.Before(time.Now()) {
+		param.Status = models.SilenceStatusExpired
+	}
+	return
+}
+
+func CreateAlarmSilence(param *models.AlarmSilenceTable, operator string) (err error) {
+	nowTime := time.Now()
+	param.Guid = "silence_" + guid.CreateGuid()
+	_, err = x.Exec("insert into alarm_silence(guid,name,reason,scope_type,scope_value,tag_matchers,start_time,end_time,cron_expr,duration,status,create_user,update_user,create_time,update_time) value (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)",
+		param.Guid, param.Name, param.Reason, param.ScopeType, param.ScopeValue, param.TagMatchers, param.StartTime, param.EndTime, param.CronExpr, param.Duration, param.Status, operator, operator, nowTime, nowTime)
+	if err != nil {
+		err = fmt.Errorf("insert alarm silence fail,%s ", err.Error())
+	}
+	return
+}
+
+func UpdateAlarmSilence(param *models.AlarmSilenceTable, operator string) (err error) {
+	execResult, execErr := x.Exec("update alarm_silence set name=?,reason=?,scope_type=?,scope_value=?,tag_matchers=?,start_time=?,end_time=?,cron_expr=?,duration=?,status=?,update_user=?,update_time=? where guid=?",
+		param.Name, param.Reason, param.ScopeType, param.ScopeValue, param.TagMatchers, param.StartTime, param.EndTime, param.CronExpr, param.Duration, param.Status, operator, time.Now(), param.Guid)
+	if execErr != nil {
+		return fmt.Errorf("update alarm silence fail,%s ", execErr.Error())
+	}
+	if affectNum, _ := execResult.RowsAffected(); affectNum == 0 {
+		err = fmt.Errorf("can not find alarm silence with guid:%s ", param.Guid)
+	}
+	return
+}
+
+func DeleteAlarmSilence(silenceGuid string) (err error) {
+	_, err = x.Exec("delete from alarm_silence where guid=?", silenceGuid)
+	if err != nil {
+		err = fmt.Errorf("delete alarm silence fail,%s ", err.Error())
+	}
+	return
+}
+
+// StartAlarmSilenceExpireCronJob 把过了结束时间的屏蔽规则置为过期
+func StartAlarmSilenceExpireCronJob() {
+	t := time.NewTicker(time.Minute).C
+	for {
+		<-t
+		nowTime := time.Now()
+		execResult, err := x.Exec("update alarm_silence set status=?,update_time=? where status=? and end_time<?", models.SilenceStatusExpired, nowTime, models.SilenceStatusActive, nowTime)
+		if err != nil {
+			log.Logger.Error("Expire alarm silence fail", log.Error(err))
+			continue
+		}
+		if affectNum, _ := execResult.RowsAffected(); affectNum > 0 {
+			log.Logger.Info("Expire alarm silence", log.Int("num", int(affectNum)))
+		}
+	}
+}
+
+// checkAlarmSilence 判断告警是否被屏蔽,firing告警命中屏蔽时记录到告警上,恢复告警跟随firing时的屏蔽结果
+func checkAlarmSilence(alarmObj *models.AlarmHandleObj) bool {
+	if alarmObj.Id <= 0 {
+		return false
+	}
+	if alarmObj.Status != "firing" {
+		queryRows, err := x.QueryString("select silence from alarm where id=?", alarmObj.Id)
+		if err != nil || len(queryRows) == 0 {
+			return false
+		}
+		return queryRows[0]["silence"] != ""
+	}
+	silenceRow := matchAlarmSilence(&alarmObj.AlarmTable, time.Now())
+	if silenceRow == nil {
+		return false
+	}
+	alarmObj.Silence = silenceRow.Guid
+	if _, err := x.Exec("update alarm set silence=? where id=?", silenceRow.Guid, alarmObj.Id); err != nil {
+		log.Logger.Error("Update alarm silence fail", log.Int("alarmId", alarmObj.Id), log.Error(err))
+	}
+	log.Logger.Info("Alarm match silence,ignore notify", log.Int("alarmId", alarmObj.Id), log.String("silence", silenceRow.Guid))
+	return true
+}
+
+// matchAlarmSilence 找到第一条在当前时间生效并且命中告警的屏蔽规则
+func matchAlarmSilence(alarmRow *models.AlarmTable, nowTime time.Time) *models.AlarmSilenceTable {
+	var silenceRows []*models.AlarmSilenceTable
+	err := x.SQL("select * from alarm_silence where status=? and start_time<=? and end_time>=?", models.SilenceStatusActive, nowTime, nowTime).Find(&silenceRows)
+	if err != nil {
+		log.Logger.Error("Query active alarm silence fail", log.Error(err))
+		return nil
+	}
+	if len(silenceRows) == 0 {
+		return nil
+	}
+	tagMap := buildAlarmMatchTagMap(alarmRow)
+	for _, v := range silenceRows {
+		if !isAlarmSilenceWindowActive(v, nowTime) {
+			continue
+		}
+		if !matchAlarmSilenceScope(v, alarmRow) {
+			continue
+		}
+		if v.TagMatchers != "" {
+			if err = json.Unmarshal([]byte(v.TagMatchers), &v.TagMatcherList); err != nil {
+				log.Logger.Error("Alarm silence tag matchers illegal", log.String("silence", v.Guid), log.Error(err))
+				continue
+			}
+		}
+		if matchAlarmSilenceTags(v.TagMatcherList, tagMap) {
+			return v
+		}
+	}
+	return nil
+}
+
+func isAlarmSilenceWindowActive(silence *models.AlarmSilenceTable, nowTime time.Time) bool {
+	if silence.CronExpr == "" {
+		return true
+	}
+	schedule, err := cronexpr.Parse(silence.CronExpr)
+	if err != nil {
+		log.Logger.Error("Alarm silence cron expr illegal", log.String("silence", silence.Guid), log.Error(err))
+		return false
+	}
+	_, matched := schedule.LastMatchWithin(nowTime, time.Duration(silence.Duration)*time.Minute)
+	return matched
+}
+
+func matchAlarmSilenceScope(silence *models.AlarmSilenceTable, alarmRow *models.AlarmTable) bool {
+	switch silence.ScopeType {
+	case models.SilenceScopeEndpoint:
+		return alarmRow.Endpoint == silence.ScopeValue
+	case models.SilenceScopeAlarmStrategy:
+		return alarmRow.AlarmStrategy == silence.ScopeValue
+	case models.SilenceScopeEndpointGroup:
+		if alarmRow.Endpoint == "eg__"+silence.ScopeValue {
+			return true
+		}
+		queryRows, _ := x.QueryString("select endpoint from endpoint_group_rel where endpoint_group=? and endpoint=? union select guid from alarm_strategy where endpoint_group=? and guid=?", silence.ScopeValue, alarmRow.Endpoint, silence.ScopeValue, alarmRow.AlarmStrategy)
+		return len(queryRows) > 0
+	case models.SilenceScopeServiceGroup:
+		if alarmRow.Endpoint == "sg__"+silence.ScopeValue {
+			return true
+		}
+		for _, v := range getAlarmAffectServiceGroupList(&models.AlarmHandleObj{AlarmTable: *alarmRow}) {
+			if v == silence.ScopeValue {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// buildAlarmMatchTagMap 告警标签加上endpoint/metric/priority/alarm_name,用于标签匹配
+func buildAlarmMatchTagMap(alarmRow *models.AlarmTable) map[string]string {
+	tagMap := convertAlarmTagsToMap(alarmRow.Tags)
+	tagMap["endpoint"] = alarmRow.Endpoint
+	tagMap["metric"] = alarmRow.SMetric
+	tagMap["priority"] = alarmRow.SPriority
+	tagMap["alarm_name"] = alarmRow.AlarmName
+	return tagMap
+}
+
+func matchAlarmSilenceTags(matchers []*models.AlarmSilenceMatcher, tagMap map[string]string) bool {
+	for _, v := range matchers {
+		tagValue := tagMap[v.Key]
+		switch v.Operator {
+		case models.SilenceMatchNotEqual:
+			if tagValue == v.Value {
+				return false
+			}
+		case models.SilenceMatchRegex, models.SilenceMatchNotRegex:
+			matched, err := regexp.MatchString("^(?:"+v.Value+")$", tagValue)
+			if err != nil || matched != (v.Operator == models.SilenceMatchRegex) {
+				return false
+			}
+		default:
+			if tagValue != v.Value {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func buildAlarmSilenceDisplay(silence *models.AlarmSilenceTable) {
+	silence.TagMatcherList = []*models.AlarmSilenceMatcher{}
+	if silence.TagMatchers != "" {
+		json.Unmarshal([]byte(silence.TagMatchers), &silence.TagMatcherList)
+	}
+	silence.StartTimeStr = silence.StartTime.Format(models.DatetimeFormat)
+	silence.EndTimeStr = silence.EndTime.Format(models.DatetimeFormat)
+	silence.CreateTimeStr = silence.CreateTime.Format(models.DatetimeFormat)
+	silence.UpdateTimeStr = silence.UpdateTime.Format(models.DatetimeFormat)
+}
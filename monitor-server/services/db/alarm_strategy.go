@@ -282,8 +282,8 @@ func getNotifyList(alarmStrategy, endpointGroup, serviceGroup string) (result []
 			okNotify = v
 		}
 	}
-	result = append(result, &models.NotifyObj{Guid: firingNotify.Guid, NotifyRoles: getNotifyRoles(firingNotify.Guid), EndpointGroup: firingNotify.EndpointGroup, ServiceGroup: firingNotify.ServiceGroup, AlarmStrategy: firingNotify.AlarmStrategy, AlarmAction: firingNotify.AlarmAction, AlarmPriority: firingNotify.AlarmPriority, NotifyNum: firingNotify.NotifyNum, ProcCallbackName: firingNotify.ProcCallbackName, ProcCallbackKey: firingNotify.ProcCallbackKey, CallbackUrl: firingNotify.CallbackUrl, CallbackParam: firingNotify.CallbackParam, ProcCallbackMode: firingNotify.ProcCallbackMode, Description: firingNotify.Description})
-	result = append(result, &models.NotifyObj{Guid: okNotify.Guid, NotifyRoles: getNotifyRoles(okNotify.Guid), EndpointGroup: okNotify.EndpointGroup, ServiceGroup: okNotify.ServiceGroup, AlarmStrategy: okNotify.AlarmStrategy, AlarmAction: okNotify.AlarmAction, AlarmPriority: okNotify.AlarmPriority, NotifyNum: okNotify.NotifyNum, ProcCallbackName: okNotify.ProcCallbackName, ProcCallbackKey: okNotify.ProcCallbackKey, CallbackUrl: okNotify.CallbackUrl, CallbackParam: okNotify.CallbackParam, ProcCallbackMode: okNotify.ProcCallbackMode, Description: okNotify.Description})
+	result = append(result, &models.NotifyObj{Guid: firingNotify.Guid, NotifyRoles: getNotifyRoles(firingNotify.Guid), EndpointGroup: firingNotify.EndpointGroup, ServiceGroup: firingNotify.ServiceGroup, AlarmStrategy: firingNotify.AlarmStrategy, AlarmAction: firingNotify.AlarmAction, AlarmPriority: firingNotify.AlarmPriority, NotifyNum: firingNotify.NotifyNum, ProcCallbackName: firingNotify.ProcCallbackName, ProcCallbackKey: firingNotify.ProcCallbackKey, CallbackUrl: firingNotify.CallbackUrl, CallbackParam: firingNotify.CallbackParam, ProcCallbackMode: firingNotify.ProcCallbackMode, Description: firingNotify.Description, NotifyInterval: firingNotify.NotifyInterval, EscalateDelay: firingNotify.EscalateDelay, EscalateRoles: splitNotifyEscalateRoles(firingNotify.EscalateRoles)})
+	result = append(result, &models.NotifyObj{Guid: okNotify.Guid, NotifyRoles: getNotifyRoles(okNotify.Guid), EndpointGroup: okNotify.EndpointGroup, ServiceGroup: okNotify.ServiceGroup, AlarmStrategy: okNotify.AlarmStrategy, AlarmAction: okNotify.AlarmAction, AlarmPriority: okNotify.AlarmPriority, NotifyNum: okNotify.NotifyNum, ProcCallbackName: okNotify.ProcCallbackName, ProcCallbackKey: okNotify.ProcCallbackKey, CallbackUrl: okNotify.CallbackUrl, CallbackParam: okNotify.CallbackParam, ProcCallbackMode: okNotify.ProcCallbackMode, Description: okNotify.Description, NotifyInterval: okNotify.NotifyInterval, EscalateDelay: okNotify.EscalateDelay, EscalateRoles: splitNotifyEscalateRoles(okNotify.EscalateRoles)})
 	return result
 }
 
@@ -331,11 +331,11 @@ func getNotifyListInsertAction(notifyList []*models.NotifyObj) (actions []*Actio
 		v.Guid = "notify_" + notifyGuidList[i]
 		tmpAction := Action{}
 		if refColumn != "" {
-			tmpAction = Action{Sql: fmt.Sprintf("insert into notify(guid,%s,alarm_action,alarm_priority,notify_num,proc_callback_name,proc_callback_key,callback_url,callback_param,proc_callback_mode,description) value (?,'%s',?,?,?,?,?,?,?,?,?)", refColumn, refValue)}
+			tmpAction = Action{Sql: fmt.Sprintf("insert into notify(guid,%s,alarm_action,alarm_priority,notify_num,proc_callback_name,proc_callback_key,callback_url,callback_param,proc_callback_mode,description,notify_interval,escalate_delay,escalate_roles) value (?,'%s',?,?,?,?,?,?,?,?,?,?,?,?)", refColumn, refValue)}
 		} else {
-			tmpAction = Action{Sql: "insert into notify(guid,alarm_action,alarm_priority,notify_num,proc_callback_name,proc_callback_key,callback_url,callback_param,proc_callback_mode,description) value (?,?,?,?,?,?,?,?,?,?)"}
+			tmpAction = Action{Sql: "insert into notify(guid,alarm_action,alarm_priority,notify_num,proc_callback_name,proc_callback_key,callback_url,callback_param,proc_callback_mode,description,notify_interval,escalate_delay,escalate_roles) value (?,?,?,?,?,?,?,?,?,?,?,?,?)"}
 		}
-		tmpAction.Param = []interface{}{v.Guid, v.AlarmAction, v.AlarmPriority, v.NotifyNum, v.ProcCallbackName, v.ProcCallbackKey, v.CallbackUrl, v.CallbackParam, v.ProcCallbackMode, v.Description}
+		tmpAction.Param = []interface{}{v.Guid, v.AlarmAction, v.AlarmPriority, v.NotifyNum, v.ProcCallbackName, v.ProcCallbackKey, v.CallbackUrl, v.CallbackParam, v.ProcCallbackMode, v.Description, v.NotifyInterval, v.EscalateDelay, strings.Join(v.EscalateRoles, ",")}
 		actions = append(actions, &tmpAction)
 		if len(v.NotifyRoles) > 0 {
 			tmpNotifyRoleGuidList := guid.CreateGuidList(len(v.NotifyRoles))
@@ -366,19 +366,19 @@ func getNotifyListUpdateAction(notifyList []*models.NotifyObj) (actions []*Actio
 			v.NotifyNum = 1
 		}
 		if v.Guid != "" {
-			tmpAction := Action{Sql: fmt.Sprintf("update notify set alarm_action=?,notify_num=?,proc_callback_name=?,proc_callback_key=?,callback_url=?,callback_param=?,proc_callback_mode=?,description=? where guid=?")}
-			tmpAction.Param = []interface{}{v.AlarmAction, v.NotifyNum, v.ProcCallbackName, v.ProcCallbackKey, v.CallbackUrl, v.CallbackParam, v.ProcCallbackMode, v.Description, v.Guid}
+			tmpAction := Action{Sql: fmt.Sprintf("update notify set alarm_action=?,notify_num=?,proc_callback_name=?,proc_callback_key=?,callback_url=?,callback_param=?,proc_callback_mode=?,description=?,notify_interval=?,escalate_delay=?,escalate_roles=? where guid=?")}
+			tmpAction.Param = []interface{}{v.AlarmAction, v.NotifyNum, v.ProcCallbackName, v.ProcCallbackKey, v.CallbackUrl, v.CallbackParam, v.ProcCallbackMode, v.Description, v.NotifyInterval, v.EscalateDelay, strings.Join(v.EscalateRoles, ","), v.Guid}
 			actions = append(actions, &tmpAction)
 			actions = append(actions, &Action{Sql: "delete from notify_role_rel where notify=?", Param: []interface{}{v.Guid}})
 		} else {
 			v.Guid = "notify_" + notifyGuidList[i]
 			tmpAction := Action{}
 			if refColumn != "" {
-				tmpAction = Action{Sql: fmt.Sprintf("insert into notify(guid,%s,alarm_action,alarm_priority,notify_num,proc_callback_name,proc_callback_key,callback_url,callback_param,proc_callback_mode,description) value (?,'%s',?,?,?,?,?,?,?,?,?)", refColumn, refValue)}
+				tmpAction = Action{Sql: fmt.Sprintf("insert into notify(guid,%s,alarm_action,alarm_priority,notify_num,proc_callback_name,proc_callback_key,callback_url,callback_param,proc_callback_mode,description,notify_interval,escalate_delay,escalate_roles) value (?,'%s',?,?,?,?,?,?,?,?,?,?,?,?)", refColumn, refValue)}
 			} else {
-				tmpAction = Action{Sql: "insert into notify(guid,alarm_action,alarm_priority,notify_num,proc_callback_name,proc_callback_key,callback_url,callback_param,proc_callback_mode,description) value (?,?,?,?,?,?,?,?,?,?)"}
+				tmpAction = Action{Sql: "insert into notify(guid,alarm_action,alarm_priority,notify_num,proc_callback_name,proc_callback_key,callback_url,callback_param,proc_callback_mode,description,notify_interval,escalate_delay,escalate_roles) value (?,?,?,?,?,?,?,?,?,?,?,?,?)"}
 			}
-			tmpAction.Param = []interface{}{v.Guid, v.AlarmAction, v.AlarmPriority, v.NotifyNum, v.ProcCallbackName, v.ProcCallbackKey, v.CallbackUrl, v.CallbackParam, v.ProcCallbackMode, v.Description}
+			tmpAction.Param = []interface{}{v.Guid, v.AlarmAction, v.AlarmPriority, v.NotifyNum, v.ProcCallbackName, v.ProcCallbackKey, v.CallbackUrl, v.CallbackParam, v.ProcCallbackMode, v.Description, v.NotifyInterval, v.EscalateDelay, strings.Join(v.EscalateRoles, ",")}
 			actions = append(actions, &tmpAction)
 		}
 		if len(v.NotifyRoles) > 0 {
@@ -483,6 +483,10 @@ func getStrategyConditionInsertAction(alarmStrategyGuid string, conditions []*mo
 		if _, ok := monitorEngineMetricMap[metricRow.Metric]; ok {
 			monitorEngineFlag = 1
 		}
+		// 动态基线需要查历史数据计算,只能由监控引擎处理
+		if conditionObj, parseErr := models.ParseAlarmCondition(metricRow.Condition); parseErr == nil && conditionObj.Type == models.AlarmConditionAnomaly {
+			monitorEngineFlag = 1
+		}
 		actions = append(actions, &Action{Sql: "insert into alarm_strategy_metric(guid,alarm_strategy,metric,`condition`,`last`,create_time,crc_hash,monitor_engine,log_type) values (?,?,?,?,?,?,?,?,?)", Param: []interface{}{
 			metricGuidList[i], alarmStrategyGuid, metricRow.Metric, metricRow.Condition, metricRow.Last, nowTime, tmpCrcHash, monitorEngineFlag, metricRow.LogType,
 		}})
@@ -735,19 +739,17 @@ func buildRuleFileContentNew(ruleFileName, guidExpr, addressExpr, ipExpr string,
 		} else {
 			tmpRfu.Alert = fmt.Sprintf("%s_%s", strategy.Metric, strategy.Guid)
 		}
-		if !strings.Contains(strategy.Condition, " ") && strategy.Condition != "" {
-			if strings.Contains(strategy.Condition, "=") {
-				strategy.Condition = strategy.Condition[:2] + " " + strategy.Condition[2:]
-			} else {
-				strategy.Condition = strategy.Condition[:1] + " " + strategy.Condition[1:]
-			}
+		conditionObj, parseErr := models.ParseAlarmCondition(strategy.Condition)
+		if parseErr != nil {
+			log.Logger.Warn("strategy condition illegal", log.String("alertId", tmpRfu.Alert), log.Error(parseErr))
+			continue
 		}
 		buildStrategyAlarmRuleExpr(guidExpr, addressExpr, ipExpr, strategy)
 		if strategy.MetricExpr == "" {
 			log.Logger.Warn("metric expr empty", log.String("alertId", tmpRfu.Alert))
 			continue
 		}
-		tmpRfu.Expr = fmt.Sprintf("(%s) %s", strategy.MetricExpr, strategy.Condition)
+		tmpRfu.Expr = conditionObj.BuildRuleExpr(strategy.MetricExpr, strategy.Last)
 		tmpRfu.For = strategy.Last
 		tmpRfu.Labels = make(map[string]string)
 		tmpRfu.Labels["strategy_guid"] = strategy.Guid
@@ -888,6 +890,13 @@ func NotifyStrategyAlarm(alarmObj *models.AlarmHandleObj) {
 		log.Logger.Error("Notify strategy alarm fail,alarmStrategy is empty", log.JsonObj("alarm", alarmObj))
 		return
 	}
+	if checkAlarmSilence(alarmObj) {
+		return
+	}
+	if checkAlarmInhibit(alarmObj) {
+		log.Logger.Info("Alarm is inhibited,ignore notify", log.Int("alarmId", alarmObj.Id), log.Int("inhibitBy", alarmObj.InhibitBy))
+		return
+	}
 	// 延迟发送通知，在延迟时间内如果告警恢复，则不发送通知，避免那种频繁告警恢复的场景
 	if alarmObj.NotifyDelay > 0 {
 		if alarmObj.Status == "firing" {
@@ -921,7 +930,7 @@ func NotifyStrategyAlarm(alarmObj *models.AlarmHandleObj) {
 	}
 	for _, v := range notifyQueryRows {
 		notifyRoles := getNotifyRoles(v.Guid)
-		if len(notifyRoles) == 0 && v.ProcCallbackKey == "" {
+		if len(notifyRoles) == 0 && v.ProcCallbackKey == "" && v.CallbackUrl == "" {
 			continue
 		}
 		notifyObject = v
@@ -929,20 +938,9 @@ func NotifyStrategyAlarm(alarmObj *models.AlarmHandleObj) {
 	}
 	// 2.如果没有再去找策略所属endpoint_group组的策略(就是界面上阈值配置给某类对象组某种对象配的接收人设置)
 	if notifyObject.Guid == "" {
-		var affectServiceGroupList []string
-		var serviceGroup []*models.EndpointServiceRelTable
-		queryErr := x.SQL("select distinct service_group from endpoint_service_rel where endpoint=?", alarmObj.Endpoint).Find(&serviceGroup)
-		if queryErr != nil {
-			log.Logger.Error("NotifyStrategyAlarm query endpoint service rel fail", log.Int("alarmId", alarmObj.Id), log.Error(queryErr))
-		}
-		for _, v := range serviceGroup {
-			tmpGuidList, _ := fetchGlobalServiceGroupParentGuidList(v.ServiceGroup)
-			for _, vv := range tmpGuidList {
-				affectServiceGroupList = append(affectServiceGroupList, vv)
-			}
-		}
+		affectServiceGroupList := getAlarmAffectServiceGroupList(alarmObj)
 		var tmpNotifyQueryRows []*models.NotifyTable
-		queryErr = x.SQL("select * from notify where alarm_action=? and endpoint_group in (select endpoint_group from alarm_strategy where guid=?)", alarmObj.Status, alarmObj.AlarmStrategy).Find(&tmpNotifyQueryRows)
+		queryErr := x.SQL("select * from notify where alarm_action=? and endpoint_group in (select endpoint_group from alarm_strategy where guid=?)", alarmObj.Status, alarmObj.AlarmStrategy).Find(&tmpNotifyQueryRows)
 		if queryErr != nil {
 			log.Logger.Error("NotifyStrategyAlarm query alarm notify fail", log.Int("alarmId", alarmObj.Id), log.String("alarmStrategy", alarmObj.AlarmStrategy), log.Error(queryErr))
 		} else {
@@ -964,7 +962,34 @@ func NotifyStrategyAlarm(alarmObj *models.AlarmHandleObj) {
 			}
 		}
 	}
+	// 开启事件聚合时,同组告警合并成一条事件通知
+	if models.Config().AlarmIncident.Enable && handleAlarmIncident(notifyObject, alarmObj) {
+		// 事件只由最先加入的告警做重复通知和升级,避免同组告警各自升级
+		if alarmObj.Status == "firing" && isIncidentRepresentative(alarmObj.Id) {
+			startAlarmNotifyEscalate(notifyObject, alarmObj)
+		}
+		return
+	}
 	notifyAction(notifyObject, alarmObj)
+	if alarmObj.Status == "firing" {
+		startAlarmNotifyEscalate(notifyObject, alarmObj)
+	}
+}
+
+// getAlarmAffectServiceGroupList 告警对象所属的层级对象及其所有上级
+func getAlarmAffectServiceGroupList(alarmObj *models.AlarmHandleObj) (affectServiceGroupList []string) {
+	var serviceGroup []*models.EndpointServiceRelTable
+	queryErr := x.SQL("select distinct service_group from endpoint_service_rel where endpoint=?", alarmObj.Endpoint).Find(&serviceGroup)
+	if queryErr != nil {
+		log.Logger.Error("NotifyStrategyAlarm query endpoint service rel fail", log.Int("alarmId", alarmObj.Id), log.Error(queryErr))
+	}
+	for _, v := range serviceGroup {
+		tmpGuidList, _ := fetchGlobalServiceGroupParentGuidList(v.ServiceGroup)
+		for _, vv := range tmpGuidList {
+			affectServiceGroupList = append(affectServiceGroupList, vv)
+		}
+	}
+	return
 }
 
 func notifyAction(notify *models.NotifyTable, alarmObj *models.AlarmHandleObj) {
@@ -977,6 +1002,11 @@ func notifyAction(notify *models.NotifyTable, alarmObj *models.AlarmHandleObj) {
 			log.Logger.Error("Notify mail fail", log.String("notifyGuid", notify.Guid), log.Error(mailErr))
 		}
 	}
+	if notify.CallbackUrl != "" {
+		if webhookErr := notifyWebhookAction(notify, alarmObj); webhookErr != nil {
+			log.Logger.Error("Notify webhook fail", log.String("notifyGuid", notify.Guid), log.Error(webhookErr))
+		}
+	}
 	if notify.ProcCallbackMode != models.AlarmNotifyAutoMode {
 		log.Logger.Info("notify proc callback mode is not auto,done", log.Int("alarmId", alarmObj.Id), log.String("notifyId", notify.Guid), log.String("mode", notify.ProcCallbackMode))
 		return
@@ -1089,7 +1119,7 @@ func getNotifyEventMessage(notifyGuid string, alarm models.AlarmTable) (result m
 	}
 	alarmObj := models.AlarmHandleObj{AlarmTable: alarm}
 	alarmObj.AlarmDetail = buildAlarmDetailData(alarmDetailList, "\r\n")
-	result.Subject, result.Content = getNotifyMessage(&alarmObj)
+	result.Subject, result.Content, _ = getNotifyMessage(models.NotifyChannelEvent, &alarmObj)
 	var roles []*models.RoleNewTable
 	if notifyObj.ServiceGroup != "" {
 		x.SQL("select guid,email from role_new where guid in (select `role` from service_group_role_rel where service_group=?)", notifyObj.ServiceGroup).Find(&roles)
@@ -1130,10 +1160,40 @@ func getNotifyEventMessage(notifyGuid string, alarm models.AlarmTable) (result m
 }
 
 func notifyMailAction(notify *models.NotifyTable, alarmObj *models.AlarmHandleObj) error {
+	toAddress := getNotifyMailAddress(notify, alarmObj.Id)
+	if len(toAddress) == 0 {
+		return nil
+	}
+	mailSender, err := newAlarmMailSender()
+	if err != nil {
+		return err
+	}
+	alarmDetailList := []*models.AlarmDetailData{}
+	if strings.HasPrefix(alarmObj.EndpointTags, "ac_") {
+		alarmDetailList, err = GetAlarmDetailList(alarmObj.Id)
+		if err != nil {
+			return err
+		}
+	} else {
+		alarmDetailList = append(alarmDetailList, &models.AlarmDetailData{Metric: alarmObj.SMetric, Cond: alarmObj.SCond, Last: alarmObj.SLast, Start: alarmObj.Start, StartValue: alarmObj.StartValue, End: alarmObj.End, EndValue: alarmObj.EndValue, Tags: alarmObj.Tags})
+	}
+	alarmObj.AlarmDetail = buildAlarmDetailData(alarmDetailList, "\r\n")
+	subject, content, contentType := getNotifyMessage(models.NotifyChannelMail, alarmObj)
+	if contentType == models.NotifyContentTypeHtml {
+		mailSender.ContentType = smtp.ContentTypeHtml
+	}
+	return mailSender.Send(subject, content, toAddress)
+}
+
+// getNotifyMailAddress 按通知配置找接收人邮箱,找不到时用全局默认接收人
+func getNotifyMailAddress(notify *models.NotifyTable, alarmId int) (toAddress []string) {
 	var roles []*models.RoleNewTable
-	var toAddress, roleList, tmpToAddress []string
+	var roleList, tmpToAddress []string
 	var queryRoleErr error
-	if notify.ServiceGroup != "" {
+	if len(notify.MailRoles) > 0 {
+		roleFilterSql, roleFilterParam := createListParams(notify.MailRoles, "")
+		queryRoleErr = x.SQL("select guid,email from `role_new` where guid in ("+roleFilterSql+")", roleFilterParam...).Find(&roles)
+	} else if notify.ServiceGroup != "" {
 		queryRoleErr = x.SQL("select guid,email from role_new where guid in (select `role` from service_group_role_rel where service_group=?)", notify.ServiceGroup).Find(&roles)
 	} else {
 		if len(notify.AffectServiceGroup) > 0 {
@@ -1143,7 +1203,7 @@ func notifyMailAction(notify *models.NotifyTable, alarmObj *models.AlarmHandleOb
 		}
 	}
 	if queryRoleErr != nil {
-		log.Logger.Error("notifyMailAction query role fail", log.Int("alarmId", alarmObj.Id), log.Error(queryRoleErr))
+		log.Logger.Error("notifyMailAction query role fail", log.Int("alarmId", alarmId), log.Error(queryRoleErr))
 	}
 	// 先拿自己角色表的邮箱，独立运行的情况下有用
 	for _, v := range roles {
@@ -1165,7 +1225,7 @@ func notifyMailAction(notify *models.NotifyTable, alarmObj *models.AlarmHandleOb
 	}
 	if len(toAddress) == 0 {
 		log.Logger.Warn("notifyMailAction toAddress empty", log.String("notify", notify.Guid), log.StringList("roleList", roleList))
-		return nil
+		return
 	}
 	for _, v := range toAddress {
 		for _, vv := range strings.Split(v, ",") {
@@ -1175,11 +1235,15 @@ func notifyMailAction(notify *models.NotifyTable, alarmObj *models.AlarmHandleOb
 		}
 	}
 	toAddress = tmpToAddress
+	return
+}
+
+func newAlarmMailSender() (mailSender smtp.MailSender, err error) {
 	mailConfig, err := GetSysAlertMailConfig()
 	if err != nil {
-		return err
+		return
 	}
-	mailSender := smtp.MailSender{SenderName: mailConfig.SenderName, SenderMail: mailConfig.SenderMail, AuthServer: mailConfig.AuthServer, AuthPassword: mailConfig.AuthPassword, AuthUser: mailConfig.AuthUser}
+	mailSender = smtp.MailSender{SenderName: mailConfig.SenderName, SenderMail: mailConfig.SenderMail, AuthServer: mailConfig.AuthServer, AuthPassword: mailConfig.AuthPassword, AuthUser: mailConfig.AuthUser}
 	mailConfig.SSL = strings.ToLower(mailConfig.SSL)
 	if mailConfig.SSL == "y" {
 		mailSender.SSL = true
@@ -1188,24 +1252,19 @@ func notifyMailAction(notify *models.NotifyTable, alarmObj *models.AlarmHandleOb
 		mailSender.ByStartTLS = true
 	}
 	err = mailSender.Init()
-	if err != nil {
-		return err
-	}
-	alarmDetailList := []*models.AlarmDetailData{}
-	if strings.HasPrefix(alarmObj.EndpointTags, "ac_") {
-		alarmDetailList, err = GetAlarmDetailList(alarmObj.Id)
-		if err != nil {
-			return err
-		}
-	} else {
-		alarmDetailList = append(alarmDetailList, &models.AlarmDetailData{Metric: alarmObj.SMetric, Cond: alarmObj.SCond, Last: alarmObj.SLast, Start: alarmObj.Start, StartValue: alarmObj.StartValue, End: alarmObj.End, EndValue: alarmObj.EndValue, Tags: alarmObj.Tags})
-	}
-	alarmObj.AlarmDetail = buildAlarmDetailData(alarmDetailList, "\r\n")
-	subject, content := getNotifyMessage(alarmObj)
-	return mailSender.Send(subject, content, toAddress)
+	return
 }
 
-func getNotifyMessage(alarmObj *models.AlarmHandleObj) (subject, content string) {
+// getNotifyMessage 优先使用匹配到的通知模版渲染,没有模版或者渲染失败时使用默认格式
+func getNotifyMessage(channel string, alarmObj *models.AlarmHandleObj) (subject, content, contentType string) {
+	contentType = models.NotifyContentTypeText
+	if notifyTemplate := getMatchNotifyTemplate(channel, alarmObj); notifyTemplate != nil {
+		renderResult, renderErr := renderNotifyTemplate(notifyTemplate, buildNotifyTemplateData(alarmObj))
+		if renderErr == nil {
+			return renderResult.Subject, renderResult.Content, renderResult.ContentType
+		}
+		log.Logger.Error("render notify template fail,use default message", log.String("template", notifyTemplate.Guid), log.Int("alarmId", alarmObj.Id), log.Error(renderErr))
+	}
 	subject = fmt.Sprintf("[%s][%s] Endpoint:%s Metric:%s", alarmObj.Status, alarmObj.SPriority, alarmObj.Endpoint, alarmObj.SMetric)
 	if strings.HasPrefix(alarmObj.EndpointTags, "ac_") {
 		content = fmt.Sprintf("Endpoint:%s \r\nStatus:%s\r\nMetric:%s\r\nPriority:%s\r\nNote:%s\r\nTime:%s\r\nDetail:\r\n%s", alarmObj.Endpoint, alarmObj.Status, alarmObj.SMetric, alarmObj.SPriority, alarmObj.Content, time.Now().Format(models.DatetimeFormat), alarmObj.AlarmDetail)
@@ -1511,6 +1570,41 @@ func GetMonitorEngineStrategy() (alarmStrategyMetricRows []*models.AlarmStrategy
 	return
 }
 
+// GetAlarmStrategyAnomalyConditions 告警策略里的动态基线条件,key为指标名
+func GetAlarmStrategyAnomalyConditions(alarmStrategyGuid string) (result map[string]*models.AlarmConditionObj, err error) {
+	result = make(map[string]*models.AlarmConditionObj)
+	queryRows, queryErr := x.QueryString("select t2.metric,t1.`condition` from alarm_strategy_metric t1 left join metric t2 on t1.metric=t2.guid where t1.alarm_strategy=?", alarmStrategyGuid)
+	if queryErr != nil {
+		err = fmt.Errorf("query alarm strategy metric fail,%s ", queryErr.Error())
+		return
+	}
+	for _, row := range queryRows {
+		if conditionObj, parseErr := models.ParseAlarmCondition(row["condition"]); parseErr == nil && conditionObj.Type == models.AlarmConditionAnomaly {
+			result[row["metric"]] = conditionObj
+		}
+	}
+	return
+}
+
+// GetEndpointAnomalyConditions 对象所在组(含层级对象下的组)告警策略里的动态基线条件,key为指标名,同一指标有多个策略时取第一个
+func GetEndpointAnomalyConditions(endpoint string) (result map[string]*models.AlarmConditionObj, err error) {
+	result = make(map[string]*models.AlarmConditionObj)
+	queryRows, queryErr := x.QueryString("select t2.metric,t1.`condition` from alarm_strategy_metric t1 left join metric t2 on t1.metric=t2.guid where t1.alarm_strategy in (select guid from alarm_strategy where endpoint_group in (select guid from endpoint_group where monitor_type in (select monitor_type from endpoint_new where guid=?) and (guid in (select endpoint_group from endpoint_group_rel where endpoint=?) or service_group in (select service_group from endpoint_service_rel where endpoint=?)))) order by t1.alarm_strategy", endpoint, endpoint, endpoint)
+	if queryErr != nil {
+		err = fmt.Errorf("query endpoint alarm strategy metric fail,%s ", queryErr.Error())
+		return
+	}
+	for _, row := range queryRows {
+		if _, b := result[row["metric"]]; b {
+			continue
+		}
+		if conditionObj, parseErr := models.ParseAlarmCondition(row["condition"]); parseErr == nil && conditionObj.Type == models.AlarmConditionAnomaly {
+			result[row["metric"]] = conditionObj
+		}
+	}
+	return
+}
+
 func GetMonitorEngineMetricMap() (metricMap map[string]int, err error) {
 	metricMap = make(map[string]int)
 	queryRows, queryErr := x.QueryString("select guid from metric where db_metric_monitor<>'' union select metric_id as `guid` from metric_comparison")
@@ -1531,14 +1625,44 @@ func UpdateAlarmStrategyMetricExpr(alarmStrategyMetricObj *models.AlarmStrategyM
 	}
 }
 
-func GetMonitorEngineAlarmList() (alarmList []*models.AlarmTable, err error) {
-	err = x.SQL("select id,endpoint,status,s_metric,tags,alarm_strategy from alarm where status='firing' and alarm_strategy in (select alarm_strategy from alarm_strategy_metric where monitor_engine=1) order by id desc").Find(&alarmList)
+// GetMonitorEngineAlarmList 监控引擎产生的未恢复告警,按策略、指标名找回所属阈值行的crc
+func GetMonitorEngineAlarmList() (alarmList []*models.AlarmHandleObj, err error) {
+	var alarmRows []*models.AlarmTable
+	err = x.SQL("select id,endpoint,status,s_metric,s_cond,s_last,tags,alarm_strategy from alarm where status='firing' and alarm_strategy in (select alarm_strategy from alarm_strategy_metric where monitor_engine=1) order by id desc").Find(&alarmRows)
 	if err != nil {
 		err = fmt.Errorf("get monitor engine alarm firing list fail,%s ", err.Error())
+		return
+	}
+	var strategyMetricRows []*models.AlarmStrategyMetricQueryRow
+	err = x.SQL("select t1.alarm_strategy,t1.`condition`,t1.`last`,t1.crc_hash,t2.metric as 'metric_name' from alarm_strategy_metric t1 left join metric t2 on t1.metric=t2.guid where t1.alarm_strategy in (select alarm_strategy from alarm_strategy_metric where monitor_engine=1)").Find(&strategyMetricRows)
+	if err != nil {
+		err = fmt.Errorf("query alarm strategy metric fail,%s ", err.Error())
+		return
+	}
+	crcMap := make(map[string]string)
+	metricCrcMap := make(map[string][]string)
+	for _, row := range strategyMetricRows {
+		crcMap[fmt.Sprintf("%s^%s^%s^%s", row.AlarmStrategy, row.MetricName, row.Condition, row.Last)] = row.CrcHash
+		metricKey := fmt.Sprintf("%s^%s", row.AlarmStrategy, row.MetricName)
+		metricCrcMap[metricKey] = append(metricCrcMap[metricKey], row.CrcHash)
+	}
+	for _, row := range alarmRows {
+		alarmList = append(alarmList, &models.AlarmHandleObj{AlarmTable: *row, AlarmConditionCrcHash: matchMonitorEngineAlarmCrc(row, crcMap, metricCrcMap)})
 	}
 	return
 }
 
+// matchMonitorEngineAlarmCrc 阈值被修改后告警上的条件和持续时间对不上,同一策略下该指标只有一条阈值时按策略+指标归属,避免告警无法恢复
+func matchMonitorEngineAlarmCrc(alarmRow *models.AlarmTable, crcMap map[string]string, metricCrcMap map[string][]string) string {
+	if crcHash, ok := crcMap[fmt.Sprintf("%s^%s^%s^%s", alarmRow.AlarmStrategy, alarmRow.SMetric, alarmRow.SCond, alarmRow.SLast)]; ok {
+		return crcHash
+	}
+	if crcList := metricCrcMap[fmt.Sprintf("%s^%s", alarmRow.AlarmStrategy, alarmRow.SMetric)]; len(crcList) == 1 {
+		return crcList[0]
+	}
+	return ""
+}
+
 func GetAlarmStrategyNotifyWorkflowList() (result []*models.WorkflowDto, err error) {
 	result = []*models.WorkflowDto{}
 	var tempList []*models.WorkflowDto
@@ -0,0 +1,188 @@
+package db
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/WeBankPartners/open-monitor/monitor-server/middleware/log"
+	"github.com/WeBankPartners/open-monitor/monitor-server/models"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	webhookSignatureHeader = "X-Monitor-Signature"
+	webhookTimestampHeader = "X-Monitor-Timestamp"
+	webhookEventHeader     = "X-Monitor-Event"
+)
+
+type alarmWebhookTask struct {
+	Notify  *models.NotifyTable
+	Payload *models.AlarmWebhookPayload
+}
+
+var alarmWebhookQueue chan *alarmWebhookTask
+
+// StartAlarmWebhookWorker 启动 webhook 推送协程,推送和失败重试都在协程里做,不阻塞告警处理
+func StartAlarmWebhookWorker() {
+	webhookConfig := models.Config().AlarmWebhook
+	alarmWebhookQueue = make(chan *alarmWebhookTask, webhookConfig.QueueSize)
+	for i := 0; i < webhookConfig.WorkerNum; i++ {
+		go func() {
+			for task := range alarmWebhookQueue {
+				if err := postAlarmWebhookPayload(task.Notify, task.Payload); err != nil {
+					log.Logger.Error("Notify webhook fail after retry", log.String("notify", task.Notify.Guid), log.String("event", task.Payload.EventId), log.Error(err))
+				}
+			}
+		}()
+	}
+}
+
+// notifyWebhookAction 把告警放入推送队列,推送到通知配置里的callback_url,失败按配置重试
+func notifyWebhookAction(notify *models.NotifyTable, alarmObj *models.AlarmHandleObj) (err error) {
+	if notify.CallbackUrl == "" {
+		return
+	}
+	if !strings.HasPrefix(notify.CallbackUrl, "http://") && !strings.HasPrefix(notify.CallbackUrl, "https://") {
+		err = fmt.Errorf("notify:%s callback url:%s illegal,only support http(s) ", notify.Guid, notify.CallbackUrl)
+		return
+	}
+	if alarmWebhookQueue == nil {
+		err = fmt.Errorf("alarm webhook worker not start ")
+		return
+	}
+	// 告警对象之后还会被修改,入队前先生成推送内容
+	task := &alarmWebhookTask{Notify: notify, Payload: buildAlarmWebhookPayload(notify, alarmObj)}
+	select {
+	case alarmWebhookQueue <- task:
+	default:
+		err = fmt.Errorf("alarm webhook queue is full,drop event:%s ", task.Payload.EventId)
+	}
+	return
+}
+
+func postAlarmWebhookPayload(notify *models.NotifyTable, payload *models.AlarmWebhookPayload) (err error) {
+	postBytes, _ := json.Marshal(payload)
+	webhookConfig := models.Config().AlarmWebhook
+	for i := 0; i < webhookConfig.RetryTimes; i++ {
+		if i > 0 && webhookConfig.RetryInterval > 0 {
+			time.Sleep(time.Duration(webhookConfig.RetryInterval) * time.Second)
+		}
+		if err = doAlarmWebhookRequest(notify.CallbackUrl, payload.EventId, postBytes, webhookConfig); err == nil {
+			log.Logger.Info("Notify webhook success", log.String("notify", notify.Guid), log.String("event", payload.EventId), log.String("url", notify.CallbackUrl))
+			break
+		}
+		log.Logger.Warn("Notify webhook fail", log.String("notify", notify.Guid), log.String("event", payload.EventId), log.Int("try", i), log.Error(err))
+	}
+	return
+}
+
+func doAlarmWebhookRequest(url, eventId string, postBytes []byte, webhookConfig models.AlarmWebhookConfig) error {
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(postBytes))
+	if err != nil {
+		return fmt.Errorf("new request fail,%s ", err.Error())
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(webhookEventHeader, eventId)
+	request.Header.Set(webhookTimestampHeader, timestamp)
+	if webhookConfig.Secret != "" {
+		request.Header.Set(webhookSignatureHeader, "sha256="+signAlarmWebhookBody(webhookConfig.Secret, timestamp, postBytes))
+	}
+	httpClient := http.Client{Timeout: time.Duration(webhookConfig.Timeout) * time.Second}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("do request fail,%s ", err.Error())
+	}
+	responseBody, _ := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("response status code:%d body:%s ", response.StatusCode, string(responseBody))
+	}
+	return nil
+}
+
+// signAlarmWebhookBody 签名内容为 timestamp + "." + body,接收方用同样的secret做hmac-sha256校验
+func signAlarmWebhookBody(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func buildAlarmWebhookPayload(notify *models.NotifyTable, alarmObj *models.AlarmHandleObj) (result *models.AlarmWebhookPayload) {
+	result = &models.AlarmWebhookPayload{
+		EventId:       fmt.Sprintf("%d-%s-%s", alarmObj.Id, alarmObj.Status, notify.Guid),
+		Notify:        notify.Guid,
+		Param:         notify.CallbackParam,
+		AlarmId:       alarmObj.Id,
+		Status:        alarmObj.Status,
+		AlarmName:     alarmObj.AlarmName,
+		AlarmStrategy: alarmObj.AlarmStrategy,
+		Endpoint:      alarmObj.Endpoint,
+		EndpointTags:  alarmObj.EndpointTags,
+		Metric:        alarmObj.SMetric,
+		Expr:          alarmObj.SExpr,
+		Cond:          alarmObj.SCond,
+		Last:          alarmObj.SLast,
+		Priority:      alarmObj.SPriority,
+		Content:       alarmObj.Content,
+		Tags:          convertAlarmTagsToMap(alarmObj.Tags),
+		Start:         formatWebhookTime(alarmObj.Start),
+		StartValue:    alarmObj.StartValue,
+		End:           formatWebhookTime(alarmObj.End),
+		EndValue:      alarmObj.EndValue,
+		Detail:        []*models.AlarmWebhookDetailObj{},
+		SendTime:      time.Now().Format(models.DatetimeFormat),
+	}
+	if alarmObj.AlarmStrategy != "" {
+		if strategyRow, err := GetSimpleAlarmStrategy(alarmObj.AlarmStrategy); err == nil {
+			result.StrategyName = strategyRow.Name
+		}
+	}
+	if endpointRow, err := GetEndpointNew(&models.EndpointNewTable{Guid: alarmObj.Endpoint}); err == nil {
+		result.EndpointIp = endpointRow.Ip
+		result.EndpointType = endpointRow.MonitorType
+	}
+	result.Title, result.Message, result.MessageType = getNotifyMessage(models.NotifyChannelWebhook, alarmObj)
+	if strings.HasPrefix(alarmObj.EndpointTags, "ac_") {
+		alarmDetailList, err := GetAlarmDetailList(alarmObj.Id)
+		if err != nil {
+			log.Logger.Warn("build webhook payload get alarm detail list fail", log.Int("alarmId", alarmObj.Id), log.Error(err))
+		}
+		for _, v := range alarmDetailList {
+			if v == nil {
+				continue
+			}
+			result.Detail = append(result.Detail, &models.AlarmWebhookDetailObj{Metric: v.Metric, Cond: v.Cond, Last: v.Last, Start: formatWebhookTime(v.Start), StartValue: v.StartValue, End: formatWebhookTime(v.End), EndValue: v.EndValue, Tags: convertAlarmTagsToMap(v.Tags)})
+		}
+	}
+	return
+}
+
+// convertAlarmTagsToMap 把 key:value^key:value 格式的告警标签转成map,去掉内部使用的标签
+func convertAlarmTagsToMap(tags string) map[string]string {
+	result := make(map[string]string)
+	for _, tagV := range strings.Split(tags, "^") {
+		if strings.HasPrefix(tagV, "e_guid:") || strings.HasPrefix(tagV, "guid:") || strings.HasPrefix(tagV, "agg:") || strings.HasPrefix(tagV, "key:") || strings.HasPrefix(tagV, "condition_crc:") {
+			continue
+		}
+		if firstSplitIndex := strings.Index(tagV, ":"); firstSplitIndex > 0 {
+			result[tagV[:firstSplitIndex]] = tagV[firstSplitIndex+1:]
+		}
+	}
+	return result
+}
+
+func formatWebhookTime(t time.Time) string {
+	if t.IsZero() || t.Unix() <= 0 {
+		return ""
+	}
+	return t.Format(models.DatetimeFormat)
+}
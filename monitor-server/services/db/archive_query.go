@@ -0,0 +1,284 @@
+package db
+
+import (
+	"fmt"
+	"github.com/WeBankPartners/open-monitor/monitor-server/middleware/log"
+	m "github.com/WeBankPartners/open-monitor/monitor-server/models"
+	"github.com/WeBankPartners/open-monitor/monitor-server/services/datasource"
+	"time"
+)
+
+// GetArchiveBoundary 本地 Prometheus 数据保留边界,早于该时间的数据只能从归档库查询
+func GetArchiveBoundary() int64 {
+	return time.Now().Unix() - m.Config().ArchiveMysql.LocalStorageMaxDay*86400
+}
+
+// QueryStitchedData 图表查询计划:查询区间跨越保留边界时,边界前的数据查归档库,边界后的查 Prometheus,
+// 按序列名拼接成一条序列并降采样到 maxPoint 以内,step 非0时为归档数据的粒度,此时结果已降采样,调用方不需要再聚合
+func QueryStitchedData(query *m.QueryMonitorData, agg string, maxPoint int) (err error, step int, result []*m.SerialModel) {
+	if !ArchiveEnable || m.Config().ArchiveMysql.LocalStorageMaxDay <= 0 {
+		result = datasource.PrometheusData(query)
+		return
+	}
+	boundary := GetArchiveBoundary()
+	if query.ChartType == "pie" {
+		if query.Start >= boundary {
+			result = datasource.PrometheusData(query)
+			return
+		}
+		// 饼图跨越保留边界时按折线查询拼接后的序列,再按饼图的合并方式计算每个扇区的值
+		lineQuery := *query
+		lineQuery.ChartType = ""
+		var serials []*m.SerialModel
+		err, step, serials = QueryStitchedData(&lineQuery, agg, maxPoint)
+		query.PieData = buildSerialsPieData(serials, query.PieAggType)
+		result = []*m.SerialModel{}
+		return
+	}
+	if query.End <= boundary {
+		err, step, result = GetArchiveData(buildArchiveQuery(query, query.Start, query.End), agg)
+		downsampleSerials(result, query.Start, query.End, agg, maxPoint)
+		return
+	}
+	if query.Start >= boundary {
+		result = datasource.PrometheusData(query)
+		if len(result) == 0 {
+			// 本地无数据(如 Prometheus 重建)时整段从归档库查询
+			err, step, result = GetArchiveData(buildArchiveQuery(query, query.Start, query.End), agg)
+			downsampleSerials(result, query.Start, query.End, agg, maxPoint)
+			return
+		}
+		// 本地数据起始时间晚于查询开始时间,尝试从归档库补齐前段
+		if firstTime := getSerialsFirstTime(result); firstTime > query.Start+120 {
+			if _, tmpStep, archiveSerials := GetArchiveData(buildArchiveQuery(query, query.Start, firstTime), agg); len(archiveSerials) > 0 {
+				step = tmpStep
+				result = stitchSerials(archiveSerials, result)
+				downsampleSerials(result, query.Start, query.End, agg, maxPoint)
+			}
+		}
+		return
+	}
+	var archiveSerials []*m.SerialModel
+	var archiveErr error
+	archiveErr, step, archiveSerials = GetArchiveData(buildArchiveQuery(query, query.Start, boundary), agg)
+	if archiveErr != nil {
+		log.Logger.Warn("query archive data fail,only return prometheus data", log.Error(archiveErr))
+	}
+	promQuery := *query
+	promQuery.Start = boundary
+	result = stitchSerials(archiveSerials, datasource.PrometheusData(&promQuery))
+	downsampleSerials(result, query.Start, query.End, agg, maxPoint)
+	return
+}
+
+func buildArchiveQuery(query *m.QueryMonitorData, start, end int64) *m.QueryMonitorData {
+	return &m.QueryMonitorData{Start: start, End: end, Endpoint: query.Endpoint, Metric: query.Metric, Legend: query.Legend, CompareLegend: query.CompareLegend, SameEndpoint: query.SameEndpoint, CustomDashboard: query.CustomDashboard}
+}
+
+func getSerialsFirstTime(serials []*m.SerialModel) int64 {
+	var firstTime int64
+	for _, serial := range serials {
+		if len(serial.Data) == 0 {
+			continue
+		}
+		if tmpTime := int64(serial.Data[0][0]) / 1000; firstTime == 0 || tmpTime < firstTime {
+			firstTime = tmpTime
+		}
+	}
+	return firstTime
+}
+
+// stitchSerials 按序列名拼接归档数据与 Prometheus 数据,归档数据只保留早于 Prometheus 首个点的部分
+func stitchSerials(archiveSerials, promSerials []*m.SerialModel) []*m.SerialModel {
+	archiveMap := make(map[string]*m.SerialModel)
+	var nameList []string
+	for _, serial := range archiveSerials {
+		archiveMap[serial.Name] = serial
+		nameList = append(nameList, serial.Name)
+	}
+	result := []*m.SerialModel{}
+	for _, serial := range promSerials {
+		archiveSerial, b := archiveMap[serial.Name]
+		if !b {
+			result = append(result, serial)
+			continue
+		}
+		delete(archiveMap, serial.Name)
+		if len(serial.Data) == 0 {
+			serial.Data = archiveSerial.Data
+			result = append(result, serial)
+			continue
+		}
+		var tmpData m.DataSort
+		for _, v := range archiveSerial.Data {
+			if v[0] < serial.Data[0][0] {
+				tmpData = append(tmpData, v)
+			}
+		}
+		serial.Data = append(tmpData, serial.Data...)
+		result = append(result, serial)
+	}
+	for _, name := range nameList {
+		if archiveSerial, b := archiveMap[name]; b {
+			result = append(result, archiveSerial)
+		}
+	}
+	return result
+}
+
+// buildSerialsPieData 每条序列作为一个扇区,pieAggType 为 new 时取最新值,否则按合并方式计算
+func buildSerialsPieData(serials []*m.SerialModel, pieAggType string) (pieData m.EChartPie) {
+	for _, serial := range serials {
+		if len(serial.Data) == 0 {
+			continue
+		}
+		pieObj := m.EChartPieObj{Name: serial.Name}
+		if pieAggType == "new" {
+			pieObj.Value = serial.Data[len(serial.Data)-1][1]
+		} else {
+			for _, v := range serial.Data {
+				pieObj.SourceValue = append(pieObj.SourceValue, v[1])
+			}
+			pieObj.Value = m.CalcData(pieObj.SourceValue, pieAggType)
+		}
+		pieData.Legend = append(pieData.Legend, pieObj.Name)
+		pieData.Data = append(pieData.Data, &pieObj)
+	}
+	return
+}
+
+// downsampleSerials 序列点数超过 maxPoint 时按图表聚合方式降采样
+func downsampleSerials(serials []*m.SerialModel, start, end int64, agg string, maxPoint int) {
+	if maxPoint <= 0 {
+		maxPoint = m.Config().ArchiveMysql.QueryMaxPoint
+	}
+	if maxPoint <= 0 || end <= start {
+		return
+	}
+	if agg == "" || agg == "none" {
+		agg = "avg"
+	}
+	aggStep := (end-start)/int64(maxPoint) + 1
+	for _, serial := range serials {
+		if len(serial.Data) > maxPoint {
+			serial.Data = m.Aggregate(serial.Data, aggStep, agg)
+		}
+	}
+}
+
+// archivePartition 一次归档查询的分区及该分区内的时间范围 [Start,End]
+type archivePartition struct {
+	Name  string
+	Start int64
+	End   int64
+}
+
+// getArchiveStep 按查询起始时间选择归档层级,越早的数据只保留在越粗的层级中
+func getArchiveStep(start int64) int {
+	nowTime := time.Now().Unix()
+	archiveConfig := m.Config().ArchiveMysql
+	if archiveConfig.DayStartDay > 0 && start < nowTime-archiveConfig.DayStartDay*86400 {
+		return 86400
+	}
+	if archiveConfig.HourStartDay > 0 && start < nowTime-archiveConfig.HourStartDay*86400 {
+		return 3600
+	}
+	if start < nowTime-archiveConfig.FiveMinStartDay*86400 {
+		return 300
+	}
+	return 60
+}
+
+// getArchiveTierBoundary 小时/天层级由 archive_mysql_tool 在日表满 five_min_start_day 天转五分钟数据时生成,
+// 早于返回时间的数据才有小时/天层级,之后的只在日表里
+func getArchiveTierBoundary() int64 {
+	fiveMinStartDay := m.Config().ArchiveMysql.FiveMinStartDay
+	if fiveMinStartDay <= 0 {
+		fiveMinStartDay = 90
+	}
+	t, _ := time.Parse("2006_01_02 15:04:05 MST", fmt.Sprintf("%s 00:00:00 "+m.DefaultLocalTimeZone, time.Now().Format("2006_01_02")))
+	return t.Unix() - fiveMinStartDay*86400
+}
+
+// getArchivePartitionList 分钟/五分钟数据按天分表 archive_2006_01_02,小时数据按月 archive_1h_2006_01,天数据按年 archive_1d_2006,
+// 小时/天层级只查 tierBoundary 之前的部分,之后还没有汇总的部分从日表补齐
+func getArchivePartitionList(start, end int64, step int, tierBoundary int64) (result []*archivePartition) {
+	if step != 3600 && step != 86400 {
+		return getArchiveDayPartitionList(start, end)
+	}
+	if start >= tierBoundary {
+		return getArchiveDayPartitionList(start, end)
+	}
+	tierEnd := end
+	if tierEnd >= tierBoundary {
+		tierEnd = tierBoundary - 1
+	}
+	result = getArchiveTierPartitionList(start, tierEnd, step)
+	if end >= tierBoundary {
+		result = append(result, getArchiveDayPartitionList(tierBoundary, end)...)
+	}
+	return
+}
+
+func getArchiveDayPartitionList(start, end int64) (result []*archivePartition) {
+	for _, v := range getDateStringList(start, end) {
+		tmpT, err := time.Parse("2006_01_02 15:04:05 MST", fmt.Sprintf("%s 00:00:00 "+m.DefaultLocalTimeZone, v))
+		if err != nil {
+			continue
+		}
+		result = append(result, &archivePartition{Name: "archive_" + v, Start: tmpT.Unix(), End: tmpT.Unix() + 86400})
+	}
+	if len(result) > 0 {
+		result[0].Start = start
+		result[len(result)-1].End = end
+	}
+	return
+}
+
+func getArchiveTierPartitionList(start, end int64, step int) (result []*archivePartition) {
+	cursorTime := time.Unix(start, 0)
+	if step == 3600 {
+		cursorTime = time.Date(cursorTime.Year(), cursorTime.Month(), 1, 0, 0, 0, 0, cursorTime.Location())
+		for cursorTime.Unix() <= end {
+			nextTime := cursorTime.AddDate(0, 1, 0)
+			result = append(result, &archivePartition{Name: "archive_1h_" + cursorTime.Format("2006_01"), Start: cursorTime.Unix(), End: nextTime.Unix() - 1})
+			cursorTime = nextTime
+		}
+	} else {
+		cursorTime = time.Date(cursorTime.Year(), 1, 1, 0, 0, 0, 0, cursorTime.Location())
+		for cursorTime.Unix() <= end {
+			nextTime := cursorTime.AddDate(1, 0, 0)
+			result = append(result, &archivePartition{Name: "archive_1d_" + cursorTime.Format("2006"), Start: cursorTime.Unix(), End: nextTime.Unix() - 1})
+			cursorTime = nextTime
+		}
+	}
+	// 小时/天数据的时间为桶的起始时间,起始分区从包含查询起点的桶开始
+	if len(result) > 0 {
+		startTime := time.Unix(start, 0)
+		if step == 3600 {
+			startTime = startTime.Truncate(time.Hour)
+		} else {
+			startTime = time.Date(startTime.Year(), startTime.Month(), startTime.Day(), 0, 0, 0, 0, startTime.Location())
+		}
+		result[0].Start = startTime.Unix()
+		result[len(result)-1].End = end
+	}
+	return
+}
+
+func getDateStringList(start, end int64) []string {
+	var dateList []string
+	cursorTime := start
+	for {
+		if cursorTime > end {
+			break
+		}
+		dateList = append(dateList, time.Unix(cursorTime, 0).Format("2006_01_02"))
+		cursorTime += 86400
+	}
+	t, _ := time.Parse("2006_01_02 15:04:05 MST", fmt.Sprintf("%s 00:00:00 "+m.DefaultLocalTimeZone, time.Unix(cursorTime, 0).Format("2006_01_02")))
+	if end > t.Unix()+60 {
+		dateList = append(dateList, time.Unix(cursorTime, 0).Format("2006_01_02"))
+	}
+	return dateList
+}
@@ -0,0 +1,122 @@
+package db
+
+import (
+	m "github.com/WeBankPartners/open-monitor/monitor-server/models"
+	"testing"
+	"time"
+)
+
+func initArchiveQueryTestTimeZone() {
+	time.Local = time.UTC
+	m.DefaultLocalTimeZone = "UTC"
+}
+
+func checkArchivePartitionList(t *testing.T, partitionList []*archivePartition, expectList []archivePartition) {
+	if len(partitionList) != len(expectList) {
+		t.Fatalf("partition num expect %d,get:%d", len(expectList), len(partitionList))
+	}
+	for i, v := range partitionList {
+		if *v != expectList[i] {
+			t.Errorf("partition %d expect %+v,get:%+v", i, expectList[i], *v)
+		}
+	}
+}
+
+func TestGetArchivePartitionListTierBoundary(t *testing.T) {
+	initArchiveQueryTestTimeZone()
+	boundary := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC).Unix()
+	start := time.Date(2023, 12, 31, 12, 0, 0, 0, time.UTC).Unix()
+	end := time.Date(2024, 3, 2, 6, 0, 0, 0, time.UTC).Unix()
+	// 天层级只查到边界前,边界后还没有汇总的部分查日表
+	checkArchivePartitionList(t, getArchivePartitionList(start, end, 86400, boundary), []archivePartition{
+		{Name: "archive_1d_2023", Start: time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC).Unix(), End: boundary - 86400*60 - 1},
+		{Name: "archive_1d_2024", Start: boundary - 86400*60, End: boundary - 1},
+		{Name: "archive_2024_03_01", Start: boundary, End: boundary + 86400},
+		{Name: "archive_2024_03_02", Start: boundary + 86400, End: end},
+	})
+	hourStart := time.Date(2024, 2, 28, 10, 30, 0, 0, time.UTC).Unix()
+	checkArchivePartitionList(t, getArchivePartitionList(hourStart, end, 3600, boundary), []archivePartition{
+		{Name: "archive_1h_2024_02", Start: time.Date(2024, 2, 28, 10, 0, 0, 0, time.UTC).Unix(), End: boundary - 1},
+		{Name: "archive_2024_03_01", Start: boundary, End: boundary + 86400},
+		{Name: "archive_2024_03_02", Start: boundary + 86400, End: end},
+	})
+	// 整段都在边界前只查小时层级
+	checkArchivePartitionList(t, getArchivePartitionList(hourStart, boundary-3600, 3600, boundary), []archivePartition{
+		{Name: "archive_1h_2024_02", Start: time.Date(2024, 2, 28, 10, 0, 0, 0, time.UTC).Unix(), End: boundary - 3600},
+	})
+	// 整段都在边界后不查小时/天层级
+	checkArchivePartitionList(t, getArchivePartitionList(boundary+3600, end, 86400, boundary), []archivePartition{
+		{Name: "archive_2024_03_01", Start: boundary + 3600, End: boundary + 86400},
+		{Name: "archive_2024_03_02", Start: boundary + 86400, End: end},
+	})
+}
+
+func getSerialTimeList(serial *m.SerialModel) (timeList []float64) {
+	for _, v := range serial.Data {
+		timeList = append(timeList, v[0])
+	}
+	return
+}
+
+func TestStitchSerials(t *testing.T) {
+	archiveSerials := []*m.SerialModel{
+		{Name: "a", Data: m.DataSort{{1000, 1}, {2000, 2}, {3000, 3}}},
+		{Name: "c", Data: m.DataSort{{1000, 1}}},
+		{Name: "d", Data: m.DataSort{{1000, 1}}},
+	}
+	promSerials := []*m.SerialModel{
+		{Name: "a", Data: m.DataSort{{3000, 30}, {4000, 40}}},
+		{Name: "b", Data: m.DataSort{{5000, 5}}},
+		{Name: "d", Data: m.DataSort{}},
+	}
+	result := stitchSerials(archiveSerials, promSerials)
+	if len(result) != 4 || result[0].Name != "a" || result[1].Name != "b" || result[2].Name != "d" || result[3].Name != "c" {
+		t.Fatalf("stitch serial order illegal:%+v", result)
+	}
+	// 归档数据只保留早于 Prometheus 首个点的部分
+	if timeList := getSerialTimeList(result[0]); len(timeList) != 4 || timeList[1] != 2000 || timeList[2] != 3000 || result[0].Data[2][1] != 30 {
+		t.Errorf("stitch serial data illegal:%v", result[0].Data)
+	}
+	if len(result[2].Data) != 1 || result[2].Data[0][1] != 1 {
+		t.Errorf("empty prometheus serial should use archive data:%v", result[2].Data)
+	}
+}
+
+func TestDownsampleSerials(t *testing.T) {
+	longSerial := &m.SerialModel{Name: "long"}
+	for i := 0; i < 100; i++ {
+		longSerial.Data = append(longSerial.Data, []float64{float64(i * 10000), float64(i)})
+	}
+	shortSerial := &m.SerialModel{Name: "short", Data: m.DataSort{{0, 1}, {500000, 2}}}
+	downsampleSerials([]*m.SerialModel{longSerial, shortSerial}, 0, 1000, "none", 10)
+	if len(longSerial.Data) > 11 || len(longSerial.Data) < 9 {
+		t.Errorf("serial should downsample to max point,get:%d", len(longSerial.Data))
+	}
+	// none 按 avg 降采样,第一个桶为 0~100s 内前 11 个点
+	if longSerial.Data[0][1] != 5 {
+		t.Errorf("downsample avg value illegal:%v", longSerial.Data[0])
+	}
+	if len(shortSerial.Data) != 2 {
+		t.Errorf("serial within max point should not change:%v", shortSerial.Data)
+	}
+	maxSerial := &m.SerialModel{Name: "max", Data: m.DataSort{{0, 1}, {10000, 9}, {20000, 3}}}
+	downsampleSerials([]*m.SerialModel{maxSerial}, 0, 1000, "max", 2)
+	if len(maxSerial.Data) != 1 || maxSerial.Data[0][1] != 9 {
+		t.Errorf("downsample max value illegal:%v", maxSerial.Data)
+	}
+}
+
+func TestBuildSerialsPieData(t *testing.T) {
+	serials := []*m.SerialModel{
+		{Name: "a", Data: m.DataSort{{1000, 1}, {2000, 3}}},
+		{Name: "empty", Data: m.DataSort{}},
+		{Name: "b", Data: m.DataSort{{1000, 5}}},
+	}
+	pieData := buildSerialsPieData(serials, "sum")
+	if len(pieData.Data) != 2 || pieData.Legend[0] != "a" || pieData.Data[0].Value != 4 || pieData.Data[1].Value != 5 {
+		t.Errorf("sum pie data illegal:%+v", pieData)
+	}
+	if pieData = buildSerialsPieData(serials, "new"); pieData.Data[0].Value != 3 {
+		t.Errorf("new pie data should use last value:%+v", pieData.Data[0])
+	}
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"fmt"
+	"github.com/WeBankPartners/open-monitor/monitor-server/common/archivefile"
+	"github.com/WeBankPartners/open-monitor/monitor-server/middleware/log"
+	m "github.com/WeBankPartners/open-monitor/monitor-server/models"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ArchiveStorage 归档数据读取接口,与 archive_mysql_tool 的存储实现对应,
+// partition 为 archive_2006_01_02 形式的表名(mysql)或目录名(file),时间范围为 [start,end]
+type ArchiveStorage interface {
+	QueryRows(partition, endpoint, metric, valueColumn string, start, end int64) ([]*m.ArchiveQueryTable, error)
+}
+
+var archiveStore ArchiveStorage
+
+func initArchiveStorage() {
+	switch strings.ToLower(m.Config().ArchiveMysql.Storage) {
+	case "file":
+		fileDir := m.Config().ArchiveMysql.FileDir
+		if info, err := os.Stat(fileDir); err != nil || !info.IsDir() {
+			ArchiveEnable = false
+			log.Logger.Error("Init archive file storage fail,dir not exist", log.String("dir", fileDir))
+			return
+		}
+		archiveStore = &fileArchiveStorage{dir: fileDir}
+		ArchiveEnable = true
+		log.Logger.Info("Init archive file storage success", log.String("dir", fileDir))
+	default:
+		archiveStore = &mysqlArchiveStorage{}
+		initArchiveDbEngine()
+	}
+}
+
+var archivePartitionYearRegexp = regexp.MustCompile(`^archive_(1h_|1d_)?(\d{4})`)
+
+type mysqlArchiveStorage struct{}
+
+// QueryRows 分区按年分库,使用 库名.表名 查询,跨年查询不依赖当前连接的库
+func (s *mysqlArchiveStorage) QueryRows(partition, endpoint, metric, valueColumn string, start, end int64) (result []*m.ArchiveQueryTable, err error) {
+	tableName := partition
+	if fetchList := archivePartitionYearRegexp.FindStringSubmatch(partition); len(fetchList) == 3 {
+		tableName = fmt.Sprintf("`%s%s`.`%s`", m.Config().ArchiveMysql.DatabasePrefix, fetchList[2], partition)
+	}
+	err = archiveMysql.SQL(fmt.Sprintf("SELECT `endpoint`,metric,tags,unix_time,`%s` AS `value`  FROM %s WHERE `endpoint`='%s' AND metric='%s' AND unix_time>=%d AND unix_time<=%d", valueColumn, tableName, endpoint, metric, start, end)).Find(&result)
+	return
+}
+
+func getArchiveFileValue(row *archivefile.Row, valueColumn string) float64 {
+	switch valueColumn {
+	case "min":
+		return row.Min
+	case "max":
+		return row.Max
+	case "p95":
+		return row.P95
+	case "sum":
+		return row.Sum
+	}
+	return row.Avg
+}
+
+// fileArchiveStorage 读取 archive_mysql_tool 写入的文件,每个对象单独一个文件,只解码需要的对象
+type fileArchiveStorage struct {
+	dir string
+}
+
+func (s *fileArchiveStorage) QueryRows(partition, endpoint, metric, valueColumn string, start, end int64) (result []*m.ArchiveQueryTable, err error) {
+	rows, err := archivefile.NewSeries(s.dir, partition, metric, endpoint).Read()
+	if err != nil {
+		err = fmt.Errorf("read archive partition:%s metric:%s endpoint:%s fail,%s ", partition, metric, endpoint, err.Error())
+		return
+	}
+	for _, row := range rows {
+		if row.UnixTime < start || row.UnixTime > end {
+			continue
+		}
+		result = append(result, &m.ArchiveQueryTable{Endpoint: endpoint, Metric: metric, Tags: row.Tags, UnixTime: row.UnixTime, Value: getArchiveFileValue(row, valueColumn)})
+	}
+	return
+}
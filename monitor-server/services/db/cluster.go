@@ -116,15 +116,28 @@ func GetSdFileListByStep(step int, cluster string) (result m.ServiceDiscoverFile
 		err = fmt.Errorf("Try to query endpoint table fail,%s ", err.Error())
 		return
 	}
+	endpointLocationMap, _, queryErr := getEndpointProbeLocationMap()
+	if queryErr != nil {
+		err = queryErr
+		return
+	}
 	result = m.ServiceDiscoverFileList{}
 	for _, v := range endpointTables {
 		if v.MonitorType == "snmp" || v.MonitorType == "process" || v.MonitorType == "custom" {
 			continue
 		}
-		if v.MonitorType == "ping" || v.MonitorType == "telnet" || v.MonitorType == "http" {
+		if m.IsPingExporterType(v.MonitorType) {
 			if v.AgentAddress == "" {
 				continue
 			}
+			// 多拨测点时每个拨测点的 ping_exporter 各一个采集目标,用 probe_location 标签区分
+			if locationList, b := endpointLocationMap[v.Guid]; b {
+				for _, location := range locationList {
+					result = append(result, &m.ServiceDiscoverFileObj{Guid: v.Guid, Step: v.Step, Cluster: v.Cluster, Address: location.Address, ProbeLocation: location.Guid})
+				}
+				log.Logger.Info("add endpoint", log.String("guid", v.Guid), log.Int("probeLocationNum", len(locationList)))
+				continue
+			}
 		}
 		tmpSdFileObj := m.ServiceDiscoverFileObj{Guid: v.Guid, Step: v.Step, Cluster: v.Cluster, Address: v.AgentAddress}
 		log.Logger.Info("add endpoint", log.String("guid", v.Guid))
@@ -632,7 +632,7 @@ func GetEndpointMetric(endpointGuid, monitorType string) (err error, result []*m
 	if endpointObj.Guid == "" {
 		return fmt.Errorf("endpoint guid: %s can not find ", endpointGuid), result
 	}
-	if endpointObj.ExportType == "ping" || endpointObj.ExportType == "telnet" || endpointObj.ExportType == "http" {
+	if m.IsPingExporterType(endpointObj.ExportType) {
 		return nil, result
 	}
 	var ip, port, exporterAddress string
@@ -854,7 +854,9 @@ func GetArchiveData(query *m.QueryMonitorData, agg string) (err error, step int,
 		log.Logger.Error("", log.Error(err))
 		return err, step, result
 	}
-	checkArchiveDatabase()
+	if _, b := archiveStore.(*mysqlArchiveStorage); b {
+		checkArchiveDatabase()
+	}
 	if query.Start == 0 || query.End == 0 || (query.Start >= query.End) {
 		err = fmt.Errorf("get archive data query start and end validate fail,start:%d end:%d ", query.Start, query.End)
 		log.Logger.Error("", log.Error(err))
@@ -864,11 +866,8 @@ func GetArchiveData(query *m.QueryMonitorData, agg string) (err error, step int,
 	if agg == "" || agg == "none" {
 		agg = "avg"
 	}
-	step = 60
-	if query.Start < time.Now().Unix()-(m.Config().ArchiveMysql.FiveMinStartDay*86400) {
-		step = 300
-	}
-	dateStringList := getDateStringList(query.Start, query.End)
+	step = getArchiveStep(query.Start)
+	partitionList := getArchivePartitionList(query.Start, query.End, step, getArchiveTierBoundary())
 	tagLength := 0
 	if len(query.Endpoint) > 1 || len(query.Metric) > 1 {
 		tagLength = 2
@@ -882,71 +881,32 @@ func GetArchiveData(query *m.QueryMonitorData, agg string) (err error, step int,
 				tmpTag = strings.Replace(tmpTag, "=", "=\"", -1)
 				tmpMetric = metric[:strings.Index(metric, "/")]
 			}
-			tmpQueryResult := queryArchiveTables(endpoint, tmpMetric, tmpTag, agg, dateStringList, query, tagLength)
+			tmpQueryResult := queryArchiveTables(endpoint, tmpMetric, tmpTag, agg, partitionList, query, tagLength)
 			result = append(result, tmpQueryResult...)
 		}
 	}
 	return err, step, result
 }
 
-func getDateStringList(start, end int64) []string {
-	var dateList []string
-	cursorTime := start
-	for {
-		if cursorTime > end {
-			break
-		}
-		dateList = append(dateList, time.Unix(cursorTime, 0).Format("2006_01_02"))
-		cursorTime += 86400
-	}
-	t, _ := time.Parse("2006_01_02 15:04:05 MST", fmt.Sprintf("%s 00:00:00 "+m.DefaultLocalTimeZone, time.Unix(cursorTime, 0).Format("2006_01_02")))
-	if end > t.Unix()+60 {
-		dateList = append(dateList, time.Unix(cursorTime, 0).Format("2006_01_02"))
-	}
-	return dateList
-}
-
-func queryArchiveTables(endpoint, metric, tag, agg string, dateList []string, query *m.QueryMonitorData, tagLength int) []*m.SerialModel {
+func queryArchiveTables(endpoint, metric, tag, agg string, partitionList []*archivePartition, query *m.QueryMonitorData, tagLength int) []*m.SerialModel {
 	var result []*m.SerialModel
 	query.Endpoint = []string{endpoint}
 	query.Metric = []string{metric}
 	resultMap := make(map[string]m.DataSort)
 	recordTagMap := make(map[string]map[string]string)
 	recordNameMap := make(map[string]string)
-	for i, v := range dateList {
-		var tmpStart, tmpEnd int64
-		if i == 0 {
-			tmpStart = query.Start
-		} else {
-			tmpT, err := time.Parse("2006_01_02 15:04:05 MST", fmt.Sprintf("%s 00:00:00 "+m.DefaultLocalTimeZone, v))
-			if err == nil {
-				tmpStart = tmpT.Unix()
-			} else {
-				continue
-			}
-		}
-		if i == len(v)-1 {
-			tmpEnd = query.End
-		} else {
-			tmpT, err := time.Parse("2006_01_02 15:04:05 MST", fmt.Sprintf("%s 00:00:00 "+m.DefaultLocalTimeZone, v))
-			if err == nil {
-				tmpEnd = tmpT.Unix() + 86400
-			} else {
-				continue
-			}
-		}
-		var tableData []*m.ArchiveQueryTable
-		err := archiveMysql.SQL(fmt.Sprintf("SELECT `endpoint`,metric,tags,unix_time,`avg` AS `value`  FROM archive_%s WHERE `endpoint`='%s' AND metric='%s' AND unix_time>=%d AND unix_time<=%d", v, endpoint, metric, tmpStart, tmpEnd)).Find(&tableData)
+	for i, partition := range partitionList {
+		tableData, err := archiveStore.QueryRows(partition.Name, endpoint, metric, getArchiveValueColumn(agg), partition.Start, partition.End)
 		if err != nil {
 			if strings.Contains(err.Error(), "doesn't exist") {
-				log.Logger.Debug(fmt.Sprintf("Query archive table:archive_%s error,table doesn't exist", v))
+				log.Logger.Debug(fmt.Sprintf("Query archive table:%s error,table doesn't exist", partition.Name))
 			} else {
-				log.Logger.Info(fmt.Sprintf("query archive table:archive_%s error", v), log.Error(err))
+				log.Logger.Info(fmt.Sprintf("query archive table:%s error", partition.Name), log.Error(err))
 			}
 			continue
 		}
 		if len(tableData) == 0 {
-			log.Logger.Info(fmt.Sprintf("query archive table:archive_%s empty", v))
+			log.Logger.Info(fmt.Sprintf("query archive table:%s empty", partition.Name))
 			continue
 		}
 		if tagLength <= 1 && i == 0 {
@@ -985,6 +945,15 @@ func queryArchiveTables(endpoint, metric, tag, agg string, dateList []string, qu
 	return result
 }
 
+// getArchiveValueColumn 归档表每分钟只保存 avg/min/max/p95/sum,按图表聚合方式取对应列
+func getArchiveValueColumn(agg string) string {
+	switch agg {
+	case "min", "max", "p95", "sum":
+		return agg
+	}
+	return "avg"
+}
+
 func getKVMapFromArchiveTags(tag string) map[string]string {
 	tMap := make(map[string]string)
 	for _, v := range strings.Split(tag, ",") {
@@ -132,8 +132,8 @@ func CreateDBKeywordConfig(param *models.DbKeywordConfigObj, operator string) (e
 
 func getCreateDbKeywordConfigActions(input *models.DbKeywordConfigObj, operator string, nowTime time.Time) (actions []*Action) {
 	input.Guid = "db_km_" + guid.CreateGuid()
-	actions = append(actions, &Action{Sql: "insert into db_keyword_monitor(guid,service_group,name,query_sql,priority,content,notify_enable,active_window,step,monitor_type,create_user,update_user,create_time,update_time) values (?,?,?,?,?,?,?,?,?,?,?,?,?,?)", Param: []interface{}{
-		input.Guid, input.ServiceGroup, input.Name, input.QuerySql, input.Priority, input.Content, input.NotifyEnable, input.ActiveWindow, input.Step, input.MonitorType, operator, operator, nowTime, nowTime,
+	actions = append(actions, &Action{Sql: "insert into db_keyword_monitor(guid,service_group,name,query_sql,priority,content,notify_enable,active_window,step,monitor_type,db_type,db_name,create_user,update_user,create_time,update_time) values (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)", Param: []interface{}{
+		input.Guid, input.ServiceGroup, input.Name, input.QuerySql, input.Priority, input.Content, input.NotifyEnable, input.ActiveWindow, input.Step, input.MonitorType, input.DbType, input.DbName, operator, operator, nowTime, nowTime,
 	}})
 	endpointRelGuidList := guid.CreateGuidList(len(input.EndpointRel))
 	for i, v := range input.EndpointRel {
@@ -187,8 +187,8 @@ func UpdateDBKeywordConfig(param *models.DbKeywordConfigObj, operator string) (e
 }
 
 func getUpdateDbKeywordConfigActions(input *models.DbKeywordConfigObj, operator string, nowTime time.Time) (actions []*Action) {
-	actions = append(actions, &Action{Sql: "update db_keyword_monitor set name=?,query_sql=?,priority=?,content=?,notify_enable=?,active_window=?,step=?,monitor_type=?,update_user=?,update_time=? where guid=?", Param: []interface{}{
-		input.Name, input.QuerySql, input.Priority, input.Content, input.NotifyEnable, input.ActiveWindow, input.Step, input.MonitorType, operator, nowTime, input.Guid,
+	actions = append(actions, &Action{Sql: "update db_keyword_monitor set name=?,query_sql=?,priority=?,content=?,notify_enable=?,active_window=?,step=?,monitor_type=?,db_type=?,db_name=?,update_user=?,update_time=? where guid=?", Param: []interface{}{
+		input.Name, input.QuerySql, input.Priority, input.Content, input.NotifyEnable, input.ActiveWindow, input.Step, input.MonitorType, input.DbType, input.DbName, operator, nowTime, input.Guid,
 	}})
 	actions = append(actions, &Action{Sql: "delete from db_keyword_endpoint_rel where db_keyword_monitor=?", Param: []interface{}{input.Guid}})
 	endpointRelGuidList := guid.CreateGuidList(len(input.EndpointRel))
@@ -398,7 +398,11 @@ func doDbKeywordMonitorJob() {
 						log.Logger.Error("update alarm table notify id fail", log.Int("alarmId", tmpAlarmObj.Id), log.Error(execErr))
 					}
 				}
-				notifyAction(tmpNotifyRow, &models.AlarmHandleObj{AlarmTable: tmpAlarmObj})
+				tmpAlarmHandleObj := &models.AlarmHandleObj{AlarmTable: tmpAlarmObj}
+				if checkAlarmSilence(tmpAlarmHandleObj) {
+					continue
+				}
+				notifyAction(tmpNotifyRow, tmpAlarmHandleObj)
 			}
 		}
 	}
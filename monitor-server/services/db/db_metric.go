@@ -26,7 +26,7 @@ func GetDbMetricByServiceGroup(serviceGroup, metricKey string) (result []*models
 	for _, v := range dbMetricTable {
 		result = append(result, &models.DbMetricMonitorObj{Guid: v.Guid, ServiceGroup: v.ServiceGroup, MetricSql: v.MetricSql,
 			Metric: v.Metric, DisplayName: v.DisplayName, Step: v.Step, MonitorType: v.MonitorType,
-			EndpointRel: getDbMetricEndpointRel(v.Guid), UpdateUser: v.UpdateUser, UpdateTime: v.UpdateTime,
+			DbType: v.DbType, DbName: v.DbName, LabelColumns: splitDbColumns(v.LabelColumns), ValueColumns: splitDbColumns(v.ValueColumns), MaxSeries: v.MaxSeries, EndpointRel: getDbMetricEndpointRel(v.Guid), UpdateUser: v.UpdateUser, UpdateTime: v.UpdateTime,
 		})
 	}
 	return
@@ -71,7 +71,8 @@ func GetDbMetric(dbMetricGuid string) (result models.DbMetricMonitorObj, err err
 	if len(dbMetricTable) == 0 {
 		return result, fmt.Errorf("Can not find db_metric_monitor with guid:%s ", dbMetricGuid)
 	}
-	result = models.DbMetricMonitorObj{Guid: dbMetricTable[0].Guid, ServiceGroup: dbMetricTable[0].ServiceGroup, MetricSql: dbMetricTable[0].MetricSql, Metric: dbMetricTable[0].Metric, DisplayName: dbMetricTable[0].DisplayName, Step: dbMetricTable[0].Step, MonitorType: dbMetricTable[0].MonitorType}
+	result = models.DbMetricMonitorObj{Guid: dbMetricTable[0].Guid, ServiceGroup: dbMetricTable[0].ServiceGroup, MetricSql: dbMetricTable[0].MetricSql, Metric: dbMetricTable[0].Metric, DisplayName: dbMetricTable[0].DisplayName, Step: dbMetricTable[0].Step, MonitorType: dbMetricTable[0].MonitorType, DbType: dbMetricTable[0].DbType, DbName: dbMetricTable[0].DbName,
+		LabelColumns: splitDbColumns(dbMetricTable[0].LabelColumns), ValueColumns: splitDbColumns(dbMetricTable[0].ValueColumns), MaxSeries: dbMetricTable[0].MaxSeries}
 	result.EndpointRel = getDbMetricEndpointRel(dbMetricGuid)
 	return
 }
@@ -87,8 +88,9 @@ func CreateDbMetric(param *models.DbMetricMonitorObj, operator string) error {
 
 func getCreateDBMetricActions(param *models.DbMetricMonitorObj, operator, nowTime string) (actions []*Action) {
 	param.Guid = "dbm_" + guid.CreateGuid()
-	insertAction := Action{Sql: "insert into db_metric_monitor(guid,service_group,metric_sql,metric,display_name,step,monitor_type,update_time,update_user) value (?,?,?,?,?,?,?,?,?)"}
-	insertAction.Param = []interface{}{param.Guid, param.ServiceGroup, param.MetricSql, param.Metric, param.DisplayName, param.Step, param.MonitorType, nowTime, operator}
+	insertAction := Action{Sql: "insert into db_metric_monitor(guid,service_group,metric_sql,metric,display_name,step,monitor_type,db_type,db_name,label_columns,value_columns,max_series,update_time,update_user) value (?,?,?,?,?,?,?,?,?,?,?,?,?,?)"}
+	insertAction.Param = []interface{}{param.Guid, param.ServiceGroup, param.MetricSql, param.Metric, param.DisplayName, param.Step, param.MonitorType, param.DbType, param.DbName,
+		strings.Join(param.LabelColumns, ","), strings.Join(param.ValueColumns, ","), param.MaxSeries, nowTime, operator}
 	actions = append(actions, &insertAction)
 	actions = append(actions, &Action{Sql: "insert into metric(guid,metric,monitor_type,prom_expr,service_group,workspace,update_time,create_time,create_user,update_user,db_metric_monitor) value (?,?,?,?,?,?,?,?,?,?,?)",
 		Param: []interface{}{fmt.Sprintf("%s__%s", param.Metric, param.ServiceGroup), param.Metric, param.MonitorType, getDbMetricExpr(param.Metric, param.ServiceGroup), param.ServiceGroup,
@@ -119,8 +121,9 @@ func UpdateDbMetric(param *models.DbMetricMonitorObj, operator string) error {
 	}
 	var affectEndpointGroup []string
 	var actions []*Action
-	updateAction := Action{Sql: "update db_metric_monitor set metric_sql=?,metric=?,display_name=?,step=?,monitor_type=?,update_time=?,update_user=? where guid=?"}
-	updateAction.Param = []interface{}{param.MetricSql, param.Metric, param.DisplayName, param.Step, param.MonitorType, time.Now().Format(models.DatetimeFormat), operator, param.Guid}
+	updateAction := Action{Sql: "update db_metric_monitor set metric_sql=?,metric=?,display_name=?,step=?,monitor_type=?,db_type=?,db_name=?,label_columns=?,value_columns=?,max_series=?,update_time=?,update_user=? where guid=?"}
+	updateAction.Param = []interface{}{param.MetricSql, param.Metric, param.DisplayName, param.Step, param.MonitorType, param.DbType, param.DbName,
+		strings.Join(param.LabelColumns, ","), strings.Join(param.ValueColumns, ","), param.MaxSeries, time.Now().Format(models.DatetimeFormat), operator, param.Guid}
 	actions = append(actions, &updateAction)
 	if dbMetricTable[0].Metric != param.Metric {
 		oldMetricGuid := fmt.Sprintf("%s__%s", dbMetricTable[0].Metric, dbMetricTable[0].ServiceGroup)
@@ -185,6 +188,16 @@ func GetDeleteDbMetricActions(dbMetricGuid string) (actions []*Action, endpointG
 	return
 }
 
+func splitDbColumns(input string) (result []string) {
+	result = []string{}
+	for _, v := range strings.Split(input, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+	return
+}
+
 func getDbMetricEndpointRel(dbMetricMonitorGuid string) (result []*models.DbMetricEndpointRelTable) {
 	result = []*models.DbMetricEndpointRelTable{}
 	x.SQL("select * from db_metric_endpoint_rel where db_metric_monitor=?", dbMetricMonitorGuid).Find(&result)
@@ -208,7 +221,7 @@ func SyncDbMetric(initFlag bool) error {
 		return fmt.Errorf("Query db_metric_monitor fail,%s ", err.Error())
 	}
 	var dbKeywordQuery []*models.DbKeywordMonitorQueryObj
-	err = x.SQL("select distinct t1.guid,t1.service_group,t1.name,t1.query_sql,t1.step,t1.monitor_type,t2.source_endpoint,t2.target_endpoint from db_keyword_monitor t1 left join db_keyword_endpoint_rel t2 on t1.guid=t2.db_keyword_monitor").Find(&dbKeywordQuery)
+	err = x.SQL("select distinct t1.guid,t1.service_group,t1.name,t1.query_sql,t1.step,t1.monitor_type,t1.db_type,t1.db_name,t2.source_endpoint,t2.target_endpoint from db_keyword_monitor t1 left join db_keyword_endpoint_rel t2 on t1.guid=t2.db_keyword_monitor").Find(&dbKeywordQuery)
 	if err != nil {
 		return fmt.Errorf("Query db_keyword_monitor fail,%s ", err.Error())
 	}
@@ -223,8 +236,12 @@ func SyncDbMetric(initFlag bool) error {
 		endpointExtMap[row.SourceEndpoint] = &models.EndpointExtendParamObj{}
 	}
 	var endpointTable []*models.EndpointNewTable
-	x.SQL("select guid,endpoint_address,extend_param from endpoint_new where monitor_type='mysql' and guid in ('" + strings.Join(endpointGuidList, "','") + "')").Find(&endpointTable)
+	x.SQL("select guid,monitor_type,endpoint_address,extend_param from endpoint_new where guid in ('" + strings.Join(endpointGuidList, "','") + "')").Find(&endpointTable)
+	endpointDbTypeMap := make(map[string]string)
 	for _, v := range endpointTable {
+		if models.IsDbType(v.MonitorType) {
+			endpointDbTypeMap[v.Guid] = v.MonitorType
+		}
 		if v.ExtendParam == "" {
 			continue
 		}
@@ -238,7 +255,7 @@ func SyncDbMetric(initFlag bool) error {
 	var postData []*models.DbMonitorTaskObj
 	for _, v := range dbMonitorQuery {
 		if extConfig, b := endpointExtMap[v.SourceEndpoint]; b {
-			taskObj := models.DbMonitorTaskObj{DbType: "mysql", Name: v.Metric, Step: v.Step, Sql: v.MetricSql, Server: extConfig.Ip, Port: extConfig.Port, User: extConfig.User, Password: extConfig.Password, Endpoint: v.SourceEndpoint, ServiceGroup: v.ServiceGroup}
+			taskObj := models.DbMonitorTaskObj{DbType: getSourceEndpointDbType(v.DbType, endpointDbTypeMap[v.SourceEndpoint]), DbName: v.DbName, LabelColumns: splitDbColumns(v.LabelColumns), ValueColumns: splitDbColumns(v.ValueColumns), MaxSeries: v.MaxSeries, Name: v.Metric, Step: v.Step, Sql: v.MetricSql, Server: extConfig.Ip, Port: extConfig.Port, User: extConfig.User, Password: extConfig.Password, Endpoint: v.SourceEndpoint, ServiceGroup: v.ServiceGroup}
 			if v.TargetEndpoint != "" {
 				taskObj.Endpoint = v.TargetEndpoint
 			}
@@ -247,7 +264,7 @@ func SyncDbMetric(initFlag bool) error {
 	}
 	for _, v := range dbKeywordQuery {
 		if extConfig, b := endpointExtMap[v.SourceEndpoint]; b {
-			taskObj := models.DbMonitorTaskObj{DbType: "mysql", Name: "db_keyword_value", Step: v.Step, Sql: v.QuerySql, Server: extConfig.Ip, Port: extConfig.Port, User: extConfig.User, Password: extConfig.Password, Endpoint: v.SourceEndpoint, ServiceGroup: v.ServiceGroup, KeywordGuid: v.Guid}
+			taskObj := models.DbMonitorTaskObj{DbType: getSourceEndpointDbType(v.DbType, endpointDbTypeMap[v.SourceEndpoint]), DbName: v.DbName, Name: "db_keyword_value", Step: v.Step, Sql: v.QuerySql, Server: extConfig.Ip, Port: extConfig.Port, User: extConfig.User, Password: extConfig.Password, Endpoint: v.SourceEndpoint, ServiceGroup: v.ServiceGroup, KeywordGuid: v.Guid}
 			if v.TargetEndpoint != "" {
 				taskObj.Endpoint = v.TargetEndpoint
 			}
@@ -291,3 +308,12 @@ func SyncDbMetric(initFlag bool) error {
 	}
 	return nil
 }
+
+// getSourceEndpointDbType 数据库类型以数据源对象的类型为准,配置的类型与对象不一致时按对象类型连接
+func getSourceEndpointDbType(configDbType, endpointDbType string) string {
+	if endpointDbType == "" || endpointDbType == configDbType {
+		return configDbType
+	}
+	log.Logger.Warn("db type not match source endpoint type,use endpoint type", log.String("dbType", configDbType), log.String("endpointType", endpointDbType))
+	return endpointDbType
+}
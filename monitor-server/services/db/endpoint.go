@@ -107,6 +107,24 @@ func GetAlarmRealEndpoint(endpointId, strategyId int, endpointType, expr string)
 	return true, endpoint
 }
 
+// GetEndpointNewByLabelValue 用外部告警的标签值找监控对象,依次按guid、agent_address、ip匹配
+func GetEndpointNewByLabelValue(value string) (result models.EndpointNewTable, err error) {
+	var endpointNew []*models.EndpointNewTable
+	ip := value
+	if splitIndex := strings.LastIndex(value, ":"); splitIndex > 0 {
+		ip = value[:splitIndex]
+	}
+	err = x.SQL("select * from endpoint_new where guid=? or agent_address=? or ip=? order by guid<>?,agent_address<>?,monitor_type<>'host'", value, value, ip, value, value).Find(&endpointNew)
+	if err != nil {
+		return result, fmt.Errorf("Query endpoint fail,%s ", err.Error())
+	}
+	if len(endpointNew) == 0 {
+		return result, fmt.Errorf("Can not find endpoint with %s ", value)
+	}
+	result = *endpointNew[0]
+	return
+}
+
 func GetEndpointNew(param *models.EndpointNewTable) (result models.EndpointNewTable, err error) {
 	var endpointNew []*models.EndpointNewTable
 	var filterMessage string
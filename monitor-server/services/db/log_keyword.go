@@ -29,7 +29,7 @@ func GetLogKeywordByServiceGroup(serviceGroupGuid, alarmName string) (result []*
 	}
 	var configList []*models.LogKeywordMonitorObj
 	for _, v := range logKeywordTable {
-		configObj := models.LogKeywordMonitorObj{Guid: v.Guid, ServiceGroup: serviceGroupGuid, LogPath: v.LogPath, MonitorType: v.MonitorType}
+		configObj := models.LogKeywordMonitorObj{Guid: v.Guid, ServiceGroup: serviceGroupGuid, LogPath: v.LogPath, MonitorType: v.MonitorType, Multiline: parseLogMultiline(v.Multiline)}
 		if configObj.KeywordList, err = ListLogKeyword(v.Guid, alarmName); err != nil {
 			return
 		}
@@ -106,7 +106,7 @@ func CreateLogKeywordMonitor(param *models.LogKeywordMonitorCreateObj) (err erro
 		} else {
 			existLogPathMap[path] = 1
 		}
-		actions = append(actions, &Action{Sql: "insert into log_keyword_monitor(guid,service_group,log_path,monitor_type,update_time) value (?,?,?,?,?)", Param: []interface{}{logKeywordGuidList[i], param.ServiceGroup, path, param.MonitorType, nowTime}})
+		actions = append(actions, &Action{Sql: "insert into log_keyword_monitor(guid,service_group,log_path,monitor_type,update_time,multiline) value (?,?,?,?,?,?)", Param: []interface{}{logKeywordGuidList[i], param.ServiceGroup, path, param.MonitorType, nowTime, getLogMultilineString(param.Multiline)}})
 		endpointRelActions, tmpErr := getLogKeywordEndpointRelCreateAction(param.EndpointRel, logKeywordGuidList[i])
 		if tmpErr != nil {
 			err = tmpErr
@@ -147,7 +147,7 @@ func UpdateLogKeywordMonitor(param *models.LogKeywordMonitorObj) (err error) {
 	}
 	var actions []*Action
 	nowTime := time.Now().Format(models.DatetimeFormat)
-	actions = append(actions, &Action{Sql: "update log_keyword_monitor set log_path=?,monitor_type=?,update_time=? where guid=?", Param: []interface{}{param.LogPath, param.MonitorType, nowTime, param.Guid}})
+	actions = append(actions, &Action{Sql: "update log_keyword_monitor set log_path=?,monitor_type=?,update_time=?,multiline=? where guid=?", Param: []interface{}{param.LogPath, param.MonitorType, nowTime, getLogMultilineString(param.Multiline), param.Guid}})
 	actions = append(actions, &Action{Sql: "delete from log_keyword_endpoint_rel where log_keyword_monitor=?", Param: []interface{}{param.Guid}})
 	endpointRelActions, tmpErr := getLogKeywordEndpointRelCreateAction(param.EndpointRel, param.Guid)
 	if tmpErr != nil {
@@ -490,7 +490,11 @@ func doLogKeywordMonitorJob() {
 						log.Logger.Error("update alarm table notify id fail", log.Int("alarmId", tmpAlarmObj.Id), log.Error(execErr))
 					}
 				}
-				notifyAction(tmpNotifyRow, &models.AlarmHandleObj{AlarmTable: tmpAlarmObj})
+				tmpAlarmHandleObj := &models.AlarmHandleObj{AlarmTable: tmpAlarmObj}
+				if checkAlarmSilence(tmpAlarmHandleObj) {
+					continue
+				}
+				notifyAction(tmpNotifyRow, tmpAlarmHandleObj)
 			}
 		}
 	}
@@ -571,8 +575,8 @@ func ImportLogAndDbKeyword(param *models.LogKeywordServiceGroupObj, operator str
 
 	nowTime := time.Now().Format(models.DatetimeFormat)
 	for _, inputKeywordConfig := range param.Config {
-		actions = append(actions, &Action{Sql: "insert into log_keyword_monitor(guid,service_group,log_path,monitor_type,update_time,update_user) value (?,?,?,?,?,?)",
-			Param: []interface{}{inputKeywordConfig.Guid, inputKeywordConfig.ServiceGroup, inputKeywordConfig.LogPath, inputKeywordConfig.MonitorType, nowTime, operator}})
+		actions = append(actions, &Action{Sql: "insert into log_keyword_monitor(guid,service_group,log_path,monitor_type,update_time,update_user,multiline) value (?,?,?,?,?,?,?)",
+			Param: []interface{}{inputKeywordConfig.Guid, inputKeywordConfig.ServiceGroup, inputKeywordConfig.LogPath, inputKeywordConfig.MonitorType, nowTime, operator, getLogMultilineString(inputKeywordConfig.Multiline)}})
 		if inputKeywordConfig.Notify != nil {
 			inputKeywordConfig.Notify.EndpointGroup = ""
 			inputKeywordConfig.Notify.ServiceGroup = ""
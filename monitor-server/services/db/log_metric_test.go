@@ -0,0 +1,23 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestGetLogMetricRateRange(t *testing.T) {
+	testCases := []struct {
+		step   int64
+		result int64
+	}{
+		{0, 60},
+		{10, 60},
+		{15, 60},
+		{30, 120},
+		{60, 240},
+	}
+	for _, v := range testCases {
+		if result := getLogMetricRateRange(v.step); result != v.result {
+			t.Errorf("step %d rate range should be %d,get:%d", v.step, v.result, result)
+		}
+	}
+}
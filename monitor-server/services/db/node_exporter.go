@@ -10,6 +10,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -76,7 +77,7 @@ func transLogMetricConfigToJob(logMetricConfig []*models.LogMetricQueryObj, endp
 	syncParam = []*models.LogMetricMonitorNeObj{}
 	for _, serviceGroupConfig := range logMetricConfig {
 		for _, lmMonitorObj := range serviceGroupConfig.Config {
-			tmpMonitorJob := models.LogMetricMonitorNeObj{Path: lmMonitorObj.LogPath, JsonConfig: []*models.LogMetricJsonNeObj{}, MetricConfig: []*models.LogMetricNeObj{}, ServiceGroup: serviceGroupConfig.Guid}
+			tmpMonitorJob := models.LogMetricMonitorNeObj{Path: lmMonitorObj.LogPath, JsonConfig: []*models.LogMetricJsonNeObj{}, MetricConfig: []*models.LogMetricNeObj{}, ServiceGroup: serviceGroupConfig.Guid, Multiline: lmMonitorObj.Multiline}
 			for _, v := range lmMonitorObj.EndpointRel {
 				if v.SourceEndpoint == endpointGuid {
 					tmpMonitorJob.TargetEndpoint = v.TargetEndpoint
@@ -86,7 +87,7 @@ func transLogMetricConfigToJob(logMetricConfig []*models.LogMetricQueryObj, endp
 			for _, v := range lmMonitorObj.JsonConfigList {
 				tmpJsonJob := models.LogMetricJsonNeObj{Regular: v.JsonRegular, Tags: v.Tags, MetricConfig: []*models.LogMetricNeObj{}}
 				for _, vv := range v.MetricList {
-					tmpMetricJob := models.LogMetricNeObj{Metric: vv.Metric, Key: vv.JsonKey, AggType: vv.AggType, Step: vv.Step, StringMap: []*models.LogMetricStringMapNeObj{}}
+					tmpMetricJob := models.LogMetricNeObj{Metric: vv.Metric, Key: vv.JsonKey, AggType: vv.AggType, Step: vv.Step, Buckets: parseLogMetricBuckets(vv.Buckets), StringMap: []*models.LogMetricStringMapNeObj{}}
 					for _, vvv := range vv.StringMap {
 						targetFloatValue, _ := strconv.ParseFloat(vvv.TargetValue, 64)
 						tmpStringMapJob := models.LogMetricStringMapNeObj{StringValue: vvv.SourceValue, IntValue: targetFloatValue, RegEnable: false, TargetStringValue: vvv.TargetValue}
@@ -101,7 +102,7 @@ func transLogMetricConfigToJob(logMetricConfig []*models.LogMetricQueryObj, endp
 				tmpMonitorJob.JsonConfig = append(tmpMonitorJob.JsonConfig, &tmpJsonJob)
 			}
 			for _, v := range lmMonitorObj.MetricConfigList {
-				tmpMetricJob := models.LogMetricNeObj{Metric: v.Metric, ValueRegular: v.Regular, AggType: v.AggType, Step: v.Step, StringMap: []*models.LogMetricStringMapNeObj{}}
+				tmpMetricJob := models.LogMetricNeObj{Metric: v.Metric, ValueRegular: v.Regular, AggType: v.AggType, Step: v.Step, Buckets: parseLogMetricBuckets(v.Buckets), StringMap: []*models.LogMetricStringMapNeObj{}}
 				for _, vv := range v.StringMap {
 					targetFloatValue, _ := strconv.ParseFloat(vv.TargetValue, 64)
 					tmpStringMapJob := models.LogMetricStringMapNeObj{StringValue: vv.SourceValue, IntValue: targetFloatValue, RegEnable: false, TargetStringValue: vv.TargetValue}
@@ -124,7 +125,7 @@ func transLogMetricConfigToJobNew(logMetricConfig []*models.LogMetricQueryObj, e
 	syncParam = []*models.LogMetricMonitorNeObj{}
 	for _, serviceGroupConfig := range logMetricConfig {
 		for _, lmMonitorObj := range serviceGroupConfig.Config {
-			tmpMonitorJob := models.LogMetricMonitorNeObj{Path: lmMonitorObj.LogPath, JsonConfig: []*models.LogMetricJsonNeObj{}, MetricConfig: []*models.LogMetricNeObj{}, MetricGroupConfig: []*models.LogMetricGroupNeObj{}, ServiceGroup: serviceGroupConfig.Guid}
+			tmpMonitorJob := models.LogMetricMonitorNeObj{Path: lmMonitorObj.LogPath, JsonConfig: []*models.LogMetricJsonNeObj{}, MetricConfig: []*models.LogMetricNeObj{}, MetricGroupConfig: []*models.LogMetricGroupNeObj{}, ServiceGroup: serviceGroupConfig.Guid, Multiline: lmMonitorObj.Multiline}
 			for _, v := range lmMonitorObj.EndpointRel {
 				if v.SourceEndpoint == endpointGuid {
 					tmpMonitorJob.TargetEndpoint = v.TargetEndpoint
@@ -150,14 +151,14 @@ func transLogMetricConfigToJobNew(logMetricConfig []*models.LogMetricQueryObj, e
 					tmpGroupJob.ParamList = append(tmpGroupJob.ParamList, &tmpGroupParamObj)
 				}
 				for _, groupMetric := range v.MetricList {
-					if groupMetric.AggType != "avg" && groupMetric.AggType != "count" && groupMetric.AggType != "sum" && groupMetric.AggType != "max" && groupMetric.AggType != "min" {
+					if !isLogMetricAggType(groupMetric.AggType) {
 						continue
 					}
 					tmpMetric := groupMetric.Metric
 					if v.MetricPrefixCode != "" {
 						tmpMetric = v.MetricPrefixCode + "_" + groupMetric.Metric
 					}
-					tmpGroupMetricObj := models.LogMetricNeObj{Metric: tmpMetric, LogParamName: groupMetric.LogParamName, AggType: groupMetric.AggType, Step: groupMetric.Step, TagConfig: []*models.LogMetricConfigTag{}}
+					tmpGroupMetricObj := models.LogMetricNeObj{Metric: tmpMetric, LogParamName: groupMetric.LogParamName, AggType: groupMetric.AggType, Step: groupMetric.Step, Buckets: parseLogMetricBuckets(groupMetric.Buckets), TagConfig: []*models.LogMetricConfigTag{}}
 					for _, vv := range groupMetric.TagConfigList {
 						tmpGroupMetricObj.TagConfig = append(tmpGroupMetricObj.TagConfig, &models.LogMetricConfigTag{LogParamName: vv})
 					}
@@ -183,7 +184,7 @@ func transLogMetricConfigToJobNew(logMetricConfig []*models.LogMetricQueryObj, e
 			//	tmpMonitorJob.JsonConfig = append(tmpMonitorJob.JsonConfig, &tmpJsonJob)
 			//}
 			for _, v := range lmMonitorObj.MetricConfigList {
-				tmpMetricJob := models.LogMetricNeObj{Metric: v.Metric, ValueRegular: v.Regular, AggType: v.AggType, Step: v.Step, StringMap: []*models.LogMetricStringMapNeObj{}}
+				tmpMetricJob := models.LogMetricNeObj{Metric: v.Metric, ValueRegular: v.Regular, AggType: v.AggType, Step: v.Step, Buckets: parseLogMetricBuckets(v.Buckets), StringMap: []*models.LogMetricStringMapNeObj{}}
 				for _, vv := range v.StringMap {
 					targetFloatValue, _ := strconv.ParseFloat(vv.TargetValue, 64)
 					tmpStringMapJob := models.LogMetricStringMapNeObj{StringValue: vv.SourceValue, IntValue: targetFloatValue, RegEnable: false, TargetStringValue: vv.TargetValue}
@@ -202,6 +203,29 @@ func transLogMetricConfigToJobNew(logMetricConfig []*models.LogMetricQueryObj, e
 	return syncParam
 }
 
+func isLogMetricAggType(aggType string) bool {
+	switch aggType {
+	case "avg", "count", "sum", "max", "min", "p50", "p90", "p95", "p99", "histogram":
+		return true
+	}
+	return false
+}
+
+// parseLogMetricBuckets 直方图的桶以逗号分隔,非法的值忽略,为空时 agent 使用默认桶
+func parseLogMetricBuckets(buckets string) (result []float64) {
+	for _, v := range strings.Split(buckets, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		if bucketValue, err := strconv.ParseFloat(v, 64); err == nil {
+			result = append(result, bucketValue)
+		} else {
+			log.Logger.Warn("Parse log metric bucket fail", log.String("buckets", buckets), log.Error(err))
+		}
+	}
+	return
+}
+
 func SyncLogKeywordExporterConfig(endpoints []string) error {
 	log.Logger.Info("UpdateNodeExportConfig", log.StringList("endpoints", endpoints))
 	var err error
@@ -265,6 +289,8 @@ func getLogKeywordExporterConfig(endpoint string) (result []*models.LogKeywordHt
 	result = []*models.LogKeywordHttpDto{}
 	var pathList []string
 	pathMap := make(map[string][]*models.LogKeywordHttpRuleObj)
+	// 同一路径有多个配置时取第一个配置了多行合并的
+	pathMultilineMap := make(map[string]*models.LogMultilineConfig)
 	for _, serviceGroupConfig := range serviceGroupKeywordList {
 		for _, logKeywordMonitor := range serviceGroupConfig.Config {
 			targetEndpoint := ""
@@ -274,6 +300,9 @@ func getLogKeywordExporterConfig(endpoint string) (result []*models.LogKeywordHt
 					break
 				}
 			}
+			if _, b := pathMultilineMap[logKeywordMonitor.LogPath]; !b && logKeywordMonitor.Multiline != nil {
+				pathMultilineMap[logKeywordMonitor.LogPath] = logKeywordMonitor.Multiline
+			}
 			if existKeywordList, b := pathMap[logKeywordMonitor.LogPath]; b {
 				existKeywordMap := make(map[string]string)
 				for _, existKeywordObj := range existKeywordList {
@@ -311,7 +340,7 @@ func getLogKeywordExporterConfig(endpoint string) (result []*models.LogKeywordHt
 		}
 	}
 	for _, path := range pathList {
-		result = append(result, &models.LogKeywordHttpDto{Path: path, Keywords: pathMap[path]})
+		result = append(result, &models.LogKeywordHttpDto{Path: path, Keywords: pathMap[path], Multiline: pathMultilineMap[path]})
 	}
 	return
 }
@@ -0,0 +1,352 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/WeBankPartners/go-common-lib/guid"
+	"github.com/WeBankPartners/open-monitor/monitor-server/middleware/log"
+	"github.com/WeBankPartners/open-monitor/monitor-server/models"
+	htmlTemplate "html/template"
+	"strings"
+	textTemplate "text/template"
+	"time"
+)
+
+var notifyTemplateFuncMap = map[string]interface{}{
+	"formatTime": func(t time.Time) string {
+		if t.Unix() <= 0 {
+			return ""
+		}
+		return t.Format(models.DatetimeFormat)
+	},
+	"formatFloat": func(v float64) string {
+		return fmt.Sprintf("%.3f", v)
+	},
+	"join": strings.Join,
+}
+
+func ListNotifyTemplate(param *models.NotifyTemplateQueryParam) (result []*models.NotifyTemplateTable, err error) {
+	result = []*models.NotifyTemplateTable{}
+	baseSql := "select * from notify_template where 1=1 "
+	var queryParams []interface{}
+	if param.Name != "" {
+		baseSql += " and name like ? "
+		queryParams = append(queryParams, "%"+param.Name+"%")
+	}
+	if param.AlarmStrategy != "" {
+		baseSql += " and alarm_strategy=? "
+		queryParams = append(queryParams, param.AlarmStrategy)
+	}
+	if param.EndpointGroup != "" {
+		baseSql += " and endpoint_group=? "
+		queryParams = append(queryParams, param.EndpointGroup)
+	}
+	if param.ServiceGroup != "" {
+		baseSql += " and service_group=? "
+		queryParams = append(queryParams, param.ServiceGroup)
+	}
+	if param.Channel != "" {
+		baseSql += " and channel=? "
+		queryParams = append(queryParams, param.Channel)
+	}
+	err = x.SQL(baseSql+" order by update_time desc", queryParams...).Find(&result)
+	if err != nil {
+		err = fmt.Errorf("query notify template table fail,%s ", err.Error())
+		return
+	}
+	for _, row := range result {
+		row.CreateTimeStr = row.CreateTime.Format(models.DatetimeFormat)
+		row.UpdateTimeStr = row.UpdateTime.Format(models.DatetimeFormat)
+	}
+	return
+}
+
+func GetNotifyTemplate(templateGuid string) (result *models.NotifyTemplateTable, err error) {
+	var templateRows []*models.NotifyTemplateTable
+	err = x.SQL("select * from notify_template where guid=?", templateGuid).Find(&templateRows)
+	if err != nil {
+		err = fmt.Errorf("query notify template table fail,%s ", err.Error())
+		return
+	}
+	if len(templateRows) == 0 {
+		err = fmt.Errorf("can not find notify template with guid:%s ", templateGuid)
+		return
+	}
+	result = templateRows[0]
+	result.CreateTimeStr = result.CreateTime.Format(models.DatetimeFormat)
+	result.UpdateTimeStr = result.UpdateTime.Format(models.DatetimeFormat)
+	return
+}
+
+// ValidateNotifyTemplate 校验模版的挂载对象与模版语法
+func ValidateNotifyTemplate(param *models.NotifyTemplateTable) (err error) {
+	refCount := 0
+	for _, v := range []string{param.AlarmStrategy, param.EndpointGroup, param.ServiceGroup} {
+		if v != "" {
+			refCount++
+		}
+	}
+	if refCount > 1 {
+		return fmt.Errorf("notify template can only attach to one of alarm_strategy,endpoint_group,service_group ")
+	}
+	if param.IsDefault == 1 && refCount > 0 {
+		return fmt.Errorf("default notify template can not attach to alarm_strategy,endpoint_group or service_group ")
+	}
+	if param.AlarmAction == "" {
+		param.AlarmAction = models.NotifyTemplateActionAll
+	}
+	if param.AlarmAction != models.NotifyTemplateActionAll && param.AlarmAction != "firing" && param.AlarmAction != "ok" {
+		return fmt.Errorf("alarm_action:%s illegal ", param.AlarmAction)
+	}
+	if param.Channel == "" {
+		param.Channel = models.NotifyChannelMail
+	}
+	if param.Channel != models.NotifyChannelMail && param.Channel != models.NotifyChannelEvent && param.Channel != models.NotifyChannelWebhook {
+		return fmt.Errorf("channel:%s illegal ", param.Channel)
+	}
+	if param.ContentType == "" {
+		param.ContentType = models.NotifyContentTypeText
+	}
+	if param.ContentType != models.NotifyContentTypeText && param.ContentType != models.NotifyContentTypeHtml {
+		return fmt.Errorf("content_type:%s illegal ", param.ContentType)
+	}
+	if strings.TrimSpace(param.Subject) == "" {
+		return fmt.Errorf("subject can not empty ")
+	}
+	if _, err = renderNotifyTemplate(param, buildSampleNotifyTemplateData()); err != nil {
+		return
+	}
+	return
+}
+
+func CreateNotifyTemplate(param *models.NotifyTemplateTable, operator string) (err error) {
+	nowTime := time.Now()
+	param.Guid = "ntpl_" + guid.CreateGuid()
+	var actions []*Action
+	if param.IsDefault == 1 {
+		actions = append(actions, &Action{Sql: "update notify_template set is_default=0 where channel=? and alarm_action=?", Param: []interface{}{param.Channel, param.AlarmAction}})
+	}
+	actions = append(actions, &Action{Sql: "insert into notify_template(guid,name,alarm_strategy,endpoint_group,service_group,alarm_action,channel,content_type,subject,content,is_default,create_user,update_user,create_time,update_time) value (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)",
+		Param: []interface{}{param.Guid, param.Name, nullString(param.AlarmStrategy), nullString(param.EndpointGroup), nullString(param.ServiceGroup), param.AlarmAction, param.Channel, param.ContentType, param.Subject, param.Content, param.IsDefault, operator, operator, nowTime, nowTime}})
+	err = Transaction(actions)
+	if err != nil {
+		err = fmt.Errorf("insert notify template fail,%s ", err.Error())
+	}
+	return
+}
+
+func UpdateNotifyTemplate(param *models.NotifyTemplateTable, operator string) (err error) {
+	if _, err = GetNotifyTemplate(param.Guid); err != nil {
+		return
+	}
+	var actions []*Action
+	if param.IsDefault == 1 {
+		actions = append(actions, &Action{Sql: "update notify_template set is_default=0 where channel=? and alarm_action=? and guid<>?", Param: []interface{}{param.Channel, param.AlarmAction, param.Guid}})
+	}
+	actions = append(actions, &Action{Sql: "update notify_template set name=?,alarm_strategy=?,endpoint_group=?,service_group=?,alarm_action=?,channel=?,content_type=?,subject=?,content=?,is_default=?,update_user=?,update_time=? where guid=?",
+		Param: []interface{}{param.Name, nullString(param.AlarmStrategy), nullString(param.EndpointGroup), nullString(param.ServiceGroup), param.AlarmAction, param.Channel, param.ContentType, param.Subject, param.Content, param.IsDefault, operator, time.Now(), param.Guid}})
+	err = Transaction(actions)
+	if err != nil {
+		err = fmt.Errorf("update notify template fail,%s ", err.Error())
+	}
+	return
+}
+
+func DeleteNotifyTemplate(templateGuid string) (err error) {
+	_, err = x.Exec("delete from notify_template where guid=?", templateGuid)
+	if err != nil {
+		err = fmt.Errorf("delete notify template fail,%s ", err.Error())
+	}
+	return
+}
+
+// PreviewNotifyTemplate 用指定告警或者样例告警渲染模版
+func PreviewNotifyTemplate(param *models.NotifyTemplatePreviewParam) (result *models.NotifyTemplatePreviewResult, err error) {
+	var templateData *models.NotifyTemplateData
+	if param.AlarmId > 0 {
+		alarmRow, getErr := GetAlarmObj(&models.AlarmTable{Id: param.AlarmId})
+		if getErr != nil {
+			err = fmt.Errorf("get alarm fail,%s ", getErr.Error())
+			return
+		}
+		if alarmRow.Id == 0 {
+			err = fmt.Errorf("can not find alarm with id:%d ", param.AlarmId)
+			return
+		}
+		templateData = buildNotifyTemplateData(&models.AlarmHandleObj{AlarmTable: alarmRow})
+	} else {
+		templateData = buildSampleNotifyTemplateData()
+	}
+	if param.Template.ContentType == "" {
+		param.Template.ContentType = models.NotifyContentTypeText
+	}
+	result, err = renderNotifyTemplate(&param.Template, templateData)
+	return
+}
+
+// getMatchNotifyTemplate 按 告警策略->对象组->层级对象->全局默认 的顺序找通知模版,找不到返回nil
+func getMatchNotifyTemplate(channel string, alarmObj *models.AlarmHandleObj) *models.NotifyTemplateTable {
+	var templateRows []*models.NotifyTemplateTable
+	err := x.SQL("select * from notify_template where channel=? and alarm_action in (?,?)", channel, alarmObj.Status, models.NotifyTemplateActionAll).Find(&templateRows)
+	if err != nil {
+		log.Logger.Error("query notify template fail", log.Int("alarmId", alarmObj.Id), log.Error(err))
+		return nil
+	}
+	if len(templateRows) == 0 {
+		return nil
+	}
+	var endpointGroup string
+	var serviceGroupList []string
+	if alarmObj.AlarmStrategy != "" {
+		if strategyRow, getErr := GetSimpleAlarmStrategy(alarmObj.AlarmStrategy); getErr == nil {
+			endpointGroup = strategyRow.EndpointGroup
+		}
+	}
+	var endpointServiceRows []*models.EndpointServiceRelTable
+	x.SQL("select distinct service_group from endpoint_service_rel where endpoint=?", alarmObj.Endpoint).Find(&endpointServiceRows)
+	for _, v := range endpointServiceRows {
+		serviceGroupList = append(serviceGroupList, v.ServiceGroup)
+		if parentList, fetchErr := fetchGlobalServiceGroupParentGuidList(v.ServiceGroup); fetchErr == nil {
+			serviceGroupList = append(serviceGroupList, parentList...)
+		}
+	}
+	// 同一层级里精确匹配告警状态的模版优先于 all
+	matchFuncList := []func(row *models.NotifyTemplateTable) bool{
+		func(row *models.NotifyTemplateTable) bool {
+			return alarmObj.AlarmStrategy != "" && row.AlarmStrategy == alarmObj.AlarmStrategy
+		},
+		func(row *models.NotifyTemplateTable) bool {
+			return endpointGroup != "" && row.EndpointGroup == endpointGroup
+		},
+		func(row *models.NotifyTemplateTable) bool {
+			for _, v := range serviceGroupList {
+				if row.ServiceGroup == v {
+					return true
+				}
+			}
+			return false
+		},
+		func(row *models.NotifyTemplateTable) bool {
+			return row.IsDefault == 1
+		},
+	}
+	for _, matchFunc := range matchFuncList {
+		var matchRow *models.NotifyTemplateTable
+		for _, row := range templateRows {
+			if !matchFunc(row) {
+				continue
+			}
+			if row.AlarmAction == alarmObj.Status {
+				matchRow = row
+				break
+			}
+			if matchRow == nil {
+				matchRow = row
+			}
+		}
+		if matchRow != nil {
+			return matchRow
+		}
+	}
+	return nil
+}
+
+func renderNotifyTemplate(tpl *models.NotifyTemplateTable, data *models.NotifyTemplateData) (result *models.NotifyTemplatePreviewResult, err error) {
+	result = &models.NotifyTemplatePreviewResult{ContentType: tpl.ContentType}
+	subjectTpl, parseErr := textTemplate.New("subject").Funcs(notifyTemplateFuncMap).Parse(tpl.Subject)
+	if parseErr != nil {
+		err = fmt.Errorf("parse subject template fail,%s ", parseErr.Error())
+		return
+	}
+	var subjectBuffer, contentBuffer bytes.Buffer
+	if err = subjectTpl.Execute(&subjectBuffer, data); err != nil {
+		err = fmt.Errorf("render subject template fail,%s ", err.Error())
+		return
+	}
+	// 邮件标题不能换行
+	result.Subject = strings.TrimSpace(strings.ReplaceAll(strings.ReplaceAll(subjectBuffer.String(), "\r", ""), "\n", " "))
+	if tpl.ContentType == models.NotifyContentTypeHtml {
+		contentTpl, contentParseErr := htmlTemplate.New("content").Funcs(notifyTemplateFuncMap).Parse(tpl.Content)
+		if contentParseErr != nil {
+			err = fmt.Errorf("parse content template fail,%s ", contentParseErr.Error())
+			return
+		}
+		err = contentTpl.Execute(&contentBuffer, data)
+	} else {
+		contentTpl, contentParseErr := textTemplate.New("content").Funcs(notifyTemplateFuncMap).Parse(tpl.Content)
+		if contentParseErr != nil {
+			err = fmt.Errorf("parse content template fail,%s ", contentParseErr.Error())
+			return
+		}
+		err = contentTpl.Execute(&contentBuffer, data)
+	}
+	if err != nil {
+		err = fmt.Errorf("render content template fail,%s ", err.Error())
+		return
+	}
+	result.Content = contentBuffer.String()
+	return
+}
+
+func buildNotifyTemplateData(alarmObj *models.AlarmHandleObj) *models.NotifyTemplateData {
+	result := models.NotifyTemplateData{AlarmHandleObj: *alarmObj, EndpointTagList: []string{}, Now: time.Now().Format(models.DatetimeFormat)}
+	result.TagMap = convertAlarmTagsToMap(alarmObj.Tags)
+	result.StartTime = formatWebhookTime(alarmObj.Start)
+	result.EndTime = formatWebhookTime(alarmObj.End)
+	if alarmObj.AlarmStrategy != "" {
+		if strategyRow, err := GetSimpleAlarmStrategy(alarmObj.AlarmStrategy); err == nil {
+			result.StrategyName = strategyRow.Name
+		}
+	}
+	if endpointRow, err := GetEndpointNew(&models.EndpointNewTable{Guid: alarmObj.Endpoint}); err == nil {
+		result.EndpointName = endpointRow.Name
+		result.EndpointIp = endpointRow.Ip
+		result.EndpointType = endpointRow.MonitorType
+		for _, v := range strings.Split(endpointRow.Tags, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				result.EndpointTagList = append(result.EndpointTagList, v)
+			}
+		}
+	}
+	if strings.HasPrefix(alarmObj.EndpointTags, "ac_") {
+		detailList, err := GetAlarmDetailList(alarmObj.Id)
+		if err != nil {
+			log.Logger.Warn("build notify template data get alarm detail fail", log.Int("alarmId", alarmObj.Id), log.Error(err))
+		}
+		result.DetailList = detailList
+	} else {
+		result.DetailList = []*models.AlarmDetailData{{Metric: alarmObj.SMetric, Cond: alarmObj.SCond, Last: alarmObj.SLast, Start: alarmObj.Start, StartValue: alarmObj.StartValue, End: alarmObj.End, EndValue: alarmObj.EndValue, Tags: alarmObj.Tags}}
+	}
+	if result.AlarmDetail == "" {
+		result.AlarmDetail = buildAlarmDetailData(result.DetailList, "\r\n")
+	}
+	result.DashboardUrl = buildAlarmDashboardUrl()
+	return &result
+}
+
+func buildSampleNotifyTemplateData() *models.NotifyTemplateData {
+	nowTime := time.Now()
+	sampleAlarm := models.AlarmHandleObj{AlarmTable: models.AlarmTable{Id: 1, Endpoint: "host01_127.0.0.1_host", Status: "firing", SMetric: "cpu_used_percent", SExpr: "node_cpu_used_percent", SCond: ">80", SLast: "60s",
+		SPriority: "high", Content: "cpu used too high", Tags: "cpu:total", StartValue: 92.5, Start: nowTime, AlarmStrategy: "strategy_sample", AlarmName: "cpu_used_high"}}
+	result := models.NotifyTemplateData{AlarmHandleObj: sampleAlarm, StrategyName: "cpu_used_high", EndpointName: "host01", EndpointIp: "127.0.0.1", EndpointType: "host",
+		EndpointTagList: []string{"app:demo"}, TagMap: map[string]string{"cpu": "total"}, StartTime: nowTime.Format(models.DatetimeFormat), Now: nowTime.Format(models.DatetimeFormat)}
+	result.DetailList = []*models.AlarmDetailData{{Metric: sampleAlarm.SMetric, Cond: sampleAlarm.SCond, Last: sampleAlarm.SLast, Start: nowTime, StartValue: sampleAlarm.StartValue, Tags: sampleAlarm.Tags}}
+	result.AlarmDetail = buildAlarmDetailData(result.DetailList, "\r\n")
+	result.DashboardUrl = buildAlarmDashboardUrl()
+	return &result
+}
+
+func buildAlarmDashboardUrl() string {
+	dashboardUrl := strings.TrimSuffix(models.Config().AlarmDashboardUrl, "/")
+	if dashboardUrl == "" {
+		return ""
+	}
+	return dashboardUrl + "/#/alarmManagement"
+}
+
+func nullString(input string) interface{} {
+	if input == "" {
+		return nil
+	}
+	return input
+}
@@ -21,7 +21,7 @@ func StartNotifyPingExport()  {
 func notifyPingExport()  {
 	log.Logger.Debug("start to notify ping exporter")
 	var endpointTable []*m.EndpointTable
-	err := x.SQL("select * from endpoint where export_type in ('ping','telnet','http') and address_agent<>'' order by address_agent").Find(&endpointTable)
+	err := x.SQL("select * from endpoint where export_type in ('ping','telnet','http','"+strings.Join(m.ProbeTypeList, "','")+"') and address_agent<>'' order by address_agent").Find(&endpointTable)
 	if err != nil {
 		log.Logger.Error("Notify ping export fail,query endpoint table fail", log.Error(err))
 		return
@@ -30,7 +30,7 @@ func notifyPingExport()  {
 		log.Logger.Warn("Notify ping export done with empty data")
 		return
 	}
-	var telnetGuidList,httpGuidList []string
+	var telnetGuidList,httpGuidList,probeGuidList []string
 	for _,v := range endpointTable {
 		if v.ExportType == "telnet" {
 			telnetGuidList = append(telnetGuidList, v.Guid)
@@ -38,6 +38,9 @@ func notifyPingExport()  {
 		if v.ExportType == "http" {
 			httpGuidList = append(httpGuidList, v.Guid)
 		}
+		if m.IsProbeType(v.ExportType) {
+			probeGuidList = append(probeGuidList, v.Guid)
+		}
 	}
 	var telnetTables []*m.EndpointTelnetTable
 	var httpTables []*m.EndpointHttpTable
@@ -47,6 +50,15 @@ func notifyPingExport()  {
 	if len(httpGuidList) > 0 {
 		x.SQL("select * from endpoint_http where endpoint_guid in ('"+strings.Join(httpGuidList, "','")+"')").Find(&httpTables)
 	}
+	var probeTables []*m.EndpointProbeTable
+	if len(probeGuidList) > 0 {
+		x.SQL("select * from endpoint_probe where endpoint_guid in ('"+strings.Join(probeGuidList, "','")+"')").Find(&probeTables)
+	}
+	endpointLocationMap, quorumMap, err := getEndpointProbeLocationMap()
+	if err != nil {
+		log.Logger.Error("Notify ping export fail,query probe location fail", log.Error(err))
+		return
+	}
 	var extendExporterMap = make(map[string][]*m.PingExportSourceObj)
 	for _,v := range endpointTable {
 		if !strings.Contains(v.AddressAgent, ":") {
@@ -67,16 +79,29 @@ func notifyPingExport()  {
 		}else if v.ExportType == "http" {
 			for _,vv := range httpTables {
 				if vv.EndpointGuid == v.Guid {
-					tmpPingExporterSourceObj.Ip = fmt.Sprintf("%s_%s",strings.ToUpper(vv.Method),vv.Url)
-					tmpPingExporterSourceObj.Guid = v.Guid
+					tmpPingExporterSourceObj = *buildHttpCheckSourceObj(vv)
 					break
 				}
 			}
+		}else if m.IsProbeType(v.ExportType) {
+			for _,vv := range probeTables {
+				if vv.EndpointGuid == v.Guid {
+					tmpPingExporterSourceObj = *buildProbeSourceObj(vv)
+					break
+				}
+			}
+		}
+		// 多拨测点的对象下发给每个拨测点,并带上 quorum 让 ping_exporter 暴露出来给告警表达式用
+		addressList := []string{v.AddressAgent}
+		if locationList,b := endpointLocationMap[v.Guid];b {
+			addressList = []string{}
+			for _,location := range locationList {
+				addressList = append(addressList, location.Address)
+			}
+			tmpPingExporterSourceObj.Quorum = quorumMap[v.Guid]
 		}
-		if _,b := extendExporterMap[v.AddressAgent];b {
-			extendExporterMap[v.AddressAgent] = append(extendExporterMap[v.AddressAgent], &tmpPingExporterSourceObj)
-		}else{
-			extendExporterMap[v.AddressAgent] = []*m.PingExportSourceObj{&tmpPingExporterSourceObj}
+		for _,address := range addressList {
+			extendExporterMap[address] = append(extendExporterMap[address], &tmpPingExporterSourceObj)
 		}
 	}
 	for k,v := range extendExporterMap {
@@ -0,0 +1,223 @@
+package db
+
+import (
+	"fmt"
+	"github.com/WeBankPartners/go-common-lib/guid"
+	"github.com/WeBankPartners/open-monitor/monitor-server/middleware/log"
+	m "github.com/WeBankPartners/open-monitor/monitor-server/models"
+	"strings"
+	"time"
+)
+
+func ProbeLocationList() (result []*m.ProbeLocationTable, err error) {
+	result = []*m.ProbeLocationTable{}
+	err = x.SQL("select * from probe_location order by guid").Find(&result)
+	if err != nil {
+		err = fmt.Errorf("Query probe location table fail,%s ", err.Error())
+		return
+	}
+	for _, row := range result {
+		row.CreateTime = row.CreateAt.Format(m.DatetimeFormat)
+		row.UpdateTime = row.UpdateAt.Format(m.DatetimeFormat)
+	}
+	return
+}
+
+func ProbeLocationCreate(input m.ProbeLocationTable, operator string) error {
+	if input.DisplayName == "" {
+		input.DisplayName = input.Guid
+	}
+	nowTime := time.Now()
+	_, err := x.Exec("insert into probe_location(guid,display_name,address,description,create_user,update_user,create_at,update_at) value (?,?,?,?,?,?,?,?)",
+		input.Guid, input.DisplayName, input.Address, input.Description, operator, operator, nowTime, nowTime)
+	if err != nil {
+		return fmt.Errorf("Insert database fail,%s ", err.Error())
+	}
+	return nil
+}
+
+// ProbeLocationUpdate 拨测点地址变了要重新生成用到它的拨测对象的服务发现
+func ProbeLocationUpdate(input m.ProbeLocationTable, operator string) error {
+	if input.DisplayName == "" {
+		input.DisplayName = input.Guid
+	}
+	_, err := x.Exec("update probe_location set display_name=?,address=?,description=?,update_user=?,update_at=? where guid=?",
+		input.DisplayName, input.Address, input.Description, operator, time.Now(), input.Guid)
+	if err != nil {
+		return fmt.Errorf("Update database fail,%s ", err.Error())
+	}
+	var endpointTables []*m.EndpointNewTable
+	err = x.SQL("select distinct step,cluster from endpoint_new where guid in (select endpoint_guid from endpoint_probe_location where concat(',',locations,',') like ?)", "%,"+input.Guid+",%").Find(&endpointTables)
+	if err != nil {
+		return fmt.Errorf("Query probe location endpoint fail,%s ", err.Error())
+	}
+	clusterStepMap := make(map[string][]int)
+	for _, v := range endpointTables {
+		clusterStepMap[v.Cluster] = append(clusterStepMap[v.Cluster], v.Step)
+	}
+	for cluster, stepList := range clusterStepMap {
+		if err = SyncSdEndpointNew(stepList, cluster, false); err != nil {
+			return fmt.Errorf("Sync sd config file fail,%s ", err.Error())
+		}
+	}
+	return nil
+}
+
+func ProbeLocationDelete(guid string) error {
+	var endpointLocationTables []*m.EndpointProbeLocationTable
+	err := x.SQL("select endpoint_guid from endpoint_probe_location where concat(',',locations,',') like ?", "%,"+guid+",%").Find(&endpointLocationTables)
+	if err != nil {
+		return fmt.Errorf("Query endpoint probe location table fail,%s ", err.Error())
+	}
+	if len(endpointLocationTables) > 0 {
+		var endpointList []string
+		for _, v := range endpointLocationTables {
+			endpointList = append(endpointList, v.EndpointGuid)
+		}
+		return fmt.Errorf("Probe location:%s is used by endpoint:%s ", guid, strings.Join(endpointList, ","))
+	}
+	_, err = x.Exec("delete from probe_location where guid=?", guid)
+	if err != nil {
+		return fmt.Errorf("Delete database fail,%s ", err.Error())
+	}
+	return nil
+}
+
+// CheckProbeLocation 校验拨测点都存在,返回按传入顺序排列的拨测点和生效的 quorum
+func CheckProbeLocation(locations []string, quorum int) (result []*m.ProbeLocationTable, validQuorum int, err error) {
+	locationMap, queryErr := getProbeLocationMap()
+	if queryErr != nil {
+		err = queryErr
+		return
+	}
+	existMap := make(map[string]bool)
+	for _, v := range locations {
+		if existMap[v] {
+			continue
+		}
+		locationObj, b := locationMap[v]
+		if !b {
+			err = fmt.Errorf("Probe location:%s not found ", v)
+			return
+		}
+		existMap[v] = true
+		result = append(result, locationObj)
+	}
+	validQuorum, err = m.BuildProbeQuorum(quorum, len(result))
+	return
+}
+
+// UpdateEndpointProbeLocation locations 为空时表示不再使用多拨测点
+func UpdateEndpointProbeLocation(endpointGuid string, locations []*m.ProbeLocationTable, quorum int) error {
+	var actions []*Action
+	actions = append(actions, &Action{Sql: "DELETE FROM endpoint_probe_location WHERE endpoint_guid=?", Param: []interface{}{endpointGuid}})
+	if len(locations) > 0 {
+		var locationList []string
+		for _, v := range locations {
+			locationList = append(locationList, v.Guid)
+		}
+		actions = append(actions, &Action{Sql: "INSERT INTO endpoint_probe_location(`endpoint_guid`,`locations`,`quorum`) VALUE (?,?,?)", Param: []interface{}{endpointGuid, strings.Join(locationList, ","), quorum}})
+	}
+	err := Transaction(actions)
+	if err != nil {
+		log.Logger.Error("Update endpoint probe location fail", log.Error(err))
+	}
+	return err
+}
+
+// MoveEndpointProbeLocationGroup 开启或取消多拨测点时,把在原默认组里的对象移到 default_X_location_group 或 default_X_group,
+// 返回有变化的组,用户自己配置的组不处理
+func MoveEndpointProbeLocationGroup(endpointGuid, endpointType string, useLocation bool, operator string) (changeGroupList []string, err error) {
+	fromGroup, toGroup := fmt.Sprintf("default_%s_group", endpointType), fmt.Sprintf("default_%s_location_group", endpointType)
+	if !useLocation {
+		fromGroup, toGroup = toGroup, fromGroup
+	}
+	var relRows []*m.EndpointGroupRelTable
+	err = x.SQL("select endpoint_group from endpoint_group_rel where endpoint=? and endpoint_group in (?,?)", endpointGuid, fromGroup, toGroup).Find(&relRows)
+	if err != nil {
+		err = fmt.Errorf("Query endpoint group rel table fail,%s ", err.Error())
+		return
+	}
+	fromExist, toExist := false, false
+	for _, v := range relRows {
+		if v.EndpointGroup == fromGroup {
+			fromExist = true
+		} else {
+			toExist = true
+		}
+	}
+	if !fromExist {
+		return
+	}
+	if _, err = GetSimpleEndpointGroup(toGroup); err != nil {
+		return
+	}
+	var actions []*Action
+	nowTime := time.Now().Format(m.DatetimeFormat)
+	actions = append(actions, &Action{Sql: "delete from endpoint_group_rel where endpoint=? and endpoint_group=?", Param: []interface{}{endpointGuid, fromGroup}})
+	if !toExist {
+		actions = append(actions, &Action{Sql: "insert into endpoint_group_rel(guid,endpoint,endpoint_group) value (?,?,?)", Param: []interface{}{guid.CreateGuid(), endpointGuid, toGroup}})
+	}
+	for _, v := range []string{fromGroup, toGroup} {
+		actions = append(actions, &Action{Sql: "update endpoint_group set update_time=?,update_user=? where guid=?", Param: []interface{}{nowTime, operator, v}})
+	}
+	if err = Transaction(actions); err != nil {
+		log.Logger.Error("Move endpoint probe location group fail", log.String("endpoint", endpointGuid), log.Error(err))
+		return
+	}
+	changeGroupList = []string{fromGroup, toGroup}
+	return
+}
+
+func GetEndpointProbeLocation(endpointGuid string) (result *m.EndpointProbeLocationTable, err error) {
+	var queryRows []*m.EndpointProbeLocationTable
+	err = x.SQL("SELECT endpoint_guid,locations,quorum FROM endpoint_probe_location WHERE endpoint_guid=?", endpointGuid).Find(&queryRows)
+	if err != nil {
+		err = fmt.Errorf("Query endpoint probe location table fail,%s ", err.Error())
+		return
+	}
+	if len(queryRows) > 0 {
+		result = queryRows[0]
+	}
+	return
+}
+
+func getProbeLocationMap() (result map[string]*m.ProbeLocationTable, err error) {
+	result = make(map[string]*m.ProbeLocationTable)
+	var locationTables []*m.ProbeLocationTable
+	err = x.SQL("select guid,display_name,address from probe_location").Find(&locationTables)
+	if err != nil {
+		err = fmt.Errorf("Query probe location table fail,%s ", err.Error())
+		return
+	}
+	for _, v := range locationTables {
+		result[v.Guid] = v
+	}
+	return
+}
+
+// getEndpointProbeLocationMap 返回 endpoint guid -> 拨测点列表,拨测点不存在的直接忽略
+func getEndpointProbeLocationMap() (result map[string][]*m.ProbeLocationTable, quorumMap map[string]int, err error) {
+	result = make(map[string][]*m.ProbeLocationTable)
+	quorumMap = make(map[string]int)
+	locationMap, queryErr := getProbeLocationMap()
+	if queryErr != nil {
+		err = queryErr
+		return
+	}
+	var endpointLocationTables []*m.EndpointProbeLocationTable
+	err = x.SQL("select endpoint_guid,locations,quorum from endpoint_probe_location").Find(&endpointLocationTables)
+	if err != nil {
+		err = fmt.Errorf("Query endpoint probe location table fail,%s ", err.Error())
+		return
+	}
+	for _, v := range endpointLocationTables {
+		for _, location := range v.LocationList() {
+			if locationObj, b := locationMap[location]; b {
+				result[v.EndpointGuid] = append(result[v.EndpointGuid], locationObj)
+			}
+		}
+		quorumMap[v.EndpointGuid] = v.Quorum
+	}
+	return
+}
@@ -137,12 +137,10 @@ func buildRuleFileContent(ruleFileName,guidExpr,addressExpr,ipExpr string,strate
 	for _,strategy := range strategyList {
 		tmpRfu := models.RFRule{}
 		tmpRfu.Alert = fmt.Sprintf("%s_%d", strategy.Metric, strategy.Id)
-		if !strings.Contains(strategy.Cond, " ") && strategy.Cond != "" {
-			if strings.Contains(strategy.Cond, "=") {
-				strategy.Cond = strategy.Cond[:2] + " " + strategy.Cond[2:]
-			}else{
-				strategy.Cond = strategy.Cond[:1] + " " + strategy.Cond[1:]
-			}
+		conditionObj,parseErr := models.ParseAlarmCondition(strategy.Cond)
+		if parseErr != nil {
+			log.Logger.Warn("strategy condition illegal", log.String("alertId", tmpRfu.Alert), log.Error(parseErr))
+			continue
 		}
 		if strings.Contains(strategy.Expr, "$address") {
 			if strings.Contains(addressExpr, "|") {
@@ -171,7 +169,7 @@ func buildRuleFileContent(ruleFileName,guidExpr,addressExpr,ipExpr string,strate
 				strategy.Expr = strings.ReplaceAll(strategy.Expr, "$ip", ipExpr)
 			}
 		}
-		tmpRfu.Expr = fmt.Sprintf("%s %s", strategy.Expr, strategy.Cond)
+		tmpRfu.Expr = conditionObj.BuildRuleExpr(strategy.Expr, strategy.Last)
 		tmpRfu.For = strategy.Last
 		tmpRfu.Labels = make(map[string]string)
 		tmpRfu.Labels["strategy_id"] = fmt.Sprintf("%d", strategy.Id)
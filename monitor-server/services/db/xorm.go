@@ -42,7 +42,7 @@ func InitDatabase() error {
 	log.Logger.Info("Success init database connect !!")
 	tmpEnable := strings.ToLower(models.Config().ArchiveMysql.Enable)
 	if tmpEnable == "y" || tmpEnable == "yes" || tmpEnable == "true" {
-		initArchiveDbEngine()
+		initArchiveStorage()
 	} else {
 		ArchiveEnable = false
 	}
@@ -1257,4 +1257,161 @@ alter table log_metric_group add column auto_dashboard tinyint(1) default 0 COMM
 alter table service_group add index service_group_update_time(update_time);
 alter table custom_chart_permission add index custom_chart_permission_role(role_id);
 alter table custom_chart add index dashboard_chart_public(public);
-#@v3.3.2-end@;
\ No newline at end of file
+#@v3.3.2-end@;
+#@v3.3.3-begin@;
+CREATE TABLE `notify_template` (
+  `guid` varchar(64) NOT NULL PRIMARY KEY,
+  `name` varchar(128) NOT NULL COMMENT '模版名称',
+  `alarm_strategy` varchar(64) default null COMMENT '告警策略',
+  `endpoint_group` varchar(64) default null COMMENT '对象组',
+  `service_group` varchar(64) default null COMMENT '层级对象',
+  `alarm_action` varchar(32) default 'all' COMMENT 'firing/ok/all',
+  `channel` varchar(32) default 'mail' COMMENT '通知渠道 mail/event/webhook',
+  `content_type` varchar(32) default 'text' COMMENT '内容格式 text/html',
+  `subject` varchar(512) default null COMMENT '标题模版',
+  `content` text default null COMMENT '内容模版',
+  `is_default` tinyint(1) default 0 COMMENT '全局默认模版,1表示默认',
+  `create_user` varchar(64) default null COMMENT '创建人',
+  `update_user` varchar(64) default null COMMENT '更新人',
+  `create_time` datetime default null COMMENT '创建时间',
+  `update_time` datetime default null COMMENT '更新时间',
+  KEY `notify_template_strategy` (`alarm_strategy`),
+  KEY `notify_template_endpoint_group` (`endpoint_group`),
+  KEY `notify_template_service_group` (`service_group`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+alter table notify add column notify_interval int default 0 COMMENT '重复通知间隔(分钟),0表示不重复';
+alter table notify add column escalate_delay int default 0 COMMENT '告警持续多久(分钟)未处理后升级,0表示不升级';
+alter table notify add column escalate_roles varchar(512) default null COMMENT '升级通知角色,逗号分隔';
+CREATE TABLE `alarm_notify_escalate` (
+  `alarm_id` int(11) NOT NULL PRIMARY KEY,
+  `notify` varchar(64) NOT NULL COMMENT '通知配置',
+  `notify_count` int default 1 COMMENT '已通知次数',
+  `escalated` tinyint(1) default 0 COMMENT '是否已升级,1表示已升级',
+  `next_notify_time` datetime default null COMMENT '下次重复通知时间',
+  `escalate_time` datetime default null COMMENT '升级时间',
+  `status` varchar(32) default 'active' COMMENT 'active/done',
+  `create_time` datetime default null COMMENT '创建时间',
+  `update_time` datetime default null COMMENT '更新时间',
+  KEY `alarm_notify_escalate_status` (`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE TABLE `alarm_notify_escalate_log` (
+  `id` int(11) NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  `alarm_id` int(11) NOT NULL COMMENT '告警id',
+  `notify` varchar(64) default null COMMENT '通知配置',
+  `step` int default 0 COMMENT '第几次通知',
+  `action` varchar(32) default null COMMENT 'repeat/escalate',
+  `roles` varchar(512) default null COMMENT '通知角色',
+  `result` varchar(32) default null COMMENT 'success/fail',
+  `message` text default null COMMENT '失败信息',
+  `create_time` datetime default null COMMENT '创建时间',
+  KEY `alarm_notify_escalate_log_alarm` (`alarm_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+alter table alarm add column ack_user varchar(64) default null COMMENT '确认人';
+alter table alarm add column ack_time datetime default null COMMENT '确认时间';
+alter table alarm add column ack_msg varchar(512) default null COMMENT '确认备注';
+CREATE TABLE `alarm_ack_log` (
+  `id` int(11) NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  `alarm_id` int(11) NOT NULL COMMENT '告警id',
+  `action` varchar(32) NOT NULL COMMENT 'ack/unack',
+  `operator` varchar(64) default null COMMENT '操作人',
+  `message` varchar(512) default null COMMENT '备注',
+  `create_time` datetime default null COMMENT '操作时间',
+  KEY `alarm_ack_log_alarm` (`alarm_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE TABLE `alarm_silence` (
+  `guid` varchar(64) NOT NULL PRIMARY KEY,
+  `name` varchar(128) NOT NULL COMMENT '名称',
+  `reason` varchar(512) default null COMMENT '屏蔽原因',
+  `scope_type` varchar(32) default 'tag' COMMENT 'endpoint/endpoint_group/service_group/alarm_strategy/tag',
+  `scope_value` varchar(128) default null COMMENT '屏蔽对象',
+  `tag_matchers` text default null COMMENT '标签匹配,json数组',
+  `start_time` datetime NOT NULL COMMENT '生效开始时间',
+  `end_time` datetime NOT NULL COMMENT '生效结束时间',
+  `cron_expr` varchar(64) default null COMMENT '周期屏蔽的cron表达式,为空表示一次性屏蔽',
+  `duration` int default 0 COMMENT '周期屏蔽每次持续时间(分钟)',
+  `status` varchar(32) default 'active' COMMENT 'active/expired',
+  `create_user` varchar(64) default null COMMENT '创建人',
+  `update_user` varchar(64) default null COMMENT '更新人',
+  `create_time` datetime default null COMMENT '创建时间',
+  `update_time` datetime default null COMMENT '更新时间',
+  KEY `alarm_silence_status` (`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+alter table alarm add column silence varchar(64) default null COMMENT '命中的屏蔽规则,不为空表示告警被屏蔽';
+CREATE TABLE `alarm_incident` (
+  `id` int(11) NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  `group_key` varchar(255) NOT NULL COMMENT '聚合键',
+  `group_label` varchar(512) default null COMMENT '聚合键展示',
+  `status` varchar(32) default 'firing' COMMENT 'firing/ok/closed',
+  `alarm_count` int default 0 COMMENT '告警数量',
+  `notify` varchar(64) default null COMMENT '通知配置',
+  `start` datetime default null COMMENT '开始时间',
+  `end` datetime default null COMMENT '结束时间',
+  `close_user` varchar(64) default null COMMENT '关闭人',
+  `update_time` datetime default null COMMENT '更新时间',
+  KEY `alarm_incident_group_key` (`group_key`,`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE TABLE `alarm_incident_rel` (
+  `id` int(11) NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  `incident` int(11) NOT NULL COMMENT '事件id',
+  `alarm_id` int(11) NOT NULL COMMENT '告警id',
+  `create_time` datetime default null COMMENT '加入时间',
+  UNIQUE KEY `alarm_incident_rel_alarm` (`alarm_id`),
+  KEY `alarm_incident_rel_incident` (`incident`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE TABLE `alarm_inhibit_rule` (
+  `guid` varchar(64) NOT NULL PRIMARY KEY,
+  `name` varchar(128) NOT NULL COMMENT '名称',
+  `source_matchers` text default null COMMENT '源告警匹配条件,json',
+  `target_matchers` text default null COMMENT '目标告警匹配条件,json',
+  `equal_labels` varchar(512) default null COMMENT '源告警和目标告警必须相等的标签,逗号分隔',
+  `enable` tinyint default 1 COMMENT '是否启用',
+  `create_user` varchar(64) default null COMMENT '创建人',
+  `update_user` varchar(64) default null COMMENT '更新人',
+  `create_time` datetime default null COMMENT '创建时间',
+  `update_time` datetime default null COMMENT '更新时间'
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+alter table alarm add column inhibit_by int default 0 COMMENT '抑制该告警的源告警id,大于0表示告警被抑制';
+alter table log_metric_config add column buckets varchar(512) default null COMMENT '直方图的桶,逗号分隔';
+alter table log_metric_monitor add column multiline varchar(1024) default null COMMENT '多行日志合并配置,json';
+alter table log_keyword_monitor add column multiline varchar(1024) default null COMMENT '多行日志合并配置,json';
+alter table endpoint_http add column check_config text default null COMMENT 'http拨测请求和断言配置';
+INSERT INTO metric (guid,metric,monitor_type,prom_expr,tag_owner,update_time,service_group,workspace) VALUES ('http_assert__http','http_assert','http','http_assert{guid="$guid",e_guid="$guid"}','',NULL,NULL,'any_object'),('http_time__http','http_time','http','http_time{guid="$guid",e_guid="$guid",phase="total"}','',NULL,NULL,'any_object'),('http_cert_expire_days__http','http_cert_expire_days','http','http_cert_expire_days{guid="$guid",e_guid="$guid"}','',NULL,NULL,'any_object');
+CREATE TABLE `endpoint_probe` (
+  `id` int(11) NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  `endpoint_guid` varchar(255) NOT NULL COMMENT '对象guid',
+  `probe_type` varchar(32) NOT NULL COMMENT 'tcp/tls/dns',
+  `target` varchar(255) default null COMMENT '拨测目标ip:port,dns为dns服务器地址',
+  `config` text default null COMMENT '拨测配置,json',
+  `update_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  KEY `endpoint_probe_endpoint` (`endpoint_guid`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+insert into monitor_type(guid,display_name,system_type) value ('tcp','tcp',1),('tls','tls',1),('dns','dns',1);
+insert into endpoint_group(guid,display_name,description,monitor_type) value ('default_tcp_group','default_tcp_group','','tcp'),('default_tls_group','default_tls_group','','tls'),('default_dns_group','default_dns_group','','dns');
+INSERT INTO metric (guid,metric,monitor_type,prom_expr,tag_owner,update_time,service_group,workspace) VALUES ('tcp_alive__tcp','tcp_alive','tcp','tcp_alive{guid="$guid",e_guid="$guid"}','',NULL,NULL,'any_object'),('tcp_time__tcp','tcp_time','tcp','tcp_time{guid="$guid",e_guid="$guid"}','',NULL,NULL,'any_object'),('tls_alive__tls','tls_alive','tls','tls_alive{guid="$guid",e_guid="$guid"}','',NULL,NULL,'any_object'),('tls_time__tls','tls_time','tls','tls_time{guid="$guid",e_guid="$guid"}','',NULL,NULL,'any_object'),('tls_verify__tls','tls_verify','tls','tls_verify{guid="$guid",e_guid="$guid"}','',NULL,NULL,'any_object'),('tls_cert_expire_days__tls','tls_cert_expire_days','tls','tls_cert_expire_days{guid="$guid",e_guid="$guid"}','',NULL,NULL,'any_object'),('dns_alive__dns','dns_alive','dns','dns_alive{guid="$guid",e_guid="$guid"}','',NULL,NULL,'any_object'),('dns_time__dns','dns_time','dns','dns_time{guid="$guid",e_guid="$guid"}','',NULL,NULL,'any_object');
+CREATE TABLE `probe_location` (
+  `guid` varchar(64) NOT NULL PRIMARY KEY,
+  `display_name` varchar(255) default null COMMENT '拨测点名称',
+  `address` varchar(64) NOT NULL COMMENT '拨测点ping_exporter地址ip:port',
+  `description` varchar(255) default null,
+  `create_user` varchar(64) default null,
+  `update_user` varchar(64) default null,
+  `create_at` datetime default null,
+  `update_at` datetime default null
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE TABLE `endpoint_probe_location` (
+  `endpoint_guid` varchar(255) NOT NULL PRIMARY KEY COMMENT '对象guid',
+  `locations` varchar(1024) NOT NULL COMMENT '拨测点guid,逗号分隔',
+  `quorum` int(11) NOT NULL default 1 COMMENT '失败的拨测点数达到该值才告警'
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+insert into endpoint_group(guid,display_name,description,monitor_type) value ('default_ping_location_group','default_ping_location_group','','ping'),('default_telnet_location_group','default_telnet_location_group','','telnet'),('default_http_location_group','default_http_location_group','','http'),('default_tcp_location_group','default_tcp_location_group','','tcp'),('default_tls_location_group','default_tls_location_group','','tls'),('default_dns_location_group','default_dns_location_group','','dns');
+INSERT INTO metric (guid,metric,monitor_type,prom_expr,tag_owner,update_time,service_group,workspace) VALUES ('ping_location_fail__ping','ping_location_fail','ping','count by (e_guid) (ping_alive{guid="$guid",e_guid="$guid"} > 0) >= on(e_guid) max by (e_guid) (probe_location_quorum{guid="$guid",e_guid="$guid"})','',NULL,NULL,'any_object'),('telnet_location_fail__telnet','telnet_location_fail','telnet','count by (e_guid) (telnet_alive{guid="$guid",e_guid="$guid"} > 0) >= on(e_guid) max by (e_guid) (probe_location_quorum{guid="$guid",e_guid="$guid"})','',NULL,NULL,'any_object'),('http_location_fail__http','http_location_fail','http','count by (e_guid) (http_assert{guid="$guid",e_guid="$guid"} > 0) >= on(e_guid) max by (e_guid) (probe_location_quorum{guid="$guid",e_guid="$guid"})','',NULL,NULL,'any_object'),('tcp_location_fail__tcp','tcp_location_fail','tcp','count by (e_guid) (tcp_alive{guid="$guid",e_guid="$guid"} > 0) >= on(e_guid) max by (e_guid) (probe_location_quorum{guid="$guid",e_guid="$guid"})','',NULL,NULL,'any_object'),('tls_location_fail__tls','tls_location_fail','tls','count by (e_guid) (tls_alive{guid="$guid",e_guid="$guid"} > 0) >= on(e_guid) max by (e_guid) (probe_location_quorum{guid="$guid",e_guid="$guid"})','',NULL,NULL,'any_object'),('dns_location_fail__dns','dns_location_fail','dns','count by (e_guid) (dns_alive{guid="$guid",e_guid="$guid"} > 0) >= on(e_guid) max by (e_guid) (probe_location_quorum{guid="$guid",e_guid="$guid"})','',NULL,NULL,'any_object');
+INSERT INTO alarm_strategy (guid,endpoint_group,metric,`condition`,`last`,priority,content,notify_enable,notify_delay_second,update_time,active_window) VALUES ('new_ping_location_fail','default_ping_location_group','ping_location_fail__ping','>0','60s','high','ping check fail on quorum probe locations',1,0,NOW(),'00:00-23:59'),('new_telnet_location_fail','default_telnet_location_group','telnet_location_fail__telnet','>0','60s','high','telnet check fail on quorum probe locations',1,0,NOW(),'00:00-23:59'),('new_http_location_fail','default_http_location_group','http_location_fail__http','>0','60s','high','http check fail on quorum probe locations',1,0,NOW(),'00:00-23:59'),('new_tcp_location_fail','default_tcp_location_group','tcp_location_fail__tcp','>0','60s','high','tcp check fail on quorum probe locations',1,0,NOW(),'00:00-23:59'),('new_tls_location_fail','default_tls_location_group','tls_location_fail__tls','>0','60s','high','tls check fail on quorum probe locations',1,0,NOW(),'00:00-23:59'),('new_dns_location_fail','default_dns_location_group','dns_location_fail__dns','>0','60s','high','dns check fail on quorum probe locations',1,0,NOW(),'00:00-23:59');
+alter table db_metric_monitor add column db_type varchar(32) default 'mysql' COMMENT '数据库类型';
+alter table db_metric_monitor add column db_name varchar(128) default null COMMENT '数据库名';
+alter table db_keyword_monitor add column db_type varchar(32) default 'mysql' COMMENT '数据库类型';
+alter table db_keyword_monitor add column db_name varchar(128) default null COMMENT '数据库名';
+alter table db_metric_monitor add column label_columns varchar(255) default null COMMENT '作为标签的列,逗号分隔';
+alter table db_metric_monitor add column value_columns varchar(255) default null COMMENT '作为取值的列,逗号分隔';
+alter table db_metric_monitor add column max_series int default 0 COMMENT '最大序列数,0用采集端默认值';
+alter table log_metric_config add column quantile double default null COMMENT '直方图展示的分位数,为空时0.95';
+#@v3.3.3-end@;
\ No newline at end of file